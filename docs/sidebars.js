@@ -47,6 +47,7 @@ const sidebars = {
             'configuration/providers/nextcloud',
             'configuration/providers/digitalocean',
             'configuration/providers/bitbucket',
+            'configuration/providers/generic_oauth2',
           ],
         },
         'configuration/session_storage',
@@ -25,7 +25,9 @@ import (
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/redirect"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/assertion"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/basic"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/stepup"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 	proxyhttp "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/http"
@@ -37,6 +39,7 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/middleware"
 	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/binding"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/upstream"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
 )
@@ -52,7 +55,11 @@ const (
 	oauthStartPath    = "/start"
 	oauthCallbackPath = "/callback"
 	authOnlyPath      = "/auth"
+	extAuthzPath      = "/ext_authz"
+	forwardAuthPath   = "/forward_auth"
 	userInfoPath      = "/userinfo"
+	jwksPath          = "/jwks.json"
+	refreshPath       = "/refresh"
 	staticPathPrefix  = "/static/"
 )
 
@@ -63,6 +70,10 @@ var (
 	// ErrAccessDenied means the user should receive a 401 Unauthorized response
 	ErrAccessDenied = errors.New("access denied")
 
+	// ErrNeedsStepUp means the user is authenticated, but has to authenticate
+	// again to satisfy the step-up requirements of the request
+	ErrNeedsStepUp = errors.New("step-up authentication required")
+
 	//go:embed static/*
 	staticFiles embed.FS
 )
@@ -102,9 +113,17 @@ type OAuthProxy struct {
 	allowQuerySemicolons bool
 	realClientIPParser   ipapi.RealClientIPParser
 	trustedIPs           *ip.NetSet
+	sessionBinder        *binding.Binder
+	stepUpPolicy         *stepup.Policy
+	assertionSigner      *assertion.Signer
+
+	userInfoFields        []string
+	userInfoIncludeTokens bool
 
 	sessionChain      alice.Chain
 	headersChain      alice.Chain
+	assertionChain    alice.Chain
+	authzHeadersChain alice.Chain
 	preAuthChain      alice.Chain
 	pageWriter        pagewriter.Writer
 	server            proxyhttp.Server
@@ -157,6 +176,18 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 	if err != nil {
 		return nil, fmt.Errorf("error initialising upstream proxy: %v", err)
 	}
+	var assertionSigner *assertion.Signer
+	if opts.IdentityAssertion != nil {
+		assertionSigner, err = assertion.NewSigner(opts.IdentityAssertion)
+		if err != nil {
+			return nil, fmt.Errorf("error initialising identity assertion signer: %v", err)
+		}
+	}
+	upstreamChain, err := buildUpstreamChain(opts, provider, assertionSigner, pageWriter)
+	if err != nil {
+		return nil, fmt.Errorf("error initialising upstream chain: %v", err)
+	}
+	upstreamProxy = upstreamChain.Then(upstreamProxy)
 
 	if opts.SkipJwtBearerTokens {
 		logger.Printf("Skipping JWT tokens from configured OIDC issuer: %q", opts.Providers[0].OIDCConfig.IssuerURL)
@@ -175,7 +206,12 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		refresh = fmt.Sprintf("after %s", opts.Cookie.Refresh)
 	}
 
-	logger.Printf("Cookie settings: name:%s secure(https):%v httponly:%v expiry:%s domains:%s path:%s samesite:%s refresh:%s", opts.Cookie.Name, opts.Cookie.Secure, opts.Cookie.HTTPOnly, opts.Cookie.Expire, strings.Join(opts.Cookie.Domains, ","), opts.Cookie.Path, opts.Cookie.SameSite, refresh)
+	secretRotation := "disabled"
+	if len(opts.Cookie.PreviousSecrets) > 0 {
+		secretRotation = fmt.Sprintf("accepting %d previous secret(s)", len(opts.Cookie.PreviousSecrets))
+	}
+
+	logger.Printf("Cookie settings: name:%s secure(https):%v httponly:%v expiry:%s domains:%s path:%s samesite:%s refresh:%s secret rotation:%s", opts.Cookie.Name, opts.Cookie.Secure, opts.Cookie.HTTPOnly, opts.Cookie.Expire, strings.Join(opts.Cookie.Domains, ","), opts.Cookie.Path, opts.Cookie.SameSite, refresh, secretRotation)
 
 	trustedIPs := ip.NewNetSet()
 	for _, ipStr := range opts.TrustedIPs {
@@ -196,15 +232,29 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		return nil, err
 	}
 
-	preAuthChain, err := buildPreAuthChain(opts, sessionStore)
+	stepUpPolicy, err := stepup.NewPolicy(opts.StepUpRoutes, opts.UpstreamServers)
+	if err != nil {
+		return nil, fmt.Errorf("could not build step-up policy: %v", err)
+	}
+
+	preAuthChain, err := buildPreAuthChain(opts, sessionStore, provider.Data())
 	if err != nil {
 		return nil, fmt.Errorf("could not build pre-auth chain: %v", err)
 	}
-	sessionChain := buildSessionChain(opts, provider, sessionStore, basicAuthValidator)
+	sessionBinder := binding.NewBinder(opts.Session.Binding, opts.GetRealClientIPParser())
+	sessionChain := buildSessionChain(opts, provider, sessionStore, sessionBinder, basicAuthValidator)
 	headersChain, err := buildHeadersChain(opts)
 	if err != nil {
 		return nil, fmt.Errorf("could not build headers chain: %v", err)
 	}
+	authzHeadersChain, err := buildAuthzHeadersChain(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not build authorization headers chain: %v", err)
+	}
+	assertionChain, err := buildResponseAssertionChain(opts, assertionSigner, pageWriter)
+	if err != nil {
+		return nil, fmt.Errorf("could not build identity assertion chain: %v", err)
+	}
 
 	redirectValidator := redirect.NewValidator(opts.WhitelistDomains)
 	appDirector := redirect.NewAppDirector(redirect.AppDirectorOpts{
@@ -229,15 +279,23 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		skipAuthPreflight:    opts.SkipAuthPreflight,
 		skipJwtBearerTokens:  opts.SkipJwtBearerTokens,
 		realClientIPParser:   opts.GetRealClientIPParser(),
+		sessionBinder:        sessionBinder,
+		stepUpPolicy:         stepUpPolicy,
+		assertionSigner:      assertionSigner,
 		SkipProviderButton:   opts.SkipProviderButton,
 		forceJSONErrors:      opts.ForceJSONErrors,
 		allowQuerySemicolons: opts.AllowQuerySemicolons,
 		trustedIPs:           trustedIPs,
 
+		userInfoFields:        opts.UserInfoFields,
+		userInfoIncludeTokens: opts.UserInfoIncludeTokens,
+
 		basicAuthValidator: basicAuthValidator,
 		basicAuthGroups:    opts.HtpasswdUserGroups,
 		sessionChain:       sessionChain,
 		headersChain:       headersChain,
+		assertionChain:     assertionChain,
+		authzHeadersChain:  authzHeadersChain.Extend(assertionChain),
 		preAuthChain:       preAuthChain,
 		pageWriter:         pageWriter,
 		upstreamProxy:      upstreamProxy,
@@ -312,6 +370,8 @@ func (p *OAuthProxy) buildServeMux(proxyPrefix string) {
 	r := mux.NewRouter().UseEncodedPath()
 	// Everything served by the router must go through the preAuthChain first.
 	r.Use(p.preAuthChain.Then)
+	// Nothing else can be served before the provider is ready.
+	r.Use(p.requireProviderReady)
 
 	// Register the robots path writer
 	r.Path(robotsPath).HandlerFunc(p.pageWriter.WriteRobotsTxt)
@@ -321,6 +381,17 @@ func (p *OAuthProxy) buildServeMux(proxyPrefix string) {
 	// likelihood of multiple requests trying to refresh sessions simultaneously.
 	r.Path(proxyPrefix + authOnlyPath).Handler(p.sessionChain.ThenFunc(p.AuthOnly))
 
+	// The ext_authz path receives the original request path appended to it by
+	// Envoy, so everything below it must be routed to the same handler.
+	r.Path(proxyPrefix + extAuthzPath).Handler(p.sessionChain.ThenFunc(p.ExtAuthz))
+	r.PathPrefix(proxyPrefix + extAuthzPath + "/").Handler(p.sessionChain.ThenFunc(p.ExtAuthz))
+	r.Path(proxyPrefix + forwardAuthPath).Handler(p.sessionChain.ThenFunc(p.ForwardAuth))
+
+	// The JWKS is registered separately so that upstreams may cache the keys.
+	if p.assertionSigner != nil {
+		r.Path(proxyPrefix + jwksPath).HandlerFunc(p.JWKS)
+	}
+
 	// This will register all of the paths under the proxy prefix, except the auth only path so that no cache headers
 	// are not applied.
 	p.buildProxySubrouter(r.PathPrefix(proxyPrefix).Subrouter())
@@ -341,15 +412,29 @@ func (p *OAuthProxy) buildProxySubrouter(s *mux.Router) {
 	// Static file paths
 	s.PathPrefix(staticPathPrefix).Handler(http.StripPrefix(p.ProxyPrefix, http.FileServer(http.FS(staticFiles))))
 
-	// The userinfo and logout endpoints needs to load sessions before handling the request
+	// The userinfo, refresh and logout endpoints need to load sessions before handling the request
 	s.Path(userInfoPath).Handler(p.sessionChain.ThenFunc(p.UserInfo))
+	s.Path(refreshPath).Handler(alice.New(forceSessionRefresh).Extend(p.sessionChain).ThenFunc(p.Refresh))
 	s.Path(signOutPath).Handler(p.sessionChain.ThenFunc(p.SignOut))
 }
 
+// requireProviderReady responds with a 503 Service Unavailable until the
+// provider is ready, which it is not until its lazy OIDC discovery succeeds.
+func (p *OAuthProxy) requireProviderReady(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if err := p.provider.Data().VerifyConnection(req.Context()); err != nil {
+			logger.Errorf("Error serving request, the provider is not ready: %v", err)
+			p.ErrorPage(rw, req, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
 // buildPreAuthChain constructs a chain that should process every request before
 // the OAuth2 Proxy authentication logic kicks in.
 // For example forcing HTTPS or health checks.
-func buildPreAuthChain(opts *options.Options, sessionStore sessionsapi.SessionStore) (alice.Chain, error) {
+func buildPreAuthChain(opts *options.Options, sessionStore sessionsapi.SessionStore, provider middleware.Verifiable) (alice.Chain, error) {
 	chain := alice.New(middleware.NewScope(opts.ReverseProxy, opts.Logging.RequestIDHeader))
 
 	if opts.ForceHTTPS {
@@ -373,23 +458,35 @@ func buildPreAuthChain(opts *options.Options, sessionStore sessionsapi.SessionSt
 	if opts.Logging.SilencePing {
 		chain = chain.Append(
 			middleware.NewHealthCheck(healthCheckPaths, healthCheckUserAgents),
-			middleware.NewReadynessCheck(opts.ReadyPath, sessionStore),
+			middleware.NewReadynessCheck(opts.ReadyPath, sessionStore, provider),
 			middleware.NewRequestLogger(),
 		)
 	} else {
 		chain = chain.Append(
 			middleware.NewRequestLogger(),
 			middleware.NewHealthCheck(healthCheckPaths, healthCheckUserAgents),
-			middleware.NewReadynessCheck(opts.ReadyPath, sessionStore),
+			middleware.NewReadynessCheck(opts.ReadyPath, sessionStore, provider),
 		)
 	}
 
 	chain = chain.Append(middleware.NewRequestMetricsWithDefaultRegistry())
 
+	// CORS preflight requests are answered before any authentication
+	matcher, err := upstream.NewMatcher(opts.UpstreamServers)
+	if err != nil {
+		return alice.Chain{}, err
+	}
+	chain = chain.Append(middleware.NewCORS(&middleware.CORSOptions{
+		Global:        opts.CORS,
+		Upstreams:     opts.UpstreamServers,
+		ProxyPrefix:   opts.ProxyPrefix,
+		MatchUpstream: matcher.Match,
+	}))
+
 	return chain, nil
 }
 
-func buildSessionChain(opts *options.Options, provider providers.Provider, sessionStore sessionsapi.SessionStore, validator basic.Validator) alice.Chain {
+func buildSessionChain(opts *options.Options, provider providers.Provider, sessionStore sessionsapi.SessionStore, sessionBinder *binding.Binder, validator basic.Validator) alice.Chain {
 	chain := alice.New()
 
 	if opts.SkipJwtBearerTokens {
@@ -410,10 +507,16 @@ func buildSessionChain(opts *options.Options, provider providers.Provider, sessi
 	}
 
 	chain = chain.Append(middleware.NewStoredSessionLoader(&middleware.StoredSessionLoaderOptions{
-		SessionStore:    sessionStore,
-		RefreshPeriod:   opts.Cookie.Refresh,
-		RefreshSession:  provider.RefreshSession,
-		ValidateSession: provider.ValidateSession,
+		SessionStore:     sessionStore,
+		RefreshPeriod:    opts.Cookie.Refresh,
+		IdleTimeout:      opts.Session.IdleTimeout,
+		LastSeenInterval: opts.Session.LastSeenInterval,
+		MaxLifetime:      opts.Session.MaxLifetime,
+		SessionBinder:    sessionBinder,
+		RefreshSession:   provider.RefreshSession,
+		ValidateSession:  provider.ValidateSession,
+
+		ClearMismatchedSessions: opts.Session.Binding.Action == options.SessionBindingReauthenticate,
 	}))
 
 	return chain
@@ -433,6 +536,72 @@ func buildHeadersChain(opts *options.Options) (alice.Chain, error) {
 	return alice.New(requestInjector, responseInjector), nil
 }
 
+// buildUpstreamChain constructs a chain of the middlewares that depend on the
+// upstream serving the request. It wraps the upstream proxy, after the
+// headers chain has injected the request headers.
+func buildUpstreamChain(opts *options.Options, provider providers.Provider, assertionSigner *assertion.Signer, pageWriter pagewriter.Writer) (alice.Chain, error) {
+	matcher, err := upstream.NewMatcher(opts.UpstreamServers)
+	if err != nil {
+		return alice.Chain{}, err
+	}
+
+	chain := alice.New(middleware.NewTokenExchange(&middleware.TokenExchangeOptions{
+		Upstreams:     opts.UpstreamServers,
+		MatchUpstream: matcher.Match,
+		ExchangeToken: provider.Data().ExchangeToken,
+		ErrorHandler:  pageWriter.ProxyErrorHandler,
+	}))
+
+	if assertionSigner != nil {
+		chain = chain.Append(middleware.NewIdentityAssertion(&middleware.IdentityAssertionOptions{
+			Header:        assertionSigner.Header(),
+			MatchUpstream: matcher.Match,
+			Sign:          assertionSigner.Sign,
+			ErrorHandler:  pageWriter.ProxyErrorHandler,
+		}))
+	}
+	return chain, nil
+}
+
+// buildResponseAssertionChain constructs a chain that sets the identity
+// assertion for the upstream of the original request on the response of the
+// external authorization endpoints. The chain is empty when no identity
+// assertion is configured.
+func buildResponseAssertionChain(opts *options.Options, assertionSigner *assertion.Signer, pageWriter pagewriter.Writer) (alice.Chain, error) {
+	if assertionSigner == nil {
+		return alice.New(), nil
+	}
+
+	matcher, err := upstream.NewMatcher(opts.UpstreamServers)
+	if err != nil {
+		return alice.Chain{}, err
+	}
+	return alice.New(middleware.NewResponseIdentityAssertion(&middleware.IdentityAssertionOptions{
+		Header:        assertionSigner.Header(),
+		MatchUpstream: matcher.Match,
+		Sign:          assertionSigner.Sign,
+		ErrorHandler:  pageWriter.ProxyErrorHandler,
+	})), nil
+}
+
+// buildAuthzHeadersChain constructs a chain that injects the headers meant for
+// the upstream request into the response.
+// This is used by external authorization integrations that copy headers from
+// the authorization response onto the request they forward upstream.
+func buildAuthzHeadersChain(opts *options.Options) (alice.Chain, error) {
+	upstreamInjector, err := middleware.NewResponseHeaderInjector(opts.InjectRequestHeaders)
+	if err != nil {
+		return alice.Chain{}, fmt.Errorf("error constructing upstream header injector: %v", err)
+	}
+
+	responseInjector, err := middleware.NewResponseHeaderInjector(opts.InjectResponseHeaders)
+	if err != nil {
+		return alice.Chain{}, fmt.Errorf("error constructing response header injector: %v", err)
+	}
+
+	return alice.New(upstreamInjector, responseInjector), nil
+}
+
 func buildSignInMessage(opts *options.Options) string {
 	var msg string
 	if len(opts.Templates.Banner) >= 1 {
@@ -565,6 +734,14 @@ func (p *OAuthProxy) ErrorPage(rw http.ResponseWriter, req *http.Request, code i
 	})
 }
 
+// sessionLimitExceeded renders the error page shown when a user signs in
+// while they already have the maximum number of concurrent sessions
+func (p *OAuthProxy) sessionLimitExceeded(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState) {
+	logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Invalid authentication: concurrent session limit reached for %s", session)
+	p.ErrorPage(rw, req, http.StatusForbidden, "Concurrent session limit reached",
+		"You already have the maximum number of active sessions. Sign out of another session and try again.")
+}
+
 // IsAllowedRequest is used to check if auth should be skipped for this request
 func (p *OAuthProxy) IsAllowedRequest(req *http.Request) bool {
 	isPreflightRequestAllowed := p.skipAuthPreflight && req.Method == "OPTIONS"
@@ -679,8 +856,13 @@ func (p *OAuthProxy) SignIn(rw http.ResponseWriter, req *http.Request) {
 
 	user, ok, statusCode := p.ManualSignIn(req)
 	if ok {
-		session := &sessionsapi.SessionState{User: user, Groups: p.basicAuthGroups}
+		session := &sessionsapi.SessionState{User: user, Groups: p.basicAuthGroups, NewLogin: true}
+		p.sessionBinder.Bind(req, session)
 		err = p.SaveSession(rw, req, session)
+		if errors.Is(err, sessionsapi.ErrSessionLimitExceeded) {
+			p.sessionLimitExceeded(rw, req, session)
+			return
+		}
 		if err != nil {
 			logger.Printf("Error saving session: %v", err)
 			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -706,8 +888,8 @@ func (p *OAuthProxy) UserInfo(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	rw.Header().Set("Content-Type", "application/json")
-	rw.WriteHeader(http.StatusOK)
 	if session == nil {
+		rw.WriteHeader(http.StatusOK)
 		if _, err := rw.Write([]byte("{}")); err != nil {
 			logger.Printf("Error encoding empty user info: %v", err)
 			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -727,9 +909,135 @@ func (p *OAuthProxy) UserInfo(rw http.ResponseWriter, req *http.Request) {
 		PreferredUsername: session.PreferredUsername,
 	}
 
-	if err := json.NewEncoder(rw).Encode(userInfo); err != nil {
+	body, err := json.Marshal(userInfo)
+	if err == nil {
+		body, err = p.appendUserInfoFields(body, session)
+	}
+	if err != nil {
 		logger.Printf("Error encoding user info: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	if _, err := rw.Write(append(body, '\n')); err != nil {
+		logger.Printf("Error writing user info: %v", err)
+	}
+}
+
+// appendUserInfoFields adds the configured userinfo fields of the session to
+// the encoded user info, after the fields that are always returned
+func (p *OAuthProxy) appendUserInfoFields(body []byte, session *sessionsapi.SessionState) ([]byte, error) {
+	fields := make(map[string]interface{})
+	for _, field := range p.userInfoFields {
+		if value, ok := p.userInfoField(session, field); ok {
+			fields[field] = value
+		}
+	}
+	if len(fields) == 0 {
+		return body, nil
+	}
+
+	extra, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	// Join the two objects: `{...}` + `{...}` becomes `{...,...}`
+	body = append(body[:len(body)-1], ',')
+	return append(body, extra[1:]...), nil
+}
+
+// userInfoField returns the value of a configured userinfo field, and false
+// if the session has no value for it
+func (p *OAuthProxy) userInfoField(session *sessionsapi.SessionState, field string) (interface{}, bool) {
+	switch field {
+	case "user", "email", "groups", "preferred_username":
+		// Always returned
+		return nil, false
+	case "access_token", "id_token", "refresh_token":
+		if !p.userInfoIncludeTokens {
+			return nil, false
+		}
+	case "created_at":
+		return session.CreatedAt, session.CreatedAt != nil
+	case "expires_on":
+		return session.ExpiresOn, session.ExpiresOn != nil
+	case "has_refresh_token":
+		return session.RefreshToken != "", true
+	case "provider":
+		return p.provider.Data().ProviderName, true
+	case "amr":
+		return session.AMR, len(session.AMR) > 0
+	}
+
+	values := session.GetClaim(field)
+	switch {
+	case len(values) == 0 || (len(values) == 1 && values[0] == ""):
+		return nil, false
+	case len(values) == 1:
+		return values[0], true
+	default:
+		return values, true
+	}
+}
+
+// Refresh refreshes the session with the provider and returns its new expiry.
+// The session is refreshed by the session chain, as the request scope asks
+// it to refresh regardless of the age of the session.
+func (p *OAuthProxy) Refresh(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, err := p.getAuthenticatedSession(rw, req)
+	if err != nil || session == nil {
+		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	scope := middlewareapi.GetRequestScope(req)
+	if scope.SessionNotRefreshable != "" {
+		http.Error(rw, "Session cannot be refreshed: "+scope.SessionNotRefreshable, http.StatusConflict)
+		return
+	}
+	if !scope.SessionRefreshed {
+		http.Error(rw, "Session could not be refreshed", http.StatusBadGateway)
+		return
+	}
+
+	expiry := struct {
+		CreatedAt *time.Time `json:"created_at,omitempty"`
+		ExpiresOn *time.Time `json:"expires_on,omitempty"`
+	}{
+		CreatedAt: session.CreatedAt,
+		ExpiresOn: session.ExpiresOn,
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(rw).Encode(expiry); err != nil {
+		logger.Printf("Error encoding session expiry: %v", err)
+	}
+}
+
+// forceSessionRefresh asks the session chain to refresh the session with the
+// provider when it is loaded by a POST request
+func forceSessionRefresh(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		middlewareapi.GetRequestScope(req).ForceRefresh = req.Method == http.MethodPost
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// JWKS publishes the public keys that verify the identity assertions sent to
+// upstreams
+func (p *OAuthProxy) JWKS(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "public, max-age=300")
+	rw.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(rw).Encode(p.assertionSigner.JWKS()); err != nil {
+		logger.Printf("Error encoding JWKS: %v", err)
 	}
 }
 
@@ -794,10 +1102,19 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 	extraParams := p.provider.Data().LoginURLParams(overrides)
 	prepareNoCache(rw)
 
-	var (
-		err                                              error
-		codeChallenge, codeVerifier, codeChallengeMethod string
-	)
+	appRedirect, err := p.appDirector.GetRedirect(req)
+	if err != nil {
+		logger.Errorf("Error obtaining application redirect: %v", err)
+		p.ErrorPage(rw, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Request the authentication required to access the redirect URL
+	for param, values := range p.stepUpPolicy.RequirementForRedirect(appRedirect).LoginURLParams() {
+		extraParams[param] = values
+	}
+
+	var codeChallenge, codeVerifier, codeChallengeMethod string
 	if p.provider.Data().CodeChallengeMethod != "" {
 		codeChallengeMethod = p.provider.Data().CodeChallengeMethod
 		codeVerifier, err = encryption.GenerateRandomASCIIString(96)
@@ -825,13 +1142,6 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 		return
 	}
 
-	appRedirect, err := p.appDirector.GetRedirect(req)
-	if err != nil {
-		logger.Errorf("Error obtaining application redirect: %v", err)
-		p.ErrorPage(rw, req, http.StatusBadRequest, err.Error())
-		return
-	}
-
 	callbackRedirect := p.getOAuthRedirectURI(req)
 	loginURL := p.provider.GetLoginURL(
 		callbackRedirect,
@@ -840,6 +1150,14 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 		extraParams,
 	)
 
+	// Send the parameters to the provider rather than through the browser
+	loginURL, err = p.provider.Data().PushAuthorizationRequest(req.Context(), loginURL)
+	if err != nil {
+		logger.Errorf("Error pushing authorization request: %v", err)
+		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	if _, err := csrf.SetCookie(rw, req); err != nil {
 		logger.Errorf("Error setting CSRF cookie: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -878,6 +1196,11 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	session, err := p.redeemCode(req, csrf.GetCodeVerifier())
+	if errors.Is(err, providers.ErrNotAuthorized) {
+		logger.PrintAuthf("", req, logger.AuthFailure, "Invalid authentication via OAuth2: %v", err)
+		p.ErrorPage(rw, req, http.StatusForbidden, "Invalid session: unauthorized")
+		return
+	}
 	if err != nil {
 		logger.Errorf("Error redeeming code during OAuth2 callback: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -885,6 +1208,11 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	err = p.enrichSessionState(req.Context(), session)
+	if errors.Is(err, providers.ErrNotAuthorized) {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Invalid authentication via OAuth2: %v", err)
+		p.ErrorPage(rw, req, http.StatusForbidden, "Invalid session: unauthorized")
+		return
+	}
 	if err != nil {
 		logger.Errorf("Error creating session during OAuth2 callback: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -923,8 +1251,23 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 		logger.Errorf("Error with authorization: %v", err)
 	}
 	if p.Validator(session.Email) && authorized {
+		// Stop here rather than redirecting back to the provider in a loop
+		// when the provider did not honour the step-up request
+		if requirement := p.stepUpPolicy.RequirementForRedirect(appRedirect); !requirement.SatisfiedBy(session) {
+			logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Invalid authentication via OAuth2: session does not satisfy %s", requirement)
+			p.ErrorPage(rw, req, http.StatusForbidden, "Step-up authentication failed",
+				"Login Failed: The identity provider did not confirm the authentication required for this page.")
+			return
+		}
+
 		logger.PrintAuthf(session.Email, req, logger.AuthSuccess, "Authenticated via OAuth2: %s", session)
+		p.sessionBinder.Bind(req, session)
+		session.NewLogin = true
 		err := p.SaveSession(rw, req, session)
+		if errors.Is(err, sessionsapi.ErrSessionLimitExceeded) {
+			p.sessionLimitExceeded(rw, req, session)
+			return
+		}
 		if err != nil {
 			logger.Errorf("Error saving session state for %s: %v", remoteAddr, err)
 			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -992,15 +1335,161 @@ func (p *OAuthProxy) AuthOnly(rw http.ResponseWriter, req *http.Request) {
 
 	// we are authenticated
 	p.addHeadersForProxying(rw, session)
-	p.headersChain.Then(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+	accepted := p.assertionChain.Then(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
 		rw.WriteHeader(http.StatusAccepted)
+	}))
+	p.headersChain.Then(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		accepted.ServeHTTP(rw, authOnlyOriginalRequest(req))
 	})).ServeHTTP(rw, req)
 }
 
+// authOnlyOriginalRequest returns the request that the reverse proxy is
+// checking with an auth request, whose path is forwarded in the X-Forwarded-Uri
+// header. The auth request itself is returned when the path isn't forwarded.
+func authOnlyOriginalRequest(req *http.Request) *http.Request {
+	uri, err := url.ParseRequestURI(requestutil.GetRequestURI(req))
+	if err != nil {
+		return req
+	}
+
+	original := req.Clone(req.Context())
+	original.URL.Path = uri.Path
+	original.URL.RawPath = uri.RawPath
+	original.URL.RawQuery = uri.RawQuery
+	original.RequestURI = uri.RequestURI()
+	return original
+}
+
+// ExtAuthz implements the HTTP service of the Envoy external authorization
+// filter. Envoy sends the original method, host and headers and appends the
+// original path to the configured `path_prefix`, the original request is
+// rebuilt from these before authenticating it.
+// An OK response allows the request, any other response is returned to the
+// client by Envoy.
+func (p *OAuthProxy) ExtAuthz(rw http.ResponseWriter, req *http.Request) {
+	original := extAuthzOriginalRequest(req, p.ProxyPrefix+extAuthzPath)
+	p.checkAuthorization(rw, original, original.URL.RequestURI())
+}
+
+// ForwardAuth authenticates requests for reverse proxies such as the Traefik
+// `forwardAuth` middleware or the Caddy `forward_auth` directive.
+// The original request is rebuilt from the X-Forwarded-Method, -Proto, -Host
+// and -Uri headers, which are only trusted when running behind a reverse proxy.
+func (p *OAuthProxy) ForwardAuth(rw http.ResponseWriter, req *http.Request) {
+	original, err := forwardAuthOriginalRequest(req)
+	if err != nil {
+		logger.Errorf("Error rebuilding forwarded request: %v", err)
+		p.ErrorPage(rw, req, http.StatusBadRequest, err.Error())
+		return
+	}
+	p.checkAuthorization(rw, original, original.URL.String())
+}
+
+// extAuthzOriginalRequest strips the ext_authz prefix from the request path to
+// obtain the request that Envoy is checking.
+func extAuthzOriginalRequest(req *http.Request, prefix string) *http.Request {
+	original := req.Clone(req.Context())
+	original.URL.Path = strings.TrimPrefix(req.URL.Path, prefix)
+	if original.URL.Path == "" {
+		original.URL.Path = "/"
+	}
+	if req.URL.RawPath != "" {
+		original.URL.RawPath = strings.TrimPrefix(req.URL.RawPath, prefix)
+		if original.URL.RawPath == "" {
+			original.URL.RawPath = "/"
+		}
+	}
+	original.RequestURI = original.URL.RequestURI()
+	return original
+}
+
+// forwardAuthOriginalRequest rebuilds the request that the reverse proxy is
+// checking from the forwarded headers.
+func forwardAuthOriginalRequest(req *http.Request) (*http.Request, error) {
+	uri, err := url.ParseRequestURI(requestutil.GetRequestURI(req))
+	if err != nil {
+		return nil, fmt.Errorf("invalid forwarded URI: %v", err)
+	}
+
+	original := req.Clone(req.Context())
+	original.Method = requestutil.GetRequestMethod(req)
+	original.Host = requestutil.GetRequestHost(req)
+	original.URL = uri
+	original.URL.Host = original.Host
+	original.URL.Scheme = requestutil.GetRequestProto(req)
+	if original.URL.Scheme == "" {
+		original.URL.Scheme = schemeHTTP
+		if req.TLS != nil {
+			original.URL.Scheme = schemeHTTPS
+		}
+	}
+	original.RequestURI = uri.RequestURI()
+	return original, nil
+}
+
+// checkAuthorization authenticates a request on behalf of an external
+// authorization integration.
+// Authenticated requests receive an OK response with the upstream headers set,
+// browsers without a session are redirected to sign in and return to the
+// redirect URL afterwards.
+func (p *OAuthProxy) checkAuthorization(rw http.ResponseWriter, req *http.Request, redirect string) {
+	session, err := p.getSteppedUpSession(rw, req)
+	switch err {
+	case nil:
+		// we are authenticated
+		p.addHeadersForProxying(rw, session)
+		p.authzHeadersChain.Then(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rw, req)
+	case ErrNeedsLogin:
+		if p.forceJSONErrors || isAjax(req) || p.isAPIPath(req) {
+			logger.Printf("No valid authentication in request. Access Denied.")
+			p.errorJSON(rw, http.StatusUnauthorized)
+			return
+		}
+
+		logger.Printf("No valid authentication in request. Redirecting to sign in.")
+		p.redirectToSignIn(rw, req, redirect)
+	case ErrNeedsStepUp:
+		if p.forceJSONErrors || isAjax(req) || p.isAPIPath(req) {
+			logger.Printf("Session does not satisfy the step-up requirements. Access Denied.")
+			p.errorJSON(rw, http.StatusUnauthorized)
+			return
+		}
+
+		logger.Printf("Session does not satisfy the step-up requirements. Redirecting to login.")
+		prepareNoCache(rw)
+		http.Redirect(rw, req, p.ProxyPrefix+oauthStartPath+"?"+url.Values{"rd": {redirect}}.Encode(), http.StatusFound)
+	case ErrAccessDenied:
+		if p.forceJSONErrors {
+			p.errorJSON(rw, http.StatusForbidden)
+		} else {
+			p.ErrorPage(rw, req, http.StatusForbidden, "The session failed authorization checks")
+		}
+	default:
+		// unknown error
+		logger.Errorf("Unexpected internal error: %v", err)
+		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// redirectToSignIn redirects the user to the sign in page, or straight to the
+// provider when the provider button is skipped, returning them to the
+// redirect URL once authenticated.
+func (p *OAuthProxy) redirectToSignIn(rw http.ResponseWriter, req *http.Request, redirect string) {
+	signInURL := p.SignInPath
+	if p.SkipProviderButton {
+		signInURL = p.ProxyPrefix + oauthStartPath
+	}
+
+	prepareNoCache(rw)
+	http.Redirect(rw, req, signInURL+"?"+url.Values{"rd": {redirect}}.Encode(), http.StatusFound)
+}
+
 // Proxy proxies the user request if the user is authenticated else it prompts
 // them to authenticate
 func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
-	session, err := p.getAuthenticatedSession(rw, req)
+	session, err := p.getSteppedUpSession(rw, req)
 	switch err {
 	case nil:
 		// we are authenticated
@@ -1025,6 +1514,18 @@ func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
 			p.SignInPage(rw, req, http.StatusForbidden)
 		}
 
+	case ErrNeedsStepUp:
+		if p.forceJSONErrors || isAjax(req) || p.isAPIPath(req) {
+			logger.Printf("Session does not satisfy the step-up requirements. Access Denied.")
+			p.errorJSON(rw, http.StatusUnauthorized)
+			return
+		}
+
+		// The user is already signed in, so skip the sign in page and
+		// restart the OAuth flow requesting the required authentication
+		logger.Printf("Session does not satisfy the step-up requirements. Initiating login.")
+		p.doOAuthStart(rw, req, nil)
+
 	case ErrAccessDenied:
 		if p.forceJSONErrors {
 			p.errorJSON(rw, http.StatusForbidden)
@@ -1129,6 +1630,22 @@ func (p *OAuthProxy) getAuthenticatedSession(rw http.ResponseWriter, req *http.R
 	return session, nil
 }
 
+// getSteppedUpSession returns the authenticated session like
+// getAuthenticatedSession, and `nil, ErrNeedsStepUp` if the session does not
+// satisfy the step-up requirements of the request.
+func (p *OAuthProxy) getSteppedUpSession(rw http.ResponseWriter, req *http.Request) (*sessionsapi.SessionState, error) {
+	session, err := p.getAuthenticatedSession(rw, req)
+	if err != nil || session == nil || p.IsAllowedRequest(req) {
+		return session, err
+	}
+
+	if requirement := p.stepUpPolicy.Requirement(req); !requirement.SatisfiedBy(session) {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Step-up authentication required: session does not satisfy %s", requirement)
+		return nil, ErrNeedsStepUp
+	}
+	return session, nil
+}
+
 // authOnlyAuthorize handles special authorization logic that is only done
 // on the AuthOnly endpoint for use with Nginx subrequest architectures.
 func authOnlyAuthorize(req *http.Request, s *sessionsapi.SessionState) bool {
@@ -3,7 +3,14 @@ package main
 import (
 	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,6 +22,8 @@ import (
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/mbland/hmacauth"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
@@ -658,6 +667,183 @@ func TestManualSignInStoresUserGroupsInTheSession(t *testing.T) {
 	assert.Equal(t, userGroups, s.Groups)
 }
 
+func TestManualSignInRejectedOverConcurrentSessionLimit(t *testing.T) {
+	opts := baseTestOptions()
+	opts.Session.Type = options.MemorySessionStoreType
+	opts.Session.Concurrent = options.ConcurrentSessionOptions{
+		Limit:  1,
+		Action: options.ConcurrentSessionReject,
+	}
+	err := validation.Validate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy, err := NewOAuthProxy(opts, func(email string) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy.basicAuthValidator = AlwaysSuccessfulValidator{}
+
+	signIn := func() *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		formData := url.Values{}
+		formData.Set("username", "someuser")
+		formData.Set("password", "somepass")
+		signInReq, _ := http.NewRequest(http.MethodPost, "/oauth2/sign_in", strings.NewReader(formData.Encode()))
+		signInReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		proxy.ServeHTTP(rw, signInReq)
+		return rw
+	}
+
+	assert.Equal(t, http.StatusFound, signIn().Code)
+
+	rw := signIn()
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assert.Contains(t, rw.Body.String(), "You already have the maximum number of active sessions.")
+	assert.Empty(t, rw.Result().Cookies())
+}
+
+type UnauthorizingTestProvider struct {
+	*TestProvider
+}
+
+func (up *UnauthorizingTestProvider) EnrichSession(_ context.Context, _ *sessions.SessionState) error {
+	return fmt.Errorf("%w: not a member of the allowed organizations", providers.ErrNotAuthorized)
+}
+
+func TestOAuthCallbackUnauthorizedEnrichment(t *testing.T) {
+	providerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"access_token": "my_auth_token"}`))
+	}))
+	defer providerServer.Close()
+
+	opts := baseTestOptions()
+	err := validation.Validate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy, err := NewOAuthProxy(opts, func(email string) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	providerURL, _ := url.Parse(providerServer.URL)
+	proxy.provider = &UnauthorizingTestProvider{TestProvider: NewTestProvider(providerURL, "john@example.com")}
+
+	csrf, err := cookies.NewCSRF(proxy.CookieOptions, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/oauth2/callback?code=callback_code&state=%s",
+		encodeState(csrf.HashOAuthState(), "%2F", false)), nil)
+	csrfCookie, err := csrf.SetCookie(httptest.NewRecorder(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.AddCookie(csrfCookie)
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+}
+
+func TestOAuthCallbackWithTheCookieOfAMismatchedSession(t *testing.T) {
+	const (
+		firefoxLinux  = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+		chromeWindows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+	)
+
+	providerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"access_token": "my_auth_token"}`))
+	}))
+	defer providerServer.Close()
+
+	opts := baseTestOptions()
+	opts.Cookie.Secure = false
+	opts.Session.Type = options.MemorySessionStoreType
+	opts.Session.Binding = options.SessionBindingOptions{
+		Attributes: []string{options.SessionBindingUserAgent},
+		Action:     options.SessionBindingReject,
+	}
+	err := validation.Validate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy, err := NewOAuthProxy(opts, func(email string) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	providerURL, _ := url.Parse(providerServer.URL)
+	testProvider := NewTestProvider(providerURL, "attacker@example.com")
+	testProvider.ValidToken = true
+	proxy.provider = testProvider
+
+	requestWithCookie := func(target, userAgent string, cookie *http.Cookie) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("User-Agent", userAgent)
+		req.AddCookie(cookie)
+		return req
+	}
+
+	// The victim signs in with Firefox
+	victimReq := httptest.NewRequest(http.MethodGet, "/", nil)
+	victimReq.Header.Set("User-Agent", firefoxLinux)
+	victim := &sessions.SessionState{User: "victim", Email: "victim@example.com", NewLogin: true}
+	proxy.sessionBinder.Bind(victimReq, victim)
+	rw := httptest.NewRecorder()
+	assert.NoError(t, proxy.SaveSession(rw, victimReq, victim))
+	victimCookie := rw.Result().Cookies()[0]
+
+	// The stolen cookie doesn't authenticate a client with another binding
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, requestWithCookie("/oauth2/auth", chromeWindows, victimCookie))
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+
+	// The attacker then signs in as themselves with the stolen cookie
+	csrf, err := cookies.NewCSRF(proxy.CookieOptions, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callbackReq := requestWithCookie(fmt.Sprintf("/oauth2/callback?code=callback_code&state=%s",
+		encodeState(csrf.HashOAuthState(), "%2F", false)), chromeWindows, victimCookie)
+	csrfCookie, err := csrf.SetCookie(httptest.NewRecorder(), callbackReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callbackReq.AddCookie(csrfCookie)
+	rw = httptest.NewRecorder()
+	proxy.ServeHTTP(rw, callbackReq)
+	assert.Equal(t, http.StatusFound, rw.Code)
+
+	var attackerCookie *http.Cookie
+	for _, c := range rw.Result().Cookies() {
+		if c.Name == opts.Cookie.Name {
+			attackerCookie = c
+		}
+	}
+	if !assert.NotNil(t, attackerCookie) {
+		return
+	}
+	assert.NotEqual(t, victimCookie.Value, attackerCookie.Value)
+
+	// The victim's cookie still loads the victim's session
+	session, err := proxy.sessionStore.Load(requestWithCookie("/", firefoxLinux, victimCookie))
+	assert.NoError(t, err)
+	assert.Equal(t, "victim@example.com", session.Email)
+
+	session, err = proxy.sessionStore.Load(requestWithCookie("/", chromeWindows, attackerCookie))
+	assert.NoError(t, err)
+	assert.Equal(t, "attacker@example.com", session.Email)
+}
+
 type ManualSignInValidator struct{}
 
 func (ManualSignInValidator) Validate(user, password string) bool {
@@ -1403,6 +1589,201 @@ func TestAuthOnlyEndpointSetBasicAuthFalseRequestHeaders(t *testing.T) {
 	assert.Equal(t, 0, len(pcTest.rw.Header().Values("Authorization")), "should not have Authorization header entries")
 }
 
+func TestExtAuthzEndpoint(t *testing.T) {
+	testCases := map[string]struct {
+		path             string
+		header           http.Header
+		session          *sessions.SessionState
+		skipAuthRegex    []string
+		expectedCode     int
+		expectedLocation string
+		expectedHeaders  map[string]string
+	}{
+		"authenticated request is allowed with upstream headers": {
+			path:         "/foo/bar",
+			session:      &sessions.SessionState{User: "oauth_user", Email: "oauth_user@example.com"},
+			expectedCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"GAP-Auth":           "oauth_user@example.com",
+				"X-Forwarded-User":   "oauth_user",
+				"X-Auth-Request-Uid": "oauth_user",
+			},
+		},
+		"unauthenticated browser request is redirected to sign in": {
+			path:             "/foo/bar?baz=1",
+			expectedCode:     http.StatusFound,
+			expectedLocation: "/oauth2/sign_in?rd=%2Ffoo%2Fbar%3Fbaz%3D1",
+		},
+		"unauthenticated request for the root path is redirected to sign in": {
+			path:             "",
+			expectedCode:     http.StatusFound,
+			expectedLocation: "/oauth2/sign_in?rd=%2F",
+		},
+		"unauthenticated ajax request is unauthorized": {
+			path:         "/foo/bar",
+			header:       http.Header{"Accept": []string{applicationJSON}},
+			expectedCode: http.StatusUnauthorized,
+		},
+		"skip auth routes apply to the original path": {
+			path:          "/public/index.html",
+			skipAuthRegex: []string{"^/public/"},
+			expectedCode:  http.StatusOK,
+		},
+		"skip auth routes do not apply to the check path": {
+			path:             "/private",
+			skipAuthRegex:    []string{"^/oauth2/ext_authz/private"},
+			expectedCode:     http.StatusFound,
+			expectedLocation: "/oauth2/sign_in?rd=%2Fprivate",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.SkipAuthRegex = tc.skipAuthRegex
+				opts.InjectRequestHeaders = []options.Header{
+					{
+						Name: "X-Forwarded-User",
+						Values: []options.HeaderValue{
+							{ClaimSource: &options.ClaimSource{Claim: "user"}},
+						},
+					},
+				}
+				opts.InjectResponseHeaders = []options.Header{
+					{
+						Name: "X-Auth-Request-Uid",
+						Values: []options.HeaderValue{
+							{ClaimSource: &options.ClaimSource{Claim: "user"}},
+						},
+					},
+				}
+			})
+			require.NoError(t, err)
+
+			test.req, err = http.NewRequest(http.MethodPost, test.opts.ProxyPrefix+extAuthzPath+tc.path, nil)
+			require.NoError(t, err)
+			test.req.Host = "app.example.com"
+			for key, values := range tc.header {
+				test.req.Header[key] = values
+			}
+
+			if tc.session != nil {
+				created := time.Now()
+				tc.session.CreatedAt = &created
+				require.NoError(t, test.SaveSession(tc.session))
+				test.rw = httptest.NewRecorder()
+			}
+
+			test.proxy.ServeHTTP(test.rw, test.req)
+			assert.Equal(t, tc.expectedCode, test.rw.Code)
+			assert.Equal(t, tc.expectedLocation, test.rw.Header().Get("Location"))
+			for key, value := range tc.expectedHeaders {
+				assert.Equal(t, value, test.rw.Header().Get(key))
+			}
+		})
+	}
+}
+
+func TestForwardAuthEndpoint(t *testing.T) {
+	testCases := map[string]struct {
+		method           string
+		uri              string
+		header           http.Header
+		session          *sessions.SessionState
+		skipAuthRoutes   []string
+		apiRoutes        []string
+		expectedCode     int
+		expectedLocation string
+		expectedHeaders  map[string]string
+	}{
+		"authenticated request is allowed with upstream headers": {
+			uri:          "/foo/bar",
+			session:      &sessions.SessionState{User: "oauth_user", Email: "oauth_user@example.com"},
+			expectedCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"GAP-Auth":         "oauth_user@example.com",
+				"X-Forwarded-User": "oauth_user",
+			},
+		},
+		"unauthenticated browser request is redirected to sign in": {
+			uri:              "/foo/bar?baz=1",
+			expectedCode:     http.StatusFound,
+			expectedLocation: "/oauth2/sign_in?rd=https%3A%2F%2Fapp.example.com%2Ffoo%2Fbar%3Fbaz%3D1",
+		},
+		"unauthenticated api request is unauthorized": {
+			uri:          "/api/v1/items",
+			apiRoutes:    []string{"^/api/"},
+			expectedCode: http.StatusUnauthorized,
+		},
+		"unauthenticated ajax request is unauthorized": {
+			uri:          "/foo/bar",
+			header:       http.Header{"Accept": []string{applicationJSON}},
+			expectedCode: http.StatusUnauthorized,
+		},
+		"skip auth routes apply to the original method and path": {
+			method:         http.MethodPost,
+			uri:            "/public/form",
+			skipAuthRoutes: []string{"POST=^/public/"},
+			expectedCode:   http.StatusOK,
+		},
+		"skip auth routes do not match a different original method": {
+			method:           http.MethodGet,
+			uri:              "/public/form",
+			skipAuthRoutes:   []string{"POST=^/public/"},
+			expectedCode:     http.StatusFound,
+			expectedLocation: "/oauth2/sign_in?rd=https%3A%2F%2Fapp.example.com%2Fpublic%2Fform",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.ReverseProxy = true
+				opts.SkipAuthRoutes = tc.skipAuthRoutes
+				opts.APIRoutes = tc.apiRoutes
+				opts.InjectRequestHeaders = []options.Header{
+					{
+						Name: "X-Forwarded-User",
+						Values: []options.HeaderValue{
+							{ClaimSource: &options.ClaimSource{Claim: "user"}},
+						},
+					},
+				}
+			})
+			require.NoError(t, err)
+
+			test.req, err = http.NewRequest(http.MethodGet, test.opts.ProxyPrefix+forwardAuthPath, nil)
+			require.NoError(t, err)
+			test.req.Host = "oauth2-proxy:4180"
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			test.req.Header.Set("X-Forwarded-Method", method)
+			test.req.Header.Set("X-Forwarded-Proto", "https")
+			test.req.Header.Set("X-Forwarded-Host", "app.example.com")
+			test.req.Header.Set("X-Forwarded-Uri", tc.uri)
+			for key, values := range tc.header {
+				test.req.Header[key] = values
+			}
+
+			if tc.session != nil {
+				created := time.Now()
+				tc.session.CreatedAt = &created
+				require.NoError(t, test.SaveSession(tc.session))
+				test.rw = httptest.NewRecorder()
+			}
+
+			test.proxy.ServeHTTP(test.rw, test.req)
+			assert.Equal(t, tc.expectedCode, test.rw.Code)
+			assert.Equal(t, tc.expectedLocation, test.rw.Header().Get("Location"))
+			for key, value := range tc.expectedHeaders {
+				assert.Equal(t, value, test.rw.Header().Get(key))
+			}
+		})
+	}
+}
+
 func TestAuthSkippedForPreflightRequests(t *testing.T) {
 	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
@@ -3467,3 +3848,432 @@ func TestGetOAuthRedirectURI(t *testing.T) {
 		})
 	}
 }
+
+func TestProxyStepUp(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-time.Minute)
+	old := now.Add(-time.Hour)
+	maxAge := options.Duration(15 * time.Minute)
+
+	testCases := []struct {
+		name             string
+		path             string
+		accept           string
+		session          *sessions.SessionState
+		expectedCode     int
+		expectedLocation []string
+	}{
+		{
+			name:         "RouteWithoutRequirement",
+			path:         "/",
+			session:      &sessions.SessionState{Email: "test", AccessToken: "oauth_token", CreatedAt: &old},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:             "MissingACR",
+			path:             "/admin/users",
+			session:          &sessions.SessionState{Email: "test", AccessToken: "oauth_token", CreatedAt: &recent},
+			expectedCode:     http.StatusFound,
+			expectedLocation: []string{"https://provider.example.com/authorize?", "acr_values=urn%3Aexample%3Amfa", "max_age=900"},
+		},
+		{
+			name:             "AuthenticatedTooLongAgo",
+			path:             "/admin/users",
+			session:          &sessions.SessionState{Email: "test", AccessToken: "oauth_token", CreatedAt: &recent, ACR: "urn:example:mfa", AuthTime: &old},
+			expectedCode:     http.StatusFound,
+			expectedLocation: []string{"acr_values=urn%3Aexample%3Amfa", "max_age=900"},
+		},
+		{
+			name:         "SteppedUp",
+			path:         "/admin/users",
+			session:      &sessions.SessionState{Email: "test", AccessToken: "oauth_token", CreatedAt: &old, ACR: "urn:example:mfa", AuthTime: &recent},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "APIRequest",
+			path:         "/admin/users",
+			accept:       applicationJSON,
+			session:      &sessions.SessionState{Email: "test", AccessToken: "oauth_token", CreatedAt: &recent},
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+			}))
+			t.Cleanup(upstreamServer.Close)
+
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.UpstreamServers = options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   upstreamServer.URL,
+							Path: "/",
+							URI:  upstreamServer.URL,
+						},
+					},
+				}
+				opts.StepUpRoutes = []options.StepUpRoute{
+					{
+						Path: "^/admin/",
+						StepUp: options.StepUp{
+							ACRValues: []string{"urn:example:mfa"},
+							MaxAge:    &maxAge,
+						},
+					},
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.proxy.provider.Data().LoginURL = &url.URL{Scheme: "https", Host: "provider.example.com", Path: "/authorize"}
+
+			test.req, _ = http.NewRequest("GET", tc.path, nil)
+			if tc.accept != "" {
+				test.req.Header.Add("accept", tc.accept)
+			}
+			err = test.SaveSession(tc.session)
+			assert.NoError(t, err)
+			test.proxy.ServeHTTP(test.rw, test.req)
+
+			assert.Equal(t, tc.expectedCode, test.rw.Code)
+			location := test.rw.Header().Get("Location")
+			for _, expected := range tc.expectedLocation {
+				assert.Contains(t, location, expected)
+			}
+		})
+	}
+}
+
+func TestProxyIdentityAssertion(t *testing.T) {
+	now := time.Now()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyData, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	var assertion string
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertion = r.Header.Get("X-Identity")
+		w.WriteHeader(200)
+	}))
+	t.Cleanup(upstreamServer.Close)
+
+	test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+		opts.UpstreamServers = options.UpstreamConfig{
+			Upstreams: []options.Upstream{
+				{
+					ID:   "app",
+					Path: "/",
+					URI:  upstreamServer.URL,
+				},
+			},
+		}
+		opts.IdentityAssertion = &options.IdentityAssertion{
+			Issuer:     "https://proxy.example.com",
+			Header:     "X-Identity",
+			SigningKey: options.SecretSource{Value: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyData})},
+		}
+	})
+	require.NoError(t, err)
+
+	// The keys are published without a session
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/oauth2/jwks.json", nil)
+	test.proxy.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "public, max-age=300", rw.Header().Get("Cache-Control"))
+
+	var jwks jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &jwks))
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, &key.PublicKey, jwks.Keys[0].Key)
+
+	// A forged assertion is replaced by one for the session's user
+	test.req, _ = http.NewRequest("GET", "/", nil)
+	test.req.Header.Set("X-Identity", "forged")
+	err = test.SaveSession(&sessions.SessionState{User: "john", Email: "john@example.com", AccessToken: "oauth_token", CreatedAt: &now})
+	require.NoError(t, err)
+	test.proxy.ServeHTTP(test.rw, test.req)
+	assert.Equal(t, http.StatusOK, test.rw.Code)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(assertion, claims, func(t *jwt.Token) (interface{}, error) {
+		keys := jwks.Key(t.Header["kid"].(string))
+		if len(keys) != 1 {
+			return nil, fmt.Errorf("unknown key %v", t.Header["kid"])
+		}
+		return keys[0].Key, nil
+	}, jwt.WithIssuer("https://proxy.example.com"), jwt.WithAudience("app"))
+	require.NoError(t, err)
+	assert.Equal(t, "john", claims["sub"])
+	assert.Equal(t, "john@example.com", claims["email"])
+}
+
+func TestAuthEndpointsIdentityAssertion(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyData, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		path         string
+		expectedCode int
+	}{
+		"auth request": {
+			path:         authOnlyPath,
+			expectedCode: http.StatusAccepted,
+		},
+		"forward auth": {
+			path:         forwardAuthPath,
+			expectedCode: http.StatusOK,
+		},
+		"ext_authz": {
+			path:         extAuthzPath + "/orders/42",
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.ReverseProxy = true
+				opts.UpstreamServers = options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   "app",
+							Path: "/",
+							URI:  "http://app.internal",
+						},
+						{
+							ID:   "orders",
+							Path: "/orders/",
+							URI:  "http://orders.internal",
+						},
+					},
+				}
+				opts.IdentityAssertion = &options.IdentityAssertion{
+					Issuer:     "https://proxy.example.com",
+					Header:     "X-Identity",
+					SigningKey: options.SecretSource{Value: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyData})},
+				}
+			})
+			require.NoError(t, err)
+
+			test.req, err = http.NewRequest(http.MethodGet, test.opts.ProxyPrefix+tc.path, nil)
+			require.NoError(t, err)
+			test.req.Header.Set("X-Forwarded-Method", http.MethodGet)
+			test.req.Header.Set("X-Forwarded-Proto", "https")
+			test.req.Header.Set("X-Forwarded-Host", "app.example.com")
+			test.req.Header.Set("X-Forwarded-Uri", "/orders/42")
+
+			created := time.Now()
+			require.NoError(t, test.SaveSession(&sessions.SessionState{User: "john", Email: "john@example.com", AccessToken: "oauth_token", CreatedAt: &created}))
+			test.proxy.ServeHTTP(test.rw, test.req)
+			assert.Equal(t, tc.expectedCode, test.rw.Code)
+
+			claims := jwt.MapClaims{}
+			_, err = jwt.ParseWithClaims(test.rw.Header().Get("X-Identity"), claims, func(*jwt.Token) (interface{}, error) {
+				return &key.PublicKey, nil
+			}, jwt.WithIssuer("https://proxy.example.com"), jwt.WithAudience("orders"))
+			require.NoError(t, err)
+			assert.Equal(t, "john", claims["sub"])
+		})
+	}
+}
+
+func TestUserInfoEndpointFields(t *testing.T) {
+	created := time.Now().UTC().Truncate(time.Second)
+	expires := created.Add(time.Hour)
+	session := &sessions.SessionState{
+		User:             "john.doe",
+		Email:            "john.doe@example.com",
+		AccessToken:      "my_access_token",
+		RefreshToken:     "my_refresh_token",
+		CreatedAt:        &created,
+		ExpiresOn:        &expires,
+		AMR:              []string{"pwd", "otp"},
+		AdditionalClaims: map[string]string{"department": "engineering"},
+	}
+
+	testCases := []struct {
+		name             string
+		fields           []string
+		includeTokens    bool
+		expectedResponse string
+	}{
+		{
+			name:             "Session fields and claims",
+			fields:           []string{"created_at", "expires_on", "has_refresh_token", "provider", "amr", "department", "acr", "email"},
+			expectedResponse: fmt.Sprintf("{\"user\":\"john.doe\",\"email\":\"john.doe@example.com\",\"amr\":[\"pwd\",\"otp\"],\"created_at\":%q,\"department\":\"engineering\",\"expires_on\":%q,\"has_refresh_token\":true,\"provider\":\"Test Provider\"}\n", created.Format(time.RFC3339), expires.Format(time.RFC3339)),
+		},
+		{
+			name:             "Tokens without including tokens",
+			fields:           []string{"access_token", "refresh_token"},
+			expectedResponse: "{\"user\":\"john.doe\",\"email\":\"john.doe@example.com\"}\n",
+		},
+		{
+			name:             "Tokens when including tokens",
+			fields:           []string{"access_token", "refresh_token"},
+			includeTokens:    true,
+			expectedResponse: "{\"user\":\"john.doe\",\"email\":\"john.doe@example.com\",\"access_token\":\"my_access_token\",\"refresh_token\":\"my_refresh_token\"}\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			test, err := NewUserInfoEndpointTest()
+			require.NoError(t, err)
+			test.proxy.provider.Data().ProviderName = "Test Provider"
+			test.proxy.userInfoFields = tc.fields
+			test.proxy.userInfoIncludeTokens = tc.includeTokens
+
+			err = test.SaveSession(session)
+			assert.NoError(t, err)
+
+			test.proxy.ServeHTTP(test.rw, test.req)
+			assert.Equal(t, http.StatusOK, test.rw.Code)
+			assert.Equal(t, tc.expectedResponse, test.rw.Body.String())
+		})
+	}
+}
+
+type RefreshingTestProvider struct {
+	*TestProvider
+	ExpiresOn *time.Time
+	Err       error
+}
+
+func (rp *RefreshingTestProvider) RefreshSession(_ context.Context, s *sessions.SessionState) (bool, error) {
+	if rp.Err != nil {
+		return false, rp.Err
+	}
+	if rp.ExpiresOn == nil {
+		return false, nil
+	}
+	s.ExpiresOn = rp.ExpiresOn
+	return true, nil
+}
+
+func TestRefreshEndpoint(t *testing.T) {
+	now := time.Now()
+	expires := now.Add(time.Minute)
+	refreshedExpires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testCases := []struct {
+		name             string
+		method           string
+		session          *sessions.SessionState
+		refreshExpiresOn *time.Time
+		refreshErr       error
+		expectedCode     int
+		expectedBody     string
+	}{
+		{
+			name:             "Refreshed",
+			method:           http.MethodPost,
+			session:          &sessions.SessionState{Email: "john.doe@example.com", AccessToken: "my_access_token", CreatedAt: &now, ExpiresOn: &expires},
+			refreshExpiresOn: &refreshedExpires,
+			expectedCode:     http.StatusOK,
+			expectedBody:     "\"expires_on\":\"2030-01-02T03:04:05Z\"",
+		},
+		{
+			name:         "ProviderWithoutRefresh",
+			method:       http.MethodPost,
+			session:      &sessions.SessionState{Email: "john.doe@example.com", AccessToken: "my_access_token", CreatedAt: &now, ExpiresOn: &expires},
+			refreshErr:   providers.ErrNotImplemented,
+			expectedCode: http.StatusConflict,
+			expectedBody: "Session cannot be refreshed: the provider does not support refreshing sessions",
+		},
+		{
+			name:         "NoRefreshToken",
+			method:       http.MethodPost,
+			session:      &sessions.SessionState{Email: "john.doe@example.com", AccessToken: "my_access_token", CreatedAt: &now, ExpiresOn: &expires},
+			expectedCode: http.StatusConflict,
+			expectedBody: "Session cannot be refreshed: the session has no refresh token",
+		},
+		{
+			name:         "ProviderError",
+			method:       http.MethodPost,
+			session:      &sessions.SessionState{Email: "john.doe@example.com", AccessToken: "my_access_token", CreatedAt: &now, ExpiresOn: &expires},
+			refreshErr:   errors.New("provider unavailable"),
+			expectedCode: http.StatusBadGateway,
+			expectedBody: "Session could not be refreshed",
+		},
+		{
+			name:         "NoSession",
+			method:       http.MethodPost,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:             "NotPost",
+			method:           http.MethodGet,
+			session:          &sessions.SessionState{Email: "john.doe@example.com", AccessToken: "my_access_token", CreatedAt: &now, ExpiresOn: &expires},
+			refreshExpiresOn: &refreshedExpires,
+			expectedCode:     http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			test, err := NewProcessCookieTestWithDefaults()
+			require.NoError(t, err)
+
+			provider := &RefreshingTestProvider{
+				TestProvider: test.proxy.provider.(*TestProvider),
+				ExpiresOn:    tc.refreshExpiresOn,
+				Err:          tc.refreshErr,
+			}
+			test.proxy.provider = provider
+			test.proxy.sessionChain = buildSessionChain(test.opts, provider, test.proxy.sessionStore, nil, nil)
+			test.proxy.buildServeMux(test.proxy.ProxyPrefix)
+
+			test.req, _ = http.NewRequest(tc.method, "/oauth2/refresh", nil)
+			if tc.session != nil {
+				require.NoError(t, test.SaveSession(tc.session))
+			}
+			test.rw = httptest.NewRecorder()
+			test.proxy.ServeHTTP(test.rw, test.req)
+
+			assert.Equal(t, tc.expectedCode, test.rw.Code)
+			assert.Contains(t, test.rw.Body.String(), tc.expectedBody)
+			if tc.expectedCode == http.StatusOK {
+				// The refreshed session is saved
+				assert.NotEmpty(t, test.rw.Result().Cookies())
+			}
+		})
+	}
+}
+
+func TestProxyCORS(t *testing.T) {
+	test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+		opts.CORS = &options.CORS{
+			AllowedOrigins:   []string{"https://app.example.com"},
+			AllowedHeaders:   []string{"Content-Type"},
+			AllowCredentials: true,
+		}
+	})
+	require.NoError(t, err)
+
+	// Preflights are answered without authentication
+	req, _ := http.NewRequest(http.MethodOptions, "/oauth2/userinfo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "content-type")
+	rw := httptest.NewRecorder()
+	test.proxy.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Equal(t, "https://app.example.com", rw.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rw.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "content-type", rw.Header().Get("Access-Control-Allow-Headers"))
+
+	// Responses that require authentication can be read by the frontend
+	req, _ = http.NewRequest(http.MethodGet, "/oauth2/userinfo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rw = httptest.NewRecorder()
+	test.proxy.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	assert.Equal(t, "https://app.example.com", rw.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -39,6 +39,19 @@ type RequestScope struct {
 	// it was loaded or not.
 	SessionRevalidated bool
 
+	// ForceRefresh indicates whether the stored session should be refreshed
+	// with the provider when it is loaded, regardless of its age.
+	ForceRefresh bool
+
+	// SessionRefreshed indicates whether the session was refreshed with the
+	// provider when it was loaded.
+	SessionRefreshed bool
+
+	// SessionNotRefreshable explains why the session cannot be refreshed with
+	// the provider, eg. because it has no refresh token. It is empty when the
+	// session was refreshed or the refresh failed.
+	SessionNotRefreshable string
+
 	// Upstream tracks which upstream was used for this request
 	Upstream string
 }
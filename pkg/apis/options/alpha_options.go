@@ -43,6 +43,22 @@ type AlphaOptions struct {
 
 	// Providers is used to configure multiple providers.
 	Providers Providers `json:"providers,omitempty"`
+
+	// StepUpRoutes is used to require a recent or stronger authentication
+	// for requests to specific paths, such as an admin area.
+	// Requirements can also be set for whole upstreams within the
+	// UpstreamConfig.
+	StepUpRoutes []StepUpRoute `json:"stepUpRoutes,omitempty"`
+
+	// IdentityAssertion is used to send upstreams a JWT signed by the proxy
+	// that asserts the identity of the authenticated user.
+	IdentityAssertion *IdentityAssertion `json:"identityAssertion,omitempty"`
+
+	// CORS is used to allow frontends served from other origins to call the
+	// proxy endpoints and the upstreams.
+	// CORS can also be configured for individual upstreams within the
+	// UpstreamConfig.
+	CORS *CORS `json:"cors,omitempty"`
 }
 
 // MergeInto replaces alpha options in the Options struct with the values
@@ -54,6 +70,9 @@ func (a *AlphaOptions) MergeInto(opts *Options) {
 	opts.Server = a.Server
 	opts.MetricsServer = a.MetricsServer
 	opts.Providers = a.Providers
+	opts.StepUpRoutes = a.StepUpRoutes
+	opts.IdentityAssertion = a.IdentityAssertion
+	opts.CORS = a.CORS
 }
 
 // ExtractFrom populates the fields in the AlphaOptions with the values from
@@ -65,4 +84,7 @@ func (a *AlphaOptions) ExtractFrom(opts *Options) {
 	a.Server = opts.Server
 	a.MetricsServer = opts.MetricsServer
 	a.Providers = opts.Providers
+	a.StepUpRoutes = opts.StepUpRoutes
+	a.IdentityAssertion = opts.IdentityAssertion
+	a.CORS = opts.CORS
 }
@@ -8,17 +8,18 @@ import (
 
 // Cookie contains configuration options relating to Cookie configuration
 type Cookie struct {
-	Name           string        `flag:"cookie-name" cfg:"cookie_name"`
-	Secret         string        `flag:"cookie-secret" cfg:"cookie_secret"`
-	Domains        []string      `flag:"cookie-domain" cfg:"cookie_domains"`
-	Path           string        `flag:"cookie-path" cfg:"cookie_path"`
-	Expire         time.Duration `flag:"cookie-expire" cfg:"cookie_expire"`
-	Refresh        time.Duration `flag:"cookie-refresh" cfg:"cookie_refresh"`
-	Secure         bool          `flag:"cookie-secure" cfg:"cookie_secure"`
-	HTTPOnly       bool          `flag:"cookie-httponly" cfg:"cookie_httponly"`
-	SameSite       string        `flag:"cookie-samesite" cfg:"cookie_samesite"`
-	CSRFPerRequest bool          `flag:"cookie-csrf-per-request" cfg:"cookie_csrf_per_request"`
-	CSRFExpire     time.Duration `flag:"cookie-csrf-expire" cfg:"cookie_csrf_expire"`
+	Name            string        `flag:"cookie-name" cfg:"cookie_name"`
+	Secret          string        `flag:"cookie-secret" cfg:"cookie_secret"`
+	PreviousSecrets []string      `flag:"cookie-previous-secret" cfg:"cookie_previous_secrets"`
+	Domains         []string      `flag:"cookie-domain" cfg:"cookie_domains"`
+	Path            string        `flag:"cookie-path" cfg:"cookie_path"`
+	Expire          time.Duration `flag:"cookie-expire" cfg:"cookie_expire"`
+	Refresh         time.Duration `flag:"cookie-refresh" cfg:"cookie_refresh"`
+	Secure          bool          `flag:"cookie-secure" cfg:"cookie_secure"`
+	HTTPOnly        bool          `flag:"cookie-httponly" cfg:"cookie_httponly"`
+	SameSite        string        `flag:"cookie-samesite" cfg:"cookie_samesite"`
+	CSRFPerRequest  bool          `flag:"cookie-csrf-per-request" cfg:"cookie_csrf_per_request"`
+	CSRFExpire      time.Duration `flag:"cookie-csrf-expire" cfg:"cookie_csrf_expire"`
 }
 
 func cookieFlagSet() *pflag.FlagSet {
@@ -26,6 +27,7 @@ func cookieFlagSet() *pflag.FlagSet {
 
 	flagSet.String("cookie-name", "_oauth2_proxy", "the name of the cookie that the oauth_proxy creates")
 	flagSet.String("cookie-secret", "", "the seed string for secure cookies (optionally base64 encoded)")
+	flagSet.StringSlice("cookie-previous-secret", []string{}, "previous cookie secrets that are still accepted to validate and decrypt cookies while rotating the cookie secret (may be given multiple times)")
 	flagSet.StringSlice("cookie-domain", []string{}, "Optional cookie domains to force cookies to (ie: `.yourcompany.com`). The longest domain matching the request's host will be used (or the shortest cookie domain if there is no match).")
 	flagSet.String("cookie-path", "/", "an optional cookie path to force cookies to (ie: /poc/)*")
 	flagSet.Duration("cookie-expire", time.Duration(168)*time.Hour, "expire timeframe for cookie")
@@ -41,16 +43,24 @@ func cookieFlagSet() *pflag.FlagSet {
 // cookieDefaults creates a Cookie populating each field with its default value
 func cookieDefaults() Cookie {
 	return Cookie{
-		Name:           "_oauth2_proxy",
-		Secret:         "",
-		Domains:        nil,
-		Path:           "/",
-		Expire:         time.Duration(168) * time.Hour,
-		Refresh:        time.Duration(0),
-		Secure:         true,
-		HTTPOnly:       true,
-		SameSite:       "",
-		CSRFPerRequest: false,
-		CSRFExpire:     time.Duration(15) * time.Minute,
+		Name:            "_oauth2_proxy",
+		Secret:          "",
+		PreviousSecrets: nil,
+		Domains:         nil,
+		Path:            "/",
+		Expire:          time.Duration(168) * time.Hour,
+		Refresh:         time.Duration(0),
+		Secure:          true,
+		HTTPOnly:        true,
+		SameSite:        "",
+		CSRFPerRequest:  false,
+		CSRFExpire:      time.Duration(15) * time.Minute,
 	}
 }
+
+// Secrets returns the current cookie secret followed by any previous secrets.
+// The current secret is used to sign and encrypt new cookies, while all of
+// the secrets are accepted when validating and decrypting existing cookies.
+func (c *Cookie) Secrets() []string {
+	return append([]string{c.Secret}, c.PreviousSecrets...)
+}
@@ -0,0 +1,58 @@
+package options
+
+// CORS configures the Cross-Origin Resource Sharing headers returned to
+// browsers, so that frontends served from other origins can call the proxy
+// endpoints, such as `/oauth2/userinfo`, and the APIs behind the proxy.
+// CORS preflight requests are answered by the proxy without authentication.
+// The CORS headers of responses are set by the proxy, upstreams should not
+// set their own.
+//
+// Examples:
+//
+// # Allow an SPA to call an API with the session cookie
+//
+// ```
+// allowedOrigins:
+// - https://app.example.com
+// allowedMethods:
+// - GET
+// - POST
+// allowedHeaders:
+// - Content-Type
+// allowCredentials: true
+// maxAge: 10m
+// ```
+type CORS struct {
+	// AllowedOrigins is the list of origins allowed to make cross-origin
+	// requests, eg. `https://app.example.com`.
+	// A `*` within an origin matches one or more subdomains, eg.
+	// `https://*.example.com`, and `*` on its own matches any origin.
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+
+	// AllowedMethods is the list of methods allowed in cross-origin requests.
+	// Defaults to `GET`, `HEAD` and `POST`.
+	//+optional
+	AllowedMethods []string `json:"allowedMethods,omitempty"`
+
+	// AllowedHeaders is the list of request headers allowed in cross-origin
+	// requests, in addition to the headers that are always allowed by
+	// browsers. `*` allows any header.
+	//+optional
+	AllowedHeaders []string `json:"allowedHeaders,omitempty"`
+
+	// ExposedHeaders is the list of response headers that frontends are
+	// allowed to read, in addition to the headers that are always exposed by
+	// browsers.
+	//+optional
+	ExposedHeaders []string `json:"exposedHeaders,omitempty"`
+
+	// AllowCredentials allows cross-origin requests to include cookies, such
+	// as the session cookie. It cannot be combined with the `*` origin.
+	//+optional
+	AllowCredentials bool `json:"allowCredentials,omitempty"`
+
+	// MaxAge is how long browsers may cache the response to a preflight
+	// request.
+	//+optional
+	MaxAge *Duration `json:"maxAge,omitempty"`
+}
@@ -0,0 +1,58 @@
+package options
+
+// DefaultIdentityAssertionHeader is the request header the identity
+// assertion is sent in when no header is configured.
+const DefaultIdentityAssertionHeader = "X-Oauth2-Proxy-Jwt-Assertion"
+
+// IdentityAssertion configures a short-lived JWT, signed by the proxy, that
+// asserts the identity of the user to the upstream serving each request.
+// Unlike the `X-Forwarded-*` headers, the assertion cannot be forged by
+// anyone who can reach the upstream directly.
+// Any assertion header sent by the client is removed from the request.
+// The `/oauth2/auth`, `/oauth2/forward_auth` and `/oauth2/ext_authz`
+// endpoints return the assertion in the same header of their response, for
+// the upstream of the original request.
+//
+// The token carries the `sub` (user), `email`, `preferred_username` and
+// `groups` of the session, the configured claims, the ID of the upstream as
+// `aud` and the configured `iss`.
+// Upstreams can verify the token with the public keys published at
+// `/oauth2/jwks.json`.
+type IdentityAssertion struct {
+	// Issuer is the `iss` claim of the token, usually the URL of the proxy.
+	Issuer string `json:"issuer"`
+
+	// Header is the header the token is sent to the upstream in.
+	// Defaults to `X-Oauth2-Proxy-Jwt-Assertion`.
+	//+optional
+	Header string `json:"header,omitempty"`
+
+	// SigningKey is the RSA or EC private key, in PEM format, that signs the
+	// token. RSA keys sign with RS256, EC keys with ES256, ES384 or ES512
+	// according to their curve.
+	SigningKey SecretSource `json:"signingKey"`
+
+	// SigningKeyID is the `kid` of the signing key.
+	// Defaults to the JWK thumbprint (RFC 7638) of the key.
+	//+optional
+	SigningKeyID string `json:"signingKeyID,omitempty"`
+
+	// AdditionalPublicKeys are published at `/oauth2/jwks.json` alongside the
+	// signing key, in PEM format. Publish the key that is about to replace
+	// the signing key, or the key it replaced, so that tokens stay
+	// verifiable while keys are rotated.
+	// Their `kid` is the JWK thumbprint of the key.
+	//+optional
+	AdditionalPublicKeys []SecretSource `json:"additionalPublicKeys,omitempty"`
+
+	// Claims is a list of session claims added to the token, eg. `acr` or a
+	// claim from the provider's ID token. Claims with a single value are
+	// added as strings, other claims as lists.
+	//+optional
+	Claims []string `json:"claims,omitempty"`
+
+	// Lifetime is how long the token is valid for.
+	// Defaults to 2 minutes.
+	//+optional
+	Lifetime *Duration `json:"lifetime,omitempty"`
+}
@@ -485,11 +485,23 @@ type LegacyProvider struct {
 	ClientSecret     string `flag:"client-secret" cfg:"client_secret"`
 	ClientSecretFile string `flag:"client-secret-file" cfg:"client_secret_file"`
 
+	TokenEndpointAuthMethod string `flag:"token-endpoint-auth-method" cfg:"token_endpoint_auth_method"`
+	ClientAssertionKeyFile  string `flag:"client-assertion-key-file" cfg:"client_assertion_key_file"`
+	ClientAssertionKeyID    string `flag:"client-assertion-key-id" cfg:"client_assertion_key_id"`
+
 	KeycloakGroups                         []string `flag:"keycloak-group" cfg:"keycloak_groups"`
 	AzureTenant                            string   `flag:"azure-tenant" cfg:"azure_tenant"`
 	AzureGraphGroupField                   string   `flag:"azure-graph-group-field" cfg:"azure_graph_group_field"`
 	BitbucketTeam                          string   `flag:"bitbucket-team" cfg:"bitbucket_team"`
+	BitbucketWorkspaces                    []string `flag:"bitbucket-workspace" cfg:"bitbucket_workspaces"`
 	BitbucketRepository                    string   `flag:"bitbucket-repository" cfg:"bitbucket_repository"`
+	BitbucketRepositoryPermission          string   `flag:"bitbucket-repository-permission" cfg:"bitbucket_repository_permission"`
+	DigitalOceanTeams                      []string `flag:"digitalocean-team" cfg:"digitalocean_teams"`
+	GiteaBaseURL                           string   `flag:"gitea-base-url" cfg:"gitea_base_url"`
+	GiteaOrgs                              []string `flag:"gitea-org" cfg:"gitea_orgs"`
+	GiteaTeams                             []string `flag:"gitea-team" cfg:"gitea_teams"`
+	GiteaRepositories                      []string `flag:"gitea-repository" cfg:"gitea_repositories"`
+	GiteaRepositoryPermission              string   `flag:"gitea-repository-permission" cfg:"gitea_repository_permission"`
 	GitHubOrg                              string   `flag:"github-org" cfg:"github_org"`
 	GitHubTeam                             string   `flag:"github-team" cfg:"github_team"`
 	GitHubRepo                             string   `flag:"github-repo" cfg:"github_repo"`
@@ -497,6 +509,10 @@ type LegacyProvider struct {
 	GitHubUsers                            []string `flag:"github-user" cfg:"github_users"`
 	GitLabGroup                            []string `flag:"gitlab-group" cfg:"gitlab_groups"`
 	GitLabProjects                         []string `flag:"gitlab-project" cfg:"gitlab_projects"`
+	NextcloudGroups                        []string `flag:"nextcloud-group" cfg:"nextcloud_groups"`
+	NextcloudAdminOnly                     bool     `flag:"nextcloud-admin-only" cfg:"nextcloud_admin_only"`
+	NextcloudResolveGroupDisplayNames      bool     `flag:"nextcloud-resolve-group-display-names" cfg:"nextcloud_resolve_group_display_names"`
+	LinkedInLegacy                         bool     `flag:"linkedin-legacy" cfg:"linkedin_legacy"`
 	GoogleGroupsLegacy                     []string `flag:"google-group" cfg:"google_group"`
 	GoogleGroups                           []string `flag:"google-group" cfg:"google_groups"`
 	GoogleAdminEmail                       string   `flag:"google-admin-email" cfg:"google_admin_email"`
@@ -506,33 +522,37 @@ type LegacyProvider struct {
 
 	// These options allow for other providers besides Google, with
 	// potential overrides.
-	ProviderType                       string   `flag:"provider" cfg:"provider"`
-	ProviderName                       string   `flag:"provider-display-name" cfg:"provider_display_name"`
-	ProviderCAFiles                    []string `flag:"provider-ca-file" cfg:"provider_ca_files"`
-	UseSystemTrustStore                bool     `flag:"use-system-trust-store" cfg:"use_system_trust_store"`
-	OIDCIssuerURL                      string   `flag:"oidc-issuer-url" cfg:"oidc_issuer_url"`
-	InsecureOIDCAllowUnverifiedEmail   bool     `flag:"insecure-oidc-allow-unverified-email" cfg:"insecure_oidc_allow_unverified_email"`
-	InsecureOIDCSkipIssuerVerification bool     `flag:"insecure-oidc-skip-issuer-verification" cfg:"insecure_oidc_skip_issuer_verification"`
-	InsecureOIDCSkipNonce              bool     `flag:"insecure-oidc-skip-nonce" cfg:"insecure_oidc_skip_nonce"`
-	SkipOIDCDiscovery                  bool     `flag:"skip-oidc-discovery" cfg:"skip_oidc_discovery"`
-	OIDCJwksURL                        string   `flag:"oidc-jwks-url" cfg:"oidc_jwks_url"`
-	OIDCEmailClaim                     string   `flag:"oidc-email-claim" cfg:"oidc_email_claim"`
-	OIDCGroupsClaim                    string   `flag:"oidc-groups-claim" cfg:"oidc_groups_claim"`
-	OIDCAudienceClaims                 []string `flag:"oidc-audience-claim" cfg:"oidc_audience_claims"`
-	OIDCExtraAudiences                 []string `flag:"oidc-extra-audience" cfg:"oidc_extra_audiences"`
-	LoginURL                           string   `flag:"login-url" cfg:"login_url"`
-	RedeemURL                          string   `flag:"redeem-url" cfg:"redeem_url"`
-	ProfileURL                         string   `flag:"profile-url" cfg:"profile_url"`
-	SkipClaimsFromProfileURL           bool     `flag:"skip-claims-from-profile-url" cfg:"skip_claims_from_profile_url"`
-	ProtectedResource                  string   `flag:"resource" cfg:"resource"`
-	ValidateURL                        string   `flag:"validate-url" cfg:"validate_url"`
-	Scope                              string   `flag:"scope" cfg:"scope"`
-	Prompt                             string   `flag:"prompt" cfg:"prompt"`
-	ApprovalPrompt                     string   `flag:"approval-prompt" cfg:"approval_prompt"` // Deprecated by OIDC 1.0
-	UserIDClaim                        string   `flag:"user-id-claim" cfg:"user_id_claim"`
-	AllowedGroups                      []string `flag:"allowed-group" cfg:"allowed_groups"`
-	AllowedRoles                       []string `flag:"allowed-role" cfg:"allowed_roles"`
-	BackendLogoutURL                   string   `flag:"backend-logout-url" cfg:"backend_logout_url"`
+	ProviderType                       string        `flag:"provider" cfg:"provider"`
+	ProviderName                       string        `flag:"provider-display-name" cfg:"provider_display_name"`
+	ProviderCAFiles                    []string      `flag:"provider-ca-file" cfg:"provider_ca_files"`
+	UseSystemTrustStore                bool          `flag:"use-system-trust-store" cfg:"use_system_trust_store"`
+	OIDCIssuerURL                      string        `flag:"oidc-issuer-url" cfg:"oidc_issuer_url"`
+	InsecureOIDCAllowUnverifiedEmail   bool          `flag:"insecure-oidc-allow-unverified-email" cfg:"insecure_oidc_allow_unverified_email"`
+	InsecureOIDCSkipIssuerVerification bool          `flag:"insecure-oidc-skip-issuer-verification" cfg:"insecure_oidc_skip_issuer_verification"`
+	InsecureOIDCSkipNonce              bool          `flag:"insecure-oidc-skip-nonce" cfg:"insecure_oidc_skip_nonce"`
+	SkipOIDCDiscovery                  bool          `flag:"skip-oidc-discovery" cfg:"skip_oidc_discovery"`
+	OIDCJwksURL                        string        `flag:"oidc-jwks-url" cfg:"oidc_jwks_url"`
+	LazyOIDCDiscovery                  bool          `flag:"oidc-lazy-discovery" cfg:"oidc_lazy_discovery"`
+	OIDCDiscoveryRefreshInterval       time.Duration `flag:"oidc-discovery-refresh-interval" cfg:"oidc_discovery_refresh_interval"`
+	OIDCEmailClaim                     string        `flag:"oidc-email-claim" cfg:"oidc_email_claim"`
+	OIDCGroupsClaim                    string        `flag:"oidc-groups-claim" cfg:"oidc_groups_claim"`
+	OIDCAudienceClaims                 []string      `flag:"oidc-audience-claim" cfg:"oidc_audience_claims"`
+	OIDCExtraAudiences                 []string      `flag:"oidc-extra-audience" cfg:"oidc_extra_audiences"`
+	LoginURL                           string        `flag:"login-url" cfg:"login_url"`
+	RedeemURL                          string        `flag:"redeem-url" cfg:"redeem_url"`
+	PushedAuthorizationRequestURL      string        `flag:"pushed-authorization-request-url" cfg:"pushed_authorization_request_url"`
+	PushedAuthorizationRequests        string        `flag:"pushed-authorization-requests" cfg:"pushed_authorization_requests"`
+	ProfileURL                         string        `flag:"profile-url" cfg:"profile_url"`
+	SkipClaimsFromProfileURL           bool          `flag:"skip-claims-from-profile-url" cfg:"skip_claims_from_profile_url"`
+	ProtectedResource                  string        `flag:"resource" cfg:"resource"`
+	ValidateURL                        string        `flag:"validate-url" cfg:"validate_url"`
+	Scope                              string        `flag:"scope" cfg:"scope"`
+	Prompt                             string        `flag:"prompt" cfg:"prompt"`
+	ApprovalPrompt                     string        `flag:"approval-prompt" cfg:"approval_prompt"` // Deprecated by OIDC 1.0
+	UserIDClaim                        string        `flag:"user-id-claim" cfg:"user_id_claim"`
+	AllowedGroups                      []string      `flag:"allowed-group" cfg:"allowed_groups"`
+	AllowedRoles                       []string      `flag:"allowed-role" cfg:"allowed_roles"`
+	BackendLogoutURL                   string        `flag:"backend-logout-url" cfg:"backend_logout_url"`
 
 	AcrValues  string `flag:"acr-values" cfg:"acr_values"`
 	JWTKey     string `flag:"jwt-key" cfg:"jwt_key"`
@@ -550,18 +570,33 @@ func legacyProviderFlagSet() *pflag.FlagSet {
 	flagSet.StringSlice("keycloak-group", []string{}, "restrict logins to members of these groups (may be given multiple times)")
 	flagSet.String("azure-tenant", "common", "go to a tenant-specific or common (tenant-independent) endpoint.")
 	flagSet.String("azure-graph-group-field", "", "configures the group field to be used when building the groups list(`id` or `displayName`. Default is `id`) from Microsoft Graph(available only for v2.0 oidc url). Based on this value, the `allowed-group` config values should be adjusted accordingly. If using `id` as group field, `allowed-group` should contains groups IDs, if using `displayName` as group field, `allowed-group` should contains groups name")
-	flagSet.String("bitbucket-team", "", "restrict logins to members of this team")
-	flagSet.String("bitbucket-repository", "", "restrict logins to user with access to this repository")
+	flagSet.String("bitbucket-team", "", "restrict logins to members of this team (deprecated: use bitbucket-workspace)")
+	flagSet.StringSlice("bitbucket-workspace", []string{}, "restrict logins to members of this workspace (may be given multiple times)")
+	flagSet.String("bitbucket-repository", "", "restrict logins to user with access to this repository, given as workspace/repository")
+	flagSet.String("bitbucket-repository-permission", "", "the minimum permission required on the bitbucket-repository: read, write or admin (default read)")
+	flagSet.StringSlice("digitalocean-team", []string{}, "restrict logins to users of this team, given by its UUID (may be given multiple times)")
+	flagSet.String("gitea-base-url", "", "the URL of the Gitea server (default https://gitea.com)")
+	flagSet.StringSlice("gitea-org", []string{}, "restrict logins to members of this organization (may be given multiple times)")
+	flagSet.StringSlice("gitea-team", []string{}, "restrict logins to members of this team, given as org/team (may be given multiple times)")
+	flagSet.StringSlice("gitea-repository", []string{}, "restrict logins to users with access to this repository, given as owner/repo (may be given multiple times)")
+	flagSet.String("gitea-repository-permission", "", "the minimum permission required on a gitea-repository: read, write or admin (default read)")
 	flagSet.String("github-org", "", "restrict logins to members of this organisation")
 	flagSet.String("github-team", "", "restrict logins to members of this team")
 	flagSet.String("github-repo", "", "restrict logins to collaborators of this repository")
 	flagSet.String("github-token", "", "the token to use when verifying repository collaborators (must have push access to the repository)")
 	flagSet.StringSlice("github-user", []string{}, "allow users with these usernames to login even if they do not belong to the specified org and team or collaborators (may be given multiple times)")
-	flagSet.StringSlice("gitlab-group", []string{}, "restrict logins to members of this group (may be given multiple times)")
+	flagSet.StringSlice("gitlab-group", []string{}, "restrict logins to members of this group (may be given multiple times) (eg `group=role` to require a minimum role: guest, reporter, developer, maintainer or owner)")
 	flagSet.StringSlice("gitlab-project", []string{}, "restrict logins to members of this project (may be given multiple times) (eg `group/project=accesslevel`). Access level should be a value matching Gitlab access levels (see https://docs.gitlab.com/ee/api/members.html#valid-access-levels), defaulted to 20 if absent")
+	flagSet.StringSlice("nextcloud-group", []string{}, "restrict logins to members of this group (may be given multiple times)")
+	flagSet.Bool("nextcloud-admin-only", false, "restrict logins to Nextcloud administrators")
+	flagSet.Bool("nextcloud-resolve-group-display-names", false, "add the display names of the user's Nextcloud groups to the session groups")
+	flagSet.Bool("linkedin-legacy", false, "use the retired LinkedIn profile API instead of Sign In with LinkedIn using OpenID Connect")
 	flagSet.String("client-id", "", "the OAuth Client ID: ie: \"123456.apps.googleusercontent.com\"")
 	flagSet.String("client-secret", "", "the OAuth Client Secret")
 	flagSet.String("client-secret-file", "", "the file with OAuth Client Secret")
+	flagSet.String("token-endpoint-auth-method", "", "how the client authenticates to the token endpoint: client_secret_basic, client_secret_post, client_secret_jwt or private_key_jwt")
+	flagSet.String("client-assertion-key-file", "", "the file with the PEM private key signing the client assertion of the private_key_jwt method")
+	flagSet.String("client-assertion-key-id", "", "the kid of the client assertion key")
 
 	flagSet.String("provider", "google", "OAuth provider")
 	flagSet.String("provider-display-name", "", "Provider display name")
@@ -573,12 +608,16 @@ func legacyProviderFlagSet() *pflag.FlagSet {
 	flagSet.Bool("insecure-oidc-skip-nonce", true, "skip verifying the OIDC ID Token's nonce claim")
 	flagSet.Bool("skip-oidc-discovery", false, "Skip OIDC discovery and use manually supplied Endpoints")
 	flagSet.String("oidc-jwks-url", "", "OpenID Connect JWKS URL (ie: https://www.googleapis.com/oauth2/v3/certs)")
+	flagSet.Bool("oidc-lazy-discovery", false, "retry the OIDC discovery in the background instead of failing to start when the provider is unreachable")
+	flagSet.Duration("oidc-discovery-refresh-interval", time.Duration(0), "period between refreshes of the OIDC discovery metadata and JWKS; changed endpoints are only logged and need a restart; 0 to disable")
 	flagSet.String("oidc-groups-claim", OIDCGroupsClaim, "which OIDC claim contains the user groups")
 	flagSet.String("oidc-email-claim", OIDCEmailClaim, "which OIDC claim contains the user's email")
 	flagSet.StringSlice("oidc-audience-claim", OIDCAudienceClaims, "which OIDC claims are used as audience to verify against client id")
 	flagSet.StringSlice("oidc-extra-audience", []string{}, "additional audiences allowed to pass audience verification")
 	flagSet.String("login-url", "", "Authentication endpoint")
 	flagSet.String("redeem-url", "", "Token redemption endpoint")
+	flagSet.String("pushed-authorization-request-url", "", "Pushed authorization request endpoint (discovered for OIDC providers)")
+	flagSet.String("pushed-authorization-requests", "", "whether to push the authorization request parameters to the provider: required, preferred or off (default off)")
 	flagSet.String("profile-url", "", "Profile access endpoint")
 	flagSet.Bool("skip-claims-from-profile-url", false, "Skip loading missing claims from profile URL")
 	flagSet.String("resource", "", "The resource that is protected (Azure AD only)")
@@ -662,22 +701,26 @@ func (l *LegacyProvider) convert() (Providers, error) {
 	providers := Providers{}
 
 	provider := Provider{
-		ClientID:                 l.ClientID,
-		ClientSecret:             l.ClientSecret,
-		ClientSecretFile:         l.ClientSecretFile,
-		Type:                     ProviderType(l.ProviderType),
-		CAFiles:                  l.ProviderCAFiles,
-		UseSystemTrustStore:      l.UseSystemTrustStore,
-		LoginURL:                 l.LoginURL,
-		RedeemURL:                l.RedeemURL,
-		ProfileURL:               l.ProfileURL,
-		SkipClaimsFromProfileURL: l.SkipClaimsFromProfileURL,
-		ProtectedResource:        l.ProtectedResource,
-		ValidateURL:              l.ValidateURL,
-		Scope:                    l.Scope,
-		AllowedGroups:            l.AllowedGroups,
-		CodeChallengeMethod:      l.CodeChallengeMethod,
-		BackendLogoutURL:         l.BackendLogoutURL,
+		ClientID:                      l.ClientID,
+		ClientSecret:                  l.ClientSecret,
+		ClientSecretFile:              l.ClientSecretFile,
+		TokenEndpointAuthMethod:       TokenEndpointAuthMethod(l.TokenEndpointAuthMethod),
+		ClientAssertionKeyID:          l.ClientAssertionKeyID,
+		Type:                          ProviderType(l.ProviderType),
+		CAFiles:                       l.ProviderCAFiles,
+		UseSystemTrustStore:           l.UseSystemTrustStore,
+		LoginURL:                      l.LoginURL,
+		RedeemURL:                     l.RedeemURL,
+		PushedAuthorizationRequestURL: l.PushedAuthorizationRequestURL,
+		PushedAuthorizationRequests:   PushedAuthorizationRequestsMode(l.PushedAuthorizationRequests),
+		ProfileURL:                    l.ProfileURL,
+		SkipClaimsFromProfileURL:      l.SkipClaimsFromProfileURL,
+		ProtectedResource:             l.ProtectedResource,
+		ValidateURL:                   l.ValidateURL,
+		Scope:                         l.Scope,
+		AllowedGroups:                 l.AllowedGroups,
+		CodeChallengeMethod:           l.CodeChallengeMethod,
+		BackendLogoutURL:              l.BackendLogoutURL,
 	}
 
 	// This part is out of the switch section for all providers that support OIDC
@@ -688,6 +731,7 @@ func (l *LegacyProvider) convert() (Providers, error) {
 		InsecureSkipNonce:              l.InsecureOIDCSkipNonce,
 		SkipDiscovery:                  l.SkipOIDCDiscovery,
 		JwksURL:                        l.OIDCJwksURL,
+		LazyDiscovery:                  l.LazyOIDCDiscovery,
 		UserIDClaim:                    l.UserIDClaim,
 		EmailClaim:                     l.OIDCEmailClaim,
 		GroupsClaim:                    l.OIDCGroupsClaim,
@@ -695,6 +739,17 @@ func (l *LegacyProvider) convert() (Providers, error) {
 		ExtraAudiences:                 l.OIDCExtraAudiences,
 	}
 
+	if l.OIDCDiscoveryRefreshInterval != 0 {
+		refreshInterval := Duration(l.OIDCDiscoveryRefreshInterval)
+		provider.OIDCConfig.DiscoveryRefreshInterval = &refreshInterval
+	}
+
+	if l.ClientAssertionKeyFile != "" {
+		provider.ClientAssertionKey = &SecretSource{
+			FromFile: l.ClientAssertionKeyFile,
+		}
+	}
+
 	// Support for legacy configuration option
 	if l.ForceCodeChallengeMethod != "" && l.CodeChallengeMethod == "" {
 		provider.CodeChallengeMethod = l.ForceCodeChallengeMethod
@@ -716,6 +771,14 @@ func (l *LegacyProvider) convert() (Providers, error) {
 			Token: l.GitHubToken,
 			Users: l.GitHubUsers,
 		}
+	case "gitea":
+		provider.GiteaConfig = GiteaOptions{
+			BaseURL:              l.GiteaBaseURL,
+			Orgs:                 l.GiteaOrgs,
+			Teams:                l.GiteaTeams,
+			Repositories:         l.GiteaRepositories,
+			RepositoryPermission: l.GiteaRepositoryPermission,
+		}
 	case "keycloak-oidc":
 		provider.KeycloakConfig = KeycloakOptions{
 			Groups: l.KeycloakGroups,
@@ -730,6 +793,16 @@ func (l *LegacyProvider) convert() (Providers, error) {
 			Group:    l.GitLabGroup,
 			Projects: l.GitLabProjects,
 		}
+	case "linkedin":
+		provider.LinkedInConfig = LinkedInOptions{
+			Legacy: l.LinkedInLegacy,
+		}
+	case "nextcloud":
+		provider.NextcloudConfig = NextcloudOptions{
+			Groups:                   l.NextcloudGroups,
+			AdminOnly:                l.NextcloudAdminOnly,
+			ResolveGroupDisplayNames: l.NextcloudResolveGroupDisplayNames,
+		}
 	case "login.gov":
 		provider.LoginGovConfig = LoginGovOptions{
 			JWTKey:     l.JWTKey,
@@ -738,8 +811,14 @@ func (l *LegacyProvider) convert() (Providers, error) {
 		}
 	case "bitbucket":
 		provider.BitbucketConfig = BitbucketOptions{
-			Team:       l.BitbucketTeam,
-			Repository: l.BitbucketRepository,
+			Team:                 l.BitbucketTeam,
+			Workspaces:           l.BitbucketWorkspaces,
+			Repository:           l.BitbucketRepository,
+			RepositoryPermission: l.BitbucketRepositoryPermission,
+		}
+	case "digitalocean":
+		provider.DigitalOceanConfig = DigitalOceanOptions{
+			Teams: l.DigitalOceanTeams,
 		}
 	case "google":
 		if len(l.GoogleGroupsLegacy) != 0 && !reflect.DeepEqual(l.GoogleGroupsLegacy, l.GoogleGroups) {
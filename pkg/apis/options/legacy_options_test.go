@@ -998,6 +998,142 @@ var _ = Describe("Legacy Options", func() {
 			GoogleServiceAccountJSON: "test.json",
 			GoogleGroupsLegacy:       []string{"1", "2"},
 		}
+
+		giteaProvider := Provider{
+			ID:       "gitea=" + clientID,
+			ClientID: clientID,
+			Type:     "gitea",
+			GiteaConfig: GiteaOptions{
+				BaseURL:              "https://gitea.example.com",
+				Orgs:                 []string{"oauth2-proxy"},
+				Teams:                []string{"oauth2-proxy/maintainers"},
+				Repositories:         []string{"oauth2-proxy/oauth2-proxy"},
+				RepositoryPermission: "write",
+			},
+			LoginURLParameters: defaultURLParams,
+		}
+
+		giteaLegacyProvider := LegacyProvider{
+			ClientID:                  clientID,
+			ProviderType:              "gitea",
+			GiteaBaseURL:              "https://gitea.example.com",
+			GiteaOrgs:                 []string{"oauth2-proxy"},
+			GiteaTeams:                []string{"oauth2-proxy/maintainers"},
+			GiteaRepositories:         []string{"oauth2-proxy/oauth2-proxy"},
+			GiteaRepositoryPermission: "write",
+		}
+
+		bitbucketProvider := Provider{
+			ID:       "bitbucket=" + clientID,
+			ClientID: clientID,
+			Type:     "bitbucket",
+			BitbucketConfig: BitbucketOptions{
+				Workspaces:           []string{"oauth2-proxy"},
+				Repository:           "oauth2-proxy/oauth2-proxy",
+				RepositoryPermission: "write",
+			},
+			LoginURLParameters: defaultURLParams,
+		}
+
+		bitbucketLegacyProvider := LegacyProvider{
+			ClientID:                      clientID,
+			ProviderType:                  "bitbucket",
+			BitbucketWorkspaces:           []string{"oauth2-proxy"},
+			BitbucketRepository:           "oauth2-proxy/oauth2-proxy",
+			BitbucketRepositoryPermission: "write",
+		}
+
+		digitalOceanProvider := Provider{
+			ID:       "digitalocean=" + clientID,
+			ClientID: clientID,
+			Type:     "digitalocean",
+			DigitalOceanConfig: DigitalOceanOptions{
+				Teams: []string{"oauth2-proxy"},
+			},
+			LoginURLParameters: defaultURLParams,
+		}
+
+		digitalOceanLegacyProvider := LegacyProvider{
+			ClientID:          clientID,
+			ProviderType:      "digitalocean",
+			DigitalOceanTeams: []string{"oauth2-proxy"},
+		}
+
+		nextcloudProvider := Provider{
+			ID:       "nextcloud=" + clientID,
+			ClientID: clientID,
+			Type:     "nextcloud",
+			NextcloudConfig: NextcloudOptions{
+				Groups:                   []string{"Engineering"},
+				AdminOnly:                true,
+				ResolveGroupDisplayNames: true,
+			},
+			LoginURLParameters: defaultURLParams,
+		}
+
+		nextcloudLegacyProvider := LegacyProvider{
+			ClientID:                          clientID,
+			ProviderType:                      "nextcloud",
+			NextcloudGroups:                   []string{"Engineering"},
+			NextcloudAdminOnly:                true,
+			NextcloudResolveGroupDisplayNames: true,
+		}
+
+		privateKeyJWTProvider := Provider{
+			ID:                      "oidc=" + clientID,
+			ClientID:                clientID,
+			Type:                    "oidc",
+			TokenEndpointAuthMethod: PrivateKeyJWT,
+			ClientAssertionKey: &SecretSource{
+				FromFile: "/etc/oauth2-proxy/client.key",
+			},
+			ClientAssertionKeyID: "key-1",
+			LoginURLParameters:   defaultURLParams,
+		}
+
+		privateKeyJWTLegacyProvider := LegacyProvider{
+			ClientID:                clientID,
+			ProviderType:            "oidc",
+			TokenEndpointAuthMethod: "private_key_jwt",
+			ClientAssertionKeyFile:  "/etc/oauth2-proxy/client.key",
+			ClientAssertionKeyID:    "key-1",
+		}
+
+		discoveryRefreshInterval := Duration(time.Hour)
+		lazyDiscoveryProvider := Provider{
+			ID:       "oidc=" + clientID,
+			ClientID: clientID,
+			Type:     "oidc",
+			OIDCConfig: OIDCOptions{
+				IssuerURL:                "https://idp.example.com",
+				LazyDiscovery:            true,
+				DiscoveryRefreshInterval: &discoveryRefreshInterval,
+			},
+			LoginURLParameters: defaultURLParams,
+		}
+
+		lazyDiscoveryLegacyProvider := LegacyProvider{
+			ClientID:                     clientID,
+			ProviderType:                 "oidc",
+			OIDCIssuerURL:                "https://idp.example.com",
+			LazyOIDCDiscovery:            true,
+			OIDCDiscoveryRefreshInterval: time.Hour,
+		}
+
+		linkedInLegacyProvider := LegacyProvider{
+			ClientID:       clientID,
+			ProviderType:   "linkedin",
+			LinkedInLegacy: true,
+		}
+
+		linkedInProvider := Provider{
+			ID:                 "linkedin=" + clientID,
+			ClientID:           clientID,
+			Type:               "linkedin",
+			LinkedInConfig:     LinkedInOptions{Legacy: true},
+			LoginURLParameters: defaultURLParams,
+		}
+
 		DescribeTable("convertLegacyProviders",
 			func(in *convertProvidersTableInput) {
 				providers, err := in.legacyProvider.convert()
@@ -1036,6 +1172,41 @@ var _ = Describe("Legacy Options", func() {
 				expectedProviders: Providers{internalConfigProvider},
 				errMsg:            "",
 			}),
+			Entry("with gitea provider config", &convertProvidersTableInput{
+				legacyProvider:    giteaLegacyProvider,
+				expectedProviders: Providers{giteaProvider},
+				errMsg:            "",
+			}),
+			Entry("with bitbucket provider config", &convertProvidersTableInput{
+				legacyProvider:    bitbucketLegacyProvider,
+				expectedProviders: Providers{bitbucketProvider},
+				errMsg:            "",
+			}),
+			Entry("with digitalocean provider config", &convertProvidersTableInput{
+				legacyProvider:    digitalOceanLegacyProvider,
+				expectedProviders: Providers{digitalOceanProvider},
+				errMsg:            "",
+			}),
+			Entry("with nextcloud provider config", &convertProvidersTableInput{
+				legacyProvider:    nextcloudLegacyProvider,
+				expectedProviders: Providers{nextcloudProvider},
+				errMsg:            "",
+			}),
+			Entry("with private_key_jwt token endpoint auth config", &convertProvidersTableInput{
+				legacyProvider:    privateKeyJWTLegacyProvider,
+				expectedProviders: Providers{privateKeyJWTProvider},
+				errMsg:            "",
+			}),
+			Entry("with lazy OIDC discovery config", &convertProvidersTableInput{
+				legacyProvider:    lazyDiscoveryLegacyProvider,
+				expectedProviders: Providers{lazyDiscoveryProvider},
+				errMsg:            "",
+			}),
+			Entry("with linkedin legacy provider config", &convertProvidersTableInput{
+				legacyProvider:    linkedInLegacyProvider,
+				expectedProviders: Providers{linkedInProvider},
+				errMsg:            "",
+			}),
 		)
 	})
 })
@@ -25,6 +25,19 @@ type Provider struct {
 	// ClientSecretFile is the name of the file
 	// containing the OAuth Client Secret, it will be used if ClientSecret is not set.
 	ClientSecretFile string `json:"clientSecretFile,omitempty"`
+	// TokenEndpointAuthMethod is how the client authenticates to the token
+	// endpoint of the provider when redeeming codes, refreshing and exchanging
+	// tokens: client_secret_basic, client_secret_post, client_secret_jwt or
+	// private_key_jwt.
+	// By default, each provider authenticates the way its IdP expects, with
+	// the client secret.
+	TokenEndpointAuthMethod TokenEndpointAuthMethod `json:"tokenEndpointAuthMethod,omitempty"`
+	// ClientAssertionKey is the RSA or EC private key, in PEM format, that
+	// signs the client assertion of the private_key_jwt method.
+	ClientAssertionKey *SecretSource `json:"clientAssertionKey,omitempty"`
+	// ClientAssertionKeyID is the `kid` of the client assertion key, as
+	// registered with the provider.
+	ClientAssertionKeyID string `json:"clientAssertionKeyID,omitempty"`
 
 	// KeycloakConfig holds all configurations for Keycloak provider.
 	KeycloakConfig KeycloakOptions `json:"keycloakConfig,omitempty"`
@@ -34,6 +47,10 @@ type Provider struct {
 	ADFSConfig ADFSOptions `json:"ADFSConfig,omitempty"`
 	// BitbucketConfig holds all configurations for Bitbucket provider.
 	BitbucketConfig BitbucketOptions `json:"bitbucketConfig,omitempty"`
+	// DigitalOceanConfig holds all configurations for DigitalOcean provider.
+	DigitalOceanConfig DigitalOceanOptions `json:"digitalOceanConfig,omitempty"`
+	// GiteaConfig holds all configurations for Gitea provider.
+	GiteaConfig GiteaOptions `json:"giteaConfig,omitempty"`
 	// GitHubConfig holds all configurations for GitHubC provider.
 	GitHubConfig GitHubOptions `json:"githubConfig,omitempty"`
 	// GitLabConfig holds all configurations for GitLab provider.
@@ -43,8 +60,14 @@ type Provider struct {
 	// OIDCConfig holds all configurations for OIDC provider
 	// or providers utilize OIDC configurations.
 	OIDCConfig OIDCOptions `json:"oidcConfig,omitempty"`
+	// LinkedInConfig holds all configurations for LinkedIn provider.
+	LinkedInConfig LinkedInOptions `json:"linkedInConfig,omitempty"`
+	// NextcloudConfig holds all configurations for Nextcloud provider.
+	NextcloudConfig NextcloudOptions `json:"nextcloudConfig,omitempty"`
 	// LoginGovConfig holds all configurations for LoginGov provider.
 	LoginGovConfig LoginGovOptions `json:"loginGovConfig,omitempty"`
+	// GenericOAuth2Config holds all configurations for the generic OAuth2 provider.
+	GenericOAuth2Config GenericOAuth2Options `json:"genericOAuth2Config,omitempty"`
 
 	// ID should be a unique identifier for the provider.
 	// This value is required for all providers.
@@ -68,6 +91,14 @@ type Provider struct {
 	LoginURLParameters []LoginURLParameter `json:"loginURLParameters,omitempty"`
 	// RedeemURL is the token redemption endpoint
 	RedeemURL string `json:"redeemURL,omitempty"`
+	// PushedAuthorizationRequestURL is the pushed authorization request
+	// endpoint (RFC 9126). It is discovered for OIDC providers that advertise it.
+	PushedAuthorizationRequestURL string `json:"pushedAuthorizationRequestURL,omitempty"`
+	// PushedAuthorizationRequests sets whether the authorization request
+	// parameters are pushed to the pushed authorization request endpoint
+	// rather than sent through the browser: required, preferred or off.
+	// Defaults to off.
+	PushedAuthorizationRequests PushedAuthorizationRequestsMode `json:"pushedAuthorizationRequests,omitempty"`
 	// ProfileURL is the profile access endpoint
 	ProfileURL string `json:"profileURL,omitempty"`
 	// SkipClaimsFromProfileURL allows to skip request to Profile URL for resolving claims not present in id_token
@@ -87,14 +118,20 @@ type Provider struct {
 	// Allows additional claims to be obtained from the `id_token`.
 	AllowAdditionalClaims []string `json:"allowAdditionalClaims,omitempty"`
 
+	// RequiredClaims is a list of claims that must be present in the `id_token`
+	// (or profile URL) with an acceptable value for a user to be authorized.
+	// They are checked at login and again each time the session is refreshed.
+	// Only OIDC based providers support required claims.
+	RequiredClaims []RequiredClaim `json:"requiredClaims,omitempty"`
+
 	// URL to call to perform backend logout, `{id_token}` would be replaced by the actual `id_token` if available in the session
 	BackendLogoutURL string `json:"backendLogoutURL"`
 }
 
 // ProviderType is used to enumerate the different provider type options
-// Valid options are: adfs, azure, bitbucket, digitalocean facebook, github,
-// gitlab, google, keycloak, keycloak-oidc, linkedin, login.gov, nextcloud
-// and oidc.
+// Valid options are: adfs, azure, bitbucket, digitalocean facebook,
+// generic-oauth2, gitea, github, gitlab, google, keycloak, keycloak-oidc,
+// linkedin, login.gov, nextcloud and oidc.
 type ProviderType string
 
 const (
@@ -113,6 +150,12 @@ const (
 	// FacebookProvider is the provider type for Facebook
 	FacebookProvider ProviderType = "facebook"
 
+	// GenericOAuth2Provider is the provider type for plain OAuth2 providers
+	GenericOAuth2Provider ProviderType = "generic-oauth2"
+
+	// GiteaProvider is the provider type for Gitea and Forgejo
+	GiteaProvider ProviderType = "gitea"
+
 	// GitHubProvider is the provider type for GitHub
 	GitHubProvider ProviderType = "github"
 
@@ -141,6 +184,46 @@ const (
 	OIDCProvider ProviderType = "oidc"
 )
 
+// PushedAuthorizationRequestsMode is used to enumerate the modes of pushed
+// authorization requests.
+// Valid options are: required, preferred and off.
+type PushedAuthorizationRequestsMode string
+
+const (
+	// PushedAuthorizationRequestsRequired always pushes the authorization
+	// request, and fails the login when it cannot be pushed
+	PushedAuthorizationRequestsRequired PushedAuthorizationRequestsMode = "required"
+
+	// PushedAuthorizationRequestsPreferred pushes the authorization request
+	// when the provider has a pushed authorization request endpoint, and sends
+	// it through the browser when it cannot be pushed
+	PushedAuthorizationRequestsPreferred PushedAuthorizationRequestsMode = "preferred"
+
+	// PushedAuthorizationRequestsOff sends the authorization request through
+	// the browser
+	PushedAuthorizationRequestsOff PushedAuthorizationRequestsMode = "off"
+)
+
+// TokenEndpointAuthMethod is used to enumerate the client authentication
+// methods of the token endpoint (OpenID Connect Core 1.0, section 9).
+// Valid options are: client_secret_basic, client_secret_post,
+// client_secret_jwt and private_key_jwt.
+type TokenEndpointAuthMethod string
+
+const (
+	// ClientSecretBasic sends the client secret with HTTP Basic authentication
+	ClientSecretBasic TokenEndpointAuthMethod = "client_secret_basic"
+
+	// ClientSecretPost sends the client secret in the request body
+	ClientSecretPost TokenEndpointAuthMethod = "client_secret_post"
+
+	// ClientSecretJWT sends a client assertion signed with the client secret
+	ClientSecretJWT TokenEndpointAuthMethod = "client_secret_jwt"
+
+	// PrivateKeyJWT sends a client assertion signed with the client assertion key
+	PrivateKeyJWT TokenEndpointAuthMethod = "private_key_jwt"
+)
+
 type KeycloakOptions struct {
 	// Group enables to restrict login to members of indicated group
 	Groups []string `json:"groups,omitempty"`
@@ -166,9 +249,103 @@ type ADFSOptions struct {
 
 type BitbucketOptions struct {
 	// Team sets restrict logins to members of this team
+	// Deprecated: Bitbucket teams are now workspaces, use Workspaces
 	Team string `json:"team,omitempty"`
-	// Repository sets restrict logins to user with access to this repository
+	// Workspaces restricts logins to members of these workspaces, given by
+	// their slug
+	Workspaces []string `json:"workspaces,omitempty"`
+	// Repository sets restrict logins to user with access to this repository,
+	// given as `workspace/repository`
 	Repository string `json:"repository,omitempty"`
+	// RepositoryPermission is the minimum permission a user needs on the
+	// Repository, one of `read`, `write` or `admin`.
+	// Default value is 'read'
+	RepositoryPermission string `json:"repositoryPermission,omitempty"`
+}
+
+type DigitalOceanOptions struct {
+	// Teams restricts logins to users authorizing one of these teams, given
+	// by their UUID. Team names are not unique and are not accepted.
+	Teams []string `json:"teams,omitempty"`
+}
+
+// GenericOAuth2Options configures how the generic OAuth2 provider builds a
+// session from the responses of the provider's API.
+type GenericOAuth2Options struct {
+	// ClaimMapping maps the response of the ProfileURL to the session.
+	// When not set, `sub`, `email`, `preferred_username` and `groups`
+	// are read from the top level of the response.
+	ClaimMapping GenericOAuth2ClaimMapping `json:"claimMapping,omitempty"`
+	// ExtraRequests are made with the user's access token after the
+	// ProfileURL, eg. to fetch the groups of the user from a separate
+	// endpoint. Their responses are mapped in the same way.
+	ExtraRequests []GenericOAuth2Request `json:"extraRequests,omitempty"`
+}
+
+// GenericOAuth2ClaimMapping holds the JSONPath expressions used to extract
+// claims from a JSON response, eg. `$.data.login` or `$.teams[*].name`.
+// Empty expressions are skipped.
+// The user, email and preferred username are taken from the first request
+// that returns a value, while groups are collected from every response.
+type GenericOAuth2ClaimMapping struct {
+	// User is the path of the user's unique identifier.
+	// If no user is found, the email is used instead.
+	User string `json:"user,omitempty"`
+	// Email is the path of the user's email address.
+	Email string `json:"email,omitempty"`
+	// PreferredUsername is the path of the user's display name.
+	PreferredUsername string `json:"preferredUsername,omitempty"`
+	// Groups is the path of the user's groups.
+	Groups string `json:"groups,omitempty"`
+	// Claims are extra claims stored in the session, available to
+	// the headers injected into requests and the userinfo endpoint.
+	Claims []GenericOAuth2Claim `json:"claims,omitempty"`
+}
+
+// GenericOAuth2Claim names the claim extracted by a JSONPath expression.
+// When the expression matches several values, or values on several pages
+// of a response, they are joined with commas.
+type GenericOAuth2Claim struct {
+	// Name is the name of the claim in the session.
+	Name string `json:"name,omitempty"`
+	// Path is the JSONPath expression of the claim.
+	Path string `json:"path,omitempty"`
+}
+
+// GenericOAuth2Request is an additional API call made to build the session.
+type GenericOAuth2Request struct {
+	// URL is the endpoint called with a GET request, authorized with the
+	// user's access token.
+	URL string `json:"url,omitempty"`
+	// ClaimMapping maps the response of the request to the session.
+	ClaimMapping GenericOAuth2ClaimMapping `json:"claimMapping,omitempty"`
+	// NextPagePath is the JSONPath expression of the URL of the next page
+	// of the response. When not set, the `next` URL of the `Link` header
+	// is followed instead.
+	NextPagePath string `json:"nextPagePath,omitempty"`
+	// MaxPages limits the number of pages requested.
+	// Defaults to 10.
+	MaxPages int `json:"maxPages,omitempty"`
+}
+
+type GiteaOptions struct {
+	// BaseURL is the URL of the Gitea server, eg. `https://gitea.example.com`.
+	// The login, redeem, profile and validate URLs default to the endpoints of
+	// this server.
+	// Default value is 'https://gitea.com'
+	BaseURL string `json:"baseURL,omitempty"`
+	// Orgs restricts logins to members of these organizations
+	Orgs []string `json:"orgs,omitempty"`
+	// Teams restricts logins to members of these teams, given as `org/team`
+	Teams []string `json:"teams,omitempty"`
+	// Repositories restricts logins to users with at least the
+	// RepositoryPermission on these repositories, given as `owner/repo`
+	Repositories []string `json:"repositories,omitempty"`
+	// RepositoryPermission is the minimum permission a user needs on one of
+	// the Repositories, one of `read`, `write` or `admin`.
+	// Every user can read a public repository.
+	// Default value is 'read'
+	RepositoryPermission string `json:"repositoryPermission,omitempty"`
 }
 
 type GitHubOptions struct {
@@ -187,7 +364,9 @@ type GitHubOptions struct {
 }
 
 type GitLabOptions struct {
-	// Group sets restrict logins to members of this group
+	// Group sets restrict logins to members of this group.
+	// A minimum role is required with `group=role`, where the role is one of
+	// guest, reporter, developer, maintainer and owner, or its access level.
 	Group []string `json:"group,omitempty"`
 	// Projects restricts logins to members of these projects
 	Projects []string `json:"projects,omitempty"`
@@ -228,6 +407,18 @@ type OIDCOptions struct {
 	// JwksURL is the OpenID Connect JWKS URL
 	// eg: https://www.googleapis.com/oauth2/v3/certs
 	JwksURL string `json:"jwksURL,omitempty"`
+	// LazyDiscovery retries the OIDC discovery in the background when the
+	// provider is unreachable at startup, instead of failing to start.
+	// The readiness check fails until the discovery succeeds.
+	// default set to 'false'
+	LazyDiscovery bool `json:"lazyDiscovery,omitempty"`
+	// DiscoveryRefreshInterval is the period between refreshes of the OIDC
+	// discovery metadata and JWKS, to pick up their rotations.
+	// Only the JWKS and the ID token verification settings are refreshed.
+	// Changes of the login, redeem, profile and other endpoints are logged
+	// but not applied, they need a restart.
+	// default set to '0' (disabled)
+	DiscoveryRefreshInterval *Duration `json:"discoveryRefreshInterval,omitempty"`
 	// EmailClaim indicates which claim contains the user email,
 	// default set to 'email'
 	EmailClaim string `json:"emailClaim,omitempty"`
@@ -245,6 +436,25 @@ type OIDCOptions struct {
 	ExtraAudiences []string `json:"extraAudiences,omitempty"`
 }
 
+type LinkedInOptions struct {
+	// Legacy uses the retired `r_emailaddress r_liteprofile` scopes and
+	// profile API instead of Sign In with LinkedIn using OpenID Connect.
+	// It is only granted to LinkedIn apps created before August 2023.
+	// Default value is 'false'
+	Legacy bool `json:"legacy,omitempty"`
+}
+
+type NextcloudOptions struct {
+	// Groups restricts logins to members of these groups, given by their ID,
+	// or by their display name when ResolveGroupDisplayNames is set
+	Groups []string `json:"groups,omitempty"`
+	// AdminOnly restricts logins to members of the Nextcloud `admin` group
+	AdminOnly bool `json:"adminOnly,omitempty"`
+	// ResolveGroupDisplayNames adds the display names of the user's groups to
+	// the session groups, next to their IDs
+	ResolveGroupDisplayNames bool `json:"resolveGroupDisplayNames,omitempty"`
+}
+
 type LoginGovOptions struct {
 	// JWTKey is a private key in PEM format used to sign JWT,
 	JWTKey string `json:"jwtKey,omitempty"`
@@ -254,6 +464,44 @@ type LoginGovOptions struct {
 	PubJWKURL string `json:"pubjwkURL,omitempty"`
 }
 
+// RequiredClaim is a claim that must be present in the claims of a user's
+// `id_token` (or profile URL) for the user to be authorized.
+// If neither values nor pattern are given, the claim only needs to be present.
+// When the claim is an array, at least one of its entries must be acceptable.
+//
+// Examples:
+//
+// # A claim that must have a fixed value
+//
+// ```
+// claim: email_verified
+// values: ["true"]
+// ```
+//
+// # A claim that must match a pattern
+//
+// ```
+// claim: address.country
+// pattern: '^(DE|AT)$'
+// ```
+type RequiredClaim struct {
+	// Claim is the name of the claim to check.
+	// Nested claims may be referenced using a dotted path, eg. `address.country`.
+	Claim string `json:"claim"`
+
+	// Values is a list of acceptable values for the claim.
+	// The claim is coerced into a string before comparison, so booleans
+	// should be given as `"true"` or `"false"`.
+	//+optional
+	Values []string `json:"values,omitempty"`
+
+	// Pattern is a regular expression that an acceptable value must match.
+	// The expression is _not_ automatically anchored to the start and end
+	// of the value.
+	//+optional
+	Pattern string `json:"pattern,omitempty"`
+}
+
 func providerDefaults() Providers {
 	providers := Providers{
 		{
@@ -1,10 +1,27 @@
 package options
 
+import "time"
+
 // SessionOptions contains configuration options for the SessionStore providers.
 type SessionOptions struct {
 	Type   string             `flag:"session-store-type" cfg:"session_store_type"`
 	Cookie CookieStoreOptions `cfg:",squash"`
 	Redis  RedisStoreOptions  `cfg:",squash"`
+	Memory MemoryStoreOptions `cfg:",squash"`
+	SQL    SQLStoreOptions    `cfg:",squash"`
+
+	// IdleTimeout rejects sessions that have not been used for longer than
+	// the timeout. LastSeenInterval limits how often the last seen time is
+	// written back to the session store.
+	IdleTimeout      time.Duration `flag:"session-idle-timeout" cfg:"session_idle_timeout"`
+	LastSeenInterval time.Duration `flag:"session-last-seen-interval" cfg:"session_last_seen_interval"`
+
+	// MaxLifetime rejects sessions once this long has passed since the user
+	// authenticated, even when the session was refreshed in the meantime.
+	MaxLifetime time.Duration `flag:"session-max-lifetime" cfg:"session_max_lifetime"`
+
+	Concurrent ConcurrentSessionOptions `cfg:",squash"`
+	Binding    SessionBindingOptions    `cfg:",squash"`
 }
 
 // CookieSessionStoreType is used to indicate the CookieSessionStore should be
@@ -15,6 +32,65 @@ var CookieSessionStoreType = "cookie"
 // used for storing sessions.
 var RedisSessionStoreType = "redis"
 
+// MemorySessionStoreType is used to indicate the MemorySessionStore should be
+// used for storing sessions.
+var MemorySessionStoreType = "memory"
+
+// SQLSessionStoreType is used to indicate the SQLSessionStore should be
+// used for storing sessions.
+var SQLSessionStoreType = "sql"
+
+// ConcurrentSessionOptions limits the number of concurrent sessions a user
+// can have in a persistent session store.
+type ConcurrentSessionOptions struct {
+	// Limit is the maximum number of active sessions per user, 0 disables
+	// the limit.
+	Limit int `flag:"session-concurrent-limit" cfg:"session_concurrent_limit"`
+	// Action is what happens when a user signs in while already at the limit.
+	// One of "evict" (remove the oldest session) or "reject" (refuse the new
+	// sign in).
+	Action string `flag:"session-concurrent-limit-action" cfg:"session_concurrent_limit_action"`
+}
+
+// ConcurrentSessionEvict removes the oldest sessions of a user to make room
+// for a new session.
+const ConcurrentSessionEvict = "evict"
+
+// ConcurrentSessionReject refuses new sessions while a user is at the limit.
+const ConcurrentSessionReject = "reject"
+
+// SessionBindingOptions binds sessions to characteristics of the client that
+// created them, so that a stolen session cookie can't be used from elsewhere.
+type SessionBindingOptions struct {
+	// Attributes are the client characteristics a session is bound to.
+	// Any of "ip-prefix", "user-agent" and "client-cert". Empty disables
+	// session binding.
+	Attributes []string `flag:"session-binding" cfg:"session_binding"`
+	// IPv4Prefix and IPv6Prefix are the lengths of the network prefix of
+	// the client IP a session is bound to with the "ip-prefix" attribute.
+	IPv4Prefix int `flag:"session-binding-ipv4-prefix" cfg:"session_binding_ipv4_prefix"`
+	IPv6Prefix int `flag:"session-binding-ipv6-prefix" cfg:"session_binding_ipv6_prefix"`
+	// Action is what happens when a session is used by a client that doesn't
+	// match its binding. One of "reauthenticate" (clear the session) or
+	// "reject" (ignore the session for the request).
+	Action string `flag:"session-binding-action" cfg:"session_binding_action"`
+}
+
+// Session binding attributes
+const (
+	SessionBindingIPPrefix   = "ip-prefix"
+	SessionBindingUserAgent  = "user-agent"
+	SessionBindingClientCert = "client-cert"
+)
+
+// SessionBindingReauthenticate clears sessions that don't match the client,
+// forcing the user to authenticate again.
+const SessionBindingReauthenticate = "reauthenticate"
+
+// SessionBindingReject ignores sessions that don't match the client for the
+// request, but keeps them for the client they are bound to.
+const SessionBindingReject = "reject"
+
 // CookieStoreOptions contains configuration options for the CookieSessionStore.
 type CookieStoreOptions struct {
 	Minimal bool `flag:"session-cookie-minimal" cfg:"session_cookie_minimal"`
@@ -36,11 +112,55 @@ type RedisStoreOptions struct {
 	IdleTimeout            int      `flag:"redis-connection-idle-timeout" cfg:"redis_connection_idle_timeout"`
 }
 
+// MemoryStoreOptions contains configuration options for the MemorySessionStore.
+type MemoryStoreOptions struct {
+	MaxEntries      int           `flag:"session-memory-max-entries" cfg:"session_memory_max_entries"`
+	CleanupInterval time.Duration `flag:"session-memory-cleanup-interval" cfg:"session_memory_cleanup_interval"`
+}
+
+// SQLStoreOptions contains configuration options for the SQLSessionStore.
+type SQLStoreOptions struct {
+	Driver          string        `flag:"sql-driver" cfg:"sql_driver"`
+	ConnectionURL   string        `flag:"sql-connection-url" cfg:"sql_connection_url"`
+	TableName       string        `flag:"sql-table-name" cfg:"sql_table_name"`
+	CleanupInterval time.Duration `flag:"sql-cleanup-interval" cfg:"sql_cleanup_interval"`
+}
+
+// SQLPostgresDriver is used to indicate the SQLSessionStore should connect
+// to a PostgreSQL database.
+const SQLPostgresDriver = "postgres"
+
+// SQLSQLiteDriver is used to indicate the SQLSessionStore should use a
+// SQLite database.
+const SQLSQLiteDriver = "sqlite"
+
 func sessionOptionsDefaults() SessionOptions {
 	return SessionOptions{
-		Type: CookieSessionStoreType,
+		Type:             CookieSessionStoreType,
+		IdleTimeout:      time.Duration(0),
+		LastSeenInterval: 5 * time.Minute,
+		MaxLifetime:      time.Duration(0),
+		Concurrent: ConcurrentSessionOptions{
+			Limit:  0,
+			Action: ConcurrentSessionEvict,
+		},
+		Binding: SessionBindingOptions{
+			Attributes: nil,
+			IPv4Prefix: 24,
+			IPv6Prefix: 64,
+			Action:     SessionBindingReauthenticate,
+		},
 		Cookie: CookieStoreOptions{
 			Minimal: false,
 		},
+		Memory: MemoryStoreOptions{
+			MaxEntries:      10000,
+			CleanupInterval: time.Minute,
+		},
+		SQL: SQLStoreOptions{
+			Driver:          SQLPostgresDriver,
+			TableName:       "oauth2_proxy_sessions",
+			CleanupInterval: 5 * time.Minute,
+		},
 	}
 }
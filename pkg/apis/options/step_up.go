@@ -0,0 +1,64 @@
+package options
+
+// StepUp defines how recently and how strongly a user must have authenticated
+// to access a route or upstream.
+// When the claims of the user's session do not satisfy the requirements, the
+// user is sent back to the provider to authenticate again, requesting the
+// required `acr_values` and `max_age`, or `prompt=login` when authentication
+// methods are required.
+// Once the user has authenticated, they are returned to the original URL.
+// If the new login still does not satisfy the requirements, an error page is
+// shown instead of starting another login.
+//
+// Examples:
+//
+// # Require a multi-factor login within the last 15 minutes
+//
+// ```
+// amr:
+// - mfa
+// maxAge: 15m
+// ```
+type StepUp struct {
+	// ACRValues is a list of acceptable Authentication Context Class
+	// References. The `acr` claim of the user's ID token must match one of
+	// these values. The values are requested from the provider in order of
+	// preference using the `acr_values` parameter.
+	//+optional
+	ACRValues []string `json:"acrValues,omitempty"`
+
+	// AMR is a list of Authentication Method References that must all be
+	// present in the `amr` claim of the user's ID token, eg. `mfa` or `hwk`.
+	// Providers cannot be asked for specific methods, so the user is asked
+	// to log in again with `prompt=login` until they are present.
+	//+optional
+	AMR []string `json:"amr,omitempty"`
+
+	// MaxAge is the maximum time since the user last authenticated with the
+	// provider. The `auth_time` claim of the user's ID token is used, or the
+	// time the session was created if the claim is not present.
+	// The age is requested from the provider using the `max_age` parameter.
+	//+optional
+	MaxAge *Duration `json:"maxAge,omitempty"`
+}
+
+// StepUpRoute applies step-up authentication requirements to the requests
+// whose path matches a regular expression.
+// Requirements apply to proxied requests and to the forward auth and
+// ext_authz endpoints. The `/oauth2/auth` endpoint cannot check them, as it
+// is not told the path of the original request.
+type StepUpRoute struct {
+	// Path is a regular expression that the request path must match for the
+	// requirements to apply, eg. `^/admin/`.
+	// The first matching route takes precedence over the step-up requirements
+	// of the upstream serving the request.
+	Path string `json:"path"`
+
+	// StepUp defines the requirements for requests matching the path.
+	StepUp StepUp `json:"stepUp"`
+}
+
+// IsEmpty reports whether the StepUp has no requirements
+func (s *StepUp) IsEmpty() bool {
+	return s == nil || (len(s.ACRValues) == 0 && len(s.AMR) == 0 && s.MaxAge == nil)
+}
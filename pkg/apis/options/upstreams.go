@@ -93,4 +93,40 @@ type Upstream struct {
 	// Timeout is the maximum duration the server will wait for a response from the upstream server.
 	// Defaults to 30 seconds.
 	Timeout *Duration `json:"timeout,omitempty"`
+
+	// StepUp defines how recently and how strongly a user must have
+	// authenticated to access this upstream.
+	// Routes in StepUpRoutes take precedence over these requirements.
+	StepUp *StepUp `json:"stepUp,omitempty"`
+
+	// TokenExchange exchanges the access token of the user's session for a
+	// token issued specifically for this upstream, using OAuth 2.0 Token
+	// Exchange (RFC 8693) at the provider's token endpoint.
+	// The exchanged token is sent to the upstream as a `Bearer` token in the
+	// Authorization header, replacing any Authorization header of the request.
+	TokenExchange *TokenExchange `json:"tokenExchange,omitempty"`
+
+	// CORS configures the CORS headers of requests to this upstream,
+	// replacing the global CORS configuration.
+	CORS *CORS `json:"cors,omitempty"`
+}
+
+// TokenExchange defines the token requested from the provider for an
+// upstream. At least one of Audience, Scopes or Resource must be set.
+// Exchanged tokens are cached per session and upstream until they expire.
+type TokenExchange struct {
+	// Audience is the logical name of the upstream the token is requested
+	// for, sent as the `audience` parameter.
+	//+optional
+	Audience string `json:"audience,omitempty"`
+
+	// Scopes is the list of scopes requested for the token, sent as the
+	// `scope` parameter.
+	//+optional
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Resource is the URI of the upstream the token is requested for, sent
+	// as the `resource` parameter.
+	//+optional
+	Resource string `json:"resource,omitempty"`
 }
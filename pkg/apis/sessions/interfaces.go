@@ -18,6 +18,14 @@ type SessionStore interface {
 var ErrLockNotObtained = errors.New("lock: not obtained")
 var ErrNotLocked = errors.New("tried to release not existing lock")
 
+// ErrSessionLimitExceeded is returned when saving a new session for a user
+// that already has the maximum number of concurrent sessions
+var ErrSessionLimitExceeded = errors.New("concurrent session limit exceeded")
+
+// ErrKeyNotFound is returned by the persistent stores when loading a key that
+// doesn't exist or has expired
+var ErrKeyNotFound = errors.New("key not found")
+
 // Lock is an interface for controlling session locks
 type Lock interface {
 	// Obtain obtains the lock on the distributed
@@ -18,6 +18,24 @@ type SessionState struct {
 	CreatedAt *time.Time `msgpack:"ca,omitempty"`
 	ExpiresOn *time.Time `msgpack:"eo,omitempty"`
 
+	// AuthenticatedAt is when the user authenticated with the provider.
+	// Unlike CreatedAt, it is not reset when the session is refreshed.
+	AuthenticatedAt *time.Time `msgpack:"aa,omitempty"`
+	// LastSeenAt is when the session was last used, updated at most once
+	// per configured interval to limit writes to the session store.
+	LastSeenAt *time.Time `msgpack:"ls,omitempty"`
+
+	// Binding is a hash of the characteristics of the client the session
+	// was created by, when session binding is enabled.
+	Binding string `msgpack:"b,omitempty"`
+
+	// ACR, AMR and AuthTime are the authentication context class, methods
+	// and time from the ID token of the latest login. They are kept when the
+	// session is refreshed and checked against step-up requirements.
+	ACR      string     `msgpack:"acr,omitempty"`
+	AMR      []string   `msgpack:"amr,omitempty"`
+	AuthTime *time.Time `msgpack:"ath,omitempty"`
+
 	AccessToken  string `msgpack:"at,omitempty"`
 	IDToken      string `msgpack:"it,omitempty"`
 	RefreshToken string `msgpack:"rt,omitempty"`
@@ -35,6 +53,16 @@ type SessionState struct {
 	// Internal helpers, not serialized
 	Clock clock.Clock `msgpack:"-"`
 	Lock  Lock        `msgpack:"-"`
+
+	// NeedsSave is set by a session store when the session was loaded in a
+	// state that should be persisted again, eg. when it was signed with a
+	// previous cookie secret.
+	NeedsSave bool `msgpack:"-"`
+
+	// NewLogin is set on a session created by a sign in, rather than loaded
+	// from a session store, so that session stores track it as a new
+	// session of its user.
+	NewLogin bool `msgpack:"-"`
 }
 
 func (s *SessionState) ObtainLock(ctx context.Context, expiration time.Duration) error {
@@ -94,6 +122,51 @@ func (s *SessionState) IsExpired() bool {
 	return false
 }
 
+// AuthenticatedAtCreation sets a SessionState's AuthenticatedAt to its
+// CreatedAt, or to now if CreatedAt is unset
+func (s *SessionState) AuthenticatedAtCreation() {
+	if s.CreatedAt == nil || s.CreatedAt.IsZero() {
+		s.CreatedAtNow()
+	}
+	authenticated := *s.CreatedAt
+	s.AuthenticatedAt = &authenticated
+}
+
+// LastSeenAtNow sets a SessionState's LastSeenAt to now
+func (s *SessionState) LastSeenAtNow() {
+	now := s.Clock.Now()
+	s.LastSeenAt = &now
+}
+
+// UpdateLastSeen sets LastSeenAt to now if it was last updated longer ago
+// than the interval. It returns true when LastSeenAt was changed and the
+// session needs to be saved.
+func (s *SessionState) UpdateLastSeen(interval time.Duration) bool {
+	if s.LastSeenAt != nil && !s.LastSeenAt.IsZero() && s.Clock.Now().Sub(*s.LastSeenAt) < interval {
+		return false
+	}
+	s.LastSeenAtNow()
+	return true
+}
+
+// IsIdle checks whether the session has not been used for longer than the
+// idle timeout. A timeout of 0 disables the check.
+func (s *SessionState) IsIdle(timeout time.Duration) bool {
+	if timeout <= 0 || s.LastSeenAt == nil || s.LastSeenAt.IsZero() {
+		return false
+	}
+	return s.Clock.Now().Sub(*s.LastSeenAt) > timeout
+}
+
+// ExceedsLifetime checks whether the user authenticated longer ago than the
+// maximum lifetime. A lifetime of 0 disables the check.
+func (s *SessionState) ExceedsLifetime(lifetime time.Duration) bool {
+	if lifetime <= 0 || s.AuthenticatedAt == nil || s.AuthenticatedAt.IsZero() {
+		return false
+	}
+	return s.Clock.Now().Sub(*s.AuthenticatedAt) > lifetime
+}
+
 // Age returns the age of a session
 func (s *SessionState) Age() time.Duration {
 	if s.CreatedAt != nil && !s.CreatedAt.IsZero() {
@@ -151,6 +224,12 @@ func (s *SessionState) GetClaim(claim string) []string {
 		return groups
 	case "preferred_username":
 		return []string{s.PreferredUsername}
+	case "acr":
+		return []string{s.ACR}
+	case "amr":
+		amr := make([]string, len(s.AMR))
+		copy(amr, s.AMR)
+		return amr
 	default:
 		// Check in AdditionalClaims
 		if value, ok := s.AdditionalClaims[claim]; ok {
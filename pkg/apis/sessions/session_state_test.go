@@ -41,6 +41,132 @@ func TestExpiresIn(t *testing.T) {
 	g.Expect(*ss.ExpiresOn).To(Equal(ss.CreatedAt.Add(ttl)))
 }
 
+func TestAuthenticatedAtCreation(t *testing.T) {
+	g := NewWithT(t)
+	ss := &SessionState{}
+
+	now := time.Unix(1234567890, 0)
+	ss.Clock.Set(now)
+
+	ss.AuthenticatedAtCreation()
+	g.Expect(*ss.CreatedAt).To(Equal(now))
+	g.Expect(*ss.AuthenticatedAt).To(Equal(now))
+
+	// Resetting CreatedAt, eg. on refresh, keeps the authentication time
+	ss.Clock.Set(now.Add(time.Hour))
+	ss.CreatedAtNow()
+	g.Expect(*ss.AuthenticatedAt).To(Equal(now))
+}
+
+func TestUpdateLastSeen(t *testing.T) {
+	g := NewWithT(t)
+	ss := &SessionState{}
+
+	now := time.Unix(1234567890, 0)
+	ss.Clock.Set(now)
+
+	g.Expect(ss.UpdateLastSeen(5 * time.Minute)).To(BeTrue())
+	g.Expect(*ss.LastSeenAt).To(Equal(now))
+
+	ss.Clock.Set(now.Add(4 * time.Minute))
+	g.Expect(ss.UpdateLastSeen(5 * time.Minute)).To(BeFalse())
+	g.Expect(*ss.LastSeenAt).To(Equal(now))
+
+	ss.Clock.Set(now.Add(5 * time.Minute))
+	g.Expect(ss.UpdateLastSeen(5 * time.Minute)).To(BeTrue())
+	g.Expect(*ss.LastSeenAt).To(Equal(now.Add(5 * time.Minute)))
+}
+
+func TestIsIdle(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+
+	testCases := []struct {
+		name       string
+		lastSeenAt *time.Time
+		timeout    time.Duration
+		expected   bool
+	}{
+		{
+			name:       "Idle timeout disabled",
+			lastSeenAt: timePtr(now.Add(-24 * time.Hour)),
+			timeout:    0,
+			expected:   false,
+		},
+		{
+			name:       "No LastSeenAt",
+			lastSeenAt: nil,
+			timeout:    time.Hour,
+			expected:   false,
+		},
+		{
+			name:       "Seen within the timeout",
+			lastSeenAt: timePtr(now.Add(-59 * time.Minute)),
+			timeout:    time.Hour,
+			expected:   false,
+		},
+		{
+			name:       "Not seen within the timeout",
+			lastSeenAt: timePtr(now.Add(-61 * time.Minute)),
+			timeout:    time.Hour,
+			expected:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ss := &SessionState{LastSeenAt: tc.lastSeenAt}
+			ss.Clock.Set(now)
+			g.Expect(ss.IsIdle(tc.timeout)).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestExceedsLifetime(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+
+	testCases := []struct {
+		name            string
+		authenticatedAt *time.Time
+		lifetime        time.Duration
+		expected        bool
+	}{
+		{
+			name:            "Maximum lifetime disabled",
+			authenticatedAt: timePtr(now.Add(-24 * time.Hour)),
+			lifetime:        0,
+			expected:        false,
+		},
+		{
+			name:            "No AuthenticatedAt",
+			authenticatedAt: nil,
+			lifetime:        time.Hour,
+			expected:        false,
+		},
+		{
+			name:            "Within the maximum lifetime",
+			authenticatedAt: timePtr(now.Add(-59 * time.Minute)),
+			lifetime:        time.Hour,
+			expected:        false,
+		},
+		{
+			name:            "Beyond the maximum lifetime",
+			authenticatedAt: timePtr(now.Add(-61 * time.Minute)),
+			lifetime:        time.Hour,
+			expected:        true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ss := &SessionState{AuthenticatedAt: tc.authenticatedAt}
+			ss.Clock.Set(now)
+			g.Expect(ss.ExceedsLifetime(tc.lifetime)).To(Equal(tc.expected))
+		})
+	}
+}
+
 func TestString(t *testing.T) {
 	g := NewWithT(t)
 	created, err := time.Parse(time.RFC3339, "2000-01-01T00:00:00Z")
@@ -240,6 +366,19 @@ func TestEncodeAndDecodeSessionState(t *testing.T) {
 				"custom_claim_1": "value1",
 			},
 		},
+		"With activity timestamps": {
+			Email:             "username@example.com",
+			User:              "username",
+			PreferredUsername: "preferred.username",
+			AccessToken:       "AccessToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			IDToken:           "IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			CreatedAt:         &created,
+			ExpiresOn:         &expires,
+			AuthenticatedAt:   &created,
+			LastSeenAt:        &created,
+			RefreshToken:      "RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			Nonce:             []byte("abcdef1234567890abcdef1234567890"),
+		},
 	}
 
 	for _, secretSize := range []int{16, 24, 32} {
@@ -285,29 +424,34 @@ func TestEncodeAndDecodeSessionState(t *testing.T) {
 }
 
 func compareSessionStates(t *testing.T, expected *SessionState, actual *SessionState) {
-	if expected.CreatedAt != nil {
-		assert.NotNil(t, actual.CreatedAt)
-		assert.Equal(t, true, expected.CreatedAt.Equal(*actual.CreatedAt))
-	} else {
-		assert.Nil(t, actual.CreatedAt)
-	}
-	if expected.ExpiresOn != nil {
-		assert.NotNil(t, actual.ExpiresOn)
-		assert.Equal(t, true, expected.ExpiresOn.Equal(*actual.ExpiresOn))
-	} else {
-		assert.Nil(t, actual.ExpiresOn)
-	}
+	compareTimes(t, expected.CreatedAt, actual.CreatedAt)
+	compareTimes(t, expected.ExpiresOn, actual.ExpiresOn)
+	compareTimes(t, expected.AuthenticatedAt, actual.AuthenticatedAt)
+	compareTimes(t, expected.LastSeenAt, actual.LastSeenAt)
 
 	// Compare sessions without *time.Time fields
 	exp := *expected
 	exp.CreatedAt = nil
 	exp.ExpiresOn = nil
+	exp.AuthenticatedAt = nil
+	exp.LastSeenAt = nil
 	act := *actual
 	act.CreatedAt = nil
 	act.ExpiresOn = nil
+	act.AuthenticatedAt = nil
+	act.LastSeenAt = nil
 	assert.Equal(t, exp, act)
 }
 
+func compareTimes(t *testing.T, expected *time.Time, actual *time.Time) {
+	if expected != nil {
+		assert.NotNil(t, actual)
+		assert.Equal(t, true, expected.Equal(*actual))
+	} else {
+		assert.Nil(t, actual)
+	}
+}
+
 func TestGetClaim(t *testing.T) {
 	createdAt := time.Now()
 	expiresOn := createdAt.Add(1 * time.Hour)
@@ -0,0 +1,243 @@
+package assertion
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+)
+
+const defaultLifetime = 2 * time.Minute
+
+// reservedClaims are set by the Signer and cannot be configured as
+// additional claims
+var reservedClaims = map[string]struct{}{
+	"iss": {}, "sub": {}, "aud": {}, "exp": {}, "nbf": {}, "iat": {}, "jti": {},
+	"email": {}, "preferred_username": {}, "groups": {},
+}
+
+// IsReservedClaim reports whether the claim is set by the Signer itself
+func IsReservedClaim(claim string) bool {
+	_, ok := reservedClaims[claim]
+	return ok
+}
+
+// Signer mints the identity assertions sent to upstreams and publishes the
+// keys they are verified with
+type Signer struct {
+	Clock clock.Clock
+
+	header   string
+	issuer   string
+	claims   []string
+	lifetime time.Duration
+
+	key    crypto.Signer
+	method jwt.SigningMethod
+	keyID  string
+	jwks   jose.JSONWebKeySet
+}
+
+// NewSigner creates a Signer from the identity assertion options.
+func NewSigner(opts *options.IdentityAssertion) (*Signer, error) {
+	s := &Signer{
+		header:   opts.Header,
+		issuer:   opts.Issuer,
+		claims:   opts.Claims,
+		lifetime: opts.Lifetime.Duration(),
+	}
+	if s.header == "" {
+		s.header = options.DefaultIdentityAssertionHeader
+	}
+	if s.lifetime == 0 {
+		s.lifetime = defaultLifetime
+	}
+
+	keyData, err := util.GetSecretValue(&opts.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not read signing key: %v", err)
+	}
+	s.key, err = ParsePrivateKey(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse signing key: %v", err)
+	}
+	s.method, err = SigningMethod(s.key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	signingJWK, err := newJWK(s.key.Public(), s.method, opts.SigningKeyID)
+	if err != nil {
+		return nil, err
+	}
+	s.keyID = signingJWK.KeyID
+	s.jwks.Keys = append(s.jwks.Keys, signingJWK)
+
+	for i := range opts.AdditionalPublicKeys {
+		keyData, err := util.GetSecretValue(&opts.AdditionalPublicKeys[i])
+		if err != nil {
+			return nil, fmt.Errorf("could not read additional public key %d: %v", i, err)
+		}
+		publicKey, err := parsePublicKey(keyData)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse additional public key %d: %v", i, err)
+		}
+		method, err := SigningMethod(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("additional public key %d: %v", i, err)
+		}
+		jwk, err := newJWK(publicKey, method, "")
+		if err != nil {
+			return nil, err
+		}
+		s.jwks.Keys = append(s.jwks.Keys, jwk)
+	}
+
+	return s, nil
+}
+
+// Header returns the request header the assertion is sent in
+func (s *Signer) Header() string {
+	return s.header
+}
+
+// JWKS returns the public keys that verify the assertions
+func (s *Signer) JWKS() jose.JSONWebKeySet {
+	return s.jwks
+}
+
+// Sign mints an assertion of the identity of the session's user for the
+// upstream with the given ID.
+func (s *Signer) Sign(session *sessionsapi.SessionState, audience string) (string, error) {
+	now := s.Clock.Now()
+	claims := jwt.MapClaims{}
+	for _, name := range s.claims {
+		switch values := session.GetClaim(name); len(values) {
+		case 0:
+		case 1:
+			claims[name] = values[0]
+		default:
+			claims[name] = values
+		}
+	}
+
+	claims["iss"] = s.issuer
+	claims["sub"] = session.User
+	claims["aud"] = audience
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(s.lifetime).Unix()
+	claims["jti"] = uuid.NewString()
+	if session.Email != "" {
+		claims["email"] = session.Email
+	}
+	if session.PreferredUsername != "" {
+		claims["preferred_username"] = session.PreferredUsername
+	}
+	if len(session.Groups) > 0 {
+		claims["groups"] = session.Groups
+	}
+
+	token := jwt.NewWithClaims(s.method, claims)
+	token.Header["kid"] = s.keyID
+	return token.SignedString(s.key)
+}
+
+// SigningMethod returns the JWT signing method for the type of key
+func SigningMethod(publicKey crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		default:
+			return nil, fmt.Errorf("unsupported elliptic curve %s", key.Curve.Params().Name)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, an RSA or EC key is required", publicKey)
+	}
+}
+
+// newJWK creates the JWK of a public key. Without a key ID, the key's
+// thumbprint is used.
+func newJWK(publicKey crypto.PublicKey, method jwt.SigningMethod, keyID string) (jose.JSONWebKey, error) {
+	jwk := jose.JSONWebKey{
+		Key:       publicKey,
+		KeyID:     keyID,
+		Algorithm: method.Alg(),
+		Use:       "sig",
+	}
+	if jwk.KeyID == "" {
+		thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return jose.JSONWebKey{}, fmt.Errorf("could not compute key thumbprint: %v", err)
+		}
+		jwk.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+	}
+	return jwk, nil
+}
+
+// ParsePrivateKey parses a PKCS #1, PKCS #8 or SEC 1 private key in PEM format
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported private key in PEM block %q", block.Type)
+}
+
+// parsePublicKey parses a PKIX or PKCS #1 public key, or the key of a
+// certificate, in PEM format
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
@@ -0,0 +1,204 @@
+package assertion
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Identity Assertion Suite", func() {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	pemSource := func(blockType string, data []byte) options.SecretSource {
+		return options.SecretSource{Value: pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data})}
+	}
+	pkcs8Source := func(key interface{}) options.SecretSource {
+		data, err := x509.MarshalPKCS8PrivateKey(key)
+		Expect(err).ToNot(HaveOccurred())
+		return pemSource("PRIVATE KEY", data)
+	}
+	publicKeySource := func(key interface{}) options.SecretSource {
+		data, err := x509.MarshalPKIXPublicKey(key)
+		Expect(err).ToNot(HaveOccurred())
+		return pemSource("PUBLIC KEY", data)
+	}
+	ecSource := func(key *ecdsa.PrivateKey) options.SecretSource {
+		data, err := x509.MarshalECPrivateKey(key)
+		Expect(err).ToNot(HaveOccurred())
+		return pemSource("EC PRIVATE KEY", data)
+	}
+
+	now := time.Unix(1700000000, 0)
+	session := &sessionsapi.SessionState{
+		User:              "123456",
+		Email:             "john@example.com",
+		PreferredUsername: "john",
+		Groups:            []string{"admins", "developers"},
+		ACR:               "gold",
+		AMR:               []string{"pwd", "otp"},
+		AdditionalClaims:  map[string]string{"department": "engineering"},
+	}
+
+	// verify parses the assertion with the published keys
+	verify := func(signer *Signer, token string) (jwt.MapClaims, *jwt.Token) {
+		claims := jwt.MapClaims{}
+		parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+			jwks := signer.JWKS()
+			keys := jwks.Key(t.Header["kid"].(string))
+			Expect(keys).To(HaveLen(1))
+			Expect(keys[0].Algorithm).To(Equal(t.Method.Alg()))
+			return keys[0].Key, nil
+		}, jwt.WithTimeFunc(func() time.Time { return now }))
+		Expect(err).ToNot(HaveOccurred())
+		return claims, parsed
+	}
+
+	Context("NewSigner", func() {
+		type newSignerTableInput struct {
+			opts          *options.IdentityAssertion
+			expectedAlg   string
+			expectedError string
+		}
+
+		DescribeTable("parses the signing key",
+			func(in newSignerTableInput) {
+				signer, err := NewSigner(in.opts)
+				if in.expectedError != "" {
+					Expect(err).To(MatchError(in.expectedError))
+					return
+				}
+				Expect(err).ToNot(HaveOccurred())
+				Expect(signer.method.Alg()).To(Equal(in.expectedAlg))
+				Expect(signer.JWKS().Keys[0].Algorithm).To(Equal(in.expectedAlg))
+			},
+			Entry("with a PKCS #1 RSA key", newSignerTableInput{
+				opts:        &options.IdentityAssertion{SigningKey: pemSource("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))},
+				expectedAlg: "RS256",
+			}),
+			Entry("with a PKCS #8 RSA key", newSignerTableInput{
+				opts:        &options.IdentityAssertion{SigningKey: pkcs8Source(rsaKey)},
+				expectedAlg: "RS256",
+			}),
+			Entry("with a SEC 1 EC key", newSignerTableInput{
+				opts:        &options.IdentityAssertion{SigningKey: ecSource(ecKey)},
+				expectedAlg: "ES384",
+			}),
+			Entry("with a PKCS #8 EC key", newSignerTableInput{
+				opts:        &options.IdentityAssertion{SigningKey: pkcs8Source(ecKey)},
+				expectedAlg: "ES384",
+			}),
+			Entry("with an Ed25519 key", newSignerTableInput{
+				opts:          &options.IdentityAssertion{SigningKey: pkcs8Source(edKey)},
+				expectedError: "unsupported key type ed25519.PublicKey, an RSA or EC key is required",
+			}),
+			Entry("with a public key", newSignerTableInput{
+				opts:          &options.IdentityAssertion{SigningKey: publicKeySource(&rsaKey.PublicKey)},
+				expectedError: "could not parse signing key: unsupported private key in PEM block \"PUBLIC KEY\"",
+			}),
+			Entry("without a PEM block", newSignerTableInput{
+				opts:          &options.IdentityAssertion{SigningKey: options.SecretSource{Value: []byte("secret")}},
+				expectedError: "could not parse signing key: no PEM block found",
+			}),
+			Entry("with an invalid additional public key", newSignerTableInput{
+				opts: &options.IdentityAssertion{
+					SigningKey:           pkcs8Source(rsaKey),
+					AdditionalPublicKeys: []options.SecretSource{{Value: []byte("secret")}},
+				},
+				expectedError: "could not parse additional public key 0: no PEM block found",
+			}),
+		)
+
+		It("applies the defaults", func() {
+			signer, err := NewSigner(&options.IdentityAssertion{SigningKey: pkcs8Source(rsaKey)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(signer.Header()).To(Equal("X-Oauth2-Proxy-Jwt-Assertion"))
+			Expect(signer.lifetime).To(Equal(2 * time.Minute))
+			Expect(signer.keyID).ToNot(BeEmpty())
+		})
+
+		It("publishes the signing key and additional public keys", func() {
+			signer, err := NewSigner(&options.IdentityAssertion{
+				SigningKey:           pkcs8Source(rsaKey),
+				SigningKeyID:         "current",
+				AdditionalPublicKeys: []options.SecretSource{publicKeySource(&ecKey.PublicKey)},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			jwks := signer.JWKS()
+			Expect(jwks.Keys).To(HaveLen(2))
+			Expect(jwks.Keys[0].KeyID).To(Equal("current"))
+			Expect(jwks.Keys[0].Key).To(Equal(&rsaKey.PublicKey))
+			Expect(jwks.Keys[0].Use).To(Equal("sig"))
+			Expect(jwks.Keys[1].KeyID).ToNot(BeEmpty())
+			Expect(jwks.Keys[1].Key).To(Equal(&ecKey.PublicKey))
+			Expect(jwks.Keys[1].Algorithm).To(Equal("ES384"))
+		})
+	})
+
+	Context("Sign", func() {
+		It("asserts the identity of the session for the audience", func() {
+			lifetime := options.Duration(time.Minute)
+			signer, err := NewSigner(&options.IdentityAssertion{
+				Issuer:     "https://proxy.example.com",
+				SigningKey: pkcs8Source(rsaKey),
+				Claims:     []string{"acr", "amr", "department", "missing"},
+				Lifetime:   &lifetime,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			signer.Clock.Set(now)
+
+			token, err := signer.Sign(session, "orders")
+			Expect(err).ToNot(HaveOccurred())
+
+			claims, parsed := verify(signer, token)
+			Expect(parsed.Header["kid"]).To(Equal(signer.keyID))
+			Expect(claims).To(HaveKey("jti"))
+			delete(claims, "jti")
+			Expect(claims).To(Equal(jwt.MapClaims{
+				"iss":                "https://proxy.example.com",
+				"sub":                "123456",
+				"aud":                "orders",
+				"iat":                float64(now.Unix()),
+				"nbf":                float64(now.Unix()),
+				"exp":                float64(now.Add(time.Minute).Unix()),
+				"email":              "john@example.com",
+				"preferred_username": "john",
+				"groups":             []interface{}{"admins", "developers"},
+				"acr":                "gold",
+				"amr":                []interface{}{"pwd", "otp"},
+				"department":         "engineering",
+			}))
+		})
+
+		It("signs with EC keys", func() {
+			signer, err := NewSigner(&options.IdentityAssertion{SigningKey: ecSource(ecKey)})
+			Expect(err).ToNot(HaveOccurred())
+			signer.Clock.Set(now)
+
+			token, err := signer.Sign(&sessionsapi.SessionState{User: "jane"}, "app")
+			Expect(err).ToNot(HaveOccurred())
+
+			claims, parsed := verify(signer, token)
+			Expect(parsed.Method.Alg()).To(Equal("ES384"))
+			Expect(claims["sub"]).To(Equal("jane"))
+			Expect(claims).ToNot(HaveKey("email"))
+			Expect(claims).ToNot(HaveKey("groups"))
+		})
+	})
+})
@@ -0,0 +1,197 @@
+package stepup
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/upstream"
+)
+
+// Requirement is the compiled form of options.StepUp
+type Requirement struct {
+	acrValues []string
+	amr       []string
+	maxAge    time.Duration
+}
+
+func newRequirement(opts *options.StepUp) *Requirement {
+	return &Requirement{
+		acrValues: opts.ACRValues,
+		amr:       opts.AMR,
+		maxAge:    opts.MaxAge.Duration(),
+	}
+}
+
+// SatisfiedBy reports whether the authentication of the session satisfies
+// the requirement. A nil Requirement is satisfied by every session.
+func (r *Requirement) SatisfiedBy(s *sessionsapi.SessionState) bool {
+	if r == nil {
+		return true
+	}
+	if len(r.acrValues) > 0 && !contains(r.acrValues, s.ACR) {
+		return false
+	}
+	for _, method := range r.amr {
+		if !contains(s.AMR, method) {
+			return false
+		}
+	}
+	if r.maxAge > 0 {
+		authTime := authenticationTime(s)
+		if authTime == nil || s.Clock.Now().Sub(*authTime) > r.maxAge {
+			return false
+		}
+	}
+	return true
+}
+
+// LoginURLParams returns the parameters that request an authentication
+// satisfying the requirement from the provider.
+func (r *Requirement) LoginURLParams() url.Values {
+	params := url.Values{}
+	if r == nil {
+		return params
+	}
+	if len(r.acrValues) > 0 {
+		params.Set("acr_values", strings.Join(r.acrValues, " "))
+	}
+	if r.maxAge > 0 {
+		params.Set("max_age", strconv.FormatInt(int64(r.maxAge/time.Second), 10))
+	}
+	if len(r.amr) > 0 {
+		// There is no parameter to request authentication methods, so
+		// make sure the user authenticates again
+		params.Set("prompt", "login")
+	}
+	return params
+}
+
+// String describes the requirement for log messages
+func (r *Requirement) String() string {
+	parts := []string{}
+	if len(r.acrValues) > 0 {
+		parts = append(parts, fmt.Sprintf("acr:%v", r.acrValues))
+	}
+	if len(r.amr) > 0 {
+		parts = append(parts, fmt.Sprintf("amr:%v", r.amr))
+	}
+	if r.maxAge > 0 {
+		parts = append(parts, fmt.Sprintf("max_age:%s", r.maxAge))
+	}
+	return "StepUp{" + strings.Join(parts, " ") + "}"
+}
+
+// authenticationTime returns when the user last authenticated with the
+// provider, preferring the `auth_time` claim of the ID token
+func authenticationTime(s *sessionsapi.SessionState) *time.Time {
+	switch {
+	case s.AuthTime != nil && !s.AuthTime.IsZero():
+		return s.AuthTime
+	case s.AuthenticatedAt != nil && !s.AuthenticatedAt.IsZero():
+		return s.AuthenticatedAt
+	case s.CreatedAt != nil && !s.CreatedAt.IsZero():
+		return s.CreatedAt
+	default:
+		return nil
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type route struct {
+	pathRegex   *regexp.Regexp
+	requirement *Requirement
+}
+
+// Policy finds the step-up requirement that applies to a request from the
+// step-up routes and the upstream serving the request.
+// A nil Policy has no requirements.
+type Policy struct {
+	routes        []route
+	upstreams     *upstream.Matcher
+	upstreamRules map[string]*Requirement
+}
+
+// NewPolicy creates a Policy from the step-up routes and the requirements of
+// the upstreams. It returns nil when no requirements are configured.
+func NewPolicy(routes []options.StepUpRoute, upstreams options.UpstreamConfig) (*Policy, error) {
+	p := &Policy{
+		upstreamRules: make(map[string]*Requirement),
+	}
+
+	for _, r := range routes {
+		pathRegex, err := regexp.Compile(r.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q for step-up route: %v", r.Path, err)
+		}
+		stepUp := r.StepUp
+		p.routes = append(p.routes, route{
+			pathRegex:   pathRegex,
+			requirement: newRequirement(&stepUp),
+		})
+	}
+
+	for _, u := range upstreams.Upstreams {
+		if !u.StepUp.IsEmpty() {
+			p.upstreamRules[u.ID] = newRequirement(u.StepUp)
+		}
+	}
+	if len(p.upstreamRules) > 0 {
+		matcher, err := upstream.NewMatcher(upstreams)
+		if err != nil {
+			return nil, err
+		}
+		p.upstreams = matcher
+	}
+
+	if len(p.routes) == 0 && len(p.upstreamRules) == 0 {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// Requirement returns the step-up requirement of the request, or nil when
+// the request has no requirement.
+func (p *Policy) Requirement(req *http.Request) *Requirement {
+	if p == nil {
+		return nil
+	}
+	for _, r := range p.routes {
+		if r.pathRegex.MatchString(req.URL.Path) {
+			return r.requirement
+		}
+	}
+	if p.upstreams != nil {
+		if id, ok := p.upstreams.Match(req); ok {
+			return p.upstreamRules[id]
+		}
+	}
+	return nil
+}
+
+// RequirementForRedirect returns the step-up requirement of the URL a user
+// is redirected to once they are authenticated.
+func (p *Policy) RequirementForRedirect(redirect string) *Requirement {
+	if p == nil {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodGet, redirect, nil)
+	if err != nil {
+		return nil
+	}
+	return p.Requirement(req)
+}
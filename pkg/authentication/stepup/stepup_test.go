@@ -0,0 +1,156 @@
+package stepup
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Step-up Authentication", func() {
+	now := time.Unix(1700000000, 0)
+	recent := now.Add(-5 * time.Minute)
+	old := now.Add(-time.Hour)
+	maxAge := options.Duration(15 * time.Minute)
+
+	type satisfiedByTableInput struct {
+		stepUp   options.StepUp
+		session  *sessionsapi.SessionState
+		expected bool
+	}
+
+	DescribeTable("SatisfiedBy",
+		func(in satisfiedByTableInput) {
+			in.session.Clock.Set(now)
+			Expect(newRequirement(&in.stepUp).SatisfiedBy(in.session)).To(Equal(in.expected))
+		},
+		Entry("with a matching acr", satisfiedByTableInput{
+			stepUp:   options.StepUp{ACRValues: []string{"silver", "gold"}},
+			session:  &sessionsapi.SessionState{ACR: "gold"},
+			expected: true,
+		}),
+		Entry("with a different acr", satisfiedByTableInput{
+			stepUp:   options.StepUp{ACRValues: []string{"gold"}},
+			session:  &sessionsapi.SessionState{ACR: "bronze"},
+			expected: false,
+		}),
+		Entry("with all required amr entries", satisfiedByTableInput{
+			stepUp:   options.StepUp{AMR: []string{"pwd", "otp"}},
+			session:  &sessionsapi.SessionState{AMR: []string{"otp", "pwd", "mfa"}},
+			expected: true,
+		}),
+		Entry("with a missing amr entry", satisfiedByTableInput{
+			stepUp:   options.StepUp{AMR: []string{"pwd", "hwk"}},
+			session:  &sessionsapi.SessionState{AMR: []string{"pwd", "otp"}},
+			expected: false,
+		}),
+		Entry("with a recent auth_time", satisfiedByTableInput{
+			stepUp:   options.StepUp{MaxAge: &maxAge},
+			session:  &sessionsapi.SessionState{AuthTime: &recent, AuthenticatedAt: &old},
+			expected: true,
+		}),
+		Entry("with an old auth_time", satisfiedByTableInput{
+			stepUp:   options.StepUp{MaxAge: &maxAge},
+			session:  &sessionsapi.SessionState{AuthTime: &old, CreatedAt: &recent},
+			expected: false,
+		}),
+		Entry("without auth_time, falling back to the authentication time", satisfiedByTableInput{
+			stepUp:   options.StepUp{MaxAge: &maxAge},
+			session:  &sessionsapi.SessionState{AuthenticatedAt: &recent},
+			expected: true,
+		}),
+		Entry("without any authentication time", satisfiedByTableInput{
+			stepUp:   options.StepUp{MaxAge: &maxAge},
+			session:  &sessionsapi.SessionState{},
+			expected: false,
+		}),
+	)
+
+	It("is satisfied by every session without a requirement", func() {
+		var requirement *Requirement
+		Expect(requirement.SatisfiedBy(&sessionsapi.SessionState{})).To(BeTrue())
+		Expect(requirement.LoginURLParams()).To(BeEmpty())
+	})
+
+	DescribeTable("LoginURLParams",
+		func(stepUp options.StepUp, expected url.Values) {
+			Expect(newRequirement(&stepUp).LoginURLParams()).To(Equal(expected))
+		},
+		Entry("with acr values", options.StepUp{ACRValues: []string{"gold", "silver"}}, url.Values{
+			"acr_values": []string{"gold silver"},
+		}),
+		Entry("with a maximum age", options.StepUp{MaxAge: &maxAge}, url.Values{
+			"max_age": []string{"900"},
+		}),
+		Entry("with amr entries", options.StepUp{AMR: []string{"mfa"}}, url.Values{
+			"prompt": []string{"login"},
+		}),
+	)
+
+	Context("Policy", func() {
+		gold := options.StepUp{ACRValues: []string{"gold"}}
+		silver := options.StepUp{ACRValues: []string{"silver"}}
+
+		upstreams := options.UpstreamConfig{
+			Upstreams: []options.Upstream{
+				{ID: "app", Path: "/", URI: "http://app.localhost"},
+				{ID: "billing", Path: "/billing/", URI: "http://billing.localhost", StepUp: &silver},
+			},
+		}
+		routes := []options.StepUpRoute{
+			{Path: "^/billing/admin/", StepUp: gold},
+		}
+
+		It("is nil without any requirements", func() {
+			policy, err := NewPolicy(nil, options.UpstreamConfig{
+				Upstreams: []options.Upstream{{ID: "app", Path: "/", URI: "http://app.localhost"}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(policy).To(BeNil())
+			Expect(policy.Requirement(httptest.NewRequest("GET", "/", nil))).To(BeNil())
+		})
+
+		It("returns an error for an invalid route path", func() {
+			_, err := NewPolicy([]options.StepUpRoute{{Path: "^/admin/(", StepUp: gold}}, options.UpstreamConfig{})
+			Expect(err).To(MatchError(ContainSubstring("invalid path \"^/admin/(\" for step-up route")))
+		})
+
+		DescribeTable("Requirement",
+			func(target string, expected *options.StepUp) {
+				policy, err := NewPolicy(routes, upstreams)
+				Expect(err).ToNot(HaveOccurred())
+
+				requirement := policy.Requirement(httptest.NewRequest("GET", target, nil))
+				if expected == nil {
+					Expect(requirement).To(BeNil())
+					return
+				}
+				Expect(requirement).To(Equal(newRequirement(expected)))
+			},
+			Entry("with an upstream without requirements", "/dashboard", nil),
+			Entry("with an upstream with requirements", "/billing/invoices", &silver),
+			Entry("with a route taking precedence over the upstream", "/billing/admin/users", &gold),
+		)
+
+		DescribeTable("RequirementForRedirect",
+			func(redirect string, expected *options.StepUp) {
+				policy, err := NewPolicy(routes, upstreams)
+				Expect(err).ToNot(HaveOccurred())
+
+				requirement := policy.RequirementForRedirect(redirect)
+				if expected == nil {
+					Expect(requirement).To(BeNil())
+					return
+				}
+				Expect(requirement).To(Equal(newRequirement(expected)))
+			},
+			Entry("with a relative redirect", "/billing/admin/users?page=2", &gold),
+			Entry("with an absolute redirect", "https://app.example.com/billing/invoices", &silver),
+			Entry("with a redirect without requirements", "/", nil),
+		)
+	})
+})
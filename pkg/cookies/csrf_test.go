@@ -120,6 +120,32 @@ var _ = Describe("CSRF Cookie Tests", func() {
 			_, _, valid := encryption.Validate(cookie, cookieOpts.Secret, cookieOpts.Expire)
 			Expect(valid).To(BeTrue())
 		})
+
+		It("decodes cookies encoded with a previous secret", func() {
+			privateCSRF.OAuthState = []byte(csrfState)
+			privateCSRF.OIDCNonce = []byte(csrfNonce)
+
+			encoded, err := privateCSRF.encodeCookie()
+			Expect(err).ToNot(HaveOccurred())
+
+			cookie := &http.Cookie{
+				Name:  privateCSRF.cookieName(),
+				Value: encoded,
+			}
+
+			rotatedOpts := *cookieOpts
+			rotatedOpts.Secret = "anotherthirtytwobytesecret+12345"
+			rotatedOpts.PreviousSecrets = []string{cookieSecret}
+
+			decoded, err := decodeCSRFCookie(cookie, &rotatedOpts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.OAuthState).To(Equal([]byte(csrfState)))
+			Expect(decoded.OIDCNonce).To(Equal([]byte(csrfNonce)))
+
+			rotatedOpts.PreviousSecrets = nil
+			_, err = decodeCSRFCookie(cookie, &rotatedOpts)
+			Expect(err).To(MatchError("CSRF cookie failed validation"))
+		})
 	})
 
 	Context("Cookie Management", func() {
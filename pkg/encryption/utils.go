@@ -71,6 +71,19 @@ func Validate(cookie *http.Cookie, seed string, expiration time.Duration) (value
 	return
 }
 
+// ValidateWithSeeds ensures a cookie is properly signed by any of the seeds.
+// The index of the seed that signed the cookie is returned so that cookies
+// signed with an older seed can be identified.
+func ValidateWithSeeds(cookie *http.Cookie, seeds []string, expiration time.Duration) (value []byte, t time.Time, index int, ok bool) {
+	for i, seed := range seeds {
+		value, t, ok = Validate(cookie, seed, expiration)
+		if ok {
+			return value, t, i, true
+		}
+	}
+	return nil, time.Time{}, -1, false
+}
+
 // SignedValue returns a cookie that is signed and can later be checked with Validate
 func SignedValue(seed string, key string, value []byte, now time.Time) (string, error) {
 	encodedValue := base64.URLEncoding.EncodeToString(value)
@@ -130,6 +130,32 @@ func TestValidate(t *testing.T) {
 	assert.Equal(t, validValue, expectedValue)
 }
 
+func TestValidateWithSeeds(t *testing.T) {
+	seed := "0123456789abcdef"
+	previousSeed := "fedcba9876543210"
+	key := "cookie-name"
+	value := []byte("I am soooo encoded")
+	now := time.Now()
+
+	signed, err := SignedValue(previousSeed, key, value, now)
+	assert.NoError(t, err)
+
+	cookie := &http.Cookie{
+		Name:  key,
+		Value: signed,
+	}
+
+	validValue, timestamp, index, ok := ValidateWithSeeds(cookie, []string{seed, previousSeed}, time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, 1, index)
+	assert.Equal(t, time.Unix(now.Unix(), 0), timestamp)
+	assert.Equal(t, value, validValue)
+
+	_, _, index, ok = ValidateWithSeeds(cookie, []string{seed}, time.Hour)
+	assert.False(t, ok)
+	assert.Equal(t, -1, index)
+}
+
 func TestGenerateRandomASCIIString(t *testing.T) {
 	randomString, err := GenerateRandomASCIIString(96)
 	assert.NoError(t, err)
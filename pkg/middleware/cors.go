@@ -0,0 +1,248 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/justinas/alice"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+var defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+// CORSOptions contains all the options for the CORS middleware
+type CORSOptions struct {
+	// Global is the CORS configuration of the proxy endpoints, and of the
+	// upstreams without their own configuration
+	Global *options.CORS
+
+	// Upstreams is the upstream configuration, the CORS configuration of
+	// individual upstreams is read from the upstreams
+	Upstreams options.UpstreamConfig
+
+	// ProxyPrefix is the prefix of the proxy endpoints, which always use the
+	// global configuration
+	ProxyPrefix string
+
+	// MatchUpstream returns the ID of the upstream a request is proxied to
+	MatchUpstream func(*http.Request) (string, bool)
+}
+
+// NewCORS creates a new middleware that answers CORS preflight requests and
+// adds the CORS headers to the responses to cross-origin requests.
+func NewCORS(opts *CORSOptions) alice.Constructor {
+	c := &cors{
+		proxyPrefix:   opts.ProxyPrefix,
+		matchUpstream: opts.MatchUpstream,
+		upstreams:     make(map[string]*corsPolicy),
+	}
+	if opts.Global != nil {
+		c.global = newCORSPolicy(opts.Global)
+	}
+	for _, u := range opts.Upstreams.Upstreams {
+		if u.CORS != nil {
+			c.upstreams[u.ID] = newCORSPolicy(u.CORS)
+		}
+	}
+
+	if c.global == nil && len(c.upstreams) == 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return c.handleCORS
+}
+
+type cors struct {
+	proxyPrefix   string
+	matchUpstream func(*http.Request) (string, bool)
+	global        *corsPolicy
+	upstreams     map[string]*corsPolicy
+}
+
+func (c *cors) handleCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		if origin == "" {
+			// Not a cross-origin request
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		policy := c.policy(req)
+		if policy == nil {
+			// CORS is not configured for this request, leave it to the
+			// upstream
+			next.ServeHTTP(rw, req)
+			return
+		}
+		if isPreflight(req) {
+			policy.handlePreflight(rw, req, origin)
+			return
+		}
+
+		policy.setResponseHeaders(rw, origin)
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// policy returns the CORS policy of the request, or nil when CORS is not
+// configured for the request
+func (c *cors) policy(req *http.Request) *corsPolicy {
+	if strings.HasPrefix(req.URL.Path, c.proxyPrefix+"/") {
+		return c.global
+	}
+	if id, ok := c.matchUpstream(req); ok {
+		if policy, ok := c.upstreams[id]; ok {
+			return policy
+		}
+	}
+	return c.global
+}
+
+// isPreflight reports whether the request is a CORS preflight request, rather
+// than an OPTIONS request meant for the upstream
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// corsPolicy is the compiled form of options.CORS
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     []string
+	methods     []string
+	anyHeader   bool
+	headers     map[string]struct{}
+	exposed     string
+	credentials bool
+	maxAge      time.Duration
+}
+
+func newCORSPolicy(opts *options.CORS) *corsPolicy {
+	p := &corsPolicy{
+		methods:     opts.AllowedMethods,
+		headers:     make(map[string]struct{}),
+		exposed:     strings.Join(opts.ExposedHeaders, ", "),
+		credentials: opts.AllowCredentials,
+		maxAge:      opts.MaxAge.Duration(),
+	}
+	if len(p.methods) == 0 {
+		p.methods = defaultCORSMethods
+	}
+	for _, origin := range opts.AllowedOrigins {
+		if origin == "*" {
+			p.anyOrigin = true
+		}
+		p.origins = append(p.origins, strings.ToLower(origin))
+	}
+	for _, header := range opts.AllowedHeaders {
+		if header == "*" {
+			p.anyHeader = true
+		}
+		p.headers[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+	return p
+}
+
+// allowsOrigin reports whether cross-origin requests from the origin are
+// allowed
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.origins {
+		if matchOrigin(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin matches an origin against an allowed origin, where a `*`
+// matches one or more subdomains
+func matchOrigin(allowed, origin string) bool {
+	prefix, suffix, wildcard := strings.Cut(allowed, "*")
+	if !wildcard {
+		return allowed == origin
+	}
+	if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	subdomains := origin[len(prefix) : len(origin)-len(suffix)]
+	return !strings.ContainsAny(subdomains, "/:@") && !strings.HasPrefix(subdomains, ".") && !strings.HasSuffix(subdomains, ".")
+}
+
+func (p *corsPolicy) allowsMethod(method string) bool {
+	for _, allowed := range p.methods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *corsPolicy) allowsHeaders(requested string) bool {
+	if p.anyHeader {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if _, ok := p.headers[http.CanonicalHeaderKey(header)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// handlePreflight answers a preflight request, allowing the request when the
+// origin, method and headers are allowed
+func (p *corsPolicy) handlePreflight(rw http.ResponseWriter, req *http.Request, origin string) {
+	rw.Header().Add("Vary", "Origin")
+	rw.Header().Add("Vary", "Access-Control-Request-Method")
+	rw.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	method := req.Header.Get("Access-Control-Request-Method")
+	requestedHeaders := req.Header.Get("Access-Control-Request-Headers")
+	if !p.allowsOrigin(origin) || !p.allowsMethod(method) || !p.allowsHeaders(requestedHeaders) {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	p.setOriginHeaders(rw, origin)
+	rw.Header().Set("Access-Control-Allow-Methods", strings.Join(p.methods, ", "))
+	if requestedHeaders != "" {
+		rw.Header().Set("Access-Control-Allow-Headers", requestedHeaders)
+	}
+	if p.maxAge > 0 {
+		rw.Header().Set("Access-Control-Max-Age", strconv.FormatInt(int64(p.maxAge/time.Second), 10))
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// setResponseHeaders adds the CORS headers to the response to a cross-origin
+// request from an allowed origin
+func (p *corsPolicy) setResponseHeaders(rw http.ResponseWriter, origin string) {
+	rw.Header().Add("Vary", "Origin")
+	if !p.allowsOrigin(origin) {
+		return
+	}
+	p.setOriginHeaders(rw, origin)
+	if p.exposed != "" {
+		rw.Header().Set("Access-Control-Expose-Headers", p.exposed)
+	}
+}
+
+func (p *corsPolicy) setOriginHeaders(rw http.ResponseWriter, origin string) {
+	if p.anyOrigin && !p.credentials {
+		rw.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		rw.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		rw.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
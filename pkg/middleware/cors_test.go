@@ -0,0 +1,245 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CORS Suite", func() {
+	maxAge := options.Duration(10 * time.Minute)
+	global := &options.CORS{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"GET", "POST", "DELETE"},
+		AllowedHeaders:   []string{"Content-Type", "X-Requested-With"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           &maxAge,
+	}
+	upstreams := options.UpstreamConfig{
+		Upstreams: []options.Upstream{
+			{ID: "app", Path: "/", URI: "http://app.localhost"},
+			{ID: "public", Path: "/public/", URI: "http://public.localhost", CORS: &options.CORS{
+				AllowedOrigins: []string{"*"},
+				AllowedHeaders: []string{"*"},
+			}},
+			{ID: "internal", Path: "/internal/", URI: "http://internal.localhost", CORS: &options.CORS{}},
+		},
+	}
+	matchUpstream := func(req *http.Request) (string, bool) {
+		switch {
+		case strings.HasPrefix(req.URL.Path, "/public/"):
+			return "public", true
+		case strings.HasPrefix(req.URL.Path, "/internal/"):
+			return "internal", true
+		default:
+			return "app", true
+		}
+	}
+
+	type corsTableInput struct {
+		global          *options.CORS
+		method          string
+		path            string
+		requestHeaders  map[string]string
+		expectedCode    int
+		expectedHeaders map[string]string
+		expectNext      bool
+	}
+
+	DescribeTable("when serving a request",
+		func(in corsTableInput) {
+			nextCalled := false
+			handler := NewCORS(&CORSOptions{
+				Global:        in.global,
+				Upstreams:     upstreams,
+				ProxyPrefix:   "/oauth2",
+				MatchUpstream: matchUpstream,
+			})(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				nextCalled = true
+				rw.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(in.method, in.path, nil)
+			for header, value := range in.requestHeaders {
+				req.Header.Set(header, value)
+			}
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			Expect(nextCalled).To(Equal(in.expectNext))
+			Expect(rw.Code).To(Equal(in.expectedCode))
+			for _, header := range []string{
+				"Access-Control-Allow-Origin",
+				"Access-Control-Allow-Credentials",
+				"Access-Control-Allow-Methods",
+				"Access-Control-Allow-Headers",
+				"Access-Control-Expose-Headers",
+				"Access-Control-Max-Age",
+			} {
+				Expect(rw.Header().Get(header)).To(Equal(in.expectedHeaders[header]), header)
+			}
+		},
+		Entry("a same-origin request", corsTableInput{
+			global:       global,
+			method:       "GET",
+			path:         "/oauth2/userinfo",
+			expectedCode: http.StatusOK,
+			expectNext:   true,
+		}),
+		Entry("a request from an allowed origin", corsTableInput{
+			global:         global,
+			method:         "GET",
+			path:           "/oauth2/userinfo",
+			requestHeaders: map[string]string{"Origin": "https://app.example.com"},
+			expectedCode:   http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-Id",
+			},
+			expectNext: true,
+		}),
+		Entry("a request from a wildcard origin", corsTableInput{
+			global:         global,
+			method:         "GET",
+			path:           "/api",
+			requestHeaders: map[string]string{"Origin": "https://a.b.example.org"},
+			expectedCode:   http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://a.b.example.org",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-Id",
+			},
+			expectNext: true,
+		}),
+		Entry("a request from an origin that is not allowed", corsTableInput{
+			global:         global,
+			method:         "GET",
+			path:           "/api",
+			requestHeaders: map[string]string{"Origin": "https://example.org"},
+			expectedCode:   http.StatusOK,
+			expectNext:     true,
+		}),
+		Entry("a preflight from an allowed origin", corsTableInput{
+			global: global,
+			method: "OPTIONS",
+			path:   "/oauth2/userinfo",
+			requestHeaders: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "DELETE",
+				"Access-Control-Request-Headers": "content-type,x-requested-with",
+			},
+			expectedCode: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, POST, DELETE",
+				"Access-Control-Allow-Headers":     "content-type,x-requested-with",
+				"Access-Control-Max-Age":           "600",
+			},
+			expectNext: false,
+		}),
+		Entry("a preflight from an origin that is not allowed", corsTableInput{
+			global: global,
+			method: "OPTIONS",
+			path:   "/api",
+			requestHeaders: map[string]string{
+				"Origin":                        "https://evil.example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			expectedCode: http.StatusForbidden,
+			expectNext:   false,
+		}),
+		Entry("a preflight for a method that is not allowed", corsTableInput{
+			global: global,
+			method: "OPTIONS",
+			path:   "/api",
+			requestHeaders: map[string]string{
+				"Origin":                        "https://app.example.com",
+				"Access-Control-Request-Method": "PUT",
+			},
+			expectedCode: http.StatusForbidden,
+			expectNext:   false,
+		}),
+		Entry("a preflight for a header that is not allowed", corsTableInput{
+			global: global,
+			method: "OPTIONS",
+			path:   "/api",
+			requestHeaders: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "Authorization",
+			},
+			expectedCode: http.StatusForbidden,
+			expectNext:   false,
+		}),
+		Entry("an OPTIONS request that is not a preflight", corsTableInput{
+			global:         global,
+			method:         "OPTIONS",
+			path:           "/api",
+			requestHeaders: map[string]string{"Origin": "https://app.example.com"},
+			expectedCode:   http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-Id",
+			},
+			expectNext: true,
+		}),
+		Entry("a preflight to an upstream with its own configuration", corsTableInput{
+			global: global,
+			method: "OPTIONS",
+			path:   "/public/data",
+			requestHeaders: map[string]string{
+				"Origin":                         "https://anywhere.example.net",
+				"Access-Control-Request-Method":  "HEAD",
+				"Access-Control-Request-Headers": "Authorization",
+			},
+			expectedCode: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET, HEAD, POST",
+				"Access-Control-Allow-Headers": "Authorization",
+			},
+			expectNext: false,
+		}),
+		Entry("a request to an upstream that allows no origins", corsTableInput{
+			global:         global,
+			method:         "GET",
+			path:           "/internal/data",
+			requestHeaders: map[string]string{"Origin": "https://app.example.com"},
+			expectedCode:   http.StatusOK,
+			expectNext:     true,
+		}),
+		Entry("a preflight without a CORS configuration", corsTableInput{
+			global: nil,
+			method: "OPTIONS",
+			path:   "/api",
+			requestHeaders: map[string]string{
+				"Origin":                        "https://app.example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			expectedCode: http.StatusOK,
+			expectNext:   true,
+		}),
+	)
+
+	DescribeTable("matchOrigin",
+		func(allowed, origin string, expected bool) {
+			Expect(matchOrigin(allowed, origin)).To(Equal(expected))
+		},
+		Entry("an exact origin", "https://app.example.com", "https://app.example.com", true),
+		Entry("a different origin", "https://app.example.com", "https://app.example.com:8443", false),
+		Entry("a subdomain", "https://*.example.com", "https://app.example.com", true),
+		Entry("nested subdomains", "https://*.example.com", "https://a.b.example.com", true),
+		Entry("the parent domain", "https://*.example.com", "https://example.com", false),
+		Entry("another domain ending in the suffix", "https://*.example.com", "https://evil.com/.example.com", false),
+		Entry("a different scheme", "https://*.example.com", "http://app.example.com", false),
+	)
+})
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/justinas/alice"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+)
+
+// IdentityAssertionOptions contains all the options for the identity
+// assertion middleware
+type IdentityAssertionOptions struct {
+	// Header is the header the assertion is sent in
+	Header string
+
+	// MatchUpstream returns the ID of the upstream a request is proxied to,
+	// which is the audience of the assertion
+	MatchUpstream func(*http.Request) (string, bool)
+
+	// Sign mints an assertion of the session's identity for an audience
+	Sign func(*sessionsapi.SessionState, string) (string, error)
+
+	// ErrorHandler renders the response when no assertion can be minted
+	ErrorHandler func(http.ResponseWriter, *http.Request, error)
+}
+
+// NewIdentityAssertion creates a new middleware that sends a signed
+// assertion of the user's identity to the upstream serving the request.
+// Assertions sent by the client are always removed.
+func NewIdentityAssertion(opts *IdentityAssertionOptions) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			req.Header.Del(opts.Header)
+
+			session := middlewareapi.GetRequestScope(req).Session
+			audience, ok := opts.MatchUpstream(req)
+			if session == nil || !ok {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			assertion, err := opts.Sign(session, audience)
+			if err != nil {
+				opts.ErrorHandler(rw, req, fmt.Errorf("could not sign identity assertion for upstream %q: %v", audience, err))
+				return
+			}
+
+			req.Header.Set(opts.Header, assertion)
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// NewResponseIdentityAssertion creates a new middleware that sets a signed
+// assertion of the user's identity on the response, for the upstream the
+// request is meant for.
+// This is used by external authorization integrations that copy headers from
+// the authorization response onto the request they forward upstream.
+func NewResponseIdentityAssertion(opts *IdentityAssertionOptions) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			session := middlewareapi.GetRequestScope(req).Session
+			audience, ok := opts.MatchUpstream(req)
+			if session == nil || !ok {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			assertion, err := opts.Sign(session, audience)
+			if err != nil {
+				opts.ErrorHandler(rw, req, fmt.Errorf("could not sign identity assertion for upstream %q: %v", audience, err))
+				return
+			}
+
+			rw.Header().Set(opts.Header, assertion)
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Identity Assertion Suite", func() {
+	const header = "X-Assertion"
+
+	type identityAssertionTableInput struct {
+		session           *sessionsapi.SessionState
+		matches           bool
+		signErr           error
+		expectedAssertion string
+		expectedCode      int
+	}
+
+	DescribeTable("when serving a request",
+		func(in identityAssertionTableInput) {
+			var assertion string
+			handler := NewIdentityAssertion(&IdentityAssertionOptions{
+				Header: header,
+				MatchUpstream: func(*http.Request) (string, bool) {
+					return "orders", in.matches
+				},
+				Sign: func(session *sessionsapi.SessionState, audience string) (string, error) {
+					if in.signErr != nil {
+						return "", in.signErr
+					}
+					return session.User + "@" + audience, nil
+				},
+				ErrorHandler: func(rw http.ResponseWriter, _ *http.Request, err error) {
+					Expect(err).To(MatchError("could not sign identity assertion for upstream \"orders\": " + in.signErr.Error()))
+					rw.WriteHeader(http.StatusBadGateway)
+				},
+			})(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				assertion = req.Header.Get(header)
+			}))
+
+			req := httptest.NewRequest("GET", "/orders/", nil)
+			req.Header.Set(header, "forged")
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{Session: in.session})
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(in.expectedCode))
+			Expect(assertion).To(Equal(in.expectedAssertion))
+		},
+		Entry("with a session", identityAssertionTableInput{
+			session:           &sessionsapi.SessionState{User: "john"},
+			matches:           true,
+			expectedAssertion: "john@orders",
+			expectedCode:      http.StatusOK,
+		}),
+		Entry("without a session", identityAssertionTableInput{
+			session:           nil,
+			matches:           true,
+			expectedAssertion: "",
+			expectedCode:      http.StatusOK,
+		}),
+		Entry("without a matching upstream", identityAssertionTableInput{
+			session:           &sessionsapi.SessionState{User: "john"},
+			matches:           false,
+			expectedAssertion: "",
+			expectedCode:      http.StatusOK,
+		}),
+		Entry("when the assertion cannot be signed", identityAssertionTableInput{
+			session:           &sessionsapi.SessionState{User: "john"},
+			matches:           true,
+			signErr:           errors.New("signing failed"),
+			expectedAssertion: "",
+			expectedCode:      http.StatusBadGateway,
+		}),
+	)
+
+	DescribeTable("when authorizing a request",
+		func(in identityAssertionTableInput) {
+			handler := NewResponseIdentityAssertion(&IdentityAssertionOptions{
+				Header: header,
+				MatchUpstream: func(*http.Request) (string, bool) {
+					return "orders", in.matches
+				},
+				Sign: func(session *sessionsapi.SessionState, audience string) (string, error) {
+					if in.signErr != nil {
+						return "", in.signErr
+					}
+					return session.User + "@" + audience, nil
+				},
+				ErrorHandler: func(rw http.ResponseWriter, _ *http.Request, err error) {
+					Expect(err).To(MatchError("could not sign identity assertion for upstream \"orders\": " + in.signErr.Error()))
+					rw.WriteHeader(http.StatusBadGateway)
+				},
+			})(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/orders/", nil)
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{Session: in.session})
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(in.expectedCode))
+			Expect(rw.Header().Get(header)).To(Equal(in.expectedAssertion))
+		},
+		Entry("with a session", identityAssertionTableInput{
+			session:           &sessionsapi.SessionState{User: "john"},
+			matches:           true,
+			expectedAssertion: "john@orders",
+			expectedCode:      http.StatusOK,
+		}),
+		Entry("without a session", identityAssertionTableInput{
+			session:           nil,
+			matches:           true,
+			expectedAssertion: "",
+			expectedCode:      http.StatusOK,
+		}),
+		Entry("without a matching upstream", identityAssertionTableInput{
+			session:           &sessionsapi.SessionState{User: "john"},
+			matches:           false,
+			expectedAssertion: "",
+			expectedCode:      http.StatusOK,
+		}),
+		Entry("when the assertion cannot be signed", identityAssertionTableInput{
+			session:           &sessionsapi.SessionState{User: "john"},
+			matches:           true,
+			signErr:           errors.New("signing failed"),
+			expectedAssertion: "",
+			expectedCode:      http.StatusBadGateway,
+		}),
+	)
+})
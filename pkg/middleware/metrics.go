@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/justinas/alice"
+	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -15,6 +16,7 @@ var DefaultMetricsHandler = NewMetricsHandlerWithDefaultRegistry()
 // NewMetricsHandlerWithDefaultRegistry creates a new http.Handler for serving
 // metrics from the default prometheus.Registry.
 func NewMetricsHandlerWithDefaultRegistry() http.Handler {
+	registerDiscoveryMetrics(prometheus.DefaultRegisterer)
 	return NewMetricsHandler(prometheus.DefaultRegisterer, prometheus.DefaultGatherer)
 }
 
@@ -120,3 +122,13 @@ func registerRequestsLatencyHistogram(registerer prometheus.Registerer) *prometh
 
 	return histogram
 }
+
+// registerDiscoveryMetrics registers the 'oauth2_proxy_oidc_discovery_metadata_age_seconds'
+// metric, the age of the cached discovery metadata of the OIDC providers
+func registerDiscoveryMetrics(registerer prometheus.Registerer) {
+	if err := internaloidc.RegisterDiscoveryMetrics(registerer); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			panic(err)
+		}
+	}
+}
@@ -15,20 +15,22 @@ type Verifiable interface {
 }
 
 // NewReadynessCheck returns a middleware that performs deep health checks
-// (verifies the connection to any underlying store) on a specific `path`
-func NewReadynessCheck(path string, verifiable Verifiable) alice.Constructor {
+// (verifies the connection to any underlying store or provider) on a specific `path`
+func NewReadynessCheck(path string, verifiables ...Verifiable) alice.Constructor {
 	return func(next http.Handler) http.Handler {
-		return readynessCheck(path, verifiable, next)
+		return readynessCheck(path, verifiables, next)
 	}
 }
 
-func readynessCheck(path string, verifiable Verifiable, next http.Handler) http.Handler {
+func readynessCheck(path string, verifiables []Verifiable, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		if path != "" && req.URL.EscapedPath() == path {
-			if err := verifiable.VerifyConnection(req.Context()); err != nil {
-				rw.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(rw, "error: %v", err)
-				return
+			for _, verifiable := range verifiables {
+				if err := verifiable.VerifyConnection(req.Context()); err != nil {
+					rw.WriteHeader(http.StatusInternalServerError)
+					fmt.Fprintf(rw, "error: %v", err)
+					return
+				}
 			}
 			rw.WriteHeader(http.StatusOK)
 			fmt.Fprintf(rw, "OK")
@@ -14,6 +14,7 @@ var _ = Describe("ReadynessCheck suite", func() {
 	type requestTableInput struct {
 		readyPath        string
 		healthVerifiable Verifiable
+		otherVerifiable  Verifiable
 		requestString    string
 		expectedStatus   int
 		expectedBody     string
@@ -25,7 +26,11 @@ var _ = Describe("ReadynessCheck suite", func() {
 
 			rw := httptest.NewRecorder()
 
-			handler := NewReadynessCheck(in.readyPath, in.healthVerifiable)(http.NotFoundHandler())
+			verifiables := []Verifiable{in.healthVerifiable}
+			if in.otherVerifiable != nil {
+				verifiables = append(verifiables, in.otherVerifiable)
+			}
+			handler := NewReadynessCheck(in.readyPath, verifiables...)(http.NotFoundHandler())
 			handler.ServeHTTP(rw, req)
 
 			Expect(rw.Code).To(Equal(in.expectedStatus))
@@ -66,6 +71,14 @@ var _ = Describe("ReadynessCheck suite", func() {
 			expectedStatus:   500,
 			expectedBody:     "error: failed to check",
 		}),
+		Entry("with full health check and with an error of another underlying verifiable", &requestTableInput{
+			readyPath:        "/ready",
+			healthVerifiable: &fakeVerifiable{nil},
+			otherVerifiable:  &fakeVerifiable{func(ctx context.Context) error { return errors.New("not discovered") }},
+			requestString:    "http://example.com/ready",
+			expectedStatus:   500,
+			expectedBody:     "error: not discovered",
+		}),
 	)
 })
 
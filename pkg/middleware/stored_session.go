@@ -11,6 +11,7 @@ import (
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/binding"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
 )
 
@@ -41,6 +42,25 @@ type StoredSessionLoaderOptions struct {
 	// How often should sessions be refreshed
 	RefreshPeriod time.Duration
 
+	// How long a session may go unused before it is rejected, 0 disables
+	// the idle timeout
+	IdleTimeout time.Duration
+
+	// How often the last seen time of a session should be updated
+	LastSeenInterval time.Duration
+
+	// How long after the user authenticated a session is rejected, regardless
+	// of any refreshes. 0 disables the maximum lifetime
+	MaxLifetime time.Duration
+
+	// Binds sessions to the client that created them, nil disables
+	// session binding
+	SessionBinder *binding.Binder
+
+	// Whether sessions used by a client that doesn't match their binding are
+	// cleared, rather than only ignored for the request
+	ClearMismatchedSessions bool
+
 	// Provider based session refreshing
 	RefreshSession func(context.Context, *sessionsapi.SessionState) (bool, error)
 
@@ -58,6 +78,11 @@ func NewStoredSessionLoader(opts *StoredSessionLoaderOptions) alice.Constructor
 	ss := &storedSessionLoader{
 		store:            opts.SessionStore,
 		refreshPeriod:    opts.RefreshPeriod,
+		idleTimeout:      opts.IdleTimeout,
+		lastSeenInterval: opts.LastSeenInterval,
+		maxLifetime:      opts.MaxLifetime,
+		sessionBinder:    opts.SessionBinder,
+		clearMismatched:  opts.ClearMismatchedSessions,
 		sessionRefresher: opts.RefreshSession,
 		sessionValidator: opts.ValidateSession,
 	}
@@ -69,6 +94,11 @@ func NewStoredSessionLoader(opts *StoredSessionLoaderOptions) alice.Constructor
 type storedSessionLoader struct {
 	store            sessionsapi.SessionStore
 	refreshPeriod    time.Duration
+	idleTimeout      time.Duration
+	lastSeenInterval time.Duration
+	maxLifetime      time.Duration
+	sessionBinder    *binding.Binder
+	clearMismatched  bool
 	sessionRefresher func(context.Context, *sessionsapi.SessionState) (bool, error)
 	sessionValidator func(context.Context, *sessionsapi.SessionState) bool
 }
@@ -113,11 +143,44 @@ func (s *storedSessionLoader) getValidatedSession(rw http.ResponseWriter, req *h
 		return nil, err
 	}
 
+	if session.ExceedsLifetime(s.maxLifetime) {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Session exceeded the maximum lifetime of %s", s.maxLifetime)
+		return nil, fmt.Errorf("session (%s) exceeded the maximum lifetime of %s", session, s.maxLifetime)
+	}
+	if session.IsIdle(s.idleTimeout) {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Session was idle for longer than %s", s.idleTimeout)
+		return nil, fmt.Errorf("session (%s) was idle for longer than %s", session, s.idleTimeout)
+	}
+	if err := s.sessionBinder.Verify(req, session); err != nil {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Session rejected: %v", err)
+		if !s.clearMismatched {
+			// Ignore the session for this request only, the client it is
+			// bound to can keep using it
+			return nil, nil
+		}
+		return nil, fmt.Errorf("session (%s) rejected: %v", session, err)
+	}
+
 	err = s.refreshSessionIfNeeded(rw, req, session)
 	if err != nil {
 		return nil, fmt.Errorf("error refreshing access token for session (%s): %v", session, err)
 	}
 
+	if s.idleTimeout > 0 && session.UpdateLastSeen(s.lastSeenInterval) {
+		session.NeedsSave = true
+	}
+
+	if session.NeedsSave {
+		// The session needs to be persisted again, eg. because it was signed
+		// with a previous cookie secret or its last seen time was updated.
+		err = s.store.Save(rw, req, session)
+		if err != nil {
+			logger.PrintAuthf(session.Email, req, logger.AuthError, "error saving session: %v", err)
+		} else {
+			session.NeedsSave = false
+		}
+	}
+
 	return session, nil
 }
 
@@ -125,7 +188,9 @@ func (s *storedSessionLoader) getValidatedSession(rw http.ResponseWriter, req *h
 // is older than the refresh period.
 // Success or fail, we will then validate the session.
 func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState) error {
-	if !needsRefresh(s.refreshPeriod, session) {
+	scope := middlewareapi.GetRequestScope(req)
+	forceRefresh := scope != nil && scope.ForceRefresh
+	if !forceRefresh && !needsRefresh(s.refreshPeriod, session) {
 		// Refresh is disabled or the session is not old enough, do nothing
 		return nil
 	}
@@ -173,13 +238,20 @@ func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req
 	// Restore the state of the fresh session into the original pointer.
 	// This is important so that changes are passed up the to the parent scope.
 	lock := session.Lock
+	binding := session.Binding
+	needsSave := session.NeedsSave
 	*session = *freshSession
 
 	// Ensure we maintain the session lock after we have refreshed the session.
 	// Loading from the session store creates a new lock in the session.
 	session.Lock = lock
+	// Keep the binding verified for this request and whether the session
+	// still needs to be persisted, so that the refreshed session is saved
+	// with them.
+	session.Binding = binding
+	session.NeedsSave = session.NeedsSave || needsSave
 
-	if !needsRefresh(s.refreshPeriod, session) {
+	if !forceRefresh && !needsRefresh(s.refreshPeriod, session) {
 		// The session must have already been refreshed while we were waiting to
 		// obtain the lock.
 		return nil
@@ -188,6 +260,12 @@ func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req
 	// We are holding the lock and the session needs a refresh
 	logger.Printf("Refreshing session - User: %s; SessionAge: %s", session.User, session.Age())
 	if err := s.refreshSession(rw, req, session); err != nil {
+		// The provider no longer authorizes the user, e.g. the refreshed
+		// claims no longer satisfy the required claims, the session must not
+		// be used any longer.
+		if errors.Is(err, providers.ErrNotAuthorized) {
+			return err
+		}
 		// If a preemptive refresh fails, we still keep the session
 		// if validateSession succeeds.
 		logger.Errorf("Unable to refresh session: %v", err)
@@ -206,6 +284,9 @@ func needsRefresh(refreshPeriod time.Duration, session *sessionsapi.SessionState
 // and will save the session if it was updated.
 func (s *storedSessionLoader) refreshSession(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState) error {
 	refreshed, err := s.sessionRefresher(req.Context(), session)
+	if errors.Is(err, providers.ErrNotAuthorized) {
+		return err
+	}
 	if err != nil && !errors.Is(err, providers.ErrNotImplemented) {
 		return fmt.Errorf("error refreshing tokens: %v", err)
 	}
@@ -216,8 +297,19 @@ func (s *storedSessionLoader) refreshSession(rw http.ResponseWriter, req *http.R
 	// Pretend it refreshed to reset the refresh timer so that `ValidateSession`
 	// isn't triggered every subsequent request and is only called once during
 	// this request.
-	if errors.Is(err, providers.ErrNotImplemented) {
+	scope := middlewareapi.GetRequestScope(req)
+	switch {
+	case errors.Is(err, providers.ErrNotImplemented):
 		refreshed = true
+		if scope != nil {
+			scope.SessionNotRefreshable = "the provider does not support refreshing sessions"
+		}
+	case refreshed:
+		if scope != nil {
+			scope.SessionRefreshed = true
+		}
+	case scope != nil:
+		scope.SessionNotRefreshable = "the session has no refresh token"
 	}
 
 	// Session not refreshed, nothing to persist.
@@ -235,6 +327,7 @@ func (s *storedSessionLoader) refreshSession(rw http.ResponseWriter, req *http.R
 		logger.PrintAuthf(session.Email, req, logger.AuthError, "error saving session: %v", err)
 		return fmt.Errorf("error saving session: %v", err)
 	}
+	session.NeedsSave = false
 	return nil
 }
 
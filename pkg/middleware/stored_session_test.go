@@ -10,8 +10,10 @@ import (
 	"time"
 
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/binding"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -87,6 +89,8 @@ var _ = Describe("Stored Session Suite", func() {
 		refreshed      = "Refreshed"
 		noRefresh      = "NoRefresh"
 		notImplemented = "NotImplemented"
+		unauthorized   = "Unauthorized"
+		revoked        = "Revoked"
 	)
 
 	var ctx = context.Background()
@@ -440,8 +444,11 @@ var _ = Describe("Stored Session Suite", func() {
 			refreshPeriod            time.Duration
 			session                  *sessionsapi.SessionState
 			concurrentSessionRefresh bool
+			forceRefresh             bool
 			expectedErr              error
 			expectRefreshed          bool
+			expectSessionRefreshed   bool
+			expectNotRefreshable     string
 			expectValidated          bool
 			expectedLockObtained     bool
 		}
@@ -485,6 +492,10 @@ var _ = Describe("Stored Session Suite", func() {
 							return false, nil
 						case notImplemented:
 							return false, providers.ErrNotImplemented
+						case unauthorized:
+							return false, fmt.Errorf("unable to redeem refresh token: %w", providers.ErrRequiredClaimNotSatisfied)
+						case revoked:
+							return false, fmt.Errorf("%w: not a member of the allowed organizations", providers.ErrNotAuthorized)
 						default:
 							return false, errors.New("error refreshing session")
 						}
@@ -495,7 +506,8 @@ var _ = Describe("Stored Session Suite", func() {
 					},
 				}
 
-				req := httptest.NewRequest("", "/", nil)
+				scope := &middlewareapi.RequestScope{ForceRefresh: in.forceRefresh}
+				req := middlewareapi.AddRequestScope(httptest.NewRequest("", "/", nil), scope)
 				err := s.refreshSessionIfNeeded(nil, req, in.session)
 				if in.expectedErr != nil {
 					Expect(err).To(MatchError(in.expectedErr))
@@ -503,6 +515,8 @@ var _ = Describe("Stored Session Suite", func() {
 					Expect(err).ToNot(HaveOccurred())
 				}
 				Expect(refreshed).To(Equal(in.expectRefreshed))
+				Expect(scope.SessionRefreshed).To(Equal(in.expectSessionRefreshed))
+				Expect(scope.SessionNotRefreshable).To(Equal(in.expectNotRefreshable))
 				Expect(validated).To(Equal(in.expectValidated))
 				testLock, ok := in.session.Lock.(*testLock)
 				Expect(ok).To(Equal(true))
@@ -555,10 +569,84 @@ var _ = Describe("Stored Session Suite", func() {
 					CreatedAt:    &createdPast,
 					Lock:         &testLock{},
 				},
-				expectedErr:          nil,
-				expectRefreshed:      true,
-				expectValidated:      true,
-				expectedLockObtained: true,
+				expectedErr:            nil,
+				expectRefreshed:        true,
+				expectSessionRefreshed: true,
+				expectValidated:        true,
+				expectedLockObtained:   true,
+			}),
+			Entry("when the refresh period is 0, and a refresh is forced", refreshSessionIfNeededTableInput{
+				refreshPeriod: time.Duration(0),
+				session: &sessionsapi.SessionState{
+					RefreshToken: refresh,
+					CreatedAt:    &createdFuture,
+					Lock:         &testLock{},
+				},
+				forceRefresh:           true,
+				expectedErr:            nil,
+				expectRefreshed:        true,
+				expectSessionRefreshed: true,
+				expectValidated:        true,
+				expectedLockObtained:   true,
+			}),
+			Entry("when the session does not need refreshing, but a refresh is forced", refreshSessionIfNeededTableInput{
+				refreshPeriod: 1 * time.Minute,
+				session: &sessionsapi.SessionState{
+					RefreshToken: refresh,
+					CreatedAt:    &createdFuture,
+					Lock:         &testLock{},
+				},
+				concurrentSessionRefresh: true,
+				forceRefresh:             true,
+				expectedErr:              nil,
+				expectRefreshed:          true,
+				expectSessionRefreshed:   true,
+				expectValidated:          true,
+				expectedLockObtained:     true,
+			}),
+			Entry("when a refresh is forced, but the provider doesn't implement refresh", refreshSessionIfNeededTableInput{
+				refreshPeriod: 1 * time.Minute,
+				session: &sessionsapi.SessionState{
+					RefreshToken: notImplemented,
+					CreatedAt:    &createdFuture,
+					Lock:         &testLock{},
+				},
+				forceRefresh:           true,
+				expectedErr:            nil,
+				expectRefreshed:        true,
+				expectSessionRefreshed: false,
+				expectNotRefreshable:   "the provider does not support refreshing sessions",
+				expectValidated:        true,
+				expectedLockObtained:   true,
+			}),
+			Entry("when a refresh is forced, but the session has no refresh token", refreshSessionIfNeededTableInput{
+				refreshPeriod: 1 * time.Minute,
+				session: &sessionsapi.SessionState{
+					RefreshToken: noRefresh,
+					CreatedAt:    &createdFuture,
+					Lock:         &testLock{},
+				},
+				forceRefresh:           true,
+				expectedErr:            nil,
+				expectRefreshed:        true,
+				expectSessionRefreshed: false,
+				expectNotRefreshable:   "the session has no refresh token",
+				expectValidated:        true,
+				expectedLockObtained:   true,
+			}),
+			Entry("when a refresh is forced, but the provider fails to refresh", refreshSessionIfNeededTableInput{
+				refreshPeriod: 1 * time.Minute,
+				session: &sessionsapi.SessionState{
+					RefreshToken: "RefreshError",
+					CreatedAt:    &createdFuture,
+					Lock:         &testLock{},
+				},
+				forceRefresh:           true,
+				expectedErr:            nil,
+				expectRefreshed:        true,
+				expectSessionRefreshed: false,
+				expectValidated:        true,
+				expectedLockObtained:   true,
 			}),
 			Entry("when obtaining lock failed, but concurrent request refreshed", refreshSessionIfNeededTableInput{
 				refreshPeriod: 1 * time.Minute,
@@ -599,6 +687,7 @@ var _ = Describe("Stored Session Suite", func() {
 				},
 				expectedErr:          nil,
 				expectRefreshed:      true,
+				expectNotRefreshable: "the session has no refresh token",
 				expectValidated:      true,
 				expectedLockObtained: true,
 			}),
@@ -611,6 +700,7 @@ var _ = Describe("Stored Session Suite", func() {
 				},
 				expectedErr:          nil,
 				expectRefreshed:      true,
+				expectNotRefreshable: "the provider does not support refreshing sessions",
 				expectValidated:      true,
 				expectedLockObtained: true,
 			}),
@@ -625,10 +715,80 @@ var _ = Describe("Stored Session Suite", func() {
 				},
 				expectedErr:          errors.New("session is invalid"),
 				expectRefreshed:      true,
+				expectNotRefreshable: "the session has no refresh token",
 				expectValidated:      true,
 				expectedLockObtained: true,
 			}),
+			Entry("when the refreshed session no longer satisfies the required claims", refreshSessionIfNeededTableInput{
+				refreshPeriod: 1 * time.Minute,
+				session: &sessionsapi.SessionState{
+					RefreshToken: unauthorized,
+					CreatedAt:    &createdPast,
+					ExpiresOn:    &createdFuture,
+					Lock:         &testLock{},
+				},
+				expectedErr:          providers.ErrRequiredClaimNotSatisfied,
+				expectRefreshed:      true,
+				expectValidated:      false,
+				expectedLockObtained: true,
+			}),
+			Entry("when the provider no longer authorizes the user", refreshSessionIfNeededTableInput{
+				refreshPeriod: 1 * time.Minute,
+				session: &sessionsapi.SessionState{
+					RefreshToken: revoked,
+					CreatedAt:    &createdPast,
+					ExpiresOn:    &createdFuture,
+					Lock:         &testLock{},
+				},
+				expectedErr:          providers.ErrNotAuthorized,
+				expectRefreshed:      true,
+				expectValidated:      false,
+				expectedLockObtained: true,
+			}),
 		)
+
+		It("keeps the binding and pending save of the session when reloading it", func() {
+			var saved *sessionsapi.SessionState
+			s := &storedSessionLoader{
+				refreshPeriod: 1 * time.Minute,
+				store: &fakeSessionStore{
+					LoadFunc: func(_ *http.Request) (*sessionsapi.SessionState, error) {
+						return &sessionsapi.SessionState{
+							RefreshToken: refresh,
+							CreatedAt:    &createdPast,
+							ExpiresOn:    &createdFuture,
+							Lock:         &testLock{},
+						}, nil
+					},
+					SaveFunc: func(_ http.ResponseWriter, _ *http.Request, ss *sessionsapi.SessionState) error {
+						saved = &sessionsapi.SessionState{}
+						*saved = *ss
+						return nil
+					},
+				},
+				sessionRefresher: func(context.Context, *sessionsapi.SessionState) (bool, error) {
+					return true, nil
+				},
+				sessionValidator: func(context.Context, *sessionsapi.SessionState) bool {
+					return true
+				},
+			}
+
+			session := &sessionsapi.SessionState{
+				RefreshToken: refresh,
+				CreatedAt:    &createdPast,
+				ExpiresOn:    &createdFuture,
+				Binding:      "binding",
+				NeedsSave:    true,
+				Lock:         &testLock{},
+			}
+			req := middlewareapi.AddRequestScope(httptest.NewRequest("", "/", nil), &middlewareapi.RequestScope{})
+			Expect(s.refreshSessionIfNeeded(nil, req, session)).To(Succeed())
+			Expect(saved).ToNot(BeNil())
+			Expect(saved.Binding).To(Equal("binding"))
+			Expect(saved.NeedsSave).To(BeTrue())
+			Expect(session.Binding).To(Equal("binding"))
+		})
 	})
 
 	Context("refreshSession", func() {
@@ -719,6 +879,198 @@ var _ = Describe("Stored Session Suite", func() {
 		)
 	})
 
+	Context("getValidatedSession", func() {
+		It("saves a session that needs to be saved again", func() {
+			var saved []*sessionsapi.SessionState
+			s := &storedSessionLoader{
+				store: &fakeSessionStore{
+					LoadFunc: func(_ *http.Request) (*sessionsapi.SessionState, error) {
+						return &sessionsapi.SessionState{
+							AccessToken: "Valid",
+							NeedsSave:   true,
+						}, nil
+					},
+					SaveFunc: func(_ http.ResponseWriter, _ *http.Request, ss *sessionsapi.SessionState) error {
+						saved = append(saved, ss)
+						return nil
+					},
+				},
+				refreshPeriod: 0,
+			}
+
+			req := httptest.NewRequest("", "/", nil)
+			session, err := s.getValidatedSession(httptest.NewRecorder(), req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(saved).To(ConsistOf(session))
+			Expect(session.NeedsSave).To(BeFalse())
+		})
+
+		type activityTableInput struct {
+			createdAt        time.Time
+			authenticatedAt  time.Time
+			lastSeenAt       time.Time
+			idleTimeout      time.Duration
+			lastSeenInterval time.Duration
+			maxLifetime      time.Duration
+			expectedErr      error
+			expectedSaved    bool
+			expectedLastSeen time.Time
+		}
+
+		now := time.Unix(1700000000, 0)
+
+		DescribeTable("with idle timeouts and maximum lifetimes",
+			func(in activityTableInput) {
+				saved := false
+				s := &storedSessionLoader{
+					store: &fakeSessionStore{
+						LoadFunc: func(_ *http.Request) (*sessionsapi.SessionState, error) {
+							ss := &sessionsapi.SessionState{
+								AccessToken:     "Valid",
+								CreatedAt:       &in.createdAt,
+								AuthenticatedAt: &in.authenticatedAt,
+								LastSeenAt:      &in.lastSeenAt,
+							}
+							ss.Clock.Set(now)
+							return ss, nil
+						},
+						SaveFunc: func(_ http.ResponseWriter, _ *http.Request, _ *sessionsapi.SessionState) error {
+							saved = true
+							return nil
+						},
+					},
+					idleTimeout:      in.idleTimeout,
+					lastSeenInterval: in.lastSeenInterval,
+					maxLifetime:      in.maxLifetime,
+				}
+
+				req := middlewareapi.AddRequestScope(httptest.NewRequest("", "/", nil), &middlewareapi.RequestScope{})
+				session, err := s.getValidatedSession(httptest.NewRecorder(), req)
+				if in.expectedErr != nil {
+					Expect(err).To(MatchError(ContainSubstring(in.expectedErr.Error())))
+					Expect(session).To(BeNil())
+					Expect(saved).To(BeFalse())
+					return
+				}
+				Expect(err).ToNot(HaveOccurred())
+				Expect(saved).To(Equal(in.expectedSaved))
+				Expect(*session.LastSeenAt).To(Equal(in.expectedLastSeen))
+			},
+			Entry("with both disabled", activityTableInput{
+				createdAt:        now.Add(-30 * 24 * time.Hour),
+				authenticatedAt:  now.Add(-30 * 24 * time.Hour),
+				lastSeenAt:       now.Add(-30 * 24 * time.Hour),
+				lastSeenInterval: 5 * time.Minute,
+				expectedSaved:    false,
+				expectedLastSeen: now.Add(-30 * 24 * time.Hour),
+			}),
+			Entry("with a session seen within the last seen interval", activityTableInput{
+				createdAt:        now.Add(-time.Hour),
+				authenticatedAt:  now.Add(-time.Hour),
+				lastSeenAt:       now.Add(-time.Minute),
+				idleTimeout:      30 * time.Minute,
+				lastSeenInterval: 5 * time.Minute,
+				expectedSaved:    false,
+				expectedLastSeen: now.Add(-time.Minute),
+			}),
+			Entry("with a session seen before the last seen interval", activityTableInput{
+				createdAt:        now.Add(-time.Hour),
+				authenticatedAt:  now.Add(-time.Hour),
+				lastSeenAt:       now.Add(-10 * time.Minute),
+				idleTimeout:      30 * time.Minute,
+				lastSeenInterval: 5 * time.Minute,
+				expectedSaved:    true,
+				expectedLastSeen: now,
+			}),
+			Entry("with an idle session", activityTableInput{
+				createdAt:        now.Add(-time.Hour),
+				authenticatedAt:  now.Add(-time.Hour),
+				lastSeenAt:       now.Add(-31 * time.Minute),
+				idleTimeout:      30 * time.Minute,
+				lastSeenInterval: 5 * time.Minute,
+				expectedErr:      errors.New("was idle for longer than 30m0s"),
+			}),
+			Entry("with a session within the maximum lifetime", activityTableInput{
+				createdAt:        now.Add(-time.Hour),
+				authenticatedAt:  now.Add(-time.Hour),
+				lastSeenAt:       now.Add(-time.Hour),
+				maxLifetime:      8 * time.Hour,
+				lastSeenInterval: 5 * time.Minute,
+				expectedSaved:    false,
+				expectedLastSeen: now.Add(-time.Hour),
+			}),
+			Entry("with a refreshed session beyond the maximum lifetime", activityTableInput{
+				createdAt:        now.Add(-time.Minute),
+				authenticatedAt:  now.Add(-9 * time.Hour),
+				lastSeenAt:       now.Add(-time.Minute),
+				idleTimeout:      30 * time.Minute,
+				lastSeenInterval: 5 * time.Minute,
+				maxLifetime:      8 * time.Hour,
+				expectedErr:      errors.New("exceeded the maximum lifetime of 8h0m0s"),
+			}),
+		)
+
+		type bindingTableInput struct {
+			userAgent       string
+			clearMismatched bool
+			expectSession   bool
+			expectedErr     error
+		}
+
+		DescribeTable("with session binding",
+			func(in bindingTableInput) {
+				binder := binding.NewBinder(options.SessionBindingOptions{
+					Attributes: []string{options.SessionBindingUserAgent},
+				}, nil)
+
+				bound := &sessionsapi.SessionState{AccessToken: "Valid"}
+				boundReq := httptest.NewRequest("", "/", nil)
+				boundReq.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0")
+				binder.Bind(boundReq, bound)
+
+				s := &storedSessionLoader{
+					store: &fakeSessionStore{
+						LoadFunc: func(_ *http.Request) (*sessionsapi.SessionState, error) {
+							return bound, nil
+						},
+					},
+					sessionBinder:   binder,
+					clearMismatched: in.clearMismatched,
+				}
+
+				req := middlewareapi.AddRequestScope(httptest.NewRequest("", "/", nil), &middlewareapi.RequestScope{})
+				req.Header.Set("User-Agent", in.userAgent)
+				session, err := s.getValidatedSession(httptest.NewRecorder(), req)
+				if in.expectedErr != nil {
+					Expect(err).To(MatchError(ContainSubstring(in.expectedErr.Error())))
+				} else {
+					Expect(err).ToNot(HaveOccurred())
+				}
+				if in.expectSession {
+					Expect(session).To(Equal(bound))
+				} else {
+					Expect(session).To(BeNil())
+				}
+			},
+			Entry("with a matching client", bindingTableInput{
+				userAgent:       "Mozilla/5.0 (X11; Linux x86_64; rv:130.0) Gecko/20100101 Firefox/130.0",
+				clearMismatched: true,
+				expectSession:   true,
+			}),
+			Entry("with a mismatched client when reauthenticating", bindingTableInput{
+				userAgent:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+				clearMismatched: true,
+				expectSession:   false,
+				expectedErr:     binding.ErrMismatch,
+			}),
+			Entry("with a mismatched client when rejecting", bindingTableInput{
+				userAgent:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+				clearMismatched: false,
+				expectSession:   false,
+			}),
+		)
+	})
+
 	Context("validateSession", func() {
 		var s *storedSessionLoader
 
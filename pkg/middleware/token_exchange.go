@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/justinas/alice"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
+)
+
+const (
+	// Exchanged tokens are renewed this long before they expire, so that
+	// they don't expire on the way to the upstream
+	exchangedTokenExpirySkew = 30 * time.Second
+
+	// The maximum number of exchanged tokens kept in the cache
+	exchangedTokenCacheSize = 10000
+)
+
+// TokenExchangeOptions contains all the options for the token exchange
+// middleware
+type TokenExchangeOptions struct {
+	// Upstreams is the upstream configuration, the token exchange targets
+	// are read from the upstreams
+	Upstreams options.UpstreamConfig
+
+	// MatchUpstream returns the ID of the upstream a request is proxied to
+	MatchUpstream func(*http.Request) (string, bool)
+
+	// ExchangeToken exchanges a session's access token with the provider
+	ExchangeToken func(context.Context, string, options.TokenExchange) (*providers.ExchangedToken, error)
+
+	// ErrorHandler renders the response when no token can be obtained
+	ErrorHandler func(http.ResponseWriter, *http.Request, error)
+}
+
+// NewTokenExchange creates a new middleware that replaces the Authorization
+// header of requests to upstreams with a token exchange target with a token
+// exchanged for the session's access token.
+func NewTokenExchange(opts *TokenExchangeOptions) alice.Constructor {
+	targets := make(map[string]options.TokenExchange)
+	for _, u := range opts.Upstreams.Upstreams {
+		if u.TokenExchange != nil {
+			targets[u.ID] = *u.TokenExchange
+		}
+	}
+	if len(targets) == 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	te := &tokenExchange{
+		matchUpstream: opts.MatchUpstream,
+		targets:       targets,
+		exchangeToken: opts.ExchangeToken,
+		errorHandler:  opts.ErrorHandler,
+		cache: &exchangedTokenCache{
+			entries: make(map[string]cachedToken),
+		},
+	}
+	return te.injectExchangedToken
+}
+
+// tokenExchange exchanges and caches the tokens for upstreams
+type tokenExchange struct {
+	matchUpstream func(*http.Request) (string, bool)
+	targets       map[string]options.TokenExchange
+	exchangeToken func(context.Context, string, options.TokenExchange) (*providers.ExchangedToken, error)
+	errorHandler  func(http.ResponseWriter, *http.Request, error)
+	cache         *exchangedTokenCache
+}
+
+func (te *tokenExchange) injectExchangedToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		id, ok := te.matchUpstream(req)
+		target, hasTarget := te.targets[id]
+		if !ok || !hasTarget {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		session := middlewareapi.GetRequestScope(req).Session
+		if session == nil || session.AccessToken == "" {
+			// Requests allowed without authentication have no token to
+			// exchange, they are passed on unchanged
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		token, err := te.getToken(req.Context(), session.AccessToken, id, target, session.ExpiresOn)
+		if err != nil {
+			te.errorHandler(rw, req, fmt.Errorf("could not exchange token for upstream %q: %v", id, err))
+			return
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// getToken returns the cached token for the session's access token and
+// upstream, exchanging it with the provider when there is none
+func (te *tokenExchange) getToken(ctx context.Context, subjectToken, upstreamID string, target options.TokenExchange, sessionExpiresOn *time.Time) (string, error) {
+	key := exchangedTokenKey(subjectToken, upstreamID)
+	if token, ok := te.cache.get(key); ok {
+		return token, nil
+	}
+
+	exchanged, err := te.exchangeToken(ctx, subjectToken, target)
+	if err != nil {
+		return "", err
+	}
+
+	// Without a lifetime, the token is trusted to live as long as the
+	// session's access token
+	expiresOn := exchanged.ExpiresOn
+	if expiresOn == nil {
+		expiresOn = sessionExpiresOn
+	}
+	if expiresOn != nil {
+		te.cache.set(key, exchanged.AccessToken, expiresOn.Add(-exchangedTokenExpirySkew))
+	}
+	return exchanged.AccessToken, nil
+}
+
+// exchangedTokenKey identifies the exchanged token of a session for an
+// upstream. The session's access token changes when the session is
+// refreshed, which also discards the exchanged tokens.
+func exchangedTokenKey(subjectToken, upstreamID string) string {
+	hash := sha256.Sum256([]byte(subjectToken + "\x00" + upstreamID))
+	return hex.EncodeToString(hash[:])
+}
+
+type cachedToken struct {
+	token     string
+	expiresOn time.Time
+}
+
+// exchangedTokenCache keeps exchanged tokens in memory until they expire
+type exchangedTokenCache struct {
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]cachedToken
+}
+
+func (c *exchangedTokenCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if !c.Clock.Now().Before(entry.expiresOn) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return entry.token, true
+}
+
+func (c *exchangedTokenCache) set(key, token string, expiresOn time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.Clock.Now()
+	if !now.Before(expiresOn) {
+		return
+	}
+
+	if len(c.entries) >= exchangedTokenCacheSize {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresOn) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) >= exchangedTokenCacheSize {
+		// All tokens are still valid, drop an arbitrary one to make room.
+		// It is exchanged again when it is next needed.
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+
+	c.entries[key] = cachedToken{token: token, expiresOn: expiresOn}
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Token Exchange Suite", func() {
+	ordersTarget := options.TokenExchange{Audience: "orders-api", Scopes: []string{"orders:read"}}
+	upstreams := options.UpstreamConfig{
+		Upstreams: []options.Upstream{
+			{ID: "app", Path: "/", URI: "http://app.localhost"},
+			{ID: "orders", Path: "/orders/", URI: "http://orders.localhost", TokenExchange: &ordersTarget},
+		},
+	}
+	matchUpstream := func(req *http.Request) (string, bool) {
+		if req.URL.Path == "/orders/" {
+			return "orders", true
+		}
+		return "app", true
+	}
+
+	var exchanges []string
+	var exchangeErr error
+	var handlerErr error
+	var authorization string
+	var nextCalled bool
+
+	exchangeToken := func(_ context.Context, subjectToken string, target options.TokenExchange) (*providers.ExchangedToken, error) {
+		Expect(target).To(Equal(ordersTarget))
+		exchanges = append(exchanges, subjectToken)
+		if exchangeErr != nil {
+			return nil, exchangeErr
+		}
+		expiresOn := time.Now().Add(time.Hour)
+		return &providers.ExchangedToken{AccessToken: "exchanged-" + subjectToken, ExpiresOn: &expiresOn}, nil
+	}
+
+	serve := func(path string, session *sessionsapi.SessionState) *httptest.ResponseRecorder {
+		handler := NewTokenExchange(&TokenExchangeOptions{
+			Upstreams:     upstreams,
+			MatchUpstream: matchUpstream,
+			ExchangeToken: exchangeToken,
+			ErrorHandler: func(rw http.ResponseWriter, _ *http.Request, err error) {
+				handlerErr = err
+				rw.WriteHeader(http.StatusBadGateway)
+			},
+		})(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			nextCalled = true
+			authorization = req.Header.Get("Authorization")
+		}))
+
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer original")
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{Session: session})
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	BeforeEach(func() {
+		exchanges = nil
+		exchangeErr = nil
+		handlerErr = nil
+		authorization = ""
+		nextCalled = false
+	})
+
+	It("passes requests to upstreams without a target on unchanged", func() {
+		serve("/", &sessionsapi.SessionState{AccessToken: "login"})
+		Expect(nextCalled).To(BeTrue())
+		Expect(exchanges).To(BeEmpty())
+		Expect(authorization).To(Equal("Bearer original"))
+	})
+
+	It("passes requests without a session on unchanged", func() {
+		serve("/orders/", nil)
+		Expect(nextCalled).To(BeTrue())
+		Expect(exchanges).To(BeEmpty())
+		Expect(authorization).To(Equal("Bearer original"))
+	})
+
+	It("sends the exchanged token to the upstream", func() {
+		serve("/orders/", &sessionsapi.SessionState{AccessToken: "login"})
+		Expect(nextCalled).To(BeTrue())
+		Expect(exchanges).To(Equal([]string{"login"}))
+		Expect(authorization).To(Equal("Bearer exchanged-login"))
+	})
+
+	It("renders an error when the token cannot be exchanged", func() {
+		exchangeErr = errors.New("invalid_target")
+		rw := serve("/orders/", &sessionsapi.SessionState{AccessToken: "login"})
+		Expect(nextCalled).To(BeFalse())
+		Expect(rw.Code).To(Equal(http.StatusBadGateway))
+		Expect(handlerErr).To(MatchError("could not exchange token for upstream \"orders\": invalid_target"))
+	})
+
+	Context("getToken", func() {
+		now := time.Unix(1700000000, 0)
+		var te *tokenExchange
+
+		BeforeEach(func() {
+			te = &tokenExchange{
+				exchangeToken: exchangeToken,
+				cache: &exchangedTokenCache{
+					entries: make(map[string]cachedToken),
+				},
+			}
+			te.cache.Clock.Set(now)
+		})
+
+		It("caches the token per session and upstream", func() {
+			for _, subject := range []string{"login", "login", "other", "login"} {
+				token, err := te.getToken(context.Background(), subject, "orders", ordersTarget, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(token).To(Equal("exchanged-" + subject))
+			}
+			_, err := te.getToken(context.Background(), "login", "billing", ordersTarget, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(exchanges).To(Equal([]string{"login", "other", "login"}))
+		})
+
+		It("exchanges the token again once it expires", func() {
+			_, err := te.getToken(context.Background(), "login", "orders", ordersTarget, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			te.cache.Clock.Set(time.Now().Add(time.Hour))
+			_, err = te.getToken(context.Background(), "login", "orders", ordersTarget, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(exchanges).To(Equal([]string{"login", "login"}))
+		})
+
+		It("caches tokens without a lifetime until the session expires", func() {
+			te.exchangeToken = func(_ context.Context, subjectToken string, _ options.TokenExchange) (*providers.ExchangedToken, error) {
+				exchanges = append(exchanges, subjectToken)
+				return &providers.ExchangedToken{AccessToken: "exchanged"}, nil
+			}
+			sessionExpiresOn := now.Add(10 * time.Minute)
+
+			_, err := te.getToken(context.Background(), "login", "orders", ordersTarget, &sessionExpiresOn)
+			Expect(err).ToNot(HaveOccurred())
+			te.cache.Clock.Set(now.Add(5 * time.Minute))
+			_, err = te.getToken(context.Background(), "login", "orders", ordersTarget, &sessionExpiresOn)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exchanges).To(HaveLen(1))
+
+			te.cache.Clock.Set(now.Add(10 * time.Minute))
+			_, err = te.getToken(context.Background(), "login", "orders", ordersTarget, &sessionExpiresOn)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exchanges).To(HaveLen(2))
+		})
+	})
+})
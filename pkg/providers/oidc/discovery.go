@@ -0,0 +1,249 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// discoveryRetryMinBackoff is the delay before the first retry of a failed
+	// lazy discovery, doubled after each failed retry.
+	discoveryRetryMinBackoff = time.Second
+
+	// discoveryRetryMaxBackoff is the maximum delay between the retries of a
+	// failed lazy discovery.
+	discoveryRetryMaxBackoff = time.Minute
+)
+
+// errDiscoveryPending is returned while a lazy discovery has not succeeded yet.
+var errDiscoveryPending = errors.New("OIDC discovery has not completed")
+
+// discoveringProviderVerifier is the ProviderVerifier of the OIDC discovery.
+// When the discovery is lazy, it is retried in the background until it
+// succeeds, and once discovered, the metadata is refreshed periodically when
+// a refresh interval is given.
+// Unknown key IDs are re-fetched from the JWKS by the key set of the
+// underlying verifier.
+type discoveringProviderVerifier struct {
+	opts ProviderVerifierOptions
+
+	lock         sync.RWMutex
+	provider     DiscoveryProvider
+	verifier     IDTokenVerifier
+	discoveredAt time.Time
+	lastErr      error
+}
+
+func newDiscoveringProviderVerifier(ctx context.Context, opts ProviderVerifierOptions) (ProviderVerifier, error) {
+	p := &discoveringProviderVerifier{
+		opts: opts,
+		// To avoid the possibility of nil pointers, the provider is empty
+		// until the discovery succeeds.
+		provider: &discoveryProvider{},
+	}
+
+	if err := p.discover(ctx, false); err != nil {
+		if !opts.LazyDiscovery {
+			return nil, fmt.Errorf("could not get verifier builder: %v", err)
+		}
+		logger.Errorf("OIDC discovery failed, retrying in the background: %v", err)
+		go p.run(ctx)
+	} else if opts.DiscoveryRefreshInterval > 0 {
+		go p.run(ctx)
+	}
+
+	discoveryMetrics.add(opts.IssuerURL, p)
+	return p, nil
+}
+
+// RegisterDiscoveryMetrics registers the collector of the
+// 'oauth2_proxy_oidc_discovery_metadata_age_seconds' metric with the registerer.
+func RegisterDiscoveryMetrics(registerer prometheus.Registerer) error {
+	return registerer.Register(discoveryMetrics)
+}
+
+// run retries the discovery until it succeeds and then refreshes it at the
+// refresh interval, until the context is done.
+func (p *discoveringProviderVerifier) run(ctx context.Context) {
+	backoff := discoveryRetryMinBackoff
+	for p.VerifyConnection(ctx) != nil {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if err := p.discover(ctx, true); err != nil {
+			backoff = min(2*backoff, discoveryRetryMaxBackoff)
+			logger.Errorf("OIDC discovery failed, retrying in %s: %v", backoff, err)
+			continue
+		}
+		logger.Printf("OIDC discovery succeeded")
+	}
+
+	if p.opts.DiscoveryRefreshInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(p.opts.DiscoveryRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := p.discover(ctx, false); err != nil {
+			logger.Errorf("Unable to refresh the OIDC discovery metadata, keeping the cached metadata: %v", err)
+		}
+	}
+}
+
+// discover performs the discovery and replaces the provider and the verifier
+// with the discovered ones. The OnDiscovery callback is called on the first
+// successful discovery when notify is set.
+func (p *discoveringProviderVerifier) discover(ctx context.Context, notify bool) error {
+	verifierBuilder, provider, err := getVerifierBuilder(ctx, p.opts)
+	if err != nil {
+		p.lock.Lock()
+		p.lastErr = err
+		p.lock.Unlock()
+		return err
+	}
+	verifier := NewVerifier(verifierBuilder(p.opts.toOIDCConfig()), p.opts.toVerificationOptions())
+
+	// Only the background discovery notifies, before it marks the provider
+	// verifier as ready, so the callback is not racing any other discovery.
+	if notify && p.opts.OnDiscovery != nil {
+		if err := p.opts.OnDiscovery(provider); err != nil {
+			err = fmt.Errorf("unable to use the discovered provider: %v", err)
+			p.lock.Lock()
+			p.lastErr = err
+			p.lock.Unlock()
+			return err
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// The endpoints are copied to the provider once, and are read by every
+	// request without a lock, so a refresh doesn't apply them.
+	if p.verifier != nil && provider.Endpoints() != p.provider.Endpoints() {
+		logger.Printf("Warning: The OIDC discovery endpoints changed to %+v, restart to use them", provider.Endpoints())
+	}
+
+	p.provider = provider
+	p.verifier = verifier
+	p.discoveredAt = time.Now()
+	p.lastErr = nil
+	return nil
+}
+
+// DiscoveryEnabled is always true, as the provider is discovered
+func (p *discoveringProviderVerifier) DiscoveryEnabled() bool {
+	return true
+}
+
+// Provider returns the last discovered provider, which is empty until the
+// discovery succeeds.
+func (p *discoveringProviderVerifier) Provider() DiscoveryProvider {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.provider
+}
+
+// Verifier returns an ID token verifier that uses the last discovered
+// metadata, and fails until the discovery succeeds.
+func (p *discoveringProviderVerifier) Verifier() IDTokenVerifier {
+	return p
+}
+
+// Verify verifies the ID token with the last discovered verifier.
+func (p *discoveringProviderVerifier) Verify(ctx context.Context, rawIDToken string) (*oidc.IDToken, error) {
+	p.lock.RLock()
+	verifier := p.verifier
+	p.lock.RUnlock()
+
+	if verifier == nil {
+		return nil, errDiscoveryPending
+	}
+	return verifier.Verify(ctx, rawIDToken)
+}
+
+// VerifyConnection returns an error until the discovery succeeds.
+func (p *discoveringProviderVerifier) VerifyConnection(context.Context) error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.verifier != nil {
+		return nil
+	}
+	if p.lastErr != nil {
+		return fmt.Errorf("%w: %v", errDiscoveryPending, p.lastErr)
+	}
+	return errDiscoveryPending
+}
+
+// metadataAge returns the age of the discovered metadata, and false until the
+// discovery succeeds.
+func (p *discoveringProviderVerifier) metadataAge() (time.Duration, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.verifier == nil {
+		return 0, false
+	}
+	return time.Since(p.discoveredAt), true
+}
+
+// discoveryMetrics exports the 'oauth2_proxy_oidc_discovery_metadata_age_seconds'
+// metric of the discovered providers, once registered by
+// RegisterDiscoveryMetrics.
+var discoveryMetrics = &discoveryMetricsCollector{
+	desc: prometheus.NewDesc(
+		"oauth2_proxy_oidc_discovery_metadata_age_seconds",
+		"Age of the cached OIDC discovery metadata by issuer.",
+		[]string{"issuer"}, nil,
+	),
+	verifiers: make(map[string]*discoveringProviderVerifier),
+}
+
+// discoveryMetricsCollector is a prometheus.Collector of the age of the
+// discovery metadata of the last provider verifier of each issuer.
+type discoveryMetricsCollector struct {
+	desc *prometheus.Desc
+
+	lock      sync.Mutex
+	verifiers map[string]*discoveringProviderVerifier
+}
+
+func (c *discoveryMetricsCollector) add(issuerURL string, p *discoveringProviderVerifier) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.verifiers[issuerURL] = p
+}
+
+// Describe implements prometheus.Collector
+func (c *discoveryMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector
+func (c *discoveryMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for issuerURL, p := range c.verifiers {
+		if age, ok := p.metadataAge(); ok {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, age.Seconds(), issuerURL)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/mockoidc"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Discovering ProviderVerifier", func() {
+	var (
+		m        *mockoidc.MockOIDC
+		ctx      context.Context
+		cancel   context.CancelFunc
+		metadata *discoveryMetadata
+	)
+
+	BeforeEach(func() {
+		var err error
+		m, err = mockoidc.NewServer(nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		metadata = &discoveryMetadata{}
+		m.AddMiddleware(newDiscoveryMetadataMiddleware(m, metadata))
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m.Start(ln, nil)).To(Succeed())
+
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+		Expect(m.Shutdown()).To(Succeed())
+	})
+
+	newOpts := func() ProviderVerifierOptions {
+		return ProviderVerifierOptions{
+			AudienceClaims: []string{"aud"},
+			ClientID:       m.Config().ClientID,
+			ExtraAudiences: []string{},
+			IssuerURL:      m.Issuer(),
+		}
+	}
+
+	signIDToken := func() string {
+		now := time.Now()
+		rawIDToken, err := m.Keypair.SignJWT(jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{m.Config().ClientID},
+			Issuer:    m.Issuer(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(1 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   "user",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return rawIDToken
+	}
+
+	It("fails when the provider is unavailable and the discovery is not lazy", func() {
+		metadata.setUnavailable(true)
+
+		_, err := NewProviderVerifier(ctx, newOpts())
+		Expect(err).To(MatchError(HavePrefix("could not get verifier builder: error while discovery OIDC configuration: failed to discover OIDC configuration: unexpected status \"503\"")))
+	})
+
+	It("retries a lazy discovery until the provider is available", func() {
+		metadata.setUnavailable(true)
+
+		var discovered DiscoveryProvider
+		opts := newOpts()
+		opts.LazyDiscovery = true
+		opts.OnDiscovery = func(provider DiscoveryProvider) error {
+			discovered = provider
+			return nil
+		}
+
+		pv, err := NewProviderVerifier(ctx, opts)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pv.DiscoveryEnabled()).To(BeTrue())
+		Expect(pv.Provider().Endpoints()).To(Equal(Endpoints{}))
+
+		Expect(pv.VerifyConnection(ctx)).To(MatchError(HavePrefix("OIDC discovery has not completed: error while discovery OIDC configuration")))
+		_, err = pv.Verifier().Verify(ctx, signIDToken())
+		Expect(err).To(MatchError("OIDC discovery has not completed"))
+
+		metadata.setUnavailable(false)
+		Eventually(func() error { return pv.VerifyConnection(ctx) }, 5*time.Second, 100*time.Millisecond).Should(Succeed())
+
+		Expect(discovered).ToNot(BeNil())
+		Expect(discovered.Endpoints().AuthURL).To(Equal(m.AuthorizationEndpoint()))
+		Expect(pv.Provider().Endpoints().TokenURL).To(Equal(m.TokenEndpoint()))
+
+		_, err = pv.Verifier().Verify(ctx, signIDToken())
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("does not call back when a lazy discovery succeeds immediately", func() {
+		opts := newOpts()
+		opts.LazyDiscovery = true
+		opts.OnDiscovery = func(DiscoveryProvider) error {
+			Fail("OnDiscovery should not be called")
+			return nil
+		}
+
+		pv, err := NewProviderVerifier(ctx, opts)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pv.VerifyConnection(ctx)).To(Succeed())
+		Expect(pv.Provider().Endpoints().AuthURL).To(Equal(m.AuthorizationEndpoint()))
+	})
+
+	It("refreshes the discovery metadata periodically", func() {
+		opts := newOpts()
+		opts.DiscoveryRefreshInterval = 50 * time.Millisecond
+
+		pv, err := NewProviderVerifier(ctx, opts)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pv.Provider().PKCE().CodeChallengeAlgs).To(BeEmpty())
+
+		metadata.setCodeChallengeAlgs([]string{"S256"})
+		Eventually(func() []string { return pv.Provider().PKCE().CodeChallengeAlgs }, 5*time.Second, 50*time.Millisecond).Should(Equal([]string{"S256"}))
+
+		// The cached metadata is kept when the refresh fails
+		metadata.setUnavailable(true)
+		Consistently(func() error { return pv.VerifyConnection(ctx) }, 200*time.Millisecond, 50*time.Millisecond).Should(Succeed())
+		Expect(pv.Provider().PKCE().CodeChallengeAlgs).To(Equal([]string{"S256"}))
+	})
+
+	It("re-fetches the JWKS when the key ID is unknown", func() {
+		pv, err := NewProviderVerifier(ctx, newOpts())
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = pv.Verifier().Verify(ctx, signIDToken())
+		Expect(err).ToNot(HaveOccurred())
+
+		// Rotate the signing key of the provider
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+		m.Keypair, err = mockoidc.NewKeypair(key)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = pv.Verifier().Verify(ctx, signIDToken())
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("exposes the age of the discovery metadata", func() {
+		_, err := NewProviderVerifier(ctx, newOpts())
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(testutil.CollectAndCount(discoveryMetrics, "oauth2_proxy_oidc_discovery_metadata_age_seconds")).To(BeNumerically(">=", 1))
+
+		registry := prometheus.NewRegistry()
+		Expect(RegisterDiscoveryMetrics(registry)).To(Succeed())
+		Expect(RegisterDiscoveryMetrics(registry)).To(BeAssignableToTypeOf(prometheus.AlreadyRegisteredError{}))
+	})
+
+	It("fails the lazy discovery when the discovered provider can't be used", func() {
+		metadata.setUnavailable(true)
+
+		opts := newOpts()
+		opts.LazyDiscovery = true
+		opts.OnDiscovery = func(DiscoveryProvider) error {
+			return errors.New("pushed authorization requests are required")
+		}
+
+		pv, err := NewProviderVerifier(ctx, opts)
+		Expect(err).ToNot(HaveOccurred())
+
+		metadata.setUnavailable(false)
+		Eventually(func() error { return pv.VerifyConnection(ctx) }, 5*time.Second, 100*time.Millisecond).Should(
+			MatchError("OIDC discovery has not completed: unable to use the discovered provider: pushed authorization requests are required"))
+		_, err = pv.Verifier().Verify(ctx, signIDToken())
+		Expect(err).To(MatchError("OIDC discovery has not completed"))
+	})
+})
+
+// discoveryMetadata controls the discovery metadata served by the mock provider
+type discoveryMetadata struct {
+	lock              sync.Mutex
+	unavailable       bool
+	codeChallengeAlgs []string
+}
+
+func (d *discoveryMetadata) setUnavailable(unavailable bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.unavailable = unavailable
+}
+
+func (d *discoveryMetadata) setCodeChallengeAlgs(algs []string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.codeChallengeAlgs = algs
+}
+
+func newDiscoveryMetadataMiddleware(m *mockoidc.MockOIDC, metadata *discoveryMetadata) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if !strings.HasSuffix(req.URL.Path, "/.well-known/openid-configuration") {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			metadata.lock.Lock()
+			defer metadata.lock.Unlock()
+			if metadata.unavailable {
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			p := providerJSON{
+				Issuer:            m.Issuer(),
+				AuthURL:           m.AuthorizationEndpoint(),
+				TokenURL:          m.TokenEndpoint(),
+				JWKsURL:           m.JWKSEndpoint(),
+				UserInfoURL:       m.UserinfoEndpoint(),
+				CodeChallengeAlgs: metadata.codeChallengeAlgs,
+			}
+			data, err := json.Marshal(p)
+			if err != nil {
+				rw.WriteHeader(500)
+			}
+			rw.Write(data)
+		})
+	}
+}
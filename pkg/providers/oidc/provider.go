@@ -18,6 +18,8 @@ type providerJSON struct {
 	UserInfoURL          string   `json:"userinfo_endpoint"`
 	CodeChallengeAlgs    []string `json:"code_challenge_methods_supported"`
 	SupportedSigningAlgs []string `json:"id_token_signing_alg_values_supported"`
+	PARURL               string   `json:"pushed_authorization_request_endpoint"`
+	RequirePAR           bool     `json:"require_pushed_authorization_requests"`
 }
 
 // Endpoints represents the endpoints discovered as part of the OIDC discovery process
@@ -35,11 +37,19 @@ type PKCE struct {
 	CodeChallengeAlgs []string
 }
 
+// PAR holds information relevant to the pushed authorization request
+// (RFC 9126) support of the provider.
+type PAR struct {
+	RequestURL string
+	Required   bool
+}
+
 // DiscoveryProvider holds information about an identity provider having
 // used OIDC discovery to retrieve the information.
 type DiscoveryProvider interface {
 	Endpoints() Endpoints
 	PKCE() PKCE
+	PAR() PAR
 	SupportedSigningAlgs() []string
 }
 
@@ -71,6 +81,8 @@ func NewProvider(ctx context.Context, issuerURL string, skipIssuerVerification b
 		userInfoURL:          p.UserInfoURL,
 		codeChallengeAlgs:    p.CodeChallengeAlgs,
 		supportedSigningAlgs: p.SupportedSigningAlgs,
+		parURL:               p.PARURL,
+		requirePAR:           p.RequirePAR,
 	}, nil
 }
 
@@ -82,6 +94,8 @@ type discoveryProvider struct {
 	userInfoURL          string
 	codeChallengeAlgs    []string
 	supportedSigningAlgs []string
+	parURL               string
+	requirePAR           bool
 }
 
 // Endpoints returns the discovered endpoints needed for an authentication provider.
@@ -101,6 +115,15 @@ func (p *discoveryProvider) PKCE() PKCE {
 	}
 }
 
+// PAR returns information related to the pushed authorization request support
+// of the provider.
+func (p *discoveryProvider) PAR() PAR {
+	return PAR{
+		RequestURL: p.parURL,
+		Required:   p.requirePAR,
+	}
+}
+
 // SupportedSigningAlgs returns the discovered provider signing algorithms.
 func (p *discoveryProvider) SupportedSigningAlgs() []string {
 	return p.supportedSigningAlgs
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
@@ -15,6 +16,7 @@ type ProviderVerifier interface {
 	DiscoveryEnabled() bool
 	Provider() DiscoveryProvider
 	Verifier() IDTokenVerifier
+	VerifyConnection(context.Context) error
 }
 
 // ProviderVerifierOptions allows you to configure a ProviderVerifier
@@ -48,6 +50,20 @@ type ProviderVerifierOptions struct {
 	// SupportedSigningAlgs is the list of signature algorithms supported by the
 	// provider.
 	SupportedSigningAlgs []string
+
+	// LazyDiscovery retries the discovery in the background when it fails,
+	// instead of failing to construct the ProviderVerifier.
+	LazyDiscovery bool
+
+	// DiscoveryRefreshInterval is the period between refreshes of the discovery
+	// metadata and JWKS. Zero disables the refresh. The refreshed endpoints
+	// are not passed to OnDiscovery.
+	DiscoveryRefreshInterval time.Duration
+
+	// OnDiscovery is called with the discovered provider when a lazy discovery
+	// succeeds in the background, before the ProviderVerifier reports as ready.
+	// When it returns an error, the discovery fails and is retried.
+	OnDiscovery func(DiscoveryProvider) error
 }
 
 // validate checks that the required options are present before attempting to create
@@ -94,6 +110,10 @@ func NewProviderVerifier(ctx context.Context, opts ProviderVerifierOptions) (Pro
 		return nil, fmt.Errorf("invalid provider verifier options: %v", err)
 	}
 
+	if !opts.SkipDiscovery {
+		return newDiscoveringProviderVerifier(ctx, opts)
+	}
+
 	verifierBuilder, provider, err := getVerifierBuilder(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("could not get verifier builder: %v", err)
@@ -164,3 +184,9 @@ func (p *providerVerifier) Provider() DiscoveryProvider {
 func (p *providerVerifier) Verifier() IDTokenVerifier {
 	return p.verifier
 }
+
+// VerifyConnection always succeeds, as the provider verifier is ready once
+// constructed.
+func (p *providerVerifier) VerifyConnection(context.Context) error {
+	return nil
+}
@@ -54,6 +54,12 @@ type claimExtractor struct {
 	requestHeaders map[string][]string
 	tokenClaims    *simplejson.Json
 	profileClaims  *simplejson.Json
+
+	// sourceClaims caches the claims resolved from each of the
+	// `_claim_sources` of the ID Token, by source name. A claim extractor only
+	// lives while a session is built, so the sources are fetched again when
+	// the session is refreshed, picking up membership changes.
+	sourceClaims map[string]*simplejson.Json
 }
 
 // GetClaim will return the value claim if it exists.
@@ -68,6 +74,14 @@ func (c *claimExtractor) GetClaim(claim string) (interface{}, bool, error) {
 		return value, true, nil
 	}
 
+	value, err := c.getSourceClaim(claim)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to resolve distributed claim: %v", err)
+	}
+	if value != nil {
+		return value, true, nil
+	}
+
 	if c.profileClaims == nil {
 		profileClaims, err := c.loadProfileClaims()
 		if err != nil {
@@ -107,6 +121,154 @@ func (c *claimExtractor) loadProfileClaims() (*simplejson.Json, error) {
 	return claims, nil
 }
 
+// getSourceClaim resolves a claim from the aggregated or distributed claim
+// source referenced by the `_claim_names` of the ID Token, as described in
+// https://openid.net/specs/openid-connect-core-1_0.html#AggregatedDistributedClaims
+func (c *claimExtractor) getSourceClaim(claim string) (interface{}, error) {
+	name := strings.Split(claim, ".")[0]
+	sourceName, err := c.tokenClaims.GetPath("_claim_names", name).String()
+	if err != nil {
+		// The claim is not distributed
+		return nil, nil
+	}
+
+	claims, ok := c.sourceClaims[sourceName]
+	if !ok {
+		claims, err = c.loadSourceClaims(sourceName)
+		if err != nil {
+			return nil, fmt.Errorf("claim source %q: %v", sourceName, err)
+		}
+		if c.sourceClaims == nil {
+			c.sourceClaims = map[string]*simplejson.Json{}
+		}
+		c.sourceClaims[sourceName] = claims
+	}
+
+	return getClaimFrom(claim, claims), nil
+}
+
+// loadSourceClaims returns the claims of an aggregated claim source, or
+// fetches the claims of a distributed claim source from its endpoint.
+// Distributed claims are requested with the access token of the source when
+// given, or else with the profile request headers.
+func (c *claimExtractor) loadSourceClaims(sourceName string) (*simplejson.Json, error) {
+	source := c.tokenClaims.GetPath("_claim_sources", sourceName)
+
+	if jwt, err := source.Get("JWT").String(); err == nil {
+		// The aggregated claims are part of the verified ID Token
+		return parseClaimsJWT(jwt)
+	}
+
+	endpoint, err := source.Get("endpoint").String()
+	if err != nil {
+		return nil, fmt.Errorf("missing JWT or endpoint")
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %v", err)
+	}
+
+	headers := http.Header(c.requestHeaders)
+	if accessToken, err := source.Get("access_token").String(); err == nil && accessToken != "" {
+		headers = http.Header{}
+		headers.Set("Authorization", "Bearer "+accessToken)
+	}
+	if headers == nil {
+		// Without an access token the request would be unauthorized, eg. when
+		// building a session from a bearer token, so the claims are skipped.
+		return simplejson.New(), nil
+	}
+
+	if isMicrosoftGroupOverage(endpointURL) {
+		groups, err := c.loadMicrosoftGroups(endpointURL, headers)
+		if err != nil {
+			return nil, err
+		}
+		claims := simplejson.New()
+		claims.Set("groups", groups)
+		return claims, nil
+	}
+
+	result := requests.New(endpointURL.String()).
+		WithContext(c.ctx).
+		WithHeaders(headers).
+		Do()
+	if result.Error() != nil {
+		return nil, fmt.Errorf("error making request to endpoint: %v", result.Error())
+	}
+	if result.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status \"%d\" from endpoint: %s", result.StatusCode(), result.Body())
+	}
+
+	// The endpoint returns either JSON or a signed JWT
+	body := strings.TrimSpace(string(result.Body()))
+	if strings.HasPrefix(body, "{") {
+		return simplejson.NewJson([]byte(body))
+	}
+	return parseClaimsJWT(body)
+}
+
+func parseClaimsJWT(jwt string) (*simplejson.Json, error) {
+	payload, err := parseJWT(jwt)
+	if err != nil {
+		return nil, err
+	}
+	return simplejson.NewJson(payload)
+}
+
+// isMicrosoftGroupOverage checks whether a distributed claim endpoint is the
+// `getMemberObjects` endpoint set by Microsoft Entra ID instead of the groups
+// claim, when a user is a member of too many groups for the token.
+func isMicrosoftGroupOverage(endpoint *url.URL) bool {
+	return strings.HasSuffix(endpoint.Path, "/getMemberObjects")
+}
+
+// loadMicrosoftGroups lists the IDs of the groups of the user through the
+// Microsoft Graph `transitiveMemberOf` endpoint, following every page.
+// The retired Azure AD Graph endpoints of older tokens are replaced with
+// Microsoft Graph.
+func (c *claimExtractor) loadMicrosoftGroups(endpoint *url.URL, headers http.Header) ([]interface{}, error) {
+	host := endpoint.Host
+	if host == "graph.windows.net" {
+		host = "graph.microsoft.com"
+	}
+	groupsURL := (&url.URL{
+		Scheme:   endpoint.Scheme,
+		Host:     host,
+		Path:     "/v1.0/me/transitiveMemberOf/microsoft.graph.group",
+		RawQuery: "$count=true&$select=id",
+	}).String()
+
+	// The cast to groups is an advanced query of Microsoft Graph
+	// https://learn.microsoft.com/en-us/graph/aad-advanced-queries
+	graphHeaders := headers.Clone()
+	graphHeaders.Set("ConsistencyLevel", "eventual")
+
+	groups := []interface{}{}
+	for groupsURL != "" {
+		var page struct {
+			Value []struct {
+				ID string `json:"id"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		err := requests.New(groupsURL).
+			WithContext(c.ctx).
+			WithHeaders(graphHeaders).
+			Do().
+			UnmarshalInto(&page)
+		if err != nil {
+			return nil, fmt.Errorf("error listing groups from Microsoft Graph: %v", err)
+		}
+
+		for _, group := range page.Value {
+			groups = append(groups, group.ID)
+		}
+		groupsURL = page.NextLink
+	}
+	return groups, nil
+}
+
 // GetClaimInto loads a claim and places it into the destination interface.
 // This will attempt to coerce the claim into the specified type.
 // If it cannot be coerced, an error may be returned.
@@ -170,6 +332,12 @@ func coerceClaim(value, dst interface{}) error {
 		*d = strSlice
 	case *bool:
 		*d = cast.ToBool(value)
+	case *int64:
+		i, err := cast.ToInt64E(value)
+		if err != nil {
+			return fmt.Errorf("could not convert value to int64: %v", err)
+		}
+		*d = i
 	default:
 		return fmt.Errorf("unknown type for destination: %T", dst)
 	}
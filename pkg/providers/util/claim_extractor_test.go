@@ -3,11 +3,13 @@ package util
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"sync/atomic"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -292,6 +294,163 @@ var _ = Describe("Claim Extractor Suite", func() {
 		Expect(value).To(BeNil())
 	})
 
+	Context("Aggregated and distributed claims", func() {
+		var server *httptest.Server
+		var requestCount int32
+
+		BeforeEach(func() {
+			requestCount = 0
+			server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&requestCount, 1)
+				switch req.URL.Path {
+				case "/claims":
+					if req.Header.Get("Authorization") != "Bearer source_access_token" {
+						rw.WriteHeader(403)
+						return
+					}
+					rw.Write([]byte(`{"groups": ["distributedGroup1", "distributedGroup2"], "roles": ["distributedRole"]}`))
+				case "/claims.jwt":
+					if !hasAuthorizedHeader(req.Header) {
+						rw.WriteHeader(403)
+						return
+					}
+					rw.Header().Set("Content-Type", "application/jwt")
+					rw.Write([]byte(createJWTFromPayload(`{"groups": ["jwtGroup"]}`)))
+				case "/v1.0/users/oid/getMemberObjects":
+					defer GinkgoRecover()
+					Fail("Unexpected request to the getMemberObjects endpoint")
+				case "/v1.0/me/transitiveMemberOf/microsoft.graph.group":
+					if !hasAuthorizedHeader(req.Header) || req.Header.Get("ConsistencyLevel") != "eventual" {
+						rw.WriteHeader(403)
+						return
+					}
+					if req.URL.Query().Get("$skiptoken") == "" {
+						rw.Write([]byte(`{"value": [{"id": "group-id-1"}, {"id": "group-id-2"}], "@odata.nextLink": "http://` + req.Host + req.URL.Path + `?$skiptoken=page2"}`))
+						return
+					}
+					rw.Write([]byte(`{"value": [{"id": "group-id-3"}]}`))
+				default:
+					rw.WriteHeader(404)
+				}
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		newSourceClaimExtractor := func(idTokenPayload string) ClaimExtractor {
+			payload := strings.ReplaceAll(idTokenPayload, "{{server}}", server.URL)
+			extractor, err := NewClaimExtractor(context.Background(), createJWTFromPayload(payload), nil, newAuthorizedHeader())
+			Expect(err).ToNot(HaveOccurred())
+			return extractor
+		}
+
+		It("resolves aggregated claims", func() {
+			extractor := newSourceClaimExtractor(`{
+				"_claim_names": {"groups": "src1"},
+				"_claim_sources": {"src1": {"JWT": "` + createJWTFromPayload(`{"groups": ["aggregatedGroup"]}`) + `"}}
+			}`)
+
+			value, exists, err := extractor.GetClaim("groups")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(value).To(Equal([]interface{}{"aggregatedGroup"}))
+			Expect(requestCount).To(BeEquivalentTo(0))
+		})
+
+		It("resolves distributed claims once per source with the access token of the source", func() {
+			extractor := newSourceClaimExtractor(`{
+				"_claim_names": {"groups": "src1", "roles": "src1"},
+				"_claim_sources": {"src1": {"endpoint": "{{server}}/claims", "access_token": "source_access_token"}}
+			}`)
+
+			var groups, roles []string
+			exists, err := extractor.GetClaimInto("groups", &groups)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(groups).To(Equal([]string{"distributedGroup1", "distributedGroup2"}))
+
+			exists, err = extractor.GetClaimInto("roles", &roles)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(roles).To(Equal([]string{"distributedRole"}))
+			Expect(requestCount).To(BeEquivalentTo(1))
+		})
+
+		It("resolves distributed claims returned as a JWT with the profile headers", func() {
+			extractor := newSourceClaimExtractor(`{
+				"_claim_names": {"groups": "src1"},
+				"_claim_sources": {"src1": {"endpoint": "{{server}}/claims.jwt"}}
+			}`)
+
+			value, exists, err := extractor.GetClaim("groups")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(value).To(Equal([]interface{}{"jwtGroup"}))
+		})
+
+		It("prefers the claims of the ID Token", func() {
+			extractor := newSourceClaimExtractor(`{
+				"groups": ["idTokenGroup"],
+				"_claim_names": {"groups": "src1"},
+				"_claim_sources": {"src1": {"endpoint": "{{server}}/claims.jwt"}}
+			}`)
+
+			value, exists, err := extractor.GetClaim("groups")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(value).To(Equal([]interface{}{"idTokenGroup"}))
+			Expect(requestCount).To(BeEquivalentTo(0))
+		})
+
+		It("lists the groups from Microsoft Graph on a group overage", func() {
+			extractor := newSourceClaimExtractor(`{
+				"_claim_names": {"groups": "src1"},
+				"_claim_sources": {"src1": {"endpoint": "{{server}}/v1.0/users/oid/getMemberObjects"}}
+			}`)
+
+			var groups []string
+			exists, err := extractor.GetClaimInto("groups", &groups)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(groups).To(Equal([]string{"group-id-1", "group-id-2", "group-id-3"}))
+			Expect(requestCount).To(BeEquivalentTo(2))
+		})
+
+		It("returns an error when the endpoint fails", func() {
+			extractor := newSourceClaimExtractor(`{
+				"_claim_names": {"groups": "src1"},
+				"_claim_sources": {"src1": {"endpoint": "{{server}}/claims"}}
+			}`)
+
+			_, _, err := extractor.GetClaim("groups")
+			Expect(err).To(MatchError(ContainSubstring(`failed to resolve distributed claim: claim source "src1": unexpected status "403"`)))
+		})
+
+		It("skips distributed claims without an access token", func() {
+			payload := strings.ReplaceAll(`{
+				"_claim_names": {"groups": "src1"},
+				"_claim_sources": {"src1": {"endpoint": "{{server}}/claims.jwt"}}
+			}`, "{{server}}", server.URL)
+			extractor, err := NewClaimExtractor(context.Background(), createJWTFromPayload(payload), nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			value, exists, err := extractor.GetClaim("groups")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeFalse())
+			Expect(value).To(BeNil())
+			Expect(requestCount).To(BeEquivalentTo(0))
+		})
+
+		It("returns an error when the source is missing", func() {
+			extractor := newSourceClaimExtractor(`{"_claim_names": {"groups": "src1"}}`)
+
+			_, _, err := extractor.GetClaim("groups")
+			Expect(err).To(MatchError(`failed to resolve distributed claim: claim source "src1": missing JWT or endpoint`))
+		})
+	})
+
 	type getClaimIntoTableInput struct {
 		testClaimExtractorOpts
 		into          interface{}
@@ -471,6 +630,11 @@ var _ = Describe("Claim Extractor Suite", func() {
 			dst:         boolPointer(false),
 			expectedDst: boolPointer(true),
 		}),
+		Entry("coerces a number to an int64", coerceClaimTableInput{
+			value:       json.Number("1700000000"),
+			dst:         int64Pointer(0),
+			expectedDst: int64Pointer(1700000000),
+		}),
 		Entry("coerces a map to a string", coerceClaimTableInput{
 			value: map[string]interface{}{
 				"foo": []interface{}{"bar", "baz"},
@@ -543,6 +707,10 @@ func boolPointer(in bool) *bool {
 	return &in
 }
 
+func int64Pointer(in int64) *int64 {
+	return &in
+}
+
 // ******************************
 // Different profile URL handlers
 // ******************************
@@ -128,4 +128,38 @@ var _ = Describe("Util Suite", func() {
 			})
 		})
 	})
+
+	Context("GetRequestMethod", func() {
+		Context("IsProxied is false", func() {
+			BeforeEach(func() {
+				req = middleware.AddRequestScope(req, &middleware.RequestScope{})
+			})
+
+			It("returns the method", func() {
+				Expect(util.GetRequestMethod(req)).To(Equal(http.MethodGet))
+			})
+
+			It("ignores X-Forwarded-Method and returns the method", func() {
+				req.Header.Add("X-Forwarded-Method", http.MethodPost)
+				Expect(util.GetRequestMethod(req)).To(Equal(http.MethodGet))
+			})
+		})
+
+		Context("IsProxied is true", func() {
+			BeforeEach(func() {
+				req = middleware.AddRequestScope(req, &middleware.RequestScope{
+					ReverseProxy: true,
+				})
+			})
+
+			It("returns the method if X-Forwarded-Method is not present", func() {
+				Expect(util.GetRequestMethod(req)).To(Equal(http.MethodGet))
+			})
+
+			It("returns the X-Forwarded-Method when present", func() {
+				req.Header.Add("X-Forwarded-Method", http.MethodPost)
+				Expect(util.GetRequestMethod(req)).To(Equal(http.MethodPost))
+			})
+		})
+	})
 })
@@ -0,0 +1,168 @@
+package binding
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	ipapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/ip"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
+)
+
+// ErrMismatch is returned when a session is used by a client that doesn't
+// match the binding of the session
+var ErrMismatch = errors.New("session binding does not match the client")
+
+// Binder binds sessions to characteristics of the client that created them.
+// A nil Binder binds nothing and accepts every session.
+type Binder struct {
+	attributes []string
+	ipv4Mask   net.IPMask
+	ipv6Mask   net.IPMask
+	ipParser   ipapi.RealClientIPParser
+}
+
+// NewBinder creates a Binder from the session binding options. It returns
+// nil when session binding is disabled.
+func NewBinder(opts options.SessionBindingOptions, ipParser ipapi.RealClientIPParser) *Binder {
+	if len(opts.Attributes) == 0 {
+		return nil
+	}
+	return &Binder{
+		attributes: opts.Attributes,
+		ipv4Mask:   net.CIDRMask(opts.IPv4Prefix, 8*net.IPv4len),
+		ipv6Mask:   net.CIDRMask(opts.IPv6Prefix, 8*net.IPv6len),
+		ipParser:   ipParser,
+	}
+}
+
+// Bind records the binding of the client making the request in the session
+func (b *Binder) Bind(req *http.Request, s *sessionsapi.SessionState) {
+	if b == nil {
+		return
+	}
+	s.Binding = b.hash(req)
+}
+
+// Verify checks that the client making the request matches the binding of
+// the session. Sessions created before binding was enabled have no binding
+// and don't match any client, as the first client using them could have
+// stolen them.
+func (b *Binder) Verify(req *http.Request, s *sessionsapi.SessionState) error {
+	if b == nil {
+		return nil
+	}
+	if s.Binding == "" || s.Binding != b.hash(req) {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// hash returns the hash of the configured attributes of the client. The
+// attribute names are included, so changing the attributes invalidates
+// existing bindings.
+func (b *Binder) hash(req *http.Request) string {
+	h := sha256.New()
+	for _, attribute := range b.attributes {
+		fmt.Fprintf(h, "%s=%s\n", attribute, b.attribute(req, attribute))
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (b *Binder) attribute(req *http.Request, attribute string) string {
+	switch attribute {
+	case options.SessionBindingIPPrefix:
+		return b.ipPrefix(req)
+	case options.SessionBindingUserAgent:
+		return UserAgentFamily(req.UserAgent())
+	case options.SessionBindingClientCert:
+		return clientCertFingerprint(req)
+	default:
+		return ""
+	}
+}
+
+// ipPrefix returns the network prefix of the client IP
+func (b *Binder) ipPrefix(req *http.Request) string {
+	clientIP, err := ip.GetClientIP(b.ipParser, req)
+	if err != nil || clientIP == nil {
+		return ""
+	}
+	if v4 := clientIP.To4(); v4 != nil {
+		return v4.Mask(b.ipv4Mask).String()
+	}
+	return clientIP.Mask(b.ipv6Mask).String()
+}
+
+// clientCertFingerprint returns the SHA-256 fingerprint of the TLS client
+// certificate the request was made with
+func clientCertFingerprint(req *http.Request) string {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	fingerprint := sha256.Sum256(req.TLS.PeerCertificates[0].Raw)
+	return hex.EncodeToString(fingerprint[:])
+}
+
+// browserFamilies are matched in order, as most browsers also include the
+// tokens of the browsers they are based on in their User-Agent
+var browserFamilies = []struct {
+	token  string
+	family string
+}{
+	{"Edg/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+}
+
+var osFamilies = []struct {
+	token  string
+	family string
+}{
+	{"Windows", "Windows"},
+	{"Android", "Android"},
+	{"iPhone", "iOS"},
+	{"iPad", "iOS"},
+	{"Mac OS X", "macOS"},
+	{"CrOS", "ChromeOS"},
+	{"Linux", "Linux"},
+}
+
+// UserAgentFamily returns the browser and operating system family of a
+// User-Agent without versions, so that browser updates keep sessions valid
+func UserAgentFamily(userAgent string) string {
+	browser := ""
+	for _, b := range browserFamilies {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.family
+			break
+		}
+	}
+	if browser == "" {
+		// Fall back to the first product token, eg. "curl" for "curl/8.0.1"
+		browser, _, _ = strings.Cut(userAgent, "/")
+		browser = strings.TrimSpace(browser)
+	}
+
+	os := ""
+	for _, o := range osFamilies {
+		if strings.Contains(userAgent, o.token) {
+			os = o.family
+			break
+		}
+	}
+	return browser + "/" + os
+}
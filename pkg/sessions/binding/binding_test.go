@@ -0,0 +1,182 @@
+package binding
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	firefoxLinux   = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	firefoxLinux2  = "Mozilla/5.0 (X11; Linux x86_64; rv:130.0) Gecko/20100101 Firefox/130.0"
+	chromeWindows  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+	edgeWindows    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.2592.87"
+	safariIPhone   = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1"
+	chromeAndroid  = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36"
+	curlUserAgent  = "curl/8.8.0"
+	defaultBinding = "ip-prefix"
+)
+
+var _ = Describe("Session Binding", func() {
+	DescribeTable("UserAgentFamily",
+		func(userAgent string, expected string) {
+			Expect(UserAgentFamily(userAgent)).To(Equal(expected))
+		},
+		Entry("Firefox on Linux", firefoxLinux, "Firefox/Linux"),
+		Entry("Chrome on Windows", chromeWindows, "Chrome/Windows"),
+		Entry("Edge on Windows", edgeWindows, "Edge/Windows"),
+		Entry("Safari on iPhone", safariIPhone, "Safari/iOS"),
+		Entry("Chrome on Android", chromeAndroid, "Chrome/Android"),
+		Entry("curl", curlUserAgent, "curl/"),
+		Entry("empty", "", "/"),
+	)
+
+	type bindingTableInput struct {
+		opts          options.SessionBindingOptions
+		realIPHeader  string
+		bindRequest   func(*http.Request)
+		verifyRequest func(*http.Request)
+		expectedErr   error
+	}
+
+	withRemoteAddr := func(addr string) func(*http.Request) {
+		return func(req *http.Request) {
+			req.RemoteAddr = addr
+		}
+	}
+
+	withUserAgent := func(userAgent string) func(*http.Request) {
+		return func(req *http.Request) {
+			req.Header.Set("User-Agent", userAgent)
+		}
+	}
+
+	withClientCert := func(raw string) func(*http.Request) {
+		return func(req *http.Request) {
+			req.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{{Raw: []byte(raw)}},
+			}
+		}
+	}
+
+	DescribeTable("Bind and Verify",
+		func(in bindingTableInput) {
+			var parser, err = ip.GetRealClientIPParser("X-Forwarded-For")
+			Expect(err).ToNot(HaveOccurred())
+			if in.realIPHeader == "" {
+				parser = nil
+			}
+			binder := NewBinder(in.opts, parser)
+
+			bindReq := httptest.NewRequest("GET", "/", nil)
+			in.bindRequest(bindReq)
+			session := &sessionsapi.SessionState{}
+			binder.Bind(bindReq, session)
+
+			verifyReq := httptest.NewRequest("GET", "/", nil)
+			in.verifyRequest(verifyReq)
+			err = binder.Verify(verifyReq, session)
+			if in.expectedErr != nil {
+				Expect(err).To(MatchError(in.expectedErr))
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+			}
+		},
+		Entry("with binding disabled", bindingTableInput{
+			opts:          options.SessionBindingOptions{},
+			bindRequest:   withRemoteAddr("192.0.2.10:1234"),
+			verifyRequest: withRemoteAddr("198.51.100.10:1234"),
+		}),
+		Entry("with an IPv4 address in the same prefix", bindingTableInput{
+			opts:          options.SessionBindingOptions{Attributes: []string{defaultBinding}, IPv4Prefix: 24, IPv6Prefix: 64},
+			bindRequest:   withRemoteAddr("192.0.2.10:1234"),
+			verifyRequest: withRemoteAddr("192.0.2.200:4321"),
+		}),
+		Entry("with an IPv4 address in a different prefix", bindingTableInput{
+			opts:          options.SessionBindingOptions{Attributes: []string{defaultBinding}, IPv4Prefix: 24, IPv6Prefix: 64},
+			bindRequest:   withRemoteAddr("192.0.2.10:1234"),
+			verifyRequest: withRemoteAddr("192.0.3.10:1234"),
+			expectedErr:   ErrMismatch,
+		}),
+		Entry("with an IPv6 address in the same prefix", bindingTableInput{
+			opts:          options.SessionBindingOptions{Attributes: []string{defaultBinding}, IPv4Prefix: 24, IPv6Prefix: 64},
+			bindRequest:   withRemoteAddr("[2001:db8:1:2::10]:1234"),
+			verifyRequest: withRemoteAddr("[2001:db8:1:2:abcd::1]:1234"),
+		}),
+		Entry("with an IPv6 address in a different prefix", bindingTableInput{
+			opts:          options.SessionBindingOptions{Attributes: []string{defaultBinding}, IPv4Prefix: 24, IPv6Prefix: 64},
+			bindRequest:   withRemoteAddr("[2001:db8:1:2::10]:1234"),
+			verifyRequest: withRemoteAddr("[2001:db8:1:3::10]:1234"),
+			expectedErr:   ErrMismatch,
+		}),
+		Entry("with the real client IP header", bindingTableInput{
+			opts:         options.SessionBindingOptions{Attributes: []string{defaultBinding}, IPv4Prefix: 24, IPv6Prefix: 64},
+			realIPHeader: "X-Forwarded-For",
+			bindRequest: func(req *http.Request) {
+				req.Header.Set("X-Forwarded-For", "192.0.2.10")
+			},
+			verifyRequest: func(req *http.Request) {
+				req.Header.Set("X-Forwarded-For", "198.51.100.10")
+			},
+			expectedErr: ErrMismatch,
+		}),
+		Entry("with an updated browser", bindingTableInput{
+			opts:          options.SessionBindingOptions{Attributes: []string{"user-agent"}},
+			bindRequest:   withUserAgent(firefoxLinux),
+			verifyRequest: withUserAgent(firefoxLinux2),
+		}),
+		Entry("with a different browser", bindingTableInput{
+			opts:          options.SessionBindingOptions{Attributes: []string{"user-agent"}},
+			bindRequest:   withUserAgent(firefoxLinux),
+			verifyRequest: withUserAgent(chromeWindows),
+			expectedErr:   ErrMismatch,
+		}),
+		Entry("with the same client certificate", bindingTableInput{
+			opts:          options.SessionBindingOptions{Attributes: []string{"client-cert"}},
+			bindRequest:   withClientCert("certificate"),
+			verifyRequest: withClientCert("certificate"),
+		}),
+		Entry("with a different client certificate", bindingTableInput{
+			opts:          options.SessionBindingOptions{Attributes: []string{"client-cert"}},
+			bindRequest:   withClientCert("certificate"),
+			verifyRequest: withClientCert("other certificate"),
+			expectedErr:   ErrMismatch,
+		}),
+		Entry("with a missing client certificate", bindingTableInput{
+			opts:          options.SessionBindingOptions{Attributes: []string{"client-cert"}},
+			bindRequest:   withClientCert("certificate"),
+			verifyRequest: func(*http.Request) {},
+			expectedErr:   ErrMismatch,
+		}),
+		Entry("with one of multiple attributes changed", bindingTableInput{
+			opts: options.SessionBindingOptions{Attributes: []string{defaultBinding, "user-agent"}, IPv4Prefix: 24, IPv6Prefix: 64},
+			bindRequest: func(req *http.Request) {
+				withRemoteAddr("192.0.2.10:1234")(req)
+				withUserAgent(firefoxLinux)(req)
+			},
+			verifyRequest: func(req *http.Request) {
+				withRemoteAddr("192.0.2.10:1234")(req)
+				withUserAgent(chromeWindows)(req)
+			},
+			expectedErr: ErrMismatch,
+		}),
+	)
+
+	It("rejects sessions created before binding was enabled", func() {
+		binder := NewBinder(options.SessionBindingOptions{Attributes: []string{"user-agent"}}, nil)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("User-Agent", firefoxLinux)
+		session := &sessionsapi.SessionState{}
+		Expect(binder.Verify(req, session)).To(MatchError(ErrMismatch))
+		Expect(session.Binding).To(BeEmpty())
+		Expect(session.NeedsSave).To(BeFalse())
+	})
+})
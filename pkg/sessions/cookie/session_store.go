@@ -31,6 +31,10 @@ type SessionStore struct {
 	Cookie       *options.Cookie
 	CookieCipher encryption.Cipher
 	Minimal      bool
+
+	// PreviousCookieCiphers are built from the previous cookie secrets, in
+	// the same order, to decrypt sessions saved before the secret was rotated.
+	PreviousCookieCiphers []encryption.Cipher
 }
 
 // Save takes a sessions.SessionState and stores the information from it
@@ -39,6 +43,12 @@ func (s *SessionStore) Save(rw http.ResponseWriter, req *http.Request, ss *sessi
 	if ss.CreatedAt == nil || ss.CreatedAt.IsZero() {
 		ss.CreatedAtNow()
 	}
+	if ss.AuthenticatedAt == nil || ss.AuthenticatedAt.IsZero() {
+		ss.AuthenticatedAtCreation()
+	}
+	if ss.LastSeenAt == nil || ss.LastSeenAt.IsZero() {
+		ss.LastSeenAtNow()
+	}
 	value, err := s.cookieForSession(ss)
 	if err != nil {
 		return err
@@ -54,15 +64,23 @@ func (s *SessionStore) Load(req *http.Request) (*sessions.SessionState, error) {
 		// always http.ErrNoCookie
 		return nil, err
 	}
-	val, _, ok := encryption.Validate(c, s.Cookie.Secret, s.Cookie.Expire)
+	val, _, index, ok := encryption.ValidateWithSeeds(c, s.Cookie.Secrets(), s.Cookie.Expire)
 	if !ok {
 		return nil, errors.New("cookie signature not valid")
 	}
 
-	session, err := sessions.DecodeSessionState(val, s.CookieCipher, true)
+	cipher := s.CookieCipher
+	if index > 0 {
+		cipher = s.PreviousCookieCiphers[index-1]
+	}
+
+	session, err := sessions.DecodeSessionState(val, cipher, true)
 	if err != nil {
 		return nil, err
 	}
+	// Sessions signed with a previous secret must be saved again with the
+	// current secret.
+	session.NeedsSave = index > 0
 	return session, nil
 }
 
@@ -152,10 +170,20 @@ func NewCookieSessionStore(opts *options.SessionOptions, cookieOpts *options.Coo
 		return nil, fmt.Errorf("error initialising cipher: %v", err)
 	}
 
+	previousCiphers := make([]encryption.Cipher, 0, len(cookieOpts.PreviousSecrets))
+	for _, secret := range cookieOpts.PreviousSecrets {
+		previousCipher, err := encryption.NewCFBCipher(encryption.SecretBytes(secret))
+		if err != nil {
+			return nil, fmt.Errorf("error initialising cipher for previous cookie secret: %v", err)
+		}
+		previousCiphers = append(previousCiphers, previousCipher)
+	}
+
 	return &SessionStore{
-		CookieCipher: cipher,
-		Cookie:       cookieOpts,
-		Minimal:      opts.Cookie.Minimal,
+		CookieCipher:          cipher,
+		Cookie:                cookieOpts,
+		Minimal:               opts.Cookie.Minimal,
+		PreviousCookieCiphers: previousCiphers,
 	}, nil
 }
 
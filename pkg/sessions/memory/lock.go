@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+)
+
+// lockEntry is a lock held on a session key in the SessionStore
+type lockEntry struct {
+	owner     *Lock
+	expiresAt time.Time
+}
+
+// Lock is an in memory lock on a session key of a SessionStore.
+type Lock struct {
+	store *SessionStore
+	key   string
+}
+
+// Obtain obtains the lock for the configured key if it isn't held yet.
+func (l *Lock) Obtain(_ context.Context, expiration time.Duration) error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	now := l.store.Clock.Now()
+	if existing, ok := l.store.locks[l.key]; ok && now.Before(existing.expiresAt) {
+		return sessions.ErrLockNotObtained
+	}
+
+	l.store.locks[l.key] = &lockEntry{
+		owner:     l,
+		expiresAt: now.Add(expiration),
+	}
+	return nil
+}
+
+// Peek returns true, if the lock is still applied.
+func (l *Lock) Peek(_ context.Context) (bool, error) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	existing, ok := l.store.locks[l.key]
+	return ok && l.store.Clock.Now().Before(existing.expiresAt), nil
+}
+
+// Refresh refreshes the expiration of a lock held by this Lock.
+func (l *Lock) Refresh(_ context.Context, expiration time.Duration) error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	existing, ok := l.heldLock()
+	if !ok {
+		return sessions.ErrNotLocked
+	}
+	existing.expiresAt = l.store.Clock.Now().Add(expiration)
+	return nil
+}
+
+// Release releases a lock held by this Lock.
+func (l *Lock) Release(_ context.Context) error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	if _, ok := l.heldLock(); !ok {
+		return sessions.ErrNotLocked
+	}
+	delete(l.store.locks, l.key)
+	return nil
+}
+
+// heldLock returns the lock entry for the key if it is held by this Lock and
+// has not expired.
+// The caller must hold the store mutex.
+func (l *Lock) heldLock() (*lockEntry, bool) {
+	existing, ok := l.store.locks[l.key]
+	if !ok || existing.owner != l || !l.store.Clock.Now().Before(existing.expiresAt) {
+		return nil, false
+	}
+	return existing, true
+}
@@ -0,0 +1,164 @@
+package memory
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+)
+
+// SessionStore is an implementation of the persistence.Store
+// interface that stores sessions in the memory of the running process.
+// Sessions are lost when the process restarts and are not shared between
+// multiple instances, so it is only suitable for single instance deployments.
+type SessionStore struct {
+	Clock clock.Clock
+
+	maxEntries      int
+	cleanupInterval time.Duration
+	lastCleanup     time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	locks   map[string]*lockEntry
+}
+
+// entry is a session saved in the SessionStore
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemorySessionStore initialises a new instance of the SessionStore and
+// wraps it in a persistence.Manager
+func NewMemorySessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
+	manager := persistence.NewManager(newSessionStore(opts.Memory), cookieOpts)
+	manager.ConcurrentSessions = opts.Concurrent
+	return manager, nil
+}
+
+func newSessionStore(opts options.MemoryStoreOptions) *SessionStore {
+	return &SessionStore{
+		maxEntries:      opts.MaxEntries,
+		cleanupInterval: opts.CleanupInterval,
+		entries:         map[string]*list.Element{},
+		lru:             list.New(),
+		locks:           map[string]*lockEntry{},
+	}
+}
+
+// Save stores the value under the key until it expires.
+// When the store is full, the least recently used session is evicted.
+func (store *SessionStore) Save(_ context.Context, key string, value []byte, exp time.Duration) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.Clock.Now()
+	store.cleanupIfNeeded(now)
+
+	if elem, ok := store.entries[key]; ok {
+		e := elem.Value.(*entry)
+		e.value = value
+		e.expiresAt = now.Add(exp)
+		store.lru.MoveToFront(elem)
+		return nil
+	}
+
+	store.entries[key] = store.lru.PushFront(&entry{
+		key:       key,
+		value:     value,
+		expiresAt: now.Add(exp),
+	})
+
+	for store.maxEntries > 0 && store.lru.Len() > store.maxEntries {
+		store.remove(store.lru.Back())
+	}
+	return nil
+}
+
+// Load returns the value stored under the key if it has not expired yet.
+func (store *SessionStore) Load(_ context.Context, key string) ([]byte, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.Clock.Now()
+	store.cleanupIfNeeded(now)
+
+	elem, ok := store.entries[key]
+	if !ok {
+		return nil, fmt.Errorf("error loading memory session: %w: %s", sessions.ErrKeyNotFound, key)
+	}
+
+	e := elem.Value.(*entry)
+	if !now.Before(e.expiresAt) {
+		store.remove(elem)
+		return nil, fmt.Errorf("error loading memory session: %w: %s", sessions.ErrKeyNotFound, key)
+	}
+
+	store.lru.MoveToFront(elem)
+	return e.value, nil
+}
+
+// Clear removes the value stored under the key.
+func (store *SessionStore) Clear(_ context.Context, key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if elem, ok := store.entries[key]; ok {
+		store.remove(elem)
+	}
+	return nil
+}
+
+// Lock creates a lock object for sessions.SessionState
+func (store *SessionStore) Lock(key string) sessions.Lock {
+	return &Lock{
+		store: store,
+		key:   key,
+	}
+}
+
+// VerifyConnection always returns no error, as there's no connection
+// in this store
+func (store *SessionStore) VerifyConnection(_ context.Context) error {
+	return nil
+}
+
+// remove deletes a session from the store.
+// The caller must hold the store mutex.
+func (store *SessionStore) remove(elem *list.Element) {
+	e := store.lru.Remove(elem).(*entry)
+	delete(store.entries, e.key)
+}
+
+// cleanupIfNeeded removes all expired sessions and locks once the cleanup
+// interval has passed since the last cleanup.
+// The caller must hold the store mutex.
+func (store *SessionStore) cleanupIfNeeded(now time.Time) {
+	if store.cleanupInterval <= 0 || now.Sub(store.lastCleanup) < store.cleanupInterval {
+		return
+	}
+	store.lastCleanup = now
+
+	for elem := store.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if !now.Before(elem.Value.(*entry).expiresAt) {
+			store.remove(elem)
+		}
+		elem = prev
+	}
+
+	for key, lock := range store.locks {
+		if !now.Before(lock.expiresAt) {
+			delete(store.locks, key)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestSessionStore(t *testing.T) {
+	logger.SetOutput(GinkgoWriter)
+	logger.SetErrOutput(GinkgoWriter)
+
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Memory SessionStore")
+}
+
+var _ = Describe("Memory SessionStore Tests", func() {
+	var store *SessionStore
+
+	BeforeEach(func() {
+		store = newSessionStore(options.MemoryStoreOptions{
+			MaxEntries:      100,
+			CleanupInterval: time.Minute,
+		})
+		store.Clock.Set(time.Now())
+	})
+
+	// Stores created within a test share the same memory, the same way
+	// multiple redis clients share the same server.
+	tests.RunSessionStoreTests(
+		func(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+			opts.Type = options.MemorySessionStoreType
+			return persistence.NewManager(store, cookieOpts), nil
+		},
+		func(d time.Duration) error {
+			return store.Clock.Add(d)
+		},
+	)
+
+	Context("with a maximum number of entries", func() {
+		ctx := context.Background()
+
+		BeforeEach(func() {
+			store = newSessionStore(options.MemoryStoreOptions{
+				MaxEntries:      2,
+				CleanupInterval: time.Minute,
+			})
+			store.Clock.Set(time.Now())
+
+			Expect(store.Save(ctx, "first", []byte("first"), time.Hour)).To(Succeed())
+			Expect(store.Save(ctx, "second", []byte("second"), time.Hour)).To(Succeed())
+		})
+
+		It("evicts the least recently saved session", func() {
+			Expect(store.Save(ctx, "third", []byte("third"), time.Hour)).To(Succeed())
+
+			_, err := store.Load(ctx, "first")
+			Expect(err).To(HaveOccurred())
+			Expect(store.Load(ctx, "second")).To(Equal([]byte("second")))
+			Expect(store.Load(ctx, "third")).To(Equal([]byte("third")))
+		})
+
+		It("evicts the least recently loaded session", func() {
+			Expect(store.Load(ctx, "first")).To(Equal([]byte("first")))
+			Expect(store.Save(ctx, "third", []byte("third"), time.Hour)).To(Succeed())
+
+			_, err := store.Load(ctx, "second")
+			Expect(err).To(HaveOccurred())
+			Expect(store.Load(ctx, "first")).To(Equal([]byte("first")))
+			Expect(store.Load(ctx, "third")).To(Equal([]byte("third")))
+		})
+
+		It("does not evict when an existing session is saved again", func() {
+			Expect(store.Save(ctx, "first", []byte("updated"), time.Hour)).To(Succeed())
+
+			Expect(store.Load(ctx, "first")).To(Equal([]byte("updated")))
+			Expect(store.Load(ctx, "second")).To(Equal([]byte("second")))
+		})
+	})
+
+	Context("cleaning up expired entries", func() {
+		ctx := context.Background()
+
+		BeforeEach(func() {
+			Expect(store.Save(ctx, "short", []byte("short"), time.Minute)).To(Succeed())
+			Expect(store.Save(ctx, "long", []byte("long"), time.Hour)).To(Succeed())
+			Expect(store.Lock("short").Obtain(ctx, time.Minute)).To(Succeed())
+		})
+
+		It("does not clean up before the cleanup interval", func() {
+			Expect(store.Clock.Add(30 * time.Second)).To(Succeed())
+			Expect(store.Save(ctx, "other", []byte("other"), time.Hour)).To(Succeed())
+
+			Expect(store.entries).To(HaveLen(3))
+			Expect(store.locks).To(HaveLen(1))
+		})
+
+		It("removes expired sessions and locks after the cleanup interval", func() {
+			Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+			Expect(store.Save(ctx, "other", []byte("other"), time.Hour)).To(Succeed())
+
+			Expect(store.entries).To(HaveLen(2))
+			Expect(store.entries).To(HaveKey("long"))
+			Expect(store.entries).To(HaveKey("other"))
+			Expect(store.locks).To(BeEmpty())
+		})
+	})
+
+	Context("Lock", func() {
+		ctx := context.Background()
+
+		It("can not be obtained while another lock holds the key", func() {
+			Expect(store.Lock("key").Obtain(ctx, time.Minute)).To(Succeed())
+			Expect(store.Lock("key").Obtain(ctx, time.Minute)).To(MatchError(sessionsapi.ErrLockNotObtained))
+		})
+
+		It("can be obtained once the previous lock expired", func() {
+			Expect(store.Lock("key").Obtain(ctx, time.Minute)).To(Succeed())
+			Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+			Expect(store.Lock("key").Obtain(ctx, time.Minute)).To(Succeed())
+		})
+
+		It("can only be released by the lock that obtained it", func() {
+			lock := store.Lock("key")
+			Expect(lock.Obtain(ctx, time.Minute)).To(Succeed())
+
+			Expect(store.Lock("key").Release(ctx)).To(MatchError(sessionsapi.ErrNotLocked))
+			Expect(lock.Release(ctx)).To(Succeed())
+
+			locked, err := store.Lock("key").Peek(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(locked).To(BeFalse())
+		})
+	})
+})
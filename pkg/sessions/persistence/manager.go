@@ -8,6 +8,7 @@ import (
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 )
 
 // Manager wraps a Store and handles the implementation details of the
@@ -15,6 +16,10 @@ import (
 type Manager struct {
 	Store   Store
 	Options *options.Cookie
+
+	// ConcurrentSessions limits the number of sessions each user can have
+	// in the Store at the same time
+	ConcurrentSessions options.ConcurrentSessionOptions
 }
 
 // NewManager creates a Manager that can wrap a Store and manage the
@@ -28,20 +33,43 @@ func NewManager(store Store, cookieOpts *options.Cookie) *Manager {
 
 // Save saves a session in a persistent Store. Save will generate (or reuse an
 // existing) ticket which manages unique per session encryption & retrieval
-// from the persistent data store.
+// from the persistent data store. The session of a new login always gets a new
+// ticket, so that it never replaces the session of the cookie of the request,
+// which may belong to another user.
 func (m *Manager) Save(rw http.ResponseWriter, req *http.Request, s *sessions.SessionState) error {
 	if s.CreatedAt == nil || s.CreatedAt.IsZero() {
 		s.CreatedAtNow()
 	}
+	if s.AuthenticatedAt == nil || s.AuthenticatedAt.IsZero() {
+		s.AuthenticatedAtCreation()
+	}
+	if s.LastSeenAt == nil || s.LastSeenAt.IsZero() {
+		s.LastSeenAtNow()
+	}
 
-	tckt, err := decodeTicketFromRequest(req, m.Options)
-	if err != nil {
+	var tckt *ticket
+	var err error
+	if s.NewLogin {
+		m.clearReplacedSession(req, s)
+	} else {
+		tckt, err = decodeTicketFromRequest(req, m.Options)
+	}
+	if tckt == nil || err != nil {
 		tckt, err = newTicket(m.Options)
 		if err != nil {
 			return fmt.Errorf("error creating a session ticket: %v", err)
 		}
 	}
 
+	// The sessions of a user are only tracked when they are created, saving
+	// a loaded session again doesn't change the sessions of its user
+	if s.NewLogin {
+		err = m.trackUserSession(req.Context(), tckt.id, s)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = tckt.saveSession(s, func(key string, val []byte, exp time.Duration) error {
 		return m.Store.Save(req.Context(), key, val, exp)
 	})
@@ -52,6 +80,27 @@ func (m *Manager) Save(rw http.ResponseWriter, req *http.Request, s *sessions.Se
 	return tckt.setCookie(rw, req, s)
 }
 
+// clearReplacedSession clears the session of the cookie of the request when a
+// new login of the same user replaces it, eg. to step up the authentication.
+// The sessions of other users, eg. of a replayed cookie, are left untouched.
+func (m *Manager) clearReplacedSession(req *http.Request, s *sessions.SessionState) {
+	tckt, err := decodeTicketFromRequest(req, m.Options)
+	if err != nil {
+		return
+	}
+	replaced, err := m.Load(req)
+	if err != nil || replaced == nil || sessionUser(replaced) != sessionUser(s) {
+		return
+	}
+
+	err = tckt.clearSession(func(key string) error {
+		return m.Store.Clear(req.Context(), key)
+	})
+	if err != nil {
+		logger.Errorf("unable to clear the replaced session: %v", err)
+	}
+}
+
 // Load reads sessions.SessionState information from a session store. It will
 // use the session ticket from the http.Request's cookie.
 func (m *Manager) Load(req *http.Request) (*sessions.SessionState, error) {
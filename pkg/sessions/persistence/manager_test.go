@@ -1,12 +1,15 @@
 package persistence
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("Persistence Manager Tests", func() {
@@ -22,4 +25,67 @@ var _ = Describe("Persistence Manager Tests", func() {
 			ms.FastForward(d)
 			return nil
 		})
+
+	Context("when signing in with the cookie of an existing session", func() {
+		var manager *Manager
+
+		cookieOpts := &options.Cookie{
+			Name:   "_oauth2_proxy",
+			Secret: "0123456789abcdef0123456789abcdef",
+			Path:   "/",
+			Expire: time.Hour,
+		}
+
+		// save saves the session and returns the cookie of the response
+		save := func(req *http.Request, s *sessionsapi.SessionState) *http.Cookie {
+			rw := httptest.NewRecorder()
+			Expect(manager.Save(rw, req, s)).To(Succeed())
+			cookies := rw.Result().Cookies()
+			Expect(cookies).To(HaveLen(1))
+			return cookies[0]
+		}
+
+		requestWithCookie := func(cookie *http.Cookie) *http.Request {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.AddCookie(cookie)
+			return req
+		}
+
+		BeforeEach(func() {
+			manager = NewManager(ms, cookieOpts)
+		})
+
+		It("keeps the session of another user", func() {
+			victim := save(httptest.NewRequest("GET", "/", nil), &sessionsapi.SessionState{User: "victim", NewLogin: true})
+			attacker := save(requestWithCookie(victim), &sessionsapi.SessionState{User: "attacker", NewLogin: true})
+			Expect(attacker.Value).ToNot(Equal(victim.Value))
+
+			session, err := manager.Load(requestWithCookie(victim))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session.User).To(Equal("victim"))
+
+			session, err = manager.Load(requestWithCookie(attacker))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session.User).To(Equal("attacker"))
+		})
+
+		It("replaces the session of the same user", func() {
+			first := save(httptest.NewRequest("GET", "/", nil), &sessionsapi.SessionState{User: "john", NewLogin: true})
+			second := save(requestWithCookie(first), &sessionsapi.SessionState{User: "john", NewLogin: true})
+			Expect(second.Value).ToNot(Equal(first.Value))
+
+			_, err := manager.Load(requestWithCookie(first))
+			Expect(err).To(HaveOccurred())
+
+			session, err := manager.Load(requestWithCookie(second))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session.User).To(Equal("john"))
+		})
+
+		It("saves a loaded session under its ticket", func() {
+			first := save(httptest.NewRequest("GET", "/", nil), &sessionsapi.SessionState{User: "john", NewLogin: true})
+			second := save(requestWithCookie(first), &sessionsapi.SessionState{User: "john", AccessToken: "refreshed"})
+			Expect(second.Value).To(Equal(first.Value))
+		})
+	})
 })
@@ -41,6 +41,10 @@ type ticket struct {
 	id      string
 	secret  []byte
 	options *options.Cookie
+
+	// signedWithPreviousSecret is set when the ticket cookie was signed with
+	// a previous cookie secret and should be signed again.
+	signedWithPreviousSecret bool
 }
 
 // newTicket creates a new ticket. The ID & secret will be randomly created
@@ -146,13 +150,18 @@ func decodeTicketFromRequest(req *http.Request, cookieOpts *options.Cookie) (*ti
 	}
 
 	// An existing cookie exists, try to retrieve the ticket
-	val, _, ok := encryption.Validate(requestCookie, cookieOpts.Secret, cookieOpts.Expire)
+	val, _, index, ok := encryption.ValidateWithSeeds(requestCookie, cookieOpts.Secrets(), cookieOpts.Expire)
 	if !ok {
 		return nil, fmt.Errorf("session ticket cookie failed validation: %v", err)
 	}
 
 	// Valid cookie, decode the ticket
-	return decodeTicket(string(val), cookieOpts)
+	tckt, err := decodeTicket(string(val), cookieOpts)
+	if err != nil {
+		return nil, err
+	}
+	tckt.signedWithPreviousSecret = index > 0
+	return tckt, nil
 }
 
 // saveSession encodes the SessionState with the ticket's secret and persists
@@ -189,6 +198,7 @@ func (t *ticket) loadSession(loader loadFunc, initLock initLockFunc) (*sessions.
 	}
 	lock := initLock(t.id)
 	sessionState.Lock = lock
+	sessionState.NeedsSave = t.signedWithPreviousSecret
 	return sessionState, nil
 }
 
@@ -0,0 +1,159 @@
+package persistence
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+const (
+	// How long to try to obtain the lock on a user's session index
+	userSessionsObtainTimeout = 5 * time.Second
+
+	// How long the lock on a user's session index is held at most
+	userSessionsLockDuration = 2 * time.Second
+
+	// How long to wait after failing to obtain the lock before trying again
+	userSessionsRetryPeriod = 10 * time.Millisecond
+)
+
+// userSessions is the index of the sessions of a single user. It is kept in
+// the Store next to the sessions and lists their keys from oldest to newest.
+type userSessions struct {
+	Keys []string `json:"keys"`
+}
+
+// userSessionsKey returns the Store key of the session index of a user.
+// The user is hashed so that it isn't stored in the key in plain text.
+func userSessionsKey(cookieOpts *options.Cookie, user string) string {
+	hash := sha256.Sum256([]byte(user))
+	return fmt.Sprintf("%s-user-%s", cookieOpts.Name, hex.EncodeToString(hash[:]))
+}
+
+// sessionUser returns the identifier sessions are grouped by
+func sessionUser(s *sessions.SessionState) string {
+	if s.User != "" {
+		return s.User
+	}
+	return s.Email
+}
+
+// trackUserSession adds the session of a new login saved under key to the
+// session index of its user, enforcing the concurrent session limit when the
+// key is new. Sessions that no longer exist in the Store are only removed
+// from the index then.
+func (m *Manager) trackUserSession(ctx context.Context, key string, s *sessions.SessionState) error {
+	limit := m.ConcurrentSessions.Limit
+	user := sessionUser(s)
+	if limit <= 0 || user == "" {
+		return nil
+	}
+
+	indexKey := userSessionsKey(m.Options, user)
+	lock := m.Store.Lock(indexKey)
+	if err := obtainLock(ctx, lock); err != nil {
+		return fmt.Errorf("error locking sessions of user: %v", err)
+	}
+	defer func() {
+		if err := lock.Release(ctx); err != nil {
+			logger.Errorf("unable to release lock: %v", err)
+		}
+	}()
+
+	index, err := m.loadUserSessions(ctx, indexKey)
+	if err != nil {
+		return err
+	}
+
+	tracked := false
+	active := []string{}
+	for _, k := range index.Keys {
+		if k == key {
+			tracked = true
+			active = append(active, k)
+			continue
+		}
+		_, err := m.Store.Load(ctx, k)
+		if errors.Is(err, sessions.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error loading session of user: %v", err)
+		}
+		active = append(active, k)
+	}
+
+	if !tracked {
+		for len(active) >= limit {
+			if m.ConcurrentSessions.Action == options.ConcurrentSessionReject {
+				return sessions.ErrSessionLimitExceeded
+			}
+
+			// Evict the oldest session to make room for the new one
+			if err := m.Store.Clear(ctx, active[0]); err != nil {
+				return fmt.Errorf("error evicting session: %v", err)
+			}
+			logger.Printf("Evicted the oldest session of user %s: concurrent session limit of %d reached", user, limit)
+			active = active[1:]
+		}
+		active = append(active, key)
+	}
+
+	index.Keys = active
+	return m.saveUserSessions(ctx, indexKey, index)
+}
+
+func (m *Manager) loadUserSessions(ctx context.Context, indexKey string) (*userSessions, error) {
+	index := &userSessions{}
+	value, err := m.Store.Load(ctx, indexKey)
+	if errors.Is(err, sessions.ErrKeyNotFound) {
+		// The user has no tracked sessions yet, start a new index
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading sessions of user: %v", err)
+	}
+	if err := json.Unmarshal(value, index); err != nil {
+		return nil, fmt.Errorf("error decoding sessions of user: %v", err)
+	}
+	return index, nil
+}
+
+func (m *Manager) saveUserSessions(ctx context.Context, indexKey string, index *userSessions) error {
+	value, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("error encoding sessions of user: %v", err)
+	}
+	// The index lives as long as the newest session it tracks
+	if err := m.Store.Save(ctx, indexKey, value, m.Options.Expire); err != nil {
+		return fmt.Errorf("error saving sessions of user: %v", err)
+	}
+	return nil
+}
+
+// obtainLock retries obtaining the lock until it succeeds or times out
+func obtainLock(ctx context.Context, lock sessions.Lock) error {
+	ctx, cancel := context.WithTimeout(ctx, userSessionsObtainTimeout)
+	defer cancel()
+
+	for {
+		err := lock.Obtain(ctx, userSessionsLockDuration)
+		if !errors.Is(err, sessions.ErrLockNotObtained) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.New("timeout obtaining lock")
+		case <-time.After(userSessionsRetryPeriod):
+		}
+	}
+}
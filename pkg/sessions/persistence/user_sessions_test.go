@@ -0,0 +1,188 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Concurrent Session Limit Tests", func() {
+	var ms *tests.MockStore
+	var manager *Manager
+
+	cookieOpts := &options.Cookie{
+		Name:   "_oauth2_proxy",
+		Secret: "0123456789abcdef0123456789abcdef",
+		Path:   "/",
+		Expire: time.Hour,
+	}
+
+	// signIn saves a new session for the user, as after a sign in without
+	// an existing session cookie, and returns the request cookie for it
+	signIn := func(user string) (*http.Cookie, error) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		err := manager.Save(rw, req, &sessionsapi.SessionState{User: user, NewLogin: true})
+		if err != nil {
+			return nil, err
+		}
+		cookies := rw.Result().Cookies()
+		Expect(cookies).To(HaveLen(1))
+		return cookies[0], nil
+	}
+
+	requestWithCookie := func(cookie *http.Cookie) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookie)
+		return req
+	}
+
+	isActive := func(cookie *http.Cookie) bool {
+		_, err := manager.Load(requestWithCookie(cookie))
+		return err == nil
+	}
+
+	trackedSessions := func(user string) []string {
+		index, err := manager.loadUserSessions(context.Background(), userSessionsKey(cookieOpts, user))
+		Expect(err).ToNot(HaveOccurred())
+		return index.Keys
+	}
+
+	BeforeEach(func() {
+		ms = tests.NewMockStore()
+		manager = NewManager(ms, cookieOpts)
+	})
+
+	Context("without a limit", func() {
+		It("does not track the sessions of users", func() {
+			for i := 0; i < 3; i++ {
+				_, err := signIn("john")
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(trackedSessions("john")).To(BeEmpty())
+		})
+	})
+
+	Context("when evicting the oldest session", func() {
+		BeforeEach(func() {
+			manager.ConcurrentSessions = options.ConcurrentSessionOptions{
+				Limit:  2,
+				Action: options.ConcurrentSessionEvict,
+			}
+		})
+
+		It("removes the oldest session once the limit is reached", func() {
+			first, err := signIn("john")
+			Expect(err).ToNot(HaveOccurred())
+			second, err := signIn("john")
+			Expect(err).ToNot(HaveOccurred())
+			third, err := signIn("john")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(isActive(first)).To(BeFalse())
+			Expect(isActive(second)).To(BeTrue())
+			Expect(isActive(third)).To(BeTrue())
+			Expect(trackedSessions("john")).To(HaveLen(2))
+		})
+
+		It("tracks the sessions of each user separately", func() {
+			first, err := signIn("john")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = signIn("jane")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = signIn("jane")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = signIn("jane")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(isActive(first)).To(BeTrue())
+			Expect(trackedSessions("john")).To(HaveLen(1))
+			Expect(trackedSessions("jane")).To(HaveLen(2))
+		})
+	})
+
+	Context("when rejecting new sessions", func() {
+		BeforeEach(func() {
+			manager.ConcurrentSessions = options.ConcurrentSessionOptions{
+				Limit:  1,
+				Action: options.ConcurrentSessionReject,
+			}
+		})
+
+		It("rejects a new session once the limit is reached", func() {
+			first, err := signIn("john")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = signIn("john")
+			Expect(err).To(MatchError(sessionsapi.ErrSessionLimitExceeded))
+
+			Expect(isActive(first)).To(BeTrue())
+			Expect(trackedSessions("john")).To(HaveLen(1))
+		})
+
+		It("saves an existing session again", func() {
+			first, err := signIn("john")
+			Expect(err).ToNot(HaveOccurred())
+
+			rw := httptest.NewRecorder()
+			err = manager.Save(rw, requestWithCookie(first), &sessionsapi.SessionState{User: "john", AccessToken: "refreshed"})
+			Expect(err).ToNot(HaveOccurred())
+
+			session, err := manager.Load(requestWithCookie(first))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session.AccessToken).To(Equal("refreshed"))
+		})
+
+		It("saves an existing session again without loading the sessions of the user", func() {
+			first, err := signIn("john")
+			Expect(err).ToNot(HaveOccurred())
+
+			manager.Store = &failingLoadStore{MockStore: ms, err: errors.New("connection refused")}
+			rw := httptest.NewRecorder()
+			err = manager.Save(rw, requestWithCookie(first), &sessionsapi.SessionState{User: "john", AccessToken: "refreshed"})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("rejects a new session when the session index can't be loaded", func() {
+			_, err := signIn("john")
+			Expect(err).ToNot(HaveOccurred())
+
+			manager.Store = &failingLoadStore{MockStore: ms, err: errors.New("connection refused")}
+			_, err = signIn("john")
+			Expect(err).To(MatchError("error loading sessions of user: connection refused"))
+
+			manager.Store = ms
+			Expect(trackedSessions("john")).To(HaveLen(1))
+		})
+
+		It("allows a new session after the previous session was cleared", func() {
+			first, err := signIn("john")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(manager.Clear(httptest.NewRecorder(), requestWithCookie(first))).To(Succeed())
+
+			second, err := signIn("john")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isActive(second)).To(BeTrue())
+			Expect(trackedSessions("john")).To(HaveLen(1))
+		})
+	})
+})
+
+// failingLoadStore is a MockStore that fails to load any key, as a store
+// with a transient error would
+type failingLoadStore struct {
+	*tests.MockStore
+	err error
+}
+
+func (s *failingLoadStore) Load(context.Context, string) ([]byte, error) {
+	return nil, s.err
+}
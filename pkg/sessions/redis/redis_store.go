@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -32,7 +33,9 @@ func NewRedisSessionStore(opts *options.SessionOptions, cookieOpts *options.Cook
 	rs := &SessionStore{
 		Client: client,
 	}
-	return persistence.NewManager(rs, cookieOpts), nil
+	manager := persistence.NewManager(rs, cookieOpts)
+	manager.ConcurrentSessions = opts.Concurrent
+	return manager, nil
 }
 
 // Save takes a sessions.SessionState and stores the information from it
@@ -49,6 +52,9 @@ func (store *SessionStore) Save(ctx context.Context, key string, value []byte, e
 // cookie within the HTTP request object
 func (store *SessionStore) Load(ctx context.Context, key string) ([]byte, error) {
 	value, err := store.Client.Get(ctx, key)
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("error loading redis session: %w: %s", sessions.ErrKeyNotFound, key)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error loading redis session: %v", err)
 	}
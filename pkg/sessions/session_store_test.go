@@ -3,6 +3,7 @@ package sessions_test
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -10,8 +11,10 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions"
 	sessionscookie "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/cookie"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/memory"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+	sessionssql "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/sql"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -76,6 +79,37 @@ var _ = Describe("NewSessionStore", func() {
 		})
 	})
 
+	Context("with type 'memory'", func() {
+		BeforeEach(func() {
+			opts.Type = options.MemorySessionStoreType
+		})
+
+		It("creates a persistence.Manager that wraps a memory.SessionStore", func() {
+			ss, err := sessions.NewSessionStore(opts, cookieOpts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss).To(BeAssignableToTypeOf(&persistence.Manager{}))
+			Expect(ss.(*persistence.Manager).Store).To(BeAssignableToTypeOf(&memory.SessionStore{}))
+		})
+	})
+
+	Context("with type 'sql'", func() {
+		BeforeEach(func() {
+			opts.Type = options.SQLSessionStoreType
+			opts.SQL = options.SQLStoreOptions{
+				Driver:        options.SQLSQLiteDriver,
+				ConnectionURL: filepath.Join(GinkgoT().TempDir(), "sessions.db"),
+				TableName:     "oauth2_proxy_sessions",
+			}
+		})
+
+		It("creates a persistence.Manager that wraps a sql.SessionStore", func() {
+			ss, err := sessions.NewSessionStore(opts, cookieOpts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss).To(BeAssignableToTypeOf(&persistence.Manager{}))
+			Expect(ss.(*persistence.Manager).Store).To(BeAssignableToTypeOf(&sessionssql.SessionStore{}))
+		})
+	})
+
 	Context("with an invalid type", func() {
 		BeforeEach(func() {
 			opts.Type = "invalid-type"
@@ -0,0 +1,122 @@
+package sql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+
+	// Register the database drivers supported by the SessionStore
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// tableNameRegex restricts table names to plain identifiers as they are
+// interpolated into the queries run by the SessionStore.
+var tableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// dialect contains the differences between the databases supported
+// by the SessionStore.
+type dialect struct {
+	// driverName is the name the database/sql driver is registered under
+	driverName string
+	// blobType is the column type used to store the encrypted sessions
+	blobType string
+	// numberedPlaceholders is true when the database uses $1, $2, ...
+	// rather than ? as query placeholders
+	numberedPlaceholders bool
+	// maxOpenConns limits the number of open connections, 0 means no limit
+	maxOpenConns int
+	// lockMigrations returns a statement that prevents concurrent migrations
+	// for the duration of the transaction it is executed in, if supported
+	lockMigrations func(table string) string
+}
+
+var dialects = map[string]dialect{
+	options.SQLPostgresDriver: {
+		driverName:           "postgres",
+		blobType:             "BYTEA",
+		numberedPlaceholders: true,
+		lockMigrations: func(table string) string {
+			return fmt.Sprintf("LOCK TABLE %s IN EXCLUSIVE MODE", table)
+		},
+	},
+	options.SQLSQLiteDriver: {
+		driverName: "sqlite",
+		blobType:   "BLOB",
+		// SQLite only allows a single writer, so share one connection rather
+		// than failing with busy errors under concurrent requests
+		maxOpenConns:   1,
+		lockMigrations: func(string) string { return "" },
+	},
+}
+
+// getDialect returns the dialect for the configured driver
+func getDialect(driver string) (dialect, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return dialect{}, fmt.Errorf("unsupported sql driver %q: must be one of %q or %q",
+			driver, options.SQLPostgresDriver, options.SQLSQLiteDriver)
+	}
+	return d, nil
+}
+
+// ValidateTableName checks that the table name can be safely used in
+// the queries run by the SessionStore
+func ValidateTableName(name string) error {
+	if !tableNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid sql table name %q: must only contain letters, digits and underscores and not start with a digit", name)
+	}
+	return nil
+}
+
+// rebind replaces the ? placeholders of a query with the placeholders
+// used by the database
+func (d dialect) rebind(query string) string {
+	if !d.numberedPlaceholders {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// migration is a versioned change to the schema of the SessionStore
+type migration struct {
+	version    int
+	statements func(d dialect, sessionsTable, locksTable string) []string
+}
+
+// migrations are applied in order and must never be changed once released.
+// New schema changes need to be added as a new migration.
+var migrations = []migration{
+	{
+		version: 1,
+		statements: func(d dialect, sessionsTable, locksTable string) []string {
+			return []string{
+				fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	session_key VARCHAR(255) NOT NULL PRIMARY KEY,
+	value %s NOT NULL,
+	expires_at BIGINT NOT NULL
+)`, sessionsTable, d.blobType),
+				fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_expires_at ON %s (expires_at)", sessionsTable, sessionsTable),
+				fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	lock_key VARCHAR(255) NOT NULL PRIMARY KEY,
+	owner VARCHAR(64) NOT NULL,
+	expires_at BIGINT NOT NULL
+)`, locksTable),
+			}
+		},
+	},
+}
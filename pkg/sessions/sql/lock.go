@@ -0,0 +1,91 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+)
+
+// Lock is a lock on a session key held as a row in the locks table of a
+// SessionStore. Each Lock has a unique owner so that only the instance that
+// obtained the lock can refresh or release it.
+type Lock struct {
+	store *SessionStore
+	key   string
+	owner string
+}
+
+func newLock(store *SessionStore, key string) sessions.Lock {
+	return &Lock{
+		store: store,
+		key:   key,
+		owner: uuid.NewString(),
+	}
+}
+
+// Obtain obtains the lock for the configured key if no other unexpired lock
+// exists. The conflicting row is only replaced when it has expired, which the
+// database evaluates atomically as part of the insert.
+func (l *Lock) Obtain(ctx context.Context, expiration time.Duration) error {
+	now := l.store.Clock.Now()
+	res, err := l.store.exec(ctx, fmt.Sprintf(
+		`INSERT INTO %[1]s (lock_key, owner, expires_at) VALUES (?, ?, ?)
+ON CONFLICT (lock_key) DO UPDATE SET owner = excluded.owner, expires_at = excluded.expires_at
+WHERE %[1]s.expires_at <= ?`, l.store.locksTable),
+		l.key, l.owner, now.Add(expiration).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("error obtaining sql session lock: %v", err)
+	}
+	return lockResult(res, sessions.ErrLockNotObtained)
+}
+
+// Peek returns true, if the lock is still applied.
+func (l *Lock) Peek(ctx context.Context) (bool, error) {
+	var count int
+	err := l.store.db.QueryRowContext(ctx, l.store.dialect.rebind(fmt.Sprintf(
+		"SELECT COUNT(*) FROM %s WHERE lock_key = ? AND expires_at > ?", l.store.locksTable)),
+		l.key, l.store.Clock.Now().UnixMilli()).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("error reading sql session lock: %v", err)
+	}
+	return count > 0, nil
+}
+
+// Refresh refreshes the expiration of a lock held by this Lock.
+func (l *Lock) Refresh(ctx context.Context, expiration time.Duration) error {
+	now := l.store.Clock.Now()
+	res, err := l.store.exec(ctx, fmt.Sprintf(
+		"UPDATE %s SET expires_at = ? WHERE lock_key = ? AND owner = ? AND expires_at > ?", l.store.locksTable),
+		now.Add(expiration).UnixMilli(), l.key, l.owner, now.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("error refreshing sql session lock: %v", err)
+	}
+	return lockResult(res, sessions.ErrNotLocked)
+}
+
+// Release releases a lock held by this Lock.
+func (l *Lock) Release(ctx context.Context) error {
+	res, err := l.store.exec(ctx, fmt.Sprintf(
+		"DELETE FROM %s WHERE lock_key = ? AND owner = ? AND expires_at > ?", l.store.locksTable),
+		l.key, l.owner, l.store.Clock.Now().UnixMilli())
+	if err != nil {
+		return fmt.Errorf("error releasing sql session lock: %v", err)
+	}
+	return lockResult(res, sessions.ErrNotLocked)
+}
+
+// lockResult returns errNoRows when the statement did not change the lock row
+func lockResult(res sql.Result, errNoRows error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error reading sql session lock result: %v", err)
+	}
+	if n == 0 {
+		return errNoRows
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+)
+
+// SessionStore is an implementation of the persistence.Store
+// interface that stores sessions in a SQL database
+type SessionStore struct {
+	Clock clock.Clock
+
+	db              *sql.DB
+	dialect         dialect
+	sessionsTable   string
+	locksTable      string
+	migrationsTable string
+
+	cleanupInterval time.Duration
+	cleanupMu       sync.Mutex
+	lastCleanup     time.Time
+}
+
+// NewSQLSessionStore initialises a new instance of the SessionStore, migrates
+// the database schema and wraps the store in a persistence.Manager
+func NewSQLSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
+	store, err := newSessionStore(opts.SQL)
+	if err != nil {
+		return nil, err
+	}
+	manager := persistence.NewManager(store, cookieOpts)
+	manager.ConcurrentSessions = opts.Concurrent
+	return manager, nil
+}
+
+func newSessionStore(opts options.SQLStoreOptions) (*SessionStore, error) {
+	d, err := getDialect(opts.Driver)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateTableName(opts.TableName); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(d.driverName, opts.ConnectionURL)
+	if err != nil {
+		return nil, fmt.Errorf("error opening sql database: %v", err)
+	}
+	if d.maxOpenConns > 0 {
+		db.SetMaxOpenConns(d.maxOpenConns)
+	}
+
+	store := &SessionStore{
+		db:              db,
+		dialect:         d,
+		sessionsTable:   opts.TableName,
+		locksTable:      opts.TableName + "_locks",
+		migrationsTable: opts.TableName + "_schema_migrations",
+		cleanupInterval: opts.CleanupInterval,
+	}
+
+	if err := store.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error migrating sql session store schema: %v", err)
+	}
+	return store, nil
+}
+
+// Save takes a sessions.SessionState and stores the information from it
+// in the sessions table, replacing any session saved under the same key
+func (store *SessionStore) Save(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	now := store.Clock.Now()
+	store.cleanupIfNeeded(ctx, now)
+
+	_, err := store.exec(ctx, fmt.Sprintf(
+		`INSERT INTO %s (session_key, value, expires_at) VALUES (?, ?, ?)
+ON CONFLICT (session_key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		store.sessionsTable), key, value, now.Add(exp).UnixMilli())
+	if err != nil {
+		return fmt.Errorf("error saving sql session: %v", err)
+	}
+	return nil
+}
+
+// Load reads the session stored under the key if it has not expired yet
+func (store *SessionStore) Load(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := store.db.QueryRowContext(ctx, store.dialect.rebind(fmt.Sprintf(
+		"SELECT value FROM %s WHERE session_key = ? AND expires_at > ?", store.sessionsTable)),
+		key, store.Clock.Now().UnixMilli()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("error loading sql session: %w: %s", sessions.ErrKeyNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading sql session: %v", err)
+	}
+	return value, nil
+}
+
+// Clear deletes any session saved under the key
+func (store *SessionStore) Clear(ctx context.Context, key string) error {
+	_, err := store.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE session_key = ?", store.sessionsTable), key)
+	if err != nil {
+		return fmt.Errorf("error clearing the session from sql: %v", err)
+	}
+	return nil
+}
+
+// Lock creates a lock object for sessions.SessionState
+func (store *SessionStore) Lock(key string) sessions.Lock {
+	return newLock(store, key)
+}
+
+// VerifyConnection verifies the database connection is valid and the
+// server is responsive
+func (store *SessionStore) VerifyConnection(ctx context.Context) error {
+	return store.db.PingContext(ctx)
+}
+
+// exec runs a statement written with ? placeholders against the database
+func (store *SessionStore) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return store.db.ExecContext(ctx, store.dialect.rebind(query), args...)
+}
+
+// migrate applies any migrations that have not been applied to the database
+// yet. Each migration runs in its own transaction together with recording
+// its version, so a failed migration is retried on the next start.
+func (store *SessionStore) migrate(ctx context.Context) error {
+	_, err := store.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version INTEGER NOT NULL PRIMARY KEY)", store.migrationsTable))
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if err := store.applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("migration %d: %v", m.version, err)
+		}
+	}
+	return nil
+}
+
+func (store *SessionStore) applyMigration(ctx context.Context, m migration) error {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Rollback is a no-op once the transaction has been committed
+		_ = tx.Rollback()
+	}()
+
+	if stmt := store.dialect.lockMigrations(store.migrationsTable); stmt != "" {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, store.dialect.rebind(fmt.Sprintf(
+		"SELECT COUNT(*) FROM %s WHERE version = ?", store.migrationsTable)), m.version).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	for _, stmt := range m.statements(store.dialect, store.sessionsTable, store.locksTable) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, store.dialect.rebind(fmt.Sprintf(
+		"INSERT INTO %s (version) VALUES (?)", store.migrationsTable)), m.version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// cleanupIfNeeded deletes all expired sessions and locks once the cleanup
+// interval has passed since the last cleanup. Expired rows are never
+// returned by Load or honoured by locks, so a failed cleanup is only logged.
+func (store *SessionStore) cleanupIfNeeded(ctx context.Context, now time.Time) {
+	store.cleanupMu.Lock()
+	if store.cleanupInterval <= 0 || now.Sub(store.lastCleanup) < store.cleanupInterval {
+		store.cleanupMu.Unlock()
+		return
+	}
+	store.lastCleanup = now
+	store.cleanupMu.Unlock()
+
+	for _, table := range []string{store.sessionsTable, store.locksTable} {
+		_, err := store.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at <= ?", table), now.UnixMilli())
+		if err != nil {
+			logger.Errorf("error deleting expired rows from %s: %v", table, err)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestSessionStore(t *testing.T) {
+	logger.SetOutput(GinkgoWriter)
+	logger.SetErrOutput(GinkgoWriter)
+
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SQL SessionStore")
+}
+
+var _ = Describe("SQL SessionStore Tests", func() {
+	var store *SessionStore
+	var storeOpts options.SQLStoreOptions
+
+	BeforeEach(func() {
+		storeOpts = options.SQLStoreOptions{
+			Driver:          options.SQLSQLiteDriver,
+			ConnectionURL:   filepath.Join(GinkgoT().TempDir(), "sessions.db"),
+			TableName:       "oauth2_proxy_sessions",
+			CleanupInterval: time.Minute,
+		}
+
+		var err error
+		store, err = newSessionStore(storeOpts)
+		Expect(err).ToNot(HaveOccurred())
+		store.Clock.Set(time.Now())
+	})
+
+	AfterEach(func() {
+		Expect(store.db.Close()).To(Succeed())
+	})
+
+	// Stores created within a test share the same database, the same way
+	// multiple instances of the proxy share a database server.
+	tests.RunSessionStoreTests(
+		func(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+			opts.Type = options.SQLSessionStoreType
+			return persistence.NewManager(store, cookieOpts), nil
+		},
+		func(d time.Duration) error {
+			return store.Clock.Add(d)
+		},
+	)
+
+	Context("NewSQLSessionStore", func() {
+		It("rejects an unsupported driver", func() {
+			storeOpts.Driver = "mysql"
+			_, err := newSessionStore(storeOpts)
+			Expect(err).To(MatchError("unsupported sql driver \"mysql\": must be one of \"postgres\" or \"sqlite\""))
+		})
+
+		It("rejects a table name that is not an identifier", func() {
+			storeOpts.TableName = "sessions; DROP TABLE users"
+			_, err := newSessionStore(storeOpts)
+			Expect(err).To(MatchError(ContainSubstring("invalid sql table name")))
+		})
+
+		It("can be started again against a migrated database", func() {
+			ctx := context.Background()
+			Expect(store.Save(ctx, "key", []byte("value"), time.Hour)).To(Succeed())
+
+			other, err := newSessionStore(storeOpts)
+			Expect(err).ToNot(HaveOccurred())
+			defer other.db.Close()
+			other.Clock.Set(store.Clock.Now())
+
+			Expect(other.Load(ctx, "key")).To(Equal([]byte("value")))
+
+			var count int
+			Expect(other.db.QueryRow("SELECT COUNT(*) FROM oauth2_proxy_sessions_schema_migrations").Scan(&count)).To(Succeed())
+			Expect(count).To(Equal(len(migrations)))
+		})
+	})
+
+	Context("expiry", func() {
+		ctx := context.Background()
+
+		countRows := func(table string) int {
+			var count int
+			Expect(store.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count)).To(Succeed())
+			return count
+		}
+
+		BeforeEach(func() {
+			Expect(store.Save(ctx, "short", []byte("short"), 30*time.Second)).To(Succeed())
+			Expect(store.Save(ctx, "long", []byte("long"), time.Hour)).To(Succeed())
+			Expect(store.Lock("short").Obtain(ctx, 30*time.Second)).To(Succeed())
+		})
+
+		It("does not load expired sessions", func() {
+			Expect(store.Clock.Add(30 * time.Second)).To(Succeed())
+
+			_, err := store.Load(ctx, "short")
+			Expect(err).To(MatchError("error loading sql session: key not found: short"))
+			Expect(store.Load(ctx, "long")).To(Equal([]byte("long")))
+		})
+
+		It("does not clean up before the cleanup interval", func() {
+			Expect(store.Clock.Add(45 * time.Second)).To(Succeed())
+			Expect(store.Save(ctx, "other", []byte("other"), time.Hour)).To(Succeed())
+
+			Expect(countRows(store.sessionsTable)).To(Equal(3))
+			Expect(countRows(store.locksTable)).To(Equal(1))
+		})
+
+		It("deletes expired sessions and locks after the cleanup interval", func() {
+			Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+			Expect(store.Save(ctx, "other", []byte("other"), time.Hour)).To(Succeed())
+
+			Expect(countRows(store.sessionsTable)).To(Equal(2))
+			Expect(countRows(store.locksTable)).To(Equal(0))
+		})
+	})
+
+	Context("Lock", func() {
+		ctx := context.Background()
+
+		It("can not be obtained while another lock holds the key", func() {
+			Expect(store.Lock("key").Obtain(ctx, time.Minute)).To(Succeed())
+			Expect(store.Lock("key").Obtain(ctx, time.Minute)).To(MatchError(sessionsapi.ErrLockNotObtained))
+		})
+
+		It("can be obtained once the previous lock expired", func() {
+			Expect(store.Lock("key").Obtain(ctx, time.Minute)).To(Succeed())
+			Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+			Expect(store.Lock("key").Obtain(ctx, time.Minute)).To(Succeed())
+		})
+
+		It("can only be refreshed and released by the lock that obtained it", func() {
+			lock := store.Lock("key")
+			Expect(lock.Obtain(ctx, time.Minute)).To(Succeed())
+
+			other := store.Lock("key")
+			Expect(other.Refresh(ctx, time.Minute)).To(MatchError(sessionsapi.ErrNotLocked))
+			Expect(other.Release(ctx)).To(MatchError(sessionsapi.ErrNotLocked))
+
+			Expect(lock.Refresh(ctx, time.Minute)).To(Succeed())
+			Expect(lock.Release(ctx)).To(Succeed())
+
+			locked, err := other.Peek(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(locked).To(BeFalse())
+		})
+	})
+})
+
+var _ = Describe("dialect", func() {
+	It("rebinds placeholders for postgres", func() {
+		d, err := getDialect(options.SQLPostgresDriver)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(d.rebind("SELECT a FROM t WHERE b = ? AND c > ?")).To(Equal("SELECT a FROM t WHERE b = $1 AND c > $2"))
+	})
+
+	It("keeps placeholders for sqlite", func() {
+		d, err := getDialect(options.SQLSQLiteDriver)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(d.rebind("SELECT a FROM t WHERE b = ?")).To(Equal("SELECT a FROM t WHERE b = ?"))
+	})
+})
@@ -45,7 +45,7 @@ func (s *MockStore) Load(_ context.Context, key string) ([]byte, error) {
 	entry, ok := s.cache[key]
 	if !ok || entry.expiration <= s.elapsed {
 		delete(s.cache, key)
-		return nil, fmt.Errorf("key not found: %s", key)
+		return nil, fmt.Errorf("%w: %s", sessions.ErrKeyNotFound, key)
 	}
 	return entry.data, nil
 }
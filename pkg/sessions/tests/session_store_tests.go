@@ -133,6 +133,30 @@ func RunSessionStoreTests(newSS NewSessionStoreFunc, persistentFastForward Persi
 				PersistentSessionStoreInterfaceTests(&input)
 			}
 		})
+
+		Context("with a rotated cookie secret", func() {
+			var previousSS sessionsapi.SessionStore
+
+			BeforeEach(func() {
+				previousSecret := make([]byte, 32)
+				_, err := rand.Read(previousSecret)
+				Expect(err).ToNot(HaveOccurred())
+
+				previousCookieOpts := *input.cookieOpts
+				previousCookieOpts.Secret = string(previousSecret)
+				previousSS, err = newSS(opts, &previousCookieOpts)
+				Expect(err).ToNot(HaveOccurred())
+
+				input.cookieOpts.PreviousSecrets = []string{string(previousSecret)}
+				ss, err = newSS(opts, input.cookieOpts)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			SessionStoreInterfaceTests(&input)
+			RotatedSecretSessionStoreTests(&input, func() sessionsapi.SessionStore {
+				return previousSS
+			})
+		})
 	})
 }
 
@@ -376,6 +400,14 @@ func SessionStoreInterfaceTests(in *testInput) {
 				Expect(in.session.CreatedAt.IsZero()).To(BeFalse())
 			})
 
+			It("Ensures the session AuthenticatedAt matches CreatedAt", func() {
+				Expect(in.session.AuthenticatedAt.Equal(*in.session.CreatedAt)).To(BeTrue())
+			})
+
+			It("Ensures the session LastSeenAt is not zero", func() {
+				Expect(in.session.LastSeenAt.IsZero()).To(BeFalse())
+			})
+
 			CheckCookieOptions(in)
 		})
 
@@ -493,6 +525,51 @@ func SessionStoreInterfaceTests(in *testInput) {
 	})
 }
 
+// RotatedSecretSessionStoreTests checks that sessions saved with a previous
+// cookie secret can still be loaded and are signed with the current secret
+// once they are saved again.
+func RotatedSecretSessionStoreTests(in *testInput, previousSS sessionStoreFunc) {
+	Context("when Load is called with a session saved using a previous secret", func() {
+		var loadedSession *sessionsapi.SessionState
+		var loadErr error
+
+		BeforeEach(func() {
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			resp := httptest.NewRecorder()
+			err := previousSS().Save(resp, req, in.session)
+			Expect(err).ToNot(HaveOccurred())
+			for _, cookie := range resp.Result().Cookies() {
+				in.request.AddCookie(cookie)
+			}
+
+			loadedSession, loadErr = in.ss().Load(in.request)
+		})
+
+		It("loads the session", func() {
+			Expect(loadErr).ToNot(HaveOccurred())
+			Expect(loadedSession.Email).To(Equal(in.session.Email))
+			Expect(loadedSession.AccessToken).To(Equal(in.session.AccessToken))
+		})
+
+		It("marks the session as needing to be saved", func() {
+			Expect(loadedSession.NeedsSave).To(BeTrue())
+		})
+
+		It("signs the session with the current secret when saved again", func() {
+			err := in.ss().Save(in.response, in.request, loadedSession)
+			Expect(err).ToNot(HaveOccurred())
+
+			cookies := in.response.Result().Cookies()
+			Expect(cookies).ToNot(BeEmpty())
+			for _, cookie := range cookies {
+				_, _, index, ok := encryption.ValidateWithSeeds(cookie, in.cookieOpts.Secrets(), in.cookieOpts.Expire)
+				Expect(ok).To(BeTrue())
+				Expect(index).To(Equal(0))
+			}
+		})
+	})
+}
+
 func LoadSessionTests(in *testInput) {
 	var loadedSession *sessionsapi.SessionState
 	BeforeEach(func() {
@@ -506,16 +583,22 @@ func LoadSessionTests(in *testInput) {
 		l := *loadedSession
 		l.CreatedAt = nil
 		l.ExpiresOn = nil
+		l.AuthenticatedAt = nil
+		l.LastSeenAt = nil
 		l.Lock = &sessionsapi.NoOpLock{}
 		s := *in.session
 		s.CreatedAt = nil
 		s.ExpiresOn = nil
+		s.AuthenticatedAt = nil
+		s.LastSeenAt = nil
 		s.Lock = &sessionsapi.NoOpLock{}
 		Expect(l).To(Equal(s))
 
 		// Compare time.Time separately
 		Expect(loadedSession.CreatedAt.Equal(*in.session.CreatedAt)).To(BeTrue())
 		Expect(loadedSession.ExpiresOn.Equal(*in.session.ExpiresOn)).To(BeTrue())
+		Expect(loadedSession.AuthenticatedAt.Equal(*in.session.AuthenticatedAt)).To(BeTrue())
+		Expect(loadedSession.LastSeenAt.Equal(*in.session.LastSeenAt)).To(BeTrue())
 
 	})
 }
@@ -0,0 +1,63 @@
+package upstream
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// Matcher finds the upstream that a request will be proxied to, before the
+// request reaches the proxy. It matches paths in the same way as the proxy.
+type Matcher struct {
+	serveMux *mux.Router
+}
+
+// NewMatcher creates a Matcher for the upstreams in the configuration.
+func NewMatcher(upstreams options.UpstreamConfig) (*Matcher, error) {
+	m := &Matcher{
+		serveMux: mux.NewRouter(),
+	}
+
+	if upstreams.ProxyRawPath {
+		m.serveMux.UseEncodedPath()
+	}
+
+	// Sort a copy so that the order of the configuration is not changed
+	sorted := make([]options.Upstream, len(upstreams.Upstreams))
+	copy(sorted, upstreams.Upstreams)
+
+	for _, upstream := range sortByPathLongest(sorted) {
+		var route *mux.Route
+		switch {
+		case upstream.RewriteTarget != "":
+			rewriteRegExp, err := regexp.Compile(upstream.Path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q for upstream: %v", upstream.Path, err)
+			}
+			route = m.serveMux.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
+				return rewriteRegExp.MatchString(req.URL.Path)
+			})
+		case strings.HasSuffix(upstream.Path, "/"):
+			route = m.serveMux.PathPrefix(upstream.Path)
+		default:
+			route = m.serveMux.Path(upstream.Path)
+		}
+		route.Name(upstream.ID)
+	}
+
+	return m, nil
+}
+
+// Match returns the ID of the upstream the request will be proxied to, or
+// false if no upstream matches the request.
+func (m *Matcher) Match(req *http.Request) (string, bool) {
+	match := &mux.RouteMatch{}
+	if !m.serveMux.Match(req, match) || match.Route == nil {
+		return "", false
+	}
+	return match.Route.GetName(), true
+}
@@ -0,0 +1,111 @@
+package upstream
+
+import (
+	"net/http/httptest"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Matcher Suite", func() {
+	upstreams := options.UpstreamConfig{
+		Upstreams: []options.Upstream{
+			{
+				ID:   "root",
+				Path: "/",
+				URI:  "http://root.localhost",
+			},
+			{
+				ID:   "admin",
+				Path: "/admin/",
+				URI:  "http://admin.localhost",
+			},
+			{
+				ID:   "exact",
+				Path: "/exact",
+				URI:  "http://exact.localhost",
+			},
+			{
+				ID:            "rewrite",
+				Path:          "^/api/v([0-9]+)/(.*)$",
+				RewriteTarget: "/$2",
+				URI:           "http://api.localhost",
+			},
+		},
+	}
+
+	type matcherTableInput struct {
+		upstreams        options.UpstreamConfig
+		target           string
+		expectedUpstream string
+		expectedMatch    bool
+	}
+
+	DescribeTable("Match",
+		func(in matcherTableInput) {
+			m, err := NewMatcher(in.upstreams)
+			Expect(err).ToNot(HaveOccurred())
+
+			upstream, ok := m.Match(httptest.NewRequest("GET", in.target, nil))
+			Expect(ok).To(Equal(in.expectedMatch))
+			Expect(upstream).To(Equal(in.expectedUpstream))
+		},
+		Entry("with a path under the root", matcherTableInput{
+			upstreams:        upstreams,
+			target:           "http://example.localhost/foo",
+			expectedUpstream: "root",
+			expectedMatch:    true,
+		}),
+		Entry("with the longest prefix", matcherTableInput{
+			upstreams:        upstreams,
+			target:           "http://example.localhost/admin/users",
+			expectedUpstream: "admin",
+			expectedMatch:    true,
+		}),
+		Entry("with an exact path", matcherTableInput{
+			upstreams:        upstreams,
+			target:           "http://example.localhost/exact",
+			expectedUpstream: "exact",
+			expectedMatch:    true,
+		}),
+		Entry("with a path under an exact path", matcherTableInput{
+			upstreams:        upstreams,
+			target:           "http://example.localhost/exact/foo",
+			expectedUpstream: "root",
+			expectedMatch:    true,
+		}),
+		Entry("with a rewrite path", matcherTableInput{
+			upstreams:        upstreams,
+			target:           "http://example.localhost/api/v1/users",
+			expectedUpstream: "rewrite",
+			expectedMatch:    true,
+		}),
+		Entry("without a matching upstream", matcherTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "admin",
+						Path: "/admin/",
+						URI:  "http://admin.localhost",
+					},
+				},
+			},
+			target:           "http://example.localhost/foo",
+			expectedUpstream: "",
+			expectedMatch:    false,
+		}),
+	)
+
+	It("does not reorder the upstream configuration", func() {
+		config := options.UpstreamConfig{
+			Upstreams: []options.Upstream{
+				{ID: "root", Path: "/", URI: "http://root.localhost"},
+				{ID: "admin", Path: "/admin/", URI: "http://admin.localhost"},
+			},
+		}
+		_, err := NewMatcher(config)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.Upstreams[0].ID).To(Equal("root"))
+	})
+})
@@ -74,6 +74,40 @@ func TestValidateCookie(t *testing.T) {
 				missingSecretMsg,
 			},
 		},
+		{
+			name: "with valid previous cookie secrets",
+			cookie: options.Cookie{
+				Name:            validName,
+				Secret:          validSecret,
+				PreviousSecrets: []string{validBase64Secret},
+				Domains:         domains,
+				Path:            "",
+				Expire:          time.Hour,
+				Refresh:         15 * time.Minute,
+				Secure:          true,
+				HTTPOnly:        false,
+				SameSite:        "",
+			},
+			errStrings: []string{},
+		},
+		{
+			name: "with an invalid previous cookie secret",
+			cookie: options.Cookie{
+				Name:            validName,
+				Secret:          validSecret,
+				PreviousSecrets: []string{validBase64Secret, invalidSecret},
+				Domains:         domains,
+				Path:            "",
+				Expire:          time.Hour,
+				Refresh:         15 * time.Minute,
+				Secure:          true,
+				HTTPOnly:        false,
+				SameSite:        "",
+			},
+			errStrings: []string{
+				"invalid previous cookie secret at index 1: " + invalidSecretMsg,
+			},
+		},
 		{
 			name: "with an invalid cookie secret",
 			cookie: options.Cookie{
@@ -0,0 +1,61 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// validateCORS ensures the CORS configuration allows well formed origins and
+// does not allow credentials from any origin
+func validateCORS(name string, cors *options.CORS) []string {
+	if cors == nil {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if len(cors.AllowedOrigins) == 0 {
+		msgs = append(msgs, fmt.Sprintf("%s must allow at least one origin", name))
+	}
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "*" {
+			if cors.AllowCredentials {
+				msgs = append(msgs, fmt.Sprintf("%s cannot allow credentials from any origin (*)", name))
+			}
+			continue
+		}
+		if msg := validateCORSOrigin(origin); msg != "" {
+			msgs = append(msgs, fmt.Sprintf("%s has invalid origin %q: %s", name, origin, msg))
+		}
+	}
+	for _, method := range cors.AllowedMethods {
+		if method == "" || method != strings.ToUpper(method) {
+			msgs = append(msgs, fmt.Sprintf("%s has invalid method %q: methods must be upper case", name, method))
+		}
+	}
+	if cors.MaxAge != nil && cors.MaxAge.Duration() < 0 {
+		msgs = append(msgs, fmt.Sprintf("%s has invalid maxAge (%s): must not be negative", name, cors.MaxAge.Duration()))
+	}
+	return msgs
+}
+
+// validateCORSOrigin checks that an origin is a scheme and host with an
+// optional port, where the host may start with a wildcard
+func validateCORSOrigin(origin string) string {
+	if strings.Count(origin, "*") > 1 {
+		return "only one wildcard is allowed"
+	}
+	u, err := url.Parse(strings.Replace(origin, "*", "wildcard", 1))
+	if err != nil {
+		return err.Error()
+	}
+	if u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "must be a scheme and host, eg. https://app.example.com"
+	}
+	if strings.Contains(origin, "*") && !strings.HasPrefix(u.Host, "wildcard.") {
+		return "a wildcard must be the first label of the host, eg. https://*.example.com"
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package validation
+
+import (
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CORS", func() {
+	type validateCORSTableInput struct {
+		cors       *options.CORS
+		errStrings []string
+	}
+
+	maxAge := options.Duration(10 * time.Minute)
+	negativeMaxAge := options.Duration(-time.Minute)
+
+	DescribeTable("validateCORS",
+		func(in validateCORSTableInput) {
+			Expect(validateCORS("cors", in.cors)).To(ConsistOf(in.errStrings))
+		},
+		Entry("without a CORS configuration", validateCORSTableInput{
+			cors:       nil,
+			errStrings: []string{},
+		}),
+		Entry("with a valid configuration", validateCORSTableInput{
+			cors: &options.CORS{
+				AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org", "http://localhost:3000"},
+				AllowedMethods:   []string{"GET", "DELETE"},
+				AllowedHeaders:   []string{"*"},
+				AllowCredentials: true,
+				MaxAge:           &maxAge,
+			},
+			errStrings: []string{},
+		}),
+		Entry("with any origin", validateCORSTableInput{
+			cors: &options.CORS{
+				AllowedOrigins: []string{"*"},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with no origins", validateCORSTableInput{
+			cors:       &options.CORS{},
+			errStrings: []string{"cors must allow at least one origin"},
+		}),
+		Entry("with credentials from any origin", validateCORSTableInput{
+			cors: &options.CORS{
+				AllowedOrigins:   []string{"*"},
+				AllowCredentials: true,
+			},
+			errStrings: []string{"cors cannot allow credentials from any origin (*)"},
+		}),
+		Entry("with invalid origins", validateCORSTableInput{
+			cors: &options.CORS{
+				AllowedOrigins: []string{"app.example.com", "https://app.example.com/", "https://app.*.example.com", "https://*.*.example.com"},
+			},
+			errStrings: []string{
+				"cors has invalid origin \"app.example.com\": must be a scheme and host, eg. https://app.example.com",
+				"cors has invalid origin \"https://app.example.com/\": must be a scheme and host, eg. https://app.example.com",
+				"cors has invalid origin \"https://app.*.example.com\": a wildcard must be the first label of the host, eg. https://*.example.com",
+				"cors has invalid origin \"https://*.*.example.com\": only one wildcard is allowed",
+			},
+		}),
+		Entry("with invalid methods and maxAge", validateCORSTableInput{
+			cors: &options.CORS{
+				AllowedOrigins: []string{"https://app.example.com"},
+				AllowedMethods: []string{"get"},
+				MaxAge:         &negativeMaxAge,
+			},
+			errStrings: []string{
+				"cors has invalid method \"get\": methods must be upper case",
+				"cors has invalid maxAge (-1m0s): must not be negative",
+			},
+		}),
+	)
+})
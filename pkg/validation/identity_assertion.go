@@ -0,0 +1,39 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/assertion"
+)
+
+// validateIdentityAssertion ensures the identity assertion has an issuer and
+// a signing key, and that the configured claims do not replace the claims
+// the proxy sets itself
+func validateIdentityAssertion(ia *options.IdentityAssertion) []string {
+	if ia == nil {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if ia.Issuer == "" {
+		msgs = append(msgs, "identityAssertion: missing issuer")
+	}
+	if msg := validateSecretSource(ia.SigningKey); msg != "" {
+		msgs = append(msgs, "identityAssertion: invalid signingKey: "+msg)
+	}
+	for i, key := range ia.AdditionalPublicKeys {
+		if msg := validateSecretSource(key); msg != "" {
+			msgs = append(msgs, fmt.Sprintf("identityAssertion: invalid additionalPublicKeys[%d]: %s", i, msg))
+		}
+	}
+	for _, claim := range ia.Claims {
+		if assertion.IsReservedClaim(claim) {
+			msgs = append(msgs, fmt.Sprintf("identityAssertion: claim %q is set by the proxy and cannot be configured", claim))
+		}
+	}
+	if ia.Lifetime != nil && ia.Lifetime.Duration() <= 0 {
+		msgs = append(msgs, fmt.Sprintf("identityAssertion: invalid lifetime (%s): must be positive", ia.Lifetime.Duration()))
+	}
+	return msgs
+}
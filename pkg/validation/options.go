@@ -21,11 +21,20 @@ import (
 func Validate(o *options.Options) error {
 	msgs := validateCookie(o.Cookie)
 	msgs = append(msgs, validateSessionCookieMinimal(o)...)
+	msgs = append(msgs, validateSessionLifetime(o)...)
+	msgs = append(msgs, validateConcurrentSessions(o)...)
+	msgs = append(msgs, validateSessionBinding(o)...)
 	msgs = append(msgs, validateRedisSessionStore(o)...)
+	msgs = append(msgs, validateMemorySessionStore(o)...)
+	msgs = append(msgs, validateSQLSessionStore(o)...)
 	msgs = append(msgs, prefixValues("injectRequestHeaders: ", validateHeaders(o.InjectRequestHeaders)...)...)
 	msgs = append(msgs, prefixValues("injectResponseHeaders: ", validateHeaders(o.InjectResponseHeaders)...)...)
 	msgs = append(msgs, validateProviders(o)...)
 	msgs = append(msgs, validateAPIRoutes(o)...)
+	msgs = append(msgs, validateStepUpRoutes(o.StepUpRoutes)...)
+	msgs = append(msgs, validateIdentityAssertion(o.IdentityAssertion)...)
+	msgs = append(msgs, validateUserInfoFields(o)...)
+	msgs = append(msgs, validateCORS("cors", o.CORS)...)
 	msgs = configureLogger(o.Logging, msgs)
 	msgs = parseSignatureKey(o, msgs)
 
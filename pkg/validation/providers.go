@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/ohler55/ojg/jp"
 )
 
 // validateProviders is the initial validation migration for multiple providrers
@@ -46,8 +47,8 @@ func validateProvider(provider options.Provider, providerIDs map[string]struct{}
 		msgs = append(msgs, "provider missing setting: client-id")
 	}
 
-	// login.gov uses a signed JWT to authenticate, not a client-secret
-	if provider.Type != "login.gov" {
+	// login.gov and private_key_jwt use a signed JWT to authenticate, not a client-secret
+	if provider.Type != "login.gov" && provider.TokenEndpointAuthMethod != options.PrivateKeyJWT {
 		if provider.ClientSecret == "" && provider.ClientSecretFile == "" {
 			msgs = append(msgs, "missing setting: client-secret or client-secret-file")
 		}
@@ -59,11 +60,47 @@ func validateProvider(provider options.Provider, providerIDs map[string]struct{}
 		}
 	}
 
+	msgs = append(msgs, validateTokenEndpointAuth(provider)...)
+	msgs = append(msgs, validatePushedAuthorizationRequests(provider)...)
 	msgs = append(msgs, validateGoogleConfig(provider)...)
+	msgs = append(msgs, validateGenericOAuth2Config(provider)...)
 
 	return msgs
 }
 
+// validateTokenEndpointAuth checks the client authentication method of the
+// token endpoint, and that private_key_jwt has a client assertion key.
+func validateTokenEndpointAuth(provider options.Provider) []string {
+	msgs := []string{}
+
+	switch provider.TokenEndpointAuthMethod {
+	case "", options.ClientSecretBasic, options.ClientSecretPost, options.ClientSecretJWT:
+	case options.PrivateKeyJWT:
+		if provider.ClientAssertionKey == nil {
+			msgs = append(msgs, "missing setting: client-assertion-key-file is required with the private_key_jwt token endpoint auth method")
+		}
+	default:
+		msgs = append(msgs, fmt.Sprintf("invalid setting: token-endpoint-auth-method %q, must be one of client_secret_basic, client_secret_post, client_secret_jwt or private_key_jwt", provider.TokenEndpointAuthMethod))
+	}
+
+	if provider.ClientAssertionKey != nil && provider.TokenEndpointAuthMethod != options.PrivateKeyJWT {
+		msgs = append(msgs, "invalid setting: client-assertion-key-file is only used by the private_key_jwt token endpoint auth method")
+	}
+
+	return msgs
+}
+
+// validatePushedAuthorizationRequests checks the pushed authorization requests
+// mode. Whether the provider has an endpoint is only known after discovery.
+func validatePushedAuthorizationRequests(provider options.Provider) []string {
+	switch provider.PushedAuthorizationRequests {
+	case "", options.PushedAuthorizationRequestsRequired, options.PushedAuthorizationRequestsPreferred, options.PushedAuthorizationRequestsOff:
+		return []string{}
+	default:
+		return []string{fmt.Sprintf("invalid setting: pushed-authorization-requests %q, must be one of required, preferred or off", provider.PushedAuthorizationRequests)}
+	}
+}
+
 func validateGoogleConfig(provider options.Provider) []string {
 	msgs := []string{}
 
@@ -96,3 +133,63 @@ func validateGoogleConfig(provider options.Provider) []string {
 
 	return msgs
 }
+
+// validateGenericOAuth2Config checks that the generic OAuth2 provider has the
+// endpoints it cannot discover, and that its JSONPath expressions parse.
+func validateGenericOAuth2Config(provider options.Provider) []string {
+	if provider.Type != options.GenericOAuth2Provider {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if provider.LoginURL == "" {
+		msgs = append(msgs, "missing setting: login-url")
+	}
+	if provider.RedeemURL == "" {
+		msgs = append(msgs, "missing setting: redeem-url")
+	}
+	if provider.ProfileURL == "" {
+		msgs = append(msgs, "missing setting: profile-url")
+	}
+
+	config := provider.GenericOAuth2Config
+	msgs = append(msgs, validateGenericOAuth2ClaimMapping("claimMapping", config.ClaimMapping)...)
+	for i, request := range config.ExtraRequests {
+		prefix := fmt.Sprintf("extraRequests[%d]", i)
+		if request.URL == "" {
+			msgs = append(msgs, fmt.Sprintf("generic-oauth2 %s has empty url", prefix))
+		}
+		if request.MaxPages < 0 {
+			msgs = append(msgs, fmt.Sprintf("generic-oauth2 %s has negative maxPages: %d", prefix, request.MaxPages))
+		}
+		msgs = append(msgs, validateJSONPath(prefix+".nextPagePath", request.NextPagePath)...)
+		msgs = append(msgs, validateGenericOAuth2ClaimMapping(prefix+".claimMapping", request.ClaimMapping)...)
+	}
+	return msgs
+}
+
+func validateGenericOAuth2ClaimMapping(prefix string, mapping options.GenericOAuth2ClaimMapping) []string {
+	msgs := []string{}
+	msgs = append(msgs, validateJSONPath(prefix+".user", mapping.User)...)
+	msgs = append(msgs, validateJSONPath(prefix+".email", mapping.Email)...)
+	msgs = append(msgs, validateJSONPath(prefix+".preferredUsername", mapping.PreferredUsername)...)
+	msgs = append(msgs, validateJSONPath(prefix+".groups", mapping.Groups)...)
+	for i, claim := range mapping.Claims {
+		if claim.Name == "" || claim.Path == "" {
+			msgs = append(msgs, fmt.Sprintf("generic-oauth2 %s.claims[%d] must have a name and a path", prefix, i))
+			continue
+		}
+		msgs = append(msgs, validateJSONPath(fmt.Sprintf("%s.claims[%d].path", prefix, i), claim.Path)...)
+	}
+	return msgs
+}
+
+func validateJSONPath(field, path string) []string {
+	if path == "" {
+		return []string{}
+	}
+	if _, err := jp.ParseString(path); err != nil {
+		return []string{fmt.Sprintf("generic-oauth2 %s is not a valid JSONPath expression: %v", field, err)}
+	}
+	return []string{}
+}
@@ -25,6 +25,29 @@ var _ = Describe("Providers", func() {
 		ClientSecret: "ClientSecret",
 	}
 
+	validGenericOAuth2Provider := options.Provider{
+		Type:         options.GenericOAuth2Provider,
+		ID:           "ProviderIDGenericOAuth2",
+		ClientID:     "ClientID",
+		ClientSecret: "ClientSecret",
+		LoginURL:     "https://example.com/oauth/authorize",
+		RedeemURL:    "https://example.com/oauth/token",
+		ProfileURL:   "https://example.com/api/user",
+		GenericOAuth2Config: options.GenericOAuth2Options{
+			ClaimMapping: options.GenericOAuth2ClaimMapping{
+				User:  "$.data.login",
+				Email: "$.data.emails[0]",
+			},
+			ExtraRequests: []options.GenericOAuth2Request{
+				{
+					URL:          "https://example.com/api/user/teams",
+					ClaimMapping: options.GenericOAuth2ClaimMapping{Groups: "$.teams[*].name"},
+					NextPagePath: "$.next",
+				},
+			},
+		},
+	}
+
 	missingIDProvider := options.Provider{
 		ClientID:     "ClientID",
 		ClientSecret: "ClientSecret",
@@ -48,10 +71,110 @@ var _ = Describe("Providers", func() {
 				Providers: options.Providers{
 					validProvider,
 					validLoginGovProvider,
+					validGenericOAuth2Provider,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a generic-oauth2 provider without endpoints", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.GenericOAuth2Provider,
+						ID:           "ProviderIDGenericOAuth2",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+					},
+				},
+			},
+			errStrings: []string{
+				"missing setting: login-url",
+				"missing setting: redeem-url",
+				"missing setting: profile-url",
+			},
+		}),
+		Entry("with an invalid generic-oauth2 mapping", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						Type:         options.GenericOAuth2Provider,
+						ID:           "ProviderIDGenericOAuth2",
+						ClientID:     "ClientID",
+						ClientSecret: "ClientSecret",
+						LoginURL:     "https://example.com/oauth/authorize",
+						RedeemURL:    "https://example.com/oauth/token",
+						ProfileURL:   "https://example.com/api/user",
+						GenericOAuth2Config: options.GenericOAuth2Options{
+							ClaimMapping: options.GenericOAuth2ClaimMapping{
+								Claims: []options.GenericOAuth2Claim{{Name: "org"}},
+							},
+							ExtraRequests: []options.GenericOAuth2Request{{MaxPages: -1}},
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"generic-oauth2 claimMapping.claims[0] must have a name and a path",
+				"generic-oauth2 extraRequests[0] has empty url",
+				"generic-oauth2 extraRequests[0] has negative maxPages: -1",
+			},
+		}),
+		Entry("with a private_key_jwt provider", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						ID:                      "ProviderID",
+						ClientID:                "ClientID",
+						TokenEndpointAuthMethod: options.PrivateKeyJWT,
+						ClientAssertionKey:      &options.SecretSource{FromFile: "/etc/oauth2-proxy/client.key"},
+						ClientAssertionKeyID:    "key-1",
+					},
 				},
 			},
 			errStrings: []string{},
 		}),
+		Entry("with a private_key_jwt provider without a client assertion key", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						ID:                      "ProviderID",
+						ClientID:                "ClientID",
+						TokenEndpointAuthMethod: options.PrivateKeyJWT,
+					},
+				},
+			},
+			errStrings: []string{"missing setting: client-assertion-key-file is required with the private_key_jwt token endpoint auth method"},
+		}),
+		Entry("with an invalid token endpoint auth method", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						ID:                      "ProviderID",
+						ClientID:                "ClientID",
+						ClientSecret:            "ClientSecret",
+						TokenEndpointAuthMethod: "tls_client_auth",
+						ClientAssertionKey:      &options.SecretSource{FromFile: "/etc/oauth2-proxy/client.key"},
+					},
+				},
+			},
+			errStrings: []string{
+				"invalid setting: token-endpoint-auth-method \"tls_client_auth\", must be one of client_secret_basic, client_secret_post, client_secret_jwt or private_key_jwt",
+				"invalid setting: client-assertion-key-file is only used by the private_key_jwt token endpoint auth method",
+			},
+		}),
+		Entry("with an invalid pushed authorization requests mode", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						ID:                          "ProviderID",
+						ClientID:                    "ClientID",
+						ClientSecret:                "ClientSecret",
+						PushedAuthorizationRequests: "always",
+					},
+				},
+			},
+			errStrings: []string{"invalid setting: pushed-authorization-requests \"always\", must be one of required, preferred or off"},
+		}),
 		Entry("with an empty providerID", &validateProvidersTableInput{
 			options: &options.Options{
 				Providers: options.Providers{
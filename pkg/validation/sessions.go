@@ -9,6 +9,7 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/sql"
 )
 
 func validateSessionCookieMinimal(o *options.Options) []string {
@@ -39,6 +40,78 @@ func validateSessionCookieMinimal(o *options.Options) []string {
 	return msgs
 }
 
+// validateSessionLifetime ensures the idle timeout and maximum lifetime of
+// sessions are consistent
+func validateSessionLifetime(o *options.Options) []string {
+	msgs := []string{}
+	if o.Session.IdleTimeout < 0 {
+		msgs = append(msgs, fmt.Sprintf("session_idle_timeout (%q) must not be negative", o.Session.IdleTimeout.String()))
+	}
+	if o.Session.MaxLifetime < 0 {
+		msgs = append(msgs, fmt.Sprintf("session_max_lifetime (%q) must not be negative", o.Session.MaxLifetime.String()))
+	}
+	if o.Session.IdleTimeout > 0 && o.Session.LastSeenInterval >= o.Session.IdleTimeout {
+		msgs = append(msgs, fmt.Sprintf(
+			"session_last_seen_interval (%q) must be less than session_idle_timeout (%q)",
+			o.Session.LastSeenInterval.String(),
+			o.Session.IdleTimeout.String()))
+	}
+	return msgs
+}
+
+// validateConcurrentSessions ensures the concurrent session limit can be
+// enforced by the configured session store
+func validateConcurrentSessions(o *options.Options) []string {
+	limit := o.Session.Concurrent
+	if limit.Limit == 0 {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if limit.Limit < 0 {
+		msgs = append(msgs, fmt.Sprintf("session_concurrent_limit (%d) must not be negative", limit.Limit))
+	}
+	switch limit.Action {
+	case options.ConcurrentSessionEvict, options.ConcurrentSessionReject:
+	default:
+		msgs = append(msgs, fmt.Sprintf("session_concurrent_limit_action (%q) must be one of ['evict', 'reject']", limit.Action))
+	}
+	if o.Session.Type == options.CookieSessionStoreType {
+		msgs = append(msgs, "session_concurrent_limit requires a persistent session store: redis, sql or memory")
+	}
+	return msgs
+}
+
+// validateSessionBinding ensures the session binding attributes, network
+// prefixes and action are valid
+func validateSessionBinding(o *options.Options) []string {
+	b := o.Session.Binding
+	if len(b.Attributes) == 0 {
+		return []string{}
+	}
+
+	msgs := []string{}
+	for _, attribute := range b.Attributes {
+		switch attribute {
+		case options.SessionBindingIPPrefix, options.SessionBindingUserAgent, options.SessionBindingClientCert:
+		default:
+			msgs = append(msgs, fmt.Sprintf("session_binding (%q) must be one of ['ip-prefix', 'user-agent', 'client-cert']", attribute))
+		}
+	}
+	if b.IPv4Prefix < 0 || b.IPv4Prefix > 32 {
+		msgs = append(msgs, fmt.Sprintf("session_binding_ipv4_prefix (%d) must be between 0 and 32", b.IPv4Prefix))
+	}
+	if b.IPv6Prefix < 0 || b.IPv6Prefix > 128 {
+		msgs = append(msgs, fmt.Sprintf("session_binding_ipv6_prefix (%d) must be between 0 and 128", b.IPv6Prefix))
+	}
+	switch b.Action {
+	case options.SessionBindingReauthenticate, options.SessionBindingReject:
+	default:
+		msgs = append(msgs, fmt.Sprintf("session_binding_action (%q) must be one of ['reauthenticate', 'reject']", b.Action))
+	}
+	return msgs
+}
+
 // validateRedisSessionStore builds a Redis Client from the options and
 // attempts to connect, Set, Get and Del a random health check key
 func validateRedisSessionStore(o *options.Options) []string {
@@ -61,6 +134,44 @@ func validateRedisSessionStore(o *options.Options) []string {
 	return sendRedisConnectionTest(client, key, nonce)
 }
 
+// validateMemorySessionStore ensures the memory session store limits are usable
+func validateMemorySessionStore(o *options.Options) []string {
+	if o.Session.Type != options.MemorySessionStoreType {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if o.Session.Memory.MaxEntries < 0 {
+		msgs = append(msgs, fmt.Sprintf("session_memory_max_entries (%d) must not be negative", o.Session.Memory.MaxEntries))
+	}
+	if o.Session.Memory.CleanupInterval <= 0 {
+		msgs = append(msgs, fmt.Sprintf("session_memory_cleanup_interval (%q) must be greater than 0", o.Session.Memory.CleanupInterval.String()))
+	}
+	return msgs
+}
+
+// validateSQLSessionStore ensures the sql session store can be used to
+// connect to a supported database
+func validateSQLSessionStore(o *options.Options) []string {
+	if o.Session.Type != options.SQLSessionStoreType {
+		return []string{}
+	}
+
+	msgs := []string{}
+	switch o.Session.SQL.Driver {
+	case options.SQLPostgresDriver, options.SQLSQLiteDriver:
+	default:
+		msgs = append(msgs, fmt.Sprintf("sql_driver (%q) must be one of ['postgres', 'sqlite']", o.Session.SQL.Driver))
+	}
+	if o.Session.SQL.ConnectionURL == "" {
+		msgs = append(msgs, "missing setting: sql-connection-url")
+	}
+	if err := sql.ValidateTableName(o.Session.SQL.TableName); err != nil {
+		msgs = append(msgs, err.Error())
+	}
+	return msgs
+}
+
 func sendRedisConnectionTest(client redis.Client, key string, val string) []string {
 	msgs := []string{}
 	ctx := context.Background()
@@ -0,0 +1,37 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// validateStepUpRoutes ensures the step-up routes have valid paths and
+// requirements
+func validateStepUpRoutes(routes []options.StepUpRoute) []string {
+	msgs := []string{}
+	for i, route := range routes {
+		if route.Path == "" {
+			msgs = append(msgs, fmt.Sprintf("step-up route %d has empty path", i))
+		} else if _, err := regexp.Compile(route.Path); err != nil {
+			msgs = append(msgs, fmt.Sprintf("step-up route %d has invalid path %q: %v", i, route.Path, err))
+		}
+		stepUp := route.StepUp
+		msgs = append(msgs, validateStepUp(fmt.Sprintf("step-up route %d", i), &stepUp)...)
+	}
+	return msgs
+}
+
+// validateStepUp ensures step-up requirements define at least one
+// requirement and a positive maximum age
+func validateStepUp(name string, stepUp *options.StepUp) []string {
+	msgs := []string{}
+	if stepUp.IsEmpty() {
+		msgs = append(msgs, fmt.Sprintf("%s must require at least one of acrValues, amr or maxAge", name))
+	}
+	if stepUp.MaxAge != nil && stepUp.MaxAge.Duration() <= 0 {
+		msgs = append(msgs, fmt.Sprintf("%s has invalid maxAge (%s): must be positive", name, stepUp.MaxAge.Duration()))
+	}
+	return msgs
+}
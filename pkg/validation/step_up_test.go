@@ -0,0 +1,63 @@
+package validation
+
+import (
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Step-up Routes", func() {
+	type validateStepUpRoutesTableInput struct {
+		routes     []options.StepUpRoute
+		errStrings []string
+	}
+
+	maxAge := options.Duration(15 * time.Minute)
+	negativeMaxAge := options.Duration(-time.Minute)
+
+	DescribeTable("validateStepUpRoutes",
+		func(o *validateStepUpRoutesTableInput) {
+			Expect(validateStepUpRoutes(o.routes)).To(ConsistOf(o.errStrings))
+		},
+		Entry("with no routes", &validateStepUpRoutesTableInput{
+			routes:     []options.StepUpRoute{},
+			errStrings: []string{},
+		}),
+		Entry("with valid routes", &validateStepUpRoutesTableInput{
+			routes: []options.StepUpRoute{
+				{
+					Path:   "^/admin/",
+					StepUp: options.StepUp{ACRValues: []string{"gold"}, MaxAge: &maxAge},
+				},
+				{
+					Path:   "^/billing/",
+					StepUp: options.StepUp{AMR: []string{"mfa"}},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an empty path and requirements", &validateStepUpRoutesTableInput{
+			routes: []options.StepUpRoute{
+				{},
+			},
+			errStrings: []string{
+				"step-up route 0 has empty path",
+				"step-up route 0 must require at least one of acrValues, amr or maxAge",
+			},
+		}),
+		Entry("with an invalid path and maximum age", &validateStepUpRoutesTableInput{
+			routes: []options.StepUpRoute{
+				{
+					Path:   "^/admin/(",
+					StepUp: options.StepUp{MaxAge: &negativeMaxAge},
+				},
+			},
+			errStrings: []string{
+				"step-up route 0 has invalid path \"^/admin/(\": error parsing regexp: missing closing ): `^/admin/(`",
+				"step-up route 0 has invalid maxAge (-1m0s): must be positive",
+			},
+		}),
+	)
+})
@@ -45,6 +45,30 @@ func validateUpstream(upstream options.Upstream, ids, paths map[string]struct{})
 
 	msgs = append(msgs, validateUpstreamURI(upstream)...)
 	msgs = append(msgs, validateStaticUpstream(upstream)...)
+	if upstream.StepUp != nil {
+		msgs = append(msgs, validateStepUp(fmt.Sprintf("upstream %q", upstream.ID), upstream.StepUp)...)
+	}
+	msgs = append(msgs, validateTokenExchange(upstream)...)
+	msgs = append(msgs, validateCORS(fmt.Sprintf("upstream %q cors", upstream.ID), upstream.CORS)...)
+	return msgs
+}
+
+// validateTokenExchange checks that a token exchange target identifies the
+// service the token is for, and that the upstream is proxied so that the
+// exchanged token can be sent to it.
+func validateTokenExchange(upstream options.Upstream) []string {
+	target := upstream.TokenExchange
+	if target == nil {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if target.Audience == "" && len(target.Scopes) == 0 && target.Resource == "" {
+		msgs = append(msgs, fmt.Sprintf("upstream %q tokenExchange must set at least one of audience, scopes or resource", upstream.ID))
+	}
+	if upstream.Static {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has tokenExchange, but is a static upstream, this will have no effect.", upstream.ID))
+	}
 	return msgs
 }
 
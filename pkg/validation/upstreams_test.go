@@ -207,5 +207,83 @@ var _ = Describe("Upstreams", func() {
 			},
 			errStrings: []string{emptyURIMsg, staticCodeMsg},
 		}),
+		Entry("with step-up requirements", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:     "foo",
+						Path:   "/foo",
+						URI:    "http://foo",
+						StepUp: &options.StepUp{AMR: []string{"mfa"}},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with empty step-up requirements", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:     "foo",
+						Path:   "/foo",
+						URI:    "http://foo",
+						StepUp: &options.StepUp{},
+					},
+				},
+			},
+			errStrings: []string{"upstream \"foo\" must require at least one of acrValues, amr or maxAge"},
+		}),
+		Entry("with a token exchange target", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:            "foo",
+						Path:          "/foo",
+						URI:           "http://foo",
+						TokenExchange: &options.TokenExchange{Audience: "foo-api"},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an empty token exchange target", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:            "foo",
+						Path:          "/foo",
+						URI:           "http://foo",
+						TokenExchange: &options.TokenExchange{},
+					},
+				},
+			},
+			errStrings: []string{"upstream \"foo\" tokenExchange must set at least one of audience, scopes or resource"},
+		}),
+		Entry("with a token exchange target on a static upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:            "foo",
+						Path:          "/foo",
+						Static:        true,
+						TokenExchange: &options.TokenExchange{Scopes: []string{"foo"}},
+					},
+				},
+			},
+			errStrings: []string{"upstream \"foo\" has tokenExchange, but is a static upstream, this will have no effect."},
+		}),
+		Entry("with an invalid CORS configuration", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://foo",
+						CORS: &options.CORS{},
+					},
+				},
+			},
+			errStrings: []string{"upstream \"foo\" cors must allow at least one origin"},
+		}),
 	)
 })
@@ -0,0 +1,23 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// validateUserInfoFields ensures tokens are only returned from the userinfo
+// endpoint when this has been explicitly enabled
+func validateUserInfoFields(o *options.Options) []string {
+	msgs := []string{}
+	if o.UserInfoIncludeTokens {
+		return msgs
+	}
+	for _, field := range o.UserInfoFields {
+		switch field {
+		case "access_token", "id_token", "refresh_token":
+			msgs = append(msgs, fmt.Sprintf("userinfo_fields (%q) would return a token from the userinfo endpoint, set userinfo_include_tokens to allow this", field))
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,45 @@
+package validation
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UserInfo", func() {
+	type validateUserInfoFieldsTableInput struct {
+		fields        []string
+		includeTokens bool
+		errStrings    []string
+	}
+
+	DescribeTable("validateUserInfoFields",
+		func(in validateUserInfoFieldsTableInput) {
+			opts := &options.Options{
+				UserInfoFields:        in.fields,
+				UserInfoIncludeTokens: in.includeTokens,
+			}
+			Expect(validateUserInfoFields(opts)).To(ConsistOf(in.errStrings))
+		},
+		Entry("with no fields", validateUserInfoFieldsTableInput{
+			fields:     []string{},
+			errStrings: []string{},
+		}),
+		Entry("with claims and session fields", validateUserInfoFieldsTableInput{
+			fields:     []string{"expires_on", "has_refresh_token", "provider", "department"},
+			errStrings: []string{},
+		}),
+		Entry("with tokens", validateUserInfoFieldsTableInput{
+			fields: []string{"access_token", "expires_on", "id_token"},
+			errStrings: []string{
+				"userinfo_fields (\"access_token\") would return a token from the userinfo endpoint, set userinfo_include_tokens to allow this",
+				"userinfo_fields (\"id_token\") would return a token from the userinfo endpoint, set userinfo_include_tokens to allow this",
+			},
+		}),
+		Entry("with tokens explicitly enabled", validateUserInfoFieldsTableInput{
+			fields:        []string{"access_token", "refresh_token"},
+			includeTokens: true,
+			errStrings:    []string{},
+		}),
+	)
+})
@@ -1,8 +1,8 @@
 package providers
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -26,6 +26,10 @@ type AzureProvider struct {
 	Tenant          string
 	GraphGroupField string
 	isV2Endpoint    bool
+
+	// validateWithProfile is set when no validate URL was configured, to
+	// validate sessions with the profile URL
+	validateWithProfile bool
 }
 
 var _ Provider = (*AzureProvider)(nil)
@@ -70,9 +74,7 @@ func NewAzureProvider(p *ProviderData, opts options.AzureOptions) *AzureProvider
 		scope:       azureDefaultScope,
 	})
 
-	if p.ValidateURL == nil || p.ValidateURL.String() == "" {
-		p.ValidateURL = p.ProfileURL
-	}
+	validateWithProfile := p.ValidateURL == nil || p.ValidateURL.String() == ""
 	p.getAuthorizationHeaderFunc = makeAzureHeader
 
 	tenant := "common"
@@ -87,9 +89,28 @@ func NewAzureProvider(p *ProviderData, opts options.AzureOptions) *AzureProvider
 		graphGroupField = opts.GraphGroupField
 	}
 
-	isV2Endpoint := false
+	provider := &AzureProvider{
+		ProviderData:        p,
+		Tenant:              tenant,
+		GraphGroupField:     graphGroupField,
+		validateWithProfile: validateWithProfile,
+	}
+	provider.configureEndpoints()
+	// A lazy OIDC discovery replaces the endpoints once the provider was created
+	p.setAfterDiscovery(provider.configureEndpoints)
+	return provider
+}
+
+// configureEndpoints derives the validate URL, the endpoint version and the
+// scope from the login and profile URLs
+func (p *AzureProvider) configureEndpoints() {
+	if p.validateWithProfile {
+		p.ValidateURL = p.ProfileURL
+	}
+
+	p.isV2Endpoint = false
 	if strings.Contains(p.LoginURL.String(), "v2.0") {
-		isV2Endpoint = true
+		p.isV2Endpoint = true
 		azureV2GraphScope := fmt.Sprintf("https://%s/.default", p.ProfileURL.Host)
 
 		if strings.Contains(p.Scope, " groups") {
@@ -106,13 +127,6 @@ func NewAzureProvider(p *ProviderData, opts options.AzureOptions) *AzureProvider
 			logger.Print("WARNING: `--resource` option has no effect when using the Azure OAuth V2 endpoint.")
 		}
 	}
-
-	return &AzureProvider{
-		ProviderData:    p,
-		Tenant:          tenant,
-		GraphGroupField: graphGroupField,
-		isV2Endpoint:    isV2Endpoint,
-	}
 }
 
 func overrideTenantURL(current, defaultURL *url.URL, tenant, path string) {
@@ -165,13 +179,11 @@ func (p *AzureProvider) Redeem(ctx context.Context, redirectURL, code, codeVerif
 		IDToken      string `json:"id_token"`
 	}
 
-	err = requests.New(p.RedeemURL.String()).
-		WithContext(ctx).
-		WithMethod("POST").
-		WithBody(bytes.NewBufferString(params.Encode())).
-		SetHeader("Content-Type", "application/x-www-form-urlencoded").
-		Do().
-		UnmarshalInto(&jsonResponse)
+	req, err := p.newTokenRequest(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	err = req.Do().UnmarshalInto(&jsonResponse)
 	if err != nil {
 		return nil, err
 	}
@@ -187,7 +199,7 @@ func (p *AzureProvider) Redeem(ctx context.Context, redirectURL, code, codeVerif
 	err = p.extractClaimsIntoSession(ctx, session)
 
 	if err != nil {
-		return nil, fmt.Errorf("unable to get email and/or groups claims from token: %v", err)
+		return nil, fmt.Errorf("unable to get email and/or groups claims from token: %w", err)
 	}
 
 	return session, nil
@@ -225,14 +237,8 @@ func (p *AzureProvider) prepareRedeem(redirectURL, code, codeVerifier string) (u
 	if code == "" {
 		return params, ErrMissingCode
 	}
-	clientSecret, err := p.GetClientSecret()
-	if err != nil {
-		return params, err
-	}
 
 	params.Add("redirect_uri", redirectURL)
-	params.Add("client_id", p.ClientID)
-	params.Add("client_secret", clientSecret)
 	params.Add("code", code)
 	params.Add("grant_type", "authorization_code")
 	if codeVerifier != "" {
@@ -262,14 +268,22 @@ func (p *AzureProvider) extractClaimsIntoSession(ctx context.Context, session *s
 	// https://github.com/oauth2-proxy/oauth2-proxy/pull/914#issuecomment-782285814
 	// https://github.com/AzureAD/azure-activedirectory-library-for-java/issues/117
 	// due to above issues, id_token may not be signed by AAD
-	// in that case, we will fallback to access token
+	// in that case, we will fallback to access token.
+	// A user not authorized by the id_token claims is not authorized by the
+	// claims of another token.
 	var err error
 	s, err = p.buildSessionFromClaims(session.IDToken, session.AccessToken)
+	if errors.Is(err, ErrNotAuthorized) {
+		return err
+	}
 	if err != nil || s.Email == "" {
 		s, err = p.buildSessionFromClaims(session.AccessToken, session.AccessToken)
 	}
+	if errors.Is(err, ErrNotAuthorized) {
+		return err
+	}
 	if err != nil {
-		return fmt.Errorf("unable to get claims from token: %v", err)
+		return fmt.Errorf("unable to get claims from token: %w", err)
 	}
 
 	session.Email = s.Email
@@ -308,21 +322,14 @@ func (p *AzureProvider) RefreshSession(ctx context.Context, s *sessions.SessionS
 
 	err := p.redeemRefreshToken(ctx, s)
 	if err != nil {
-		return false, fmt.Errorf("unable to redeem refresh token: %v", err)
+		return false, fmt.Errorf("unable to redeem refresh token: %w", err)
 	}
 
 	return true, nil
 }
 
 func (p *AzureProvider) redeemRefreshToken(ctx context.Context, s *sessions.SessionState) error {
-	clientSecret, err := p.GetClientSecret()
-	if err != nil {
-		return err
-	}
-
 	params := url.Values{}
-	params.Add("client_id", p.ClientID)
-	params.Add("client_secret", clientSecret)
 	params.Add("refresh_token", s.RefreshToken)
 	params.Add("grant_type", "refresh_token")
 
@@ -333,13 +340,11 @@ func (p *AzureProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sess
 		IDToken      string `json:"id_token"`
 	}
 
-	err = requests.New(p.RedeemURL.String()).
-		WithContext(ctx).
-		WithMethod("POST").
-		WithBody(bytes.NewBufferString(params.Encode())).
-		SetHeader("Content-Type", "application/x-www-form-urlencoded").
-		Do().
-		UnmarshalInto(&jsonResponse)
+	req, err := p.newTokenRequest(ctx, params)
+	if err != nil {
+		return err
+	}
+	err = req.Do().UnmarshalInto(&jsonResponse)
 	if err != nil {
 		return err
 	}
@@ -354,6 +359,10 @@ func (p *AzureProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sess
 	err = p.extractClaimsIntoSession(ctx, s)
 
 	if err != nil {
+		// The refreshed claims no longer authorize the user
+		if errors.Is(err, ErrNotAuthorized) {
+			return err
+		}
 		logger.Printf("unable to get email and/or groups claims from token: %v", err)
 	}
 
@@ -2,6 +2,8 @@ package providers
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -367,6 +369,42 @@ func TestAzureProviderRedeem(t *testing.T) {
 	}
 }
 
+func TestAzureProviderRedeemRequiredClaims(t *testing.T) {
+	audience := jwt.RegisteredClaims{Audience: jwt.ClaimStrings{"cd6d4fae-f6a6-4a34-8454-2c6b598e9532"}}
+	idToken, err := newSignedTestIDToken(idTokenClaims{
+		RegisteredClaims: audience,
+		Email:            "foo1@example.com",
+		Groups:           []string{"aa", "bb"},
+	})
+	assert.NoError(t, err)
+	accessToken, err := newSignedTestIDToken(idTokenClaims{
+		RegisteredClaims: audience,
+		Email:            "foo1@example.com",
+		Groups:           []string{"admins"},
+	})
+	assert.NoError(t, err)
+
+	payloadBytes, err := json.Marshal(azureOAuthPayload{
+		IDToken:      idToken,
+		AccessToken:  accessToken,
+		RefreshToken: "some_refresh_token",
+		ExpiresOn:    time.Now().Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+
+	b := testAzureBackend(string(payloadBytes), accessToken, "some_refresh_token")
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testAzureProvider(bURL.Host, options.AzureOptions{})
+	p.Data().RedeemURL.Path = "/common/oauth2/token"
+	assert.Empty(t, p.compileRequiredClaims([]options.RequiredClaim{{Claim: "groups", Values: []string{"admins"}}}))
+
+	// The access token claims don't satisfy the claims the id_token doesn't
+	_, err = p.Redeem(context.Background(), "https://localhost", "1234", "123")
+	assert.ErrorIs(t, err, ErrRequiredClaimNotSatisfied)
+}
+
 func TestAzureProviderProtectedResourceConfiguredOAuthV1(t *testing.T) {
 	p := testAzureProvider("", options.AzureOptions{})
 	p.ProtectedResource, _ = url.Parse("http://my.resource.test")
@@ -430,3 +468,75 @@ func TestAzureProviderRefresh(t *testing.T) {
 	assert.Equal(t, email, session.Email)
 	assert.Equal(t, timestamp, session.ExpiresOn.UTC())
 }
+
+func TestAzureProviderGroupOverage(t *testing.T) {
+	const overageAccessToken = "overage_access_token"
+	graphRequests := 0
+	var payload []byte
+
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/common/oauth2/token":
+			w.Write(payload)
+		case "/v1.0/me/transitiveMemberOf/microsoft.graph.group":
+			graphRequests++
+			assert.Equal(t, "Bearer "+overageAccessToken, r.Header.Get("Authorization"))
+			assert.Equal(t, "eventual", r.Header.Get("ConsistencyLevel"))
+			w.Write([]byte(`{"value":[{"id":"11111111-2222-3333-4444-555555555555"},{"id":"66666666-7777-8888-9999-000000000000"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer b.Close()
+
+	// Entra ID replaces the groups claim with a distributed claim when the
+	// user is a member of too many groups
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	idToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, struct {
+		idTokenClaims
+		ClaimNames   map[string]string            `json:"_claim_names"`
+		ClaimSources map[string]map[string]string `json:"_claim_sources"`
+	}{
+		idTokenClaims: idTokenClaims{
+			Email: "foo@example.com",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience: jwt.ClaimStrings{"cd6d4fae-f6a6-4a34-8454-2c6b598e9532"},
+				Subject:  "foo",
+			},
+		},
+		ClaimNames: map[string]string{"groups": "src1"},
+		ClaimSources: map[string]map[string]string{
+			"src1": {"endpoint": b.URL + "/v1.0/users/d4b3d6d7/getMemberObjects"},
+		},
+	}).SignedString(key)
+	assert.NoError(t, err)
+
+	payload, err = json.Marshal(azureOAuthPayload{
+		IDToken:      idToken,
+		AccessToken:  overageAccessToken,
+		RefreshToken: "some_refresh_token",
+		ExpiresOn:    time.Now().Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+
+	bURL, _ := url.Parse(b.URL)
+	p := testAzureProvider(bURL.Host, options.AzureOptions{})
+	p.Data().RedeemURL.Path = "/common/oauth2/token"
+	p.SkipClaimsFromProfileURL = true
+
+	s, err := p.Redeem(context.Background(), "https://localhost", "1234", "123")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "foo@example.com", s.Email)
+	assert.Equal(t, []string{"11111111-2222-3333-4444-555555555555", "66666666-7777-8888-9999-000000000000"}, s.Groups)
+	assert.Equal(t, 1, graphRequests)
+
+	// The groups are listed again on refresh, so that membership changes apply
+	refreshed, err := p.RefreshSession(context.Background(), s)
+	assert.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.Len(t, s.Groups, 2)
+	assert.Equal(t, 2, graphRequests)
+}
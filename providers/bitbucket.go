@@ -2,6 +2,8 @@ package providers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/url"
 	"strings"
 
@@ -14,17 +16,29 @@ import (
 // BitbucketProvider represents an Bitbucket based Identity Provider
 type BitbucketProvider struct {
 	*ProviderData
-	Team       string
-	Repository string
+	Workspaces           []string
+	Repository           string
+	RepositoryPermission string
 }
 
 var _ Provider = (*BitbucketProvider)(nil)
 
 const (
 	bitbucketProviderName = "Bitbucket"
-	bitbucketDefaultScope = "email"
+	bitbucketDefaultScope = "account email"
+
+	bitbucketPermissionRead  = "read"
+	bitbucketPermissionWrite = "write"
+	bitbucketPermissionAdmin = "admin"
 )
 
+// bitbucketPermissionLevels orders the repository permissions of Bitbucket
+var bitbucketPermissionLevels = map[string]int{
+	bitbucketPermissionRead:  1,
+	bitbucketPermissionWrite: 2,
+	bitbucketPermissionAdmin: 3,
+}
+
 var (
 	// Default Login URL for Bitbucket.
 	// Pre-parsed URL of https://bitbucket.org/site/oauth2/authorize.
@@ -42,9 +56,16 @@ var (
 		Path:   "/site/oauth2/access_token",
 	}
 
+	// Default Profile URL for Bitbucket.
+	// Pre-parsed URL of https://api.bitbucket.org/2.0/user.
+	bitbucketDefaultProfileURL = &url.URL{
+		Scheme: "https",
+		Host:   "api.bitbucket.org",
+		Path:   "/2.0/user",
+	}
+
 	// Default Validation URL for Bitbucket.
 	// This simply returns the email of the authenticated user.
-	// Bitbucket does not have a Profile URL to use.
 	// Pre-parsed URL of https://api.bitbucket.org/2.0/user/emails.
 	bitbucketDefaultValidateURL = &url.URL{
 		Scheme: "https",
@@ -54,33 +75,43 @@ var (
 )
 
 // NewBitbucketProvider initiates a new BitbucketProvider
-func NewBitbucketProvider(p *ProviderData, opts options.BitbucketOptions) *BitbucketProvider {
+func NewBitbucketProvider(p *ProviderData, opts options.BitbucketOptions) (*BitbucketProvider, error) {
 	p.setProviderDefaults(providerDefaults{
 		name:        bitbucketProviderName,
 		loginURL:    bitbucketDefaultLoginURL,
 		redeemURL:   bitbucketDefaultRedeemURL,
-		profileURL:  nil,
+		profileURL:  bitbucketDefaultProfileURL,
 		validateURL: bitbucketDefaultValidateURL,
 		scope:       bitbucketDefaultScope,
 	})
+	p.getAuthorizationHeaderFunc = makeOIDCHeader
 
-	provider := &BitbucketProvider{ProviderData: p}
+	permission := opts.RepositoryPermission
+	if permission == "" {
+		permission = bitbucketPermissionRead
+	}
+	if _, ok := bitbucketPermissionLevels[permission]; !ok {
+		return nil, fmt.Errorf("invalid repository permission %q: must be one of read, write or admin", permission)
+	}
+
+	provider := &BitbucketProvider{
+		ProviderData:         p,
+		RepositoryPermission: permission,
+	}
 
+	// Bitbucket teams were migrated to workspaces with the same slug
+	workspaces := opts.Workspaces
 	if opts.Team != "" {
-		provider.setTeam(opts.Team)
+		workspaces = append([]string{opts.Team}, workspaces...)
 	}
+	provider.Workspaces = workspaces
 	if opts.Repository != "" {
+		if workspace, name, ok := strings.Cut(opts.Repository, "/"); !ok || workspace == "" || name == "" {
+			return nil, fmt.Errorf("invalid repository %q: repositories must be given as workspace/repository", opts.Repository)
+		}
 		provider.setRepository(opts.Repository)
 	}
-	return provider
-}
-
-// setTeam defines the Bitbucket team the user must be part of
-func (p *BitbucketProvider) setTeam(team string) {
-	p.Team = team
-	if !strings.Contains(p.Scope, "team") {
-		p.Scope += " team"
-	}
+	return provider, nil
 }
 
 // setRepository defines the repository the user must have access to
@@ -91,100 +122,218 @@ func (p *BitbucketProvider) setRepository(repository string) {
 	}
 }
 
-// GetEmailAddress returns the email of the authenticated user
-func (p *BitbucketProvider) GetEmailAddress(ctx context.Context, s *sessions.SessionState) (string, error) {
+// makeBitbucketAPIEndpoint builds an endpoint of the Bitbucket API, on the
+// host of the ValidateURL.
+func (p *BitbucketProvider) makeBitbucketAPIEndpoint(endpoint string, params url.Values) string {
+	u := &url.URL{
+		Scheme:   p.ValidateURL.Scheme,
+		Host:     p.ValidateURL.Host,
+		Path:     endpoint,
+		RawQuery: params.Encode(),
+	}
+	return u.String()
+}
 
-	var emails struct {
-		Values []struct {
-			Email   string `json:"email"`
-			Primary bool   `json:"is_primary"`
-		}
+// Redeem exchanges the OAuth2 authentication code for an access token,
+// keeping the refresh token and expiry of the response.
+func (p *BitbucketProvider) Redeem(ctx context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
+	return p.redeemOAuth2Code(ctx, redirectURL, code, codeVerifier)
+}
+
+// EnrichSession sets the user, email and workspaces of the session and checks
+// that the user is allowed by the configured workspaces and repository.
+func (p *BitbucketProvider) EnrichSession(ctx context.Context, s *sessions.SessionState) error {
+	if err := p.getUser(ctx, s); err != nil {
+		return err
 	}
-	var teams struct {
-		Values []struct {
-			Name string `json:"username"`
-		}
+	if err := p.getEmail(ctx, s); err != nil {
+		return err
+	}
+	if err := p.getWorkspaces(ctx, s); err != nil {
+		return err
+	}
+	return p.checkRestrictions(ctx, s)
+}
+
+// ValidateSession validates the AccessToken
+func (p *BitbucketProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
+	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
+}
+
+// RefreshSession uses the RefreshToken to fetch a new access token, then
+// reloads the user's workspaces and checks again that the user is allowed.
+func (p *BitbucketProvider) RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	if s == nil || s.RefreshToken == "" {
+		return false, nil
+	}
+
+	if err := p.redeemOAuth2RefreshToken(ctx, s); err != nil {
+		return false, fmt.Errorf("unable to redeem refresh token: %w", err)
+	}
+
+	s.Groups = nil
+	if err := p.getWorkspaces(ctx, s); err != nil {
+		return false, err
+	}
+	if err := p.checkRestrictions(ctx, s); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// getUser updates the SessionState User and PreferredUsername
+func (p *BitbucketProvider) getUser(ctx context.Context, s *sessions.SessionState) error {
+	// https://developer.atlassian.com/cloud/bitbucket/rest/api-group-users/#api-user-get
+	var user struct {
+		Username    string `json:"username"`
+		DisplayName string `json:"display_name"`
+	}
+
+	err := requests.New(p.ProfileURL.String()).
+		WithContext(ctx).
+		WithHeaders(makeOIDCHeader(s.AccessToken)).
+		Do().
+		UnmarshalInto(&user)
+	if err != nil {
+		return fmt.Errorf("unable to fetch user: %v", err)
 	}
-	var repositories struct {
+
+	s.User = user.Username
+	s.PreferredUsername = user.DisplayName
+	return nil
+}
+
+// getEmail updates the SessionState Email with the user's primary email
+func (p *BitbucketProvider) getEmail(ctx context.Context, s *sessions.SessionState) error {
+	// https://developer.atlassian.com/cloud/bitbucket/rest/api-group-users/#api-user-emails-get
+	var emails struct {
 		Values []struct {
-			FullName string `json:"full_name"`
-		}
+			Email     string `json:"email"`
+			Primary   bool   `json:"is_primary"`
+			Confirmed bool   `json:"is_confirmed"`
+		} `json:"values"`
 	}
 
-	requestURL := p.ValidateURL.String() + "?access_token=" + s.AccessToken
-	err := requests.New(requestURL).
+	err := requests.New(p.ValidateURL.String()).
 		WithContext(ctx).
+		WithHeaders(makeOIDCHeader(s.AccessToken)).
 		Do().
 		UnmarshalInto(&emails)
 	if err != nil {
-		logger.Errorf("failed making request: %v", err)
-		return "", err
+		return fmt.Errorf("unable to fetch emails: %v", err)
 	}
 
-	if p.Team != "" {
-		teamURL := &url.URL{}
-		*teamURL = *p.ValidateURL
-		teamURL.Path = "/2.0/teams"
+	for _, email := range emails.Values {
+		if email.Primary && email.Confirmed {
+			s.Email = email.Email
+			return nil
+		}
+	}
+	return errors.New("user has no confirmed primary email")
+}
 
-		requestURL := teamURL.String() + "?role=member&access_token=" + s.AccessToken
+// getWorkspaces adds the slugs of the user's workspaces to the SessionState
+// Groups.
+func (p *BitbucketProvider) getWorkspaces(ctx context.Context, s *sessions.SessionState) error {
+	// https://developer.atlassian.com/cloud/bitbucket/rest/api-group-workspaces/#api-user-permissions-workspaces-get
+	var memberships struct {
+		Values []struct {
+			Workspace struct {
+				Slug string `json:"slug"`
+			} `json:"workspace"`
+		} `json:"values"`
+		Next string `json:"next"`
+	}
 
-		err := requests.New(requestURL).
+	next := p.makeBitbucketAPIEndpoint("/2.0/user/permissions/workspaces", url.Values{"pagelen": {"100"}})
+	for next != "" {
+		memberships.Next = ""
+		memberships.Values = nil
+
+		err := requests.New(next).
 			WithContext(ctx).
+			WithHeaders(makeOIDCHeader(s.AccessToken)).
 			Do().
-			UnmarshalInto(&teams)
+			UnmarshalInto(&memberships)
 		if err != nil {
-			logger.Errorf("failed requesting teams membership: %v", err)
-			return "", err
-		}
-		var found = false
-		for _, team := range teams.Values {
-			if p.Team == team.Name {
-				found = true
-				break
-			}
+			return fmt.Errorf("unable to fetch workspaces: %v", err)
 		}
-		if !found {
-			logger.Error("team membership test failed, access denied")
-			return "", nil
+
+		for _, membership := range memberships.Values {
+			logger.Printf("Member of Bitbucket Workspace:%q", membership.Workspace.Slug)
+			s.Groups = append(s.Groups, membership.Workspace.Slug)
 		}
+		next = memberships.Next
 	}
 
-	if p.Repository != "" {
-		repositoriesURL := &url.URL{}
-		*repositoriesURL = *p.ValidateURL
-		repositoriesURL.Path = "/2.0/repositories/" + strings.Split(p.Repository, "/")[0]
+	return nil
+}
 
-		requestURL := repositoriesURL.String() + "?role=contributor" +
-			"&q=full_name=" + url.QueryEscape("\""+p.Repository+"\"") +
-			"&access_token=" + s.AccessToken
+// checkRestrictions allows the user when they are a member of one of the
+// workspaces, when set, and have the minimum permission on the repository,
+// when set.
+func (p *BitbucketProvider) checkRestrictions(ctx context.Context, s *sessions.SessionState) error {
+	if len(p.Workspaces) > 0 && !p.isWorkspaceMember(s) {
+		return fmt.Errorf("%w: not a member of the allowed workspaces", ErrNotAuthorized)
+	}
 
-		err := requests.New(requestURL).
-			WithContext(ctx).
-			Do().
-			UnmarshalInto(&repositories)
+	if p.Repository != "" {
+		ok, err := p.hasRepositoryPermission(ctx, s.AccessToken)
 		if err != nil {
-			logger.Errorf("failed checking repository access: %v", err)
-			return "", err
+			return err
 		}
+		if !ok {
+			return fmt.Errorf("%w: no %s permission on repository %q", ErrNotAuthorized, p.RepositoryPermission, p.Repository)
+		}
+		logger.Printf("Found Bitbucket Repository:%q with permission %q", p.Repository, p.RepositoryPermission)
+	}
 
-		var found = false
-		for _, repository := range repositories.Values {
-			if p.Repository == repository.FullName {
-				found = true
-				break
+	return nil
+}
+
+func (p *BitbucketProvider) isWorkspaceMember(s *sessions.SessionState) bool {
+	for _, group := range s.Groups {
+		for _, workspace := range p.Workspaces {
+			if strings.EqualFold(group, workspace) {
+				logger.Printf("Found Bitbucket Workspace:%q", workspace)
+				return true
 			}
 		}
-		if !found {
-			logger.Error("repository access test failed, access denied")
-			return "", nil
-		}
 	}
+	return false
+}
 
-	for _, email := range emails.Values {
-		if email.Primary {
-			return email.Email, nil
-		}
+// hasRepositoryPermission checks the user's permission on the Repository.
+// Repositories the user has no access to are not listed by Bitbucket.
+func (p *BitbucketProvider) hasRepositoryPermission(ctx context.Context, accessToken string) (bool, error) {
+	// https://developer.atlassian.com/cloud/bitbucket/rest/api-group-repositories/#api-user-permissions-repositories-get
+	var permissions struct {
+		Values []struct {
+			Permission string `json:"permission"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+		} `json:"values"`
+	}
+
+	params := url.Values{"q": {fmt.Sprintf("repository.full_name=%q", p.Repository)}}
+	err := requests.New(p.makeBitbucketAPIEndpoint("/2.0/user/permissions/repositories", params)).
+		WithContext(ctx).
+		WithHeaders(makeOIDCHeader(accessToken)).
+		Do().
+		UnmarshalInto(&permissions)
+	if err != nil {
+		return false, fmt.Errorf("unable to fetch permission on repository %q: %v", p.Repository, err)
 	}
 
-	return "", nil
+	for _, permission := range permissions.Values {
+		if !strings.EqualFold(permission.Repository.FullName, p.Repository) {
+			continue
+		}
+		if bitbucketPermissionLevels[permission.Permission] >= bitbucketPermissionLevels[p.RepositoryPermission] {
+			return true, nil
+		}
+	}
+	return false, nil
 }
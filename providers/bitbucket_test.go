@@ -14,8 +14,8 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func testBitbucketProvider(hostname, team string, repository string) *BitbucketProvider {
-	p := NewBitbucketProvider(
+func testBitbucketProvider(hostname string, opts options.BitbucketOptions) *BitbucketProvider {
+	p, err := NewBitbucketProvider(
 		&ProviderData{
 			ProviderName: "",
 			LoginURL:     &url.URL{},
@@ -23,11 +23,11 @@ func testBitbucketProvider(hostname, team string, repository string) *BitbucketP
 			ProfileURL:   &url.URL{},
 			ValidateURL:  &url.URL{},
 			Scope:        ""},
-		options.BitbucketOptions{
-			Team:       team,
-			Repository: repository,
-		},
+		opts,
 	)
+	if err != nil {
+		panic(err)
+	}
 
 	if hostname != "" {
 		updateURL(p.Data().LoginURL, hostname)
@@ -38,23 +38,52 @@ func testBitbucketProvider(hostname, team string, repository string) *BitbucketP
 	return p
 }
 
-func testBitbucketBackend(payload string) *httptest.Server {
-	paths := map[string]bool{
-		"/2.0/user/emails": true,
-		"/2.0/teams":       true,
+// testBitbucketBackend serves the Bitbucket API 2.0 endpoints used by the
+// provider. The emails payload can be overridden.
+func testBitbucketBackend(t *testing.T, emails string) *httptest.Server {
+	if emails == "" {
+		emails = `{"values": [{"email": "mbland@example.com", "is_primary": false, "is_confirmed": true}, {"email": "michael.bland@gsa.gov", "is_primary": true, "is_confirmed": true}]}`
 	}
 
 	return httptest.NewServer(http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			url := r.URL
-			if !paths[url.Path] {
-				log.Printf("%s not in %+v\n", url.Path, paths)
-				w.WriteHeader(404)
-			} else if !IsAuthorizedInURL(r.URL) {
+			if r.URL.Path == "/site/oauth2/access_token" {
+				assert.NoError(t, r.ParseForm())
+				if r.PostForm.Get("grant_type") == "refresh_token" {
+					assert.Equal(t, "bitbucket_refresh_token", r.PostForm.Get("refresh_token"))
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token":"` + authorizedAccessToken + `","token_type":"bearer","expires_in":7200,"refresh_token":"bitbucket_refresh_token_2"}`))
+				return
+			}
+
+			if !IsAuthorizedInHeader(r.Header) {
 				w.WriteHeader(403)
-			} else {
-				w.WriteHeader(200)
-				w.Write([]byte(payload))
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/2.0/user":
+				w.Write([]byte(`{"username": "mbland", "display_name": "Michael Bland"}`))
+			case "/2.0/user/emails":
+				w.Write([]byte(emails))
+			case "/2.0/user/permissions/workspaces":
+				if r.URL.Query().Get("page") == "" {
+					w.Write([]byte(`{"values": [{"permission": "member", "workspace": {"slug": "bioinformatics"}}], "next": "http://` + r.Host + `/2.0/user/permissions/workspaces?page=2"}`))
+					return
+				}
+				w.Write([]byte(`{"values": [{"permission": "owner", "workspace": {"slug": "oauth2-proxy"}}]}`))
+			case "/2.0/user/permissions/repositories":
+				switch r.URL.Query().Get("q") {
+				case `repository.full_name="oauth2-proxy/oauth2-proxy"`:
+					w.Write([]byte(`{"values": [{"permission": "write", "repository": {"full_name": "oauth2-proxy/oauth2-proxy"}}]}`))
+				default:
+					w.Write([]byte(`{"values": []}`))
+				}
+			default:
+				log.Printf("%s not found\n", r.URL.Path)
+				w.WriteHeader(404)
 			}
 		}))
 }
@@ -63,29 +92,40 @@ func TestNewBitbucketProvider(t *testing.T) {
 	g := NewWithT(t)
 
 	// Test that defaults are set when calling for a new provider with nothing set
-	providerData := NewBitbucketProvider(&ProviderData{}, options.BitbucketOptions{}).Data()
+	p, err := NewBitbucketProvider(&ProviderData{}, options.BitbucketOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	providerData := p.Data()
 	g.Expect(providerData.ProviderName).To(Equal("Bitbucket"))
 	g.Expect(providerData.LoginURL.String()).To(Equal("https://bitbucket.org/site/oauth2/authorize"))
 	g.Expect(providerData.RedeemURL.String()).To(Equal("https://bitbucket.org/site/oauth2/access_token"))
-	g.Expect(providerData.ProfileURL.String()).To(Equal(""))
+	g.Expect(providerData.ProfileURL.String()).To(Equal("https://api.bitbucket.org/2.0/user"))
 	g.Expect(providerData.ValidateURL.String()).To(Equal("https://api.bitbucket.org/2.0/user/emails"))
-	g.Expect(providerData.Scope).To(Equal("email"))
+	g.Expect(providerData.Scope).To(Equal("account email"))
+	g.Expect(p.RepositoryPermission).To(Equal("read"))
 }
 
-func TestBitbucketProviderScopeAdjustForTeam(t *testing.T) {
-	p := testBitbucketProvider("", "test-team", "")
-	assert.NotEqual(t, nil, p)
-	assert.Equal(t, "email team", p.Data().Scope)
+func TestNewBitbucketProviderInvalidOptions(t *testing.T) {
+	_, err := NewBitbucketProvider(&ProviderData{}, options.BitbucketOptions{RepositoryPermission: "owner"})
+	assert.EqualError(t, err, `invalid repository permission "owner": must be one of read, write or admin`)
+
+	_, err = NewBitbucketProvider(&ProviderData{}, options.BitbucketOptions{Repository: "oauth2-proxy"})
+	assert.EqualError(t, err, `invalid repository "oauth2-proxy": repositories must be given as workspace/repository`)
+}
+
+func TestBitbucketProviderTeamIsWorkspace(t *testing.T) {
+	p := testBitbucketProvider("", options.BitbucketOptions{Team: "test-team", Workspaces: []string{"test-workspace"}})
+	assert.Equal(t, []string{"test-team", "test-workspace"}, p.Workspaces)
+	assert.Equal(t, "account email", p.Data().Scope)
 }
 
 func TestBitbucketProviderScopeAdjustForRepository(t *testing.T) {
-	p := testBitbucketProvider("", "", "rest-repo")
+	p := testBitbucketProvider("", options.BitbucketOptions{Repository: "test-workspace/rest-repo"})
 	assert.NotEqual(t, nil, p)
-	assert.Equal(t, "email repository", p.Data().Scope)
+	assert.Equal(t, "account email repository", p.Data().Scope)
 }
 
 func TestBitbucketProviderOverrides(t *testing.T) {
-	p := NewBitbucketProvider(
+	p, err := NewBitbucketProvider(
 		&ProviderData{
 			LoginURL: &url.URL{
 				Scheme: "https",
@@ -101,7 +141,7 @@ func TestBitbucketProviderOverrides(t *testing.T) {
 				Path:   "/api/v3/user"},
 			Scope: "profile"},
 		options.BitbucketOptions{})
-	assert.NotEqual(t, nil, p)
+	assert.NoError(t, err)
 	assert.Equal(t, "Bitbucket", p.Data().ProviderName)
 	assert.Equal(t, "https://example.com/oauth/auth",
 		p.Data().LoginURL.String())
@@ -112,59 +152,145 @@ func TestBitbucketProviderOverrides(t *testing.T) {
 	assert.Equal(t, "profile", p.Data().Scope)
 }
 
-func TestBitbucketProviderGetEmailAddress(t *testing.T) {
-	b := testBitbucketBackend("{\"values\": [ { \"email\": \"michael.bland@gsa.gov\", \"is_primary\": true } ] }")
+func TestBitbucketProviderEnrichSession(t *testing.T) {
+	b := testBitbucketBackend(t, "")
 	defer b.Close()
 
 	bURL, _ := url.Parse(b.URL)
-	p := testBitbucketProvider(bURL.Host, "", "")
+	p := testBitbucketProvider(bURL.Host, options.BitbucketOptions{})
 
 	session := CreateAuthorizedSession()
-	email, err := p.GetEmailAddress(context.Background(), session)
-	assert.Equal(t, nil, err)
-	assert.Equal(t, "michael.bland@gsa.gov", email)
+	err := p.EnrichSession(context.Background(), session)
+	assert.NoError(t, err)
+	assert.Equal(t, "mbland", session.User)
+	assert.Equal(t, "Michael Bland", session.PreferredUsername)
+	assert.Equal(t, "michael.bland@gsa.gov", session.Email)
+	assert.Equal(t, []string{"bioinformatics", "oauth2-proxy"}, session.Groups)
 }
 
-func TestBitbucketProviderGetEmailAddressAndGroup(t *testing.T) {
-	b := testBitbucketBackend("{\"values\": [ { \"email\": \"michael.bland@gsa.gov\", \"is_primary\": true, \"username\": \"bioinformatics\" } ] }")
+func TestBitbucketProviderEnrichSessionRestrictions(t *testing.T) {
+	b := testBitbucketBackend(t, "")
 	defer b.Close()
-
 	bURL, _ := url.Parse(b.URL)
-	p := testBitbucketProvider(bURL.Host, "bioinformatics", "")
 
-	session := CreateAuthorizedSession()
-	email, err := p.GetEmailAddress(context.Background(), session)
-	assert.Equal(t, nil, err)
-	assert.Equal(t, "michael.bland@gsa.gov", email)
+	testCases := map[string]struct {
+		opts          options.BitbucketOptions
+		expectedError string
+	}{
+		"member of an allowed workspace": {
+			opts: options.BitbucketOptions{Workspaces: []string{"other", "oauth2-proxy"}},
+		},
+		"member of the deprecated team": {
+			opts: options.BitbucketOptions{Team: "bioinformatics"},
+		},
+		"not a member of an allowed workspace": {
+			opts:          options.BitbucketOptions{Workspaces: []string{"other"}},
+			expectedError: "user is not authorized: not a member of the allowed workspaces",
+		},
+		"with the repository permission": {
+			opts: options.BitbucketOptions{Repository: "oauth2-proxy/oauth2-proxy", RepositoryPermission: "write"},
+		},
+		"with less than the repository permission": {
+			opts:          options.BitbucketOptions{Repository: "oauth2-proxy/oauth2-proxy", RepositoryPermission: "admin"},
+			expectedError: `user is not authorized: no admin permission on repository "oauth2-proxy/oauth2-proxy"`,
+		},
+		"without access to the repository": {
+			opts:          options.BitbucketOptions{Repository: "oauth2-proxy/private"},
+			expectedError: `user is not authorized: no read permission on repository "oauth2-proxy/private"`,
+		},
+		"member of a workspace without the repository permission": {
+			opts:          options.BitbucketOptions{Workspaces: []string{"oauth2-proxy"}, Repository: "oauth2-proxy/private"},
+			expectedError: `user is not authorized: no read permission on repository "oauth2-proxy/private"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p := testBitbucketProvider(bURL.Host, tc.opts)
+
+			err := p.EnrichSession(context.Background(), CreateAuthorizedSession())
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
 }
 
 // Note that trying to trigger the "failed building request" case is not
 // practical, since the only way it can fail is if the URL fails to parse.
-func TestBitbucketProviderGetEmailAddressFailedRequest(t *testing.T) {
-	b := testBitbucketBackend("unused payload")
+func TestBitbucketProviderEnrichSessionFailedRequest(t *testing.T) {
+	b := testBitbucketBackend(t, "")
 	defer b.Close()
 
 	bURL, _ := url.Parse(b.URL)
-	p := testBitbucketProvider(bURL.Host, "", "")
+	p := testBitbucketProvider(bURL.Host, options.BitbucketOptions{})
 
 	// We'll trigger a request failure by using an unexpected access
 	// token. Alternatively, we could allow the parsing of the payload as
 	// JSON to fail.
 	session := &sessions.SessionState{AccessToken: "unexpected_access_token"}
-	email, err := p.GetEmailAddress(context.Background(), session)
-	assert.NotEqual(t, nil, err)
-	assert.Equal(t, "", email)
+	err := p.EnrichSession(context.Background(), session)
+	assert.Error(t, err)
+	assert.Equal(t, "", session.Email)
 }
 
-func TestBitbucketProviderGetEmailAddressEmailNotPresentInPayload(t *testing.T) {
-	b := testBitbucketBackend("{\"foo\": \"bar\"}")
+func TestBitbucketProviderEnrichSessionEmailNotPresentInPayload(t *testing.T) {
+	b := testBitbucketBackend(t, `{"foo": "bar"}`)
 	defer b.Close()
 
 	bURL, _ := url.Parse(b.URL)
-	p := testBitbucketProvider(bURL.Host, "", "")
+	p := testBitbucketProvider(bURL.Host, options.BitbucketOptions{})
 
 	session := CreateAuthorizedSession()
-	email, err := p.GetEmailAddress(context.Background(), session)
-	assert.Equal(t, "", email)
-	assert.Equal(t, nil, err)
+	err := p.EnrichSession(context.Background(), session)
+	assert.EqualError(t, err, "user has no confirmed primary email")
+	assert.Equal(t, "", session.Email)
+}
+
+func TestBitbucketProviderRedeem(t *testing.T) {
+	b := testBitbucketBackend(t, "")
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testBitbucketProvider(bURL.Host, options.BitbucketOptions{})
+
+	s, err := p.Redeem(context.Background(), "https://proxy.example.com/oauth2/callback", "bitbucket_code", "")
+	assert.NoError(t, err)
+	assert.Equal(t, authorizedAccessToken, s.AccessToken)
+	assert.Equal(t, "bitbucket_refresh_token_2", s.RefreshToken)
+	assert.NotNil(t, s.ExpiresOn)
+}
+
+func TestBitbucketProviderRefreshSession(t *testing.T) {
+	b := testBitbucketBackend(t, "")
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testBitbucketProvider(bURL.Host, options.BitbucketOptions{Workspaces: []string{"oauth2-proxy"}})
+
+	refreshed, err := p.RefreshSession(context.Background(), &sessions.SessionState{})
+	assert.NoError(t, err)
+	assert.False(t, refreshed)
+
+	s := &sessions.SessionState{
+		User:         "mbland",
+		AccessToken:  "expired",
+		RefreshToken: "bitbucket_refresh_token",
+		Groups:       []string{"oauth2-proxy"},
+	}
+	refreshed, err = p.RefreshSession(context.Background(), s)
+	assert.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.Equal(t, authorizedAccessToken, s.AccessToken)
+	assert.Equal(t, "bitbucket_refresh_token_2", s.RefreshToken)
+	assert.Equal(t, []string{"bioinformatics", "oauth2-proxy"}, s.Groups)
+
+	// The user has left the workspace since the session was created
+	p.Workspaces = []string{"other"}
+	s.RefreshToken = "bitbucket_refresh_token"
+	refreshed, err = p.RefreshSession(context.Background(), s)
+	assert.EqualError(t, err, "user is not authorized: not a member of the allowed workspaces")
+	assert.False(t, refreshed)
 }
@@ -0,0 +1,313 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/assertion"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// clientAssertionType is the type of the JWT client assertions (RFC 7523)
+	clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	// clientAssertionLifetime is how long a client assertion is valid for. A
+	// new assertion is signed for each token endpoint request.
+	clientAssertionLifetime = 5 * time.Minute
+)
+
+// setClientAuthentication configures how the client authenticates to the
+// token endpoint, loading the client assertion key of the private_key_jwt
+// method.
+func (p *ProviderData) setClientAuthentication(providerConfig options.Provider) error {
+	p.TokenEndpointAuthMethod = providerConfig.TokenEndpointAuthMethod
+	p.ClientAssertionKeyID = providerConfig.ClientAssertionKeyID
+	p.issuerURL = providerConfig.OIDCConfig.IssuerURL
+	if p.TokenEndpointAuthMethod != options.PrivateKeyJWT {
+		return nil
+	}
+
+	if providerConfig.ClientAssertionKey == nil {
+		return errors.New("the private_key_jwt token endpoint auth method requires a client assertion key")
+	}
+	keyData, err := util.GetSecretValue(providerConfig.ClientAssertionKey)
+	if err != nil {
+		return fmt.Errorf("could not load client assertion key: %v", err)
+	}
+	key, err := assertion.ParsePrivateKey(keyData)
+	if err != nil {
+		return fmt.Errorf("could not parse client assertion key: %v", err)
+	}
+	method, err := assertion.SigningMethod(key.Public())
+	if err != nil {
+		return fmt.Errorf("invalid client assertion key: %v", err)
+	}
+
+	p.clientAssertionKey = key
+	p.clientAssertionMethod = method
+	return nil
+}
+
+// newTokenRequest returns a POST request of the parameters to the token
+// endpoint, authenticating the client with the TokenEndpointAuthMethod.
+// By default, the client secret is sent in the parameters.
+func (p *ProviderData) newTokenRequest(ctx context.Context, params url.Values) (requests.Builder, error) {
+	return p.newClientAuthenticatedRequest(ctx, p.RedeemURL.String(), params)
+}
+
+// newClientAuthenticatedRequest returns a POST request of the parameters to
+// an endpoint of the authorization server that authenticates the client the
+// same way as the token endpoint.
+func (p *ProviderData) newClientAuthenticatedRequest(ctx context.Context, endpoint string, params url.Values) (requests.Builder, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	switch p.TokenEndpointAuthMethod {
+	case options.ClientSecretBasic:
+		clientSecret, err := p.GetClientSecret()
+		if err != nil {
+			return nil, err
+		}
+		params.Del("client_id")
+		header.Set("Authorization", basicClientAuthorization(p.ClientID, clientSecret))
+	case options.ClientSecretJWT, options.PrivateKeyJWT:
+		if err := p.addClientAssertion(params, p.clientAssertionAudience(endpoint)); err != nil {
+			return nil, err
+		}
+	default:
+		clientSecret, err := p.GetClientSecret()
+		if err != nil {
+			return nil, err
+		}
+		params.Set("client_id", p.ClientID)
+		params.Set("client_secret", clientSecret)
+	}
+
+	return requests.New(endpoint).
+		WithContext(ctx).
+		WithMethod("POST").
+		WithBody(bytes.NewBufferString(params.Encode())).
+		WithHeaders(header), nil
+}
+
+// newOAuth2Config returns the oauth2.Config of the token endpoint, and the
+// context its token requests must be made with to authenticate the client
+// with the TokenEndpointAuthMethod.
+// By default, the oauth2 package detects how to send the client secret.
+func (p *ProviderData) newOAuth2Config(ctx context.Context, redirectURL string) (context.Context, *oauth2.Config, error) {
+	c := &oauth2.Config{
+		ClientID: p.ClientID,
+		Endpoint: oauth2.Endpoint{
+			TokenURL: p.RedeemURL.String(),
+		},
+		RedirectURL: redirectURL,
+	}
+
+	switch p.TokenEndpointAuthMethod {
+	case options.ClientSecretJWT, options.PrivateKeyJWT:
+		// The oauth2 package cannot send a client assertion, it is added to
+		// the body of the token requests by the transport of the client.
+		c.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+		client := &http.Client{Transport: &clientAssertionTransport{
+			provider: p,
+			next:     requests.DefaultHTTPClient.Transport,
+		}}
+		return oidc.ClientContext(ctx, client), c, nil
+	case options.ClientSecretBasic:
+		c.Endpoint.AuthStyle = oauth2.AuthStyleInHeader
+	case options.ClientSecretPost:
+		c.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+
+	clientSecret, err := p.GetClientSecret()
+	if err != nil {
+		return nil, nil, err
+	}
+	c.ClientSecret = clientSecret
+	return oidc.ClientContext(ctx, requests.DefaultHTTPClient), c, nil
+}
+
+// redeemOAuth2Code exchanges the OAuth2 authentication code for an access
+// token with the oauth2.Config of the token endpoint, keeping the refresh
+// token and expiry of the response.
+func (p *ProviderData) redeemOAuth2Code(ctx context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
+	if code == "" {
+		return nil, ErrMissingCode
+	}
+	var opts []oauth2.AuthCodeOption
+	if codeVerifier != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
+	}
+
+	ctx, c, err := p.newOAuth2Config(ctx, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.Exchange(ctx, code, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %v", err)
+	}
+
+	s := &sessions.SessionState{}
+	updateOAuth2SessionTokens(s, token)
+	return s, nil
+}
+
+// redeemOAuth2RefreshToken redeems the refresh token of the session for new
+// tokens with the oauth2.Config of the token endpoint.
+func (p *ProviderData) redeemOAuth2RefreshToken(ctx context.Context, s *sessions.SessionState) error {
+	ctx, c, err := p.newOAuth2Config(ctx, "")
+	if err != nil {
+		return err
+	}
+	t := &oauth2.Token{
+		RefreshToken: s.RefreshToken,
+		Expiry:       time.Now().Add(-time.Hour),
+	}
+
+	token, err := c.TokenSource(ctx, t).Token()
+	if err != nil {
+		return fmt.Errorf("failed to get token: %v", err)
+	}
+
+	updateOAuth2SessionTokens(s, token)
+	return nil
+}
+
+// updateOAuth2SessionTokens sets the tokens of the session, keeping the
+// previous refresh token when the response has none.
+func updateOAuth2SessionTokens(s *sessions.SessionState, token *oauth2.Token) {
+	s.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		s.RefreshToken = token.RefreshToken
+	}
+	s.CreatedAtNow()
+	if !token.Expiry.IsZero() {
+		s.SetExpiresOn(token.Expiry)
+	}
+}
+
+// addClientAssertion adds a client assertion (RFC 7523) for the audience,
+// signed with the client secret or the client assertion key, to the
+// parameters of a request to the authorization server.
+func (p *ProviderData) addClientAssertion(params url.Values, audience string) error {
+	clientAssertion, err := p.signClientAssertion(audience)
+	if err != nil {
+		return fmt.Errorf("could not sign client assertion: %v", err)
+	}
+
+	params.Del("client_secret")
+	params.Set("client_id", p.ClientID)
+	params.Set("client_assertion_type", clientAssertionType)
+	params.Set("client_assertion", clientAssertion)
+	return nil
+}
+
+// clientAssertionAudience returns the audience of the client assertions sent
+// to an endpoint of the authorization server. The pushed authorization request
+// endpoint is sent assertions for the issuer when it is known, as recommended
+// by RFC 9126, the token endpoint is sent assertions for its own URL.
+func (p *ProviderData) clientAssertionAudience(endpoint string) string {
+	if p.issuerURL != "" && p.PushedAuthorizationRequestURL != nil && endpoint == p.PushedAuthorizationRequestURL.String() {
+		return p.issuerURL
+	}
+	return p.RedeemURL.String()
+}
+
+// signClientAssertion signs a client assertion for the audience, with the
+// client secret for client_secret_jwt and with the client assertion key for
+// private_key_jwt.
+func (p *ProviderData) signClientAssertion(audience string) (string, error) {
+	now := time.Now()
+	claims := &jwt.RegisteredClaims{
+		Issuer:    p.ClientID,
+		Subject:   p.ClientID,
+		Audience:  jwt.ClaimStrings{audience},
+		ID:        uuid.New().String(),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(clientAssertionLifetime)),
+	}
+
+	var (
+		method jwt.SigningMethod
+		key    interface{}
+	)
+	switch p.TokenEndpointAuthMethod {
+	case options.ClientSecretJWT:
+		clientSecret, err := p.GetClientSecret()
+		if err != nil {
+			return "", err
+		}
+		method, key = jwt.SigningMethodHS256, []byte(clientSecret)
+	case options.PrivateKeyJWT:
+		if p.clientAssertionKey == nil {
+			return "", errors.New("no client assertion key")
+		}
+		method, key = p.clientAssertionMethod, p.clientAssertionKey
+	default:
+		return "", fmt.Errorf("token endpoint auth method %q does not use a client assertion", p.TokenEndpointAuthMethod)
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	if p.ClientAssertionKeyID != "" {
+		token.Header["kid"] = p.ClientAssertionKeyID
+	}
+	return token.SignedString(key)
+}
+
+// basicClientAuthorization returns the Authorization header of the
+// client_secret_basic method. The client ID and secret are form encoded
+// first, as required by RFC 6749, section 2.3.1.
+func basicClientAuthorization(clientID, clientSecret string) string {
+	credentials := url.QueryEscape(clientID) + ":" + url.QueryEscape(clientSecret)
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+}
+
+// clientAssertionTransport adds a client assertion to the body of the token
+// requests made by the oauth2 package.
+type clientAssertionTransport struct {
+	provider *ProviderData
+	next     http.RoundTripper
+}
+
+func (t *clientAssertionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Method != http.MethodPost || req.URL.String() != t.provider.RedeemURL.String() {
+		return t.next.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	params, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse token request: %v", err)
+	}
+	if err := t.provider.addClientAssertion(params, t.provider.RedeemURL.String()); err != nil {
+		return nil, err
+	}
+
+	encoded := params.Encode()
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(strings.NewReader(encoded))
+	req.ContentLength = int64(len(encoded))
+	req.GetBody = nil
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,166 @@
+package providers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/gomega"
+)
+
+func TestProviderDataTokenEndpointAuth(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	testCases := []struct {
+		name     string
+		config   options.Provider
+		validate func(g *WithT, r *http.Request, tokenURL string)
+	}{
+		{
+			name:   "Default",
+			config: options.Provider{},
+			validate: func(g *WithT, r *http.Request, _ string) {
+				// The oauth2 package tries HTTP Basic authentication first
+				if clientID, clientSecret, ok := r.BasicAuth(); ok {
+					g.Expect(clientID).To(Equal("client"))
+					g.Expect(clientSecret).To(Equal("secret"))
+					return
+				}
+				g.Expect(r.PostForm.Get("client_id")).To(Equal("client"))
+				g.Expect(r.PostForm.Get("client_secret")).To(Equal("secret"))
+			},
+		},
+		{
+			name:   "ClientSecretPost",
+			config: options.Provider{TokenEndpointAuthMethod: options.ClientSecretPost},
+			validate: func(g *WithT, r *http.Request, _ string) {
+				g.Expect(r.Header.Get("Authorization")).To(BeEmpty())
+				g.Expect(r.PostForm.Get("client_id")).To(Equal("client"))
+				g.Expect(r.PostForm.Get("client_secret")).To(Equal("secret"))
+			},
+		},
+		{
+			name:   "ClientSecretBasic",
+			config: options.Provider{TokenEndpointAuthMethod: options.ClientSecretBasic},
+			validate: func(g *WithT, r *http.Request, _ string) {
+				clientID, clientSecret, ok := r.BasicAuth()
+				g.Expect(ok).To(BeTrue())
+				g.Expect(clientID).To(Equal("client"))
+				g.Expect(clientSecret).To(Equal("secret"))
+				g.Expect(r.PostForm).ToNot(HaveKey("client_secret"))
+			},
+		},
+		{
+			name:   "ClientSecretJWT",
+			config: options.Provider{TokenEndpointAuthMethod: options.ClientSecretJWT},
+			validate: func(g *WithT, r *http.Request, tokenURL string) {
+				g.Expect(r.PostForm).ToNot(HaveKey("client_secret"))
+				g.Expect(r.PostForm.Get("client_id")).To(Equal("client"))
+				g.Expect(r.PostForm.Get("client_assertion_type")).To(Equal(clientAssertionType))
+
+				claims := &jwt.RegisteredClaims{}
+				_, err := jwt.ParseWithClaims(r.PostForm.Get("client_assertion"), claims, func(token *jwt.Token) (interface{}, error) {
+					return []byte("secret"), nil
+				}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithAudience(tokenURL), jwt.WithIssuer("client"), jwt.WithSubject("client"))
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(claims.ID).ToNot(BeEmpty())
+			},
+		},
+		{
+			name: "PrivateKeyJWT",
+			config: options.Provider{
+				TokenEndpointAuthMethod: options.PrivateKeyJWT,
+				ClientAssertionKey:      &options.SecretSource{Value: keyPEM},
+				ClientAssertionKeyID:    "key-1",
+			},
+			validate: func(g *WithT, r *http.Request, tokenURL string) {
+				g.Expect(r.PostForm).ToNot(HaveKey("client_secret"))
+				g.Expect(r.PostForm.Get("client_assertion_type")).To(Equal(clientAssertionType))
+
+				token, err := jwt.ParseWithClaims(r.PostForm.Get("client_assertion"), &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+					return key.Public(), nil
+				}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience(tokenURL), jwt.WithIssuer("client"), jwt.WithSubject("client"))
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(token.Header["kid"]).To(Equal("key-1"))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var requests []*http.Request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				g.Expect(r.ParseForm()).To(Succeed())
+				requests = append(requests, r)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token":"access","token_type":"Bearer","expires_in":300}`))
+			}))
+			defer server.Close()
+			tokenURL := server.URL + "/token"
+
+			config := tc.config
+			config.Type = options.KeycloakProvider
+			config.ClientID = "client"
+			config.ClientSecret = "secret"
+			config.RedeemURL = tokenURL
+			p, err := newProviderDataFromConfig(config)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			// Requests made with the requests package
+			req, err := p.newTokenRequest(context.Background(), url.Values{"grant_type": {"refresh_token"}})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(req.Do().Error()).ToNot(HaveOccurred())
+
+			// Requests made with the oauth2 package
+			ctx, c, err := p.newOAuth2Config(context.Background(), "https://proxy.example.com/oauth2/callback")
+			g.Expect(err).ToNot(HaveOccurred())
+			token, err := c.Exchange(ctx, "code")
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(token.AccessToken).To(Equal("access"))
+
+			g.Expect(requests).To(HaveLen(2))
+			g.Expect(requests[1].PostForm.Get("code")).To(Equal("code"))
+			for _, r := range requests {
+				tc.validate(g, r, tokenURL)
+			}
+		})
+	}
+}
+
+func TestProviderDataTokenEndpointAuthWithoutKey(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := newProviderDataFromConfig(options.Provider{
+		Type:                    options.KeycloakProvider,
+		ClientID:                "client",
+		TokenEndpointAuthMethod: options.PrivateKeyJWT,
+	})
+	g.Expect(err).To(MatchError(ContainSubstring("requires a client assertion key")))
+
+	_, err = newProviderDataFromConfig(options.Provider{
+		Type:                    options.KeycloakProvider,
+		ClientID:                "client",
+		TokenEndpointAuthMethod: options.PrivateKeyJWT,
+		ClientAssertionKey:      &options.SecretSource{Value: []byte("not a key")},
+	})
+	g.Expect(err).To(MatchError(ContainSubstring("could not parse client assertion key")))
+}
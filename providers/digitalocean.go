@@ -3,15 +3,20 @@ package providers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/url"
+	"strings"
 
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 )
 
 // DigitalOceanProvider represents a DigitalOcean based Identity Provider
 type DigitalOceanProvider struct {
 	*ProviderData
+	Teams []string
 }
 
 var _ Provider = (*DigitalOceanProvider)(nil)
@@ -19,6 +24,10 @@ var _ Provider = (*DigitalOceanProvider)(nil)
 const (
 	digitalOceanProviderName = "DigitalOcean"
 	digitalOceanDefaultScope = "read"
+
+	// digitalOceanTeamNamePrefix namespaces the team name in the groups of
+	// the session
+	digitalOceanTeamNamePrefix = "name:"
 )
 
 var (
@@ -48,7 +57,7 @@ var (
 )
 
 // NewDigitalOceanProvider initiates a new DigitalOceanProvider
-func NewDigitalOceanProvider(p *ProviderData) *DigitalOceanProvider {
+func NewDigitalOceanProvider(p *ProviderData, opts options.DigitalOceanOptions) *DigitalOceanProvider {
 	p.setProviderDefaults(providerDefaults{
 		name:        digitalOceanProviderName,
 		loginURL:    digitalOceanDefaultLoginURL,
@@ -59,32 +68,116 @@ func NewDigitalOceanProvider(p *ProviderData) *DigitalOceanProvider {
 	})
 	p.getAuthorizationHeaderFunc = makeOIDCHeader
 
-	return &DigitalOceanProvider{ProviderData: p}
+	return &DigitalOceanProvider{
+		ProviderData: p,
+		Teams:        opts.Teams,
+	}
+}
+
+// Redeem exchanges the OAuth2 authentication code for an access token,
+// keeping the refresh token and expiry of the response.
+func (p *DigitalOceanProvider) Redeem(ctx context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
+	return p.redeemOAuth2Code(ctx, redirectURL, code, codeVerifier)
 }
 
-// GetEmailAddress returns the Account email address
-func (p *DigitalOceanProvider) GetEmailAddress(ctx context.Context, s *sessions.SessionState) (string, error) {
-	if s.AccessToken == "" {
-		return "", errors.New("missing access token")
+// digitalOceanAccount is the account of a DigitalOcean access token.
+// https://docs.digitalocean.com/reference/api/api-reference/#operation/account_get
+type digitalOceanAccount struct {
+	Email string `json:"email"`
+	Team  struct {
+		UUID string `json:"uuid"`
+		Name string `json:"name"`
+	} `json:"team"`
+}
+
+// getAccount fetches the account of the access token. DigitalOcean access
+// tokens are issued for the team chosen by the user when authorizing the
+// application.
+func (p *DigitalOceanProvider) getAccount(ctx context.Context, accessToken string) (*digitalOceanAccount, error) {
+	if accessToken == "" {
+		return nil, errors.New("missing access token")
 	}
 
-	json, err := requests.New(p.ProfileURL.String()).
+	var response struct {
+		Account digitalOceanAccount `json:"account"`
+	}
+	err := requests.New(p.ProfileURL.String()).
 		WithContext(ctx).
-		WithHeaders(makeOIDCHeader(s.AccessToken)).
+		WithHeaders(makeOIDCHeader(accessToken)).
 		Do().
-		UnmarshalSimpleJSON()
+		UnmarshalInto(&response)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return &response.Account, nil
+}
 
-	email, err := json.GetPath("account", "email").String()
+// EnrichSession sets the email and team of the session and checks that the
+// team is allowed.
+func (p *DigitalOceanProvider) EnrichSession(ctx context.Context, s *sessions.SessionState) error {
+	account, err := p.getAccount(ctx, s.AccessToken)
 	if err != nil {
-		return "", err
+		return err
+	}
+	if account.Email == "" {
+		return errors.New("account has no email")
 	}
-	return email, nil
+
+	s.Email = account.Email
+	return p.setTeam(s, account)
+}
+
+// setTeam adds the UUID and name of the account's team to the SessionState
+// Groups, then checks that the team is one of the allowed Teams.
+// Team names are not unique, so only the UUID is matched against the allowed
+// Teams, and the name is added as `name:<team>` so that it can't be mistaken
+// for a UUID.
+func (p *DigitalOceanProvider) setTeam(s *sessions.SessionState, account *digitalOceanAccount) error {
+	if account.Team.UUID != "" {
+		logger.Printf("Member of DigitalOcean Team:%q (%s)", account.Team.Name, account.Team.UUID)
+		s.Groups = append(s.Groups, account.Team.UUID)
+		if account.Team.Name != "" {
+			s.Groups = append(s.Groups, digitalOceanTeamNamePrefix+account.Team.Name)
+		}
+	}
+
+	if len(p.Teams) == 0 {
+		return nil
+	}
+	for _, team := range p.Teams {
+		if account.Team.UUID != "" && strings.EqualFold(account.Team.UUID, team) {
+			logger.Printf("Found DigitalOcean Team:%q", team)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: not a member of the allowed teams", ErrNotAuthorized)
 }
 
 // ValidateSession validates the AccessToken
 func (p *DigitalOceanProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
 	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
 }
+
+// RefreshSession uses the RefreshToken to fetch a new access token, then
+// reloads the team of the account and checks again that it is allowed.
+func (p *DigitalOceanProvider) RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	if s == nil || s.RefreshToken == "" {
+		return false, nil
+	}
+
+	if err := p.redeemOAuth2RefreshToken(ctx, s); err != nil {
+		return false, fmt.Errorf("unable to redeem refresh token: %w", err)
+	}
+
+	account, err := p.getAccount(ctx, s.AccessToken)
+	if err != nil {
+		return false, fmt.Errorf("unable to fetch account: %w", err)
+	}
+
+	s.Groups = nil
+	if err := p.setTeam(s, account); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -7,12 +7,13 @@ import (
 	"net/url"
 	"testing"
 
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/assert"
 )
 
-func testDigitalOceanProvider(hostname string) *DigitalOceanProvider {
+func testDigitalOceanProvider(hostname string, teams ...string) *DigitalOceanProvider {
 	p := NewDigitalOceanProvider(
 		&ProviderData{
 			ProviderName: "",
@@ -20,7 +21,8 @@ func testDigitalOceanProvider(hostname string) *DigitalOceanProvider {
 			RedeemURL:    &url.URL{},
 			ProfileURL:   &url.URL{},
 			ValidateURL:  &url.URL{},
-			Scope:        ""})
+			Scope:        ""},
+		options.DigitalOceanOptions{Teams: teams})
 	if hostname != "" {
 		updateURL(p.Data().LoginURL, hostname)
 		updateURL(p.Data().RedeemURL, hostname)
@@ -34,7 +36,14 @@ func testDigitalOceanBackend(payload string) *httptest.Server {
 
 	return httptest.NewServer(http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path != path {
+			if r.URL.Path == "/v1/oauth/token" {
+				if r.FormValue("grant_type") == "refresh_token" && r.FormValue("refresh_token") != "digitalocean_refresh_token" {
+					w.WriteHeader(400)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token":"` + authorizedAccessToken + `","token_type":"bearer","expires_in":2592000,"refresh_token":"digitalocean_refresh_token_2"}`))
+			} else if r.URL.Path != path {
 				w.WriteHeader(404)
 			} else if !IsAuthorizedInHeader(r.Header) {
 				w.WriteHeader(403)
@@ -49,7 +58,7 @@ func TestNewDigitalOceanProvider(t *testing.T) {
 	g := NewWithT(t)
 
 	// Test that defaults are set when calling for a new provider with nothing set
-	providerData := NewDigitalOceanProvider(&ProviderData{}).Data()
+	providerData := NewDigitalOceanProvider(&ProviderData{}, options.DigitalOceanOptions{}).Data()
 	g.Expect(providerData.ProviderName).To(Equal("DigitalOcean"))
 	g.Expect(providerData.LoginURL.String()).To(Equal("https://cloud.digitalocean.com/v1/oauth/authorize"))
 	g.Expect(providerData.RedeemURL.String()).To(Equal("https://cloud.digitalocean.com/v1/oauth/token"))
@@ -77,7 +86,8 @@ func TestDigitalOceanProviderOverrides(t *testing.T) {
 				Scheme: "https",
 				Host:   "example.com",
 				Path:   "/oauth/tokeninfo"},
-			Scope: "profile"})
+			Scope: "profile"},
+		options.DigitalOceanOptions{})
 	assert.NotEqual(t, nil, p)
 	assert.Equal(t, "DigitalOcean", p.Data().ProviderName)
 	assert.Equal(t, "https://example.com/oauth/auth",
@@ -91,7 +101,9 @@ func TestDigitalOceanProviderOverrides(t *testing.T) {
 	assert.Equal(t, "profile", p.Data().Scope)
 }
 
-func TestDigitalOceanProviderGetEmailAddress(t *testing.T) {
+const digitalOceanTeamAccount = `{"account": {"email": "user@example.com", "team": {"uuid": "6c3b2d9a-2f41-4ae5-a8b2-b0d4f5a1c9e7", "name": "oauth2-proxy"}}}`
+
+func TestDigitalOceanProviderEnrichSession(t *testing.T) {
 	b := testDigitalOceanBackend(`{"account": {"email": "user@example.com"}}`)
 	defer b.Close()
 
@@ -99,12 +111,13 @@ func TestDigitalOceanProviderGetEmailAddress(t *testing.T) {
 	p := testDigitalOceanProvider(bURL.Host)
 
 	session := CreateAuthorizedSession()
-	email, err := p.GetEmailAddress(context.Background(), session)
+	err := p.EnrichSession(context.Background(), session)
 	assert.Equal(t, nil, err)
-	assert.Equal(t, "user@example.com", email)
+	assert.Equal(t, "user@example.com", session.Email)
+	assert.Empty(t, session.Groups)
 }
 
-func TestDigitalOceanProviderGetEmailAddressFailedRequest(t *testing.T) {
+func TestDigitalOceanProviderEnrichSessionFailedRequest(t *testing.T) {
 	b := testDigitalOceanBackend("unused payload")
 	defer b.Close()
 
@@ -115,12 +128,12 @@ func TestDigitalOceanProviderGetEmailAddressFailedRequest(t *testing.T) {
 	// token. Alternatively, we could allow the parsing of the payload as
 	// JSON to fail.
 	session := &sessions.SessionState{AccessToken: "unexpected_access_token"}
-	email, err := p.GetEmailAddress(context.Background(), session)
+	err := p.EnrichSession(context.Background(), session)
 	assert.NotEqual(t, nil, err)
-	assert.Equal(t, "", email)
+	assert.Equal(t, "", session.Email)
 }
 
-func TestDigitalOceanProviderGetEmailAddressEmailNotPresentInPayload(t *testing.T) {
+func TestDigitalOceanProviderEnrichSessionEmailNotPresentInPayload(t *testing.T) {
 	b := testDigitalOceanBackend("{\"foo\": \"bar\"}")
 	defer b.Close()
 
@@ -128,7 +141,93 @@ func TestDigitalOceanProviderGetEmailAddressEmailNotPresentInPayload(t *testing.
 	p := testDigitalOceanProvider(bURL.Host)
 
 	session := CreateAuthorizedSession()
-	email, err := p.GetEmailAddress(context.Background(), session)
+	err := p.EnrichSession(context.Background(), session)
 	assert.NotEqual(t, nil, err)
-	assert.Equal(t, "", email)
+	assert.Equal(t, "", session.Email)
+}
+
+func TestDigitalOceanProviderEnrichSessionTeams(t *testing.T) {
+	b := testDigitalOceanBackend(digitalOceanTeamAccount)
+	defer b.Close()
+	bURL, _ := url.Parse(b.URL)
+
+	testCases := map[string]struct {
+		teams         []string
+		expectedError string
+	}{
+		"without allowed teams": {},
+		"with an allowed team UUID": {
+			teams: []string{"6c3b2d9a-2f41-4ae5-a8b2-b0d4f5a1c9e7"},
+		},
+		"with an allowed team name": {
+			teams:         []string{"other", "OAuth2-Proxy", "name:oauth2-proxy"},
+			expectedError: "user is not authorized: not a member of the allowed teams",
+		},
+		"with other allowed teams": {
+			teams:         []string{"other"},
+			expectedError: "user is not authorized: not a member of the allowed teams",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p := testDigitalOceanProvider(bURL.Host, tc.teams...)
+
+			session := CreateAuthorizedSession()
+			err := p.EnrichSession(context.Background(), session)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "user@example.com", session.Email)
+			assert.Equal(t, []string{"6c3b2d9a-2f41-4ae5-a8b2-b0d4f5a1c9e7", "name:oauth2-proxy"}, session.Groups)
+		})
+	}
+}
+
+func TestDigitalOceanProviderRedeem(t *testing.T) {
+	b := testDigitalOceanBackend(digitalOceanTeamAccount)
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testDigitalOceanProvider(bURL.Host)
+
+	s, err := p.Redeem(context.Background(), "https://proxy.example.com/oauth2/callback", "digitalocean_code", "")
+	assert.NoError(t, err)
+	assert.Equal(t, authorizedAccessToken, s.AccessToken)
+	assert.Equal(t, "digitalocean_refresh_token_2", s.RefreshToken)
+	assert.NotNil(t, s.ExpiresOn)
+}
+
+func TestDigitalOceanProviderRefreshSession(t *testing.T) {
+	b := testDigitalOceanBackend(digitalOceanTeamAccount)
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testDigitalOceanProvider(bURL.Host, "6c3b2d9a-2f41-4ae5-a8b2-b0d4f5a1c9e7")
+
+	refreshed, err := p.RefreshSession(context.Background(), &sessions.SessionState{})
+	assert.NoError(t, err)
+	assert.False(t, refreshed)
+
+	s := &sessions.SessionState{
+		Email:        "user@example.com",
+		AccessToken:  "expired",
+		RefreshToken: "digitalocean_refresh_token",
+		Groups:       []string{"name:oauth2-proxy"},
+	}
+	refreshed, err = p.RefreshSession(context.Background(), s)
+	assert.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.Equal(t, authorizedAccessToken, s.AccessToken)
+	assert.Equal(t, "digitalocean_refresh_token_2", s.RefreshToken)
+	assert.Equal(t, []string{"6c3b2d9a-2f41-4ae5-a8b2-b0d4f5a1c9e7", "name:oauth2-proxy"}, s.Groups)
+
+	// The team is no longer allowed since the session was created
+	p.Teams = []string{"other"}
+	s.RefreshToken = "digitalocean_refresh_token"
+	refreshed, err = p.RefreshSession(context.Background(), s)
+	assert.EqualError(t, err, "user is not authorized: not a member of the allowed teams")
+	assert.False(t, refreshed)
 }
@@ -0,0 +1,393 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+	"github.com/ohler55/ojg/jp"
+)
+
+// GenericOAuth2Provider represents a plain OAuth2 based Identity Provider.
+// The session is built from the JSON responses of the provider's API, mapped
+// with JSONPath expressions.
+type GenericOAuth2Provider struct {
+	*ProviderData
+
+	claimMapping  *genericClaimMapping
+	extraRequests []genericRequest
+}
+
+var _ Provider = (*GenericOAuth2Provider)(nil)
+
+const (
+	genericOAuth2ProviderName = "OAuth2"
+
+	genericOAuth2DefaultMaxPages = 10
+)
+
+// genericOAuth2DefaultClaimMapping maps the ProfileURL response when no
+// claim mapping is configured.
+var genericOAuth2DefaultClaimMapping = options.GenericOAuth2ClaimMapping{
+	User:              "$.sub",
+	Email:             "$.email",
+	PreferredUsername: "$.preferred_username",
+	Groups:            "$.groups",
+}
+
+// genericClaimMapping is the compiled form of a GenericOAuth2ClaimMapping.
+type genericClaimMapping struct {
+	user              jp.Expr
+	email             jp.Expr
+	preferredUsername jp.Expr
+	groups            jp.Expr
+	claims            []genericClaim
+}
+
+type genericClaim struct {
+	name string
+	path jp.Expr
+}
+
+// genericRequest is the compiled form of a GenericOAuth2Request.
+type genericRequest struct {
+	url          string
+	claimMapping *genericClaimMapping
+	nextPagePath jp.Expr
+	maxPages     int
+}
+
+// NewGenericOAuth2Provider initiates a new GenericOAuth2Provider
+func NewGenericOAuth2Provider(p *ProviderData, opts options.GenericOAuth2Options) (*GenericOAuth2Provider, error) {
+	p.setProviderDefaults(providerDefaults{
+		name:        genericOAuth2ProviderName,
+		validateURL: p.ProfileURL,
+	})
+	p.getAuthorizationHeaderFunc = makeOIDCHeader
+
+	mapping := opts.ClaimMapping
+	if mapping.User == "" && mapping.Email == "" && mapping.PreferredUsername == "" &&
+		mapping.Groups == "" && len(mapping.Claims) == 0 {
+		mapping = genericOAuth2DefaultClaimMapping
+	}
+	claimMapping, err := compileGenericClaimMapping(mapping)
+	if err != nil {
+		return nil, fmt.Errorf("invalid claim mapping: %v", err)
+	}
+
+	provider := &GenericOAuth2Provider{
+		ProviderData: p,
+		claimMapping: claimMapping,
+	}
+
+	for i, request := range opts.ExtraRequests {
+		compiled, err := compileGenericRequest(request)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extra request %d: %v", i, err)
+		}
+		provider.extraRequests = append(provider.extraRequests, compiled)
+	}
+
+	return provider, nil
+}
+
+func compileGenericClaimMapping(mapping options.GenericOAuth2ClaimMapping) (*genericClaimMapping, error) {
+	var err error
+	compiled := &genericClaimMapping{}
+	if compiled.user, err = parseJSONPath(mapping.User); err != nil {
+		return nil, fmt.Errorf("user: %v", err)
+	}
+	if compiled.email, err = parseJSONPath(mapping.Email); err != nil {
+		return nil, fmt.Errorf("email: %v", err)
+	}
+	if compiled.preferredUsername, err = parseJSONPath(mapping.PreferredUsername); err != nil {
+		return nil, fmt.Errorf("preferredUsername: %v", err)
+	}
+	if compiled.groups, err = parseJSONPath(mapping.Groups); err != nil {
+		return nil, fmt.Errorf("groups: %v", err)
+	}
+	for _, claim := range mapping.Claims {
+		if claim.Name == "" || claim.Path == "" {
+			return nil, errors.New("claims must have a name and a path")
+		}
+		path, err := parseJSONPath(claim.Path)
+		if err != nil {
+			return nil, fmt.Errorf("claim %q: %v", claim.Name, err)
+		}
+		compiled.claims = append(compiled.claims, genericClaim{name: claim.Name, path: path})
+	}
+	return compiled, nil
+}
+
+func compileGenericRequest(request options.GenericOAuth2Request) (genericRequest, error) {
+	if request.URL == "" {
+		return genericRequest{}, errors.New("missing url")
+	}
+	if _, err := url.Parse(request.URL); err != nil {
+		return genericRequest{}, fmt.Errorf("invalid url: %v", err)
+	}
+
+	claimMapping, err := compileGenericClaimMapping(request.ClaimMapping)
+	if err != nil {
+		return genericRequest{}, err
+	}
+	nextPagePath, err := parseJSONPath(request.NextPagePath)
+	if err != nil {
+		return genericRequest{}, fmt.Errorf("nextPagePath: %v", err)
+	}
+
+	maxPages := request.MaxPages
+	if maxPages <= 0 {
+		maxPages = genericOAuth2DefaultMaxPages
+	}
+
+	return genericRequest{
+		url:          request.URL,
+		claimMapping: claimMapping,
+		nextPagePath: nextPagePath,
+		maxPages:     maxPages,
+	}, nil
+}
+
+// parseJSONPath parses a JSONPath expression, returning nil for an empty
+// expression.
+func parseJSONPath(path string) (jp.Expr, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return jp.ParseString(path)
+}
+
+// EnrichSession requests the ProfileURL and any extra requests with the
+// session's access token and maps their responses to the session.
+func (p *GenericOAuth2Provider) EnrichSession(ctx context.Context, s *sessions.SessionState) error {
+	if s.AccessToken == "" {
+		return errors.New("missing access token")
+	}
+	if p.ProfileURL == nil || p.ProfileURL.String() == "" {
+		return errors.New("missing profile url")
+	}
+
+	data, _, err := p.getJSON(ctx, p.ProfileURL.String(), s.AccessToken)
+	if err != nil {
+		return fmt.Errorf("unable to fetch profile: %v", err)
+	}
+	p.claimMapping.apply(data, s)
+
+	for _, request := range p.extraRequests {
+		if err := p.doExtraRequest(ctx, request, s); err != nil {
+			return err
+		}
+	}
+
+	if s.User == "" {
+		s.User = s.Email
+	}
+	if s.User == "" {
+		return errors.New("unable to find the user or email in the provider's responses")
+	}
+	return nil
+}
+
+// doExtraRequest requests every page of an extra request, following either
+// the next page path of the response or the `Link` header.
+func (p *GenericOAuth2Provider) doExtraRequest(ctx context.Context, request genericRequest, s *sessions.SessionState) error {
+	endpoint := request.url
+	for page := 0; page < request.maxPages && endpoint != ""; page++ {
+		data, headers, err := p.getJSON(ctx, endpoint, s.AccessToken)
+		if err != nil {
+			return fmt.Errorf("unable to fetch %s: %v", stripToken(endpoint), err)
+		}
+		request.claimMapping.apply(data, s)
+
+		next := nextLink(headers)
+		if request.nextPagePath != nil {
+			next = firstString(request.nextPagePath.Get(data))
+		}
+		endpoint, err = resolveURL(endpoint, next)
+		if err != nil {
+			return fmt.Errorf("invalid next page url %q: %v", next, err)
+		}
+		// The access token is sent with every page, so only pages on the
+		// origin of the configured request are followed
+		if endpoint != "" && !sameOrigin(request.url, endpoint) {
+			return fmt.Errorf("invalid next page url %q: not on the origin of %s", next, stripToken(request.url))
+		}
+	}
+	return nil
+}
+
+func (p *GenericOAuth2Provider) getJSON(ctx context.Context, endpoint, accessToken string) (interface{}, http.Header, error) {
+	result := requests.New(endpoint).
+		WithContext(ctx).
+		WithHeaders(makeOIDCHeader(accessToken)).
+		Do()
+
+	var data interface{}
+	if err := result.UnmarshalInto(&data); err != nil {
+		return nil, nil, err
+	}
+	return data, result.Headers(), nil
+}
+
+// ValidateSession validates the AccessToken
+func (p *GenericOAuth2Provider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
+	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
+}
+
+// apply sets the session fields found in the data. The user, email and
+// preferred username are only set when the session doesn't have them yet,
+// while groups and claims are added to those already in the session, so that
+// the values of every page of a response are kept.
+func (m *genericClaimMapping) apply(data interface{}, s *sessions.SessionState) {
+	if s.User == "" && m.user != nil {
+		s.User = firstString(m.user.Get(data))
+	}
+	if s.Email == "" && m.email != nil {
+		s.Email = firstString(m.email.Get(data))
+	}
+	if s.PreferredUsername == "" && m.preferredUsername != nil {
+		s.PreferredUsername = firstString(m.preferredUsername.Get(data))
+	}
+	if m.groups != nil {
+		for _, group := range flattenStrings(m.groups.Get(data)) {
+			if group != "" && !containsString(s.Groups, group) {
+				s.Groups = append(s.Groups, group)
+			}
+		}
+	}
+
+	for _, claim := range m.claims {
+		values := flattenStrings(claim.path.Get(data))
+		if len(values) == 0 {
+			continue
+		}
+		if s.AdditionalClaims == nil {
+			s.AdditionalClaims = map[string]string{}
+		}
+		merged := []string{}
+		if existing := s.AdditionalClaims[claim.name]; existing != "" {
+			merged = strings.Split(existing, ",")
+		}
+		for _, value := range values {
+			if !containsString(merged, value) {
+				merged = append(merged, value)
+			}
+		}
+		s.AdditionalClaims[claim.name] = strings.Join(merged, ",")
+	}
+}
+
+// flattenStrings formats the results of a JSONPath expression as strings,
+// expanding a result that is a list into its entries.
+func flattenStrings(results []interface{}) []string {
+	values := []string{}
+	for _, result := range results {
+		if list, ok := result.([]interface{}); ok {
+			for _, entry := range list {
+				if value, ok := formatJSONValue(entry); ok {
+					values = append(values, value)
+				}
+			}
+			continue
+		}
+		if value, ok := formatJSONValue(result); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func firstString(results []interface{}) string {
+	values := flattenStrings(results)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func formatJSONValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(raw), true
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// nextLink returns the URL of the `next` relation of a `Link` header.
+func nextLink(headers http.Header) string {
+	for _, header := range headers.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(name, "rel") && containsString(strings.Fields(strings.Trim(value, `"`)), "next") {
+					return strings.Trim(target, "<>")
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// resolveURL resolves a possibly relative next page URL against the URL of
+// the current page.
+func resolveURL(current, next string) (string, error) {
+	if next == "" {
+		return "", nil
+	}
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// sameOrigin returns whether both URLs have the same scheme and host.
+func sameOrigin(a, b string) bool {
+	aURL, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	bURL, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(aURL.Scheme, bURL.Scheme) && strings.EqualFold(aURL.Host, bURL.Host)
+}
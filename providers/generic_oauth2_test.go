@@ -0,0 +1,291 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const genericOAuth2AccessToken = "generic_oauth2_access_token"
+
+func newGenericOAuth2Server() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+genericOAuth2AccessToken {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/userinfo":
+			rw.Write([]byte(`{"sub":"123","email":"michael.bland@gsa.gov","preferred_username":"mbland","groups":["admins"]}`))
+		case "/api/user":
+			rw.Write([]byte(`{"data":{"id":42,"login":"mbland","emails":["michael.bland@gsa.gov","mbland@example.com"],"org":{"name":"gsa"}}}`))
+		case "/api/teams":
+			switch r.URL.Query().Get("page") {
+			case "":
+				rw.Header().Set("Link", `</api/teams?page=2>; rel="next", </api/teams?page=3>; rel="last"`)
+				rw.Write([]byte(`[{"name":"team-a"},{"name":"team-b"}]`))
+			case "2":
+				rw.Header().Set("Link", `</api/teams?page=3>; rel="next"`)
+				rw.Write([]byte(`[{"name":"team-b"},{"name":"team-c"}]`))
+			default:
+				rw.Write([]byte(`[{"name":"team-d"}]`))
+			}
+		case "/api/projects":
+			rw.Header().Set("Link", `<https://attacker.example.com/api/projects?page=2>; rel="next"`)
+			rw.Write([]byte(`[{"name":"project-a"}]`))
+		case "/api/roles":
+			if r.URL.Query().Get("cursor") == "" {
+				rw.Write([]byte(`{"roles":["viewer"],"next":"/api/roles?cursor=abc"}`))
+				return
+			}
+			rw.Write([]byte(`{"roles":["editor"]}`))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func testGenericOAuth2Provider(serverURL string, opts options.GenericOAuth2Options) (*GenericOAuth2Provider, error) {
+	profileURL, err := url.Parse(serverURL + "/userinfo")
+	if err != nil {
+		return nil, err
+	}
+	return NewGenericOAuth2Provider(&ProviderData{ProfileURL: profileURL}, opts)
+}
+
+var _ = Describe("Generic OAuth2 Provider Tests", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = newGenericOAuth2Server()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("New Provider Init", func() {
+		It("uses defaults", func() {
+			p, err := testGenericOAuth2Provider(server.URL, options.GenericOAuth2Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.Data().ProviderName).To(Equal("OAuth2"))
+			Expect(p.Data().ValidateURL.String()).To(Equal(server.URL + "/userinfo"))
+		})
+
+		It("rejects invalid JSONPath expressions", func() {
+			_, err := testGenericOAuth2Provider(server.URL, options.GenericOAuth2Options{
+				ClaimMapping: options.GenericOAuth2ClaimMapping{User: "$.data[?(@.id"},
+			})
+			Expect(err).To(MatchError(ContainSubstring("invalid claim mapping: user:")))
+		})
+
+		It("rejects extra requests without a URL", func() {
+			_, err := testGenericOAuth2Provider(server.URL, options.GenericOAuth2Options{
+				ExtraRequests: []options.GenericOAuth2Request{{}},
+			})
+			Expect(err).To(MatchError("invalid extra request 0: missing url"))
+		})
+	})
+
+	type enrichSessionTableInput struct {
+		profilePath     string
+		opts            func(serverURL string) options.GenericOAuth2Options
+		accessToken     string
+		expectedError   string
+		expectedSession *sessions.SessionState
+	}
+
+	DescribeTable("EnrichSession",
+		func(in enrichSessionTableInput) {
+			opts := options.GenericOAuth2Options{}
+			if in.opts != nil {
+				opts = in.opts(server.URL)
+			}
+			p, err := testGenericOAuth2Provider(server.URL, opts)
+			Expect(err).ToNot(HaveOccurred())
+			if in.profilePath != "" {
+				p.ProfileURL.Path = in.profilePath
+			}
+
+			s := &sessions.SessionState{AccessToken: in.accessToken}
+			err = p.EnrichSession(context.Background(), s)
+			if in.expectedError != "" {
+				Expect(err).To(MatchError(ContainSubstring(in.expectedError)))
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+
+			in.expectedSession.AccessToken = in.accessToken
+			Expect(s).To(Equal(in.expectedSession))
+		},
+		Entry("with the default claim mapping", enrichSessionTableInput{
+			accessToken: genericOAuth2AccessToken,
+			expectedSession: &sessions.SessionState{
+				User:              "123",
+				Email:             "michael.bland@gsa.gov",
+				PreferredUsername: "mbland",
+				Groups:            []string{"admins"},
+			},
+		}),
+		Entry("with a custom claim mapping", enrichSessionTableInput{
+			profilePath: "/api/user",
+			opts: func(string) options.GenericOAuth2Options {
+				return options.GenericOAuth2Options{
+					ClaimMapping: options.GenericOAuth2ClaimMapping{
+						User:  "$.data.id",
+						Email: "$.data.emails[*]",
+						Claims: []options.GenericOAuth2Claim{
+							{Name: "org", Path: "$.data.org.name"},
+							{Name: "emails", Path: "$.data.emails"},
+							{Name: "missing", Path: "$.data.missing"},
+						},
+					},
+				}
+			},
+			accessToken: genericOAuth2AccessToken,
+			expectedSession: &sessions.SessionState{
+				User:  "42",
+				Email: "michael.bland@gsa.gov",
+				AdditionalClaims: map[string]string{
+					"org":    "gsa",
+					"emails": "michael.bland@gsa.gov,mbland@example.com",
+				},
+			},
+		}),
+		Entry("falls back to the email when no user is found", enrichSessionTableInput{
+			profilePath: "/api/user",
+			opts: func(string) options.GenericOAuth2Options {
+				return options.GenericOAuth2Options{
+					ClaimMapping: options.GenericOAuth2ClaimMapping{
+						User:  "$.data.username",
+						Email: "$.data.emails[0]",
+					},
+				}
+			},
+			accessToken: genericOAuth2AccessToken,
+			expectedSession: &sessions.SessionState{
+				User:  "michael.bland@gsa.gov",
+				Email: "michael.bland@gsa.gov",
+			},
+		}),
+		Entry("with paginated extra requests", enrichSessionTableInput{
+			opts: func(serverURL string) options.GenericOAuth2Options {
+				return options.GenericOAuth2Options{
+					ClaimMapping: genericOAuth2DefaultClaimMapping,
+					ExtraRequests: []options.GenericOAuth2Request{
+						{
+							URL:          serverURL + "/api/teams",
+							ClaimMapping: options.GenericOAuth2ClaimMapping{Groups: "$[*].name"},
+						},
+						{
+							URL:          serverURL + "/api/roles",
+							ClaimMapping: options.GenericOAuth2ClaimMapping{Groups: "$.roles"},
+							NextPagePath: "$.next",
+						},
+					},
+				}
+			},
+			accessToken: genericOAuth2AccessToken,
+			expectedSession: &sessions.SessionState{
+				User:              "123",
+				Email:             "michael.bland@gsa.gov",
+				PreferredUsername: "mbland",
+				Groups:            []string{"admins", "team-a", "team-b", "team-c", "team-d", "viewer", "editor"},
+			},
+		}),
+		Entry("with claims on several pages", enrichSessionTableInput{
+			opts: func(serverURL string) options.GenericOAuth2Options {
+				return options.GenericOAuth2Options{
+					ClaimMapping: genericOAuth2DefaultClaimMapping,
+					ExtraRequests: []options.GenericOAuth2Request{
+						{
+							URL: serverURL + "/api/teams",
+							ClaimMapping: options.GenericOAuth2ClaimMapping{
+								Claims: []options.GenericOAuth2Claim{{Name: "teams", Path: "$[*].name"}},
+							},
+						},
+					},
+				}
+			},
+			accessToken: genericOAuth2AccessToken,
+			expectedSession: &sessions.SessionState{
+				User:              "123",
+				Email:             "michael.bland@gsa.gov",
+				PreferredUsername: "mbland",
+				Groups:            []string{"admins"},
+				AdditionalClaims: map[string]string{
+					"teams": "team-a,team-b,team-c,team-d",
+				},
+			},
+		}),
+		Entry("stops after the maximum number of pages", enrichSessionTableInput{
+			opts: func(serverURL string) options.GenericOAuth2Options {
+				return options.GenericOAuth2Options{
+					ExtraRequests: []options.GenericOAuth2Request{
+						{
+							URL:          serverURL + "/api/teams",
+							ClaimMapping: options.GenericOAuth2ClaimMapping{Groups: "$[*].name"},
+							MaxPages:     2,
+						},
+					},
+				}
+			},
+			accessToken: genericOAuth2AccessToken,
+			expectedSession: &sessions.SessionState{
+				User:              "123",
+				Email:             "michael.bland@gsa.gov",
+				PreferredUsername: "mbland",
+				Groups:            []string{"admins", "team-a", "team-b", "team-c"},
+			},
+		}),
+		Entry("refuses a next page on another origin", enrichSessionTableInput{
+			opts: func(serverURL string) options.GenericOAuth2Options {
+				return options.GenericOAuth2Options{
+					ExtraRequests: []options.GenericOAuth2Request{
+						{
+							URL:          serverURL + "/api/projects",
+							ClaimMapping: options.GenericOAuth2ClaimMapping{Groups: "$[*].name"},
+						},
+					},
+				}
+			},
+			accessToken:   genericOAuth2AccessToken,
+			expectedError: `invalid next page url "https://attacker.example.com/api/projects?page=2": not on the origin of http://`,
+		}),
+		Entry("when an extra request fails", enrichSessionTableInput{
+			opts: func(serverURL string) options.GenericOAuth2Options {
+				return options.GenericOAuth2Options{
+					ExtraRequests: []options.GenericOAuth2Request{{URL: fmt.Sprintf("%s/api/unknown", serverURL)}},
+				}
+			},
+			accessToken:   genericOAuth2AccessToken,
+			expectedError: "unable to fetch http://",
+		}),
+		Entry("with an invalid access token", enrichSessionTableInput{
+			accessToken:   "invalid",
+			expectedError: "unable to fetch profile",
+		}),
+		Entry("without a user or email", enrichSessionTableInput{
+			profilePath:   "/api/teams",
+			accessToken:   genericOAuth2AccessToken,
+			expectedError: "unable to find the user or email",
+		}),
+	)
+
+	It("validates the session with the profile URL", func() {
+		p, err := testGenericOAuth2Provider(server.URL, options.GenericOAuth2Options{})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(p.ValidateSession(context.Background(), &sessions.SessionState{AccessToken: genericOAuth2AccessToken})).To(BeTrue())
+		Expect(p.ValidateSession(context.Background(), &sessions.SessionState{AccessToken: "invalid"})).To(BeFalse())
+	})
+})
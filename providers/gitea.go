@@ -0,0 +1,354 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+)
+
+// GiteaProvider represents a Gitea (or Forgejo) based Identity Provider
+type GiteaProvider struct {
+	*ProviderData
+
+	apiURL               *url.URL
+	Orgs                 []string
+	Teams                []string
+	Repositories         []string
+	RepositoryPermission string
+}
+
+var _ Provider = (*GiteaProvider)(nil)
+
+const (
+	giteaProviderName = "Gitea"
+	giteaDefaultScope = "read:user read:organization read:repository"
+
+	giteaOrgTeamSeparator = "/"
+
+	giteaPermissionRead  = "read"
+	giteaPermissionWrite = "write"
+	giteaPermissionAdmin = "admin"
+
+	// giteaPageLimit is the number of entries requested per page, the
+	// default maximum of the Gitea API. Servers may return fewer.
+	giteaPageLimit = 50
+
+	// giteaMaxPages limits the number of pages requested from a paginated
+	// endpoint, so that a server that never runs out of pages can't keep
+	// the provider requesting them.
+	giteaMaxPages = 20
+)
+
+// giteaDefaultBaseURL is the Gitea server used when no base URL is set.
+// Pre-parsed URL of https://gitea.com.
+var giteaDefaultBaseURL = &url.URL{
+	Scheme: "https",
+	Host:   "gitea.com",
+}
+
+// NewGiteaProvider initiates a new GiteaProvider
+func NewGiteaProvider(p *ProviderData, opts options.GiteaOptions) (*GiteaProvider, error) {
+	baseURL := giteaDefaultBaseURL
+	if opts.BaseURL != "" {
+		var err error
+		baseURL, err = url.Parse(strings.TrimSuffix(opts.BaseURL, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base URL: %v", err)
+		}
+	}
+
+	p.setProviderDefaults(providerDefaults{
+		name:        giteaProviderName,
+		loginURL:    giteaURL(baseURL, "/login/oauth/authorize"),
+		redeemURL:   giteaURL(baseURL, "/login/oauth/access_token"),
+		profileURL:  giteaURL(baseURL, "/api/v1/user"),
+		validateURL: giteaURL(baseURL, "/api/v1/user"),
+		scope:       giteaDefaultScope,
+	})
+	p.getAuthorizationHeaderFunc = makeOIDCHeader
+
+	permission := opts.RepositoryPermission
+	if permission == "" {
+		permission = giteaPermissionRead
+	}
+	switch permission {
+	case giteaPermissionRead, giteaPermissionWrite, giteaPermissionAdmin:
+	default:
+		return nil, fmt.Errorf("invalid repository permission %q: must be one of read, write or admin", permission)
+	}
+
+	for _, team := range opts.Teams {
+		if org, name, ok := strings.Cut(team, giteaOrgTeamSeparator); !ok || org == "" || name == "" {
+			return nil, fmt.Errorf("invalid team %q: teams must be given as org/team", team)
+		}
+	}
+	for _, repo := range opts.Repositories {
+		if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" {
+			return nil, fmt.Errorf("invalid repository %q: repositories must be given as owner/repo", repo)
+		}
+	}
+
+	return &GiteaProvider{
+		ProviderData:         p,
+		apiURL:               giteaURL(baseURL, "/api/v1"),
+		Orgs:                 opts.Orgs,
+		Teams:                opts.Teams,
+		Repositories:         opts.Repositories,
+		RepositoryPermission: permission,
+	}, nil
+}
+
+func giteaURL(base *url.URL, endpoint string) *url.URL {
+	u := *base
+	u.Path = path.Join(base.Path, endpoint)
+	return &u
+}
+
+func (p *GiteaProvider) makeGiteaAPIEndpoint(endpoint string, params url.Values) string {
+	u := giteaURL(p.apiURL, endpoint)
+	u.RawQuery = params.Encode()
+	return u.String()
+}
+
+// Redeem exchanges the OAuth2 authentication code for an access token,
+// keeping the refresh token and expiry of the response.
+func (p *GiteaProvider) Redeem(ctx context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
+	return p.redeemOAuth2Code(ctx, redirectURL, code, codeVerifier)
+}
+
+// EnrichSession sets the user, email and groups of the session and checks
+// that the user is allowed by the configured orgs, teams and repositories.
+func (p *GiteaProvider) EnrichSession(ctx context.Context, s *sessions.SessionState) error {
+	if err := p.getUser(ctx, s); err != nil {
+		return err
+	}
+	if err := p.getGroups(ctx, s); err != nil {
+		return err
+	}
+	return p.checkRestrictions(ctx, s)
+}
+
+// ValidateSession validates the AccessToken
+func (p *GiteaProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
+	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
+}
+
+// RefreshSession uses the RefreshToken to fetch a new access token, then
+// reloads the user's orgs and teams and checks again that the user is allowed.
+func (p *GiteaProvider) RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	if s == nil || s.RefreshToken == "" {
+		return false, nil
+	}
+
+	if err := p.redeemOAuth2RefreshToken(ctx, s); err != nil {
+		return false, fmt.Errorf("unable to redeem refresh token: %w", err)
+	}
+
+	s.Groups = nil
+	if err := p.getGroups(ctx, s); err != nil {
+		return false, err
+	}
+	if err := p.checkRestrictions(ctx, s); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// getUser updates the SessionState User and Email
+func (p *GiteaProvider) getUser(ctx context.Context, s *sessions.SessionState) error {
+	// https://gitea.com/api/swagger#/user/userGetCurrent
+	var user struct {
+		Login string `json:"login"`
+		Email string `json:"email"`
+	}
+
+	err := requests.New(p.ProfileURL.String()).
+		WithContext(ctx).
+		WithHeaders(makeOIDCHeader(s.AccessToken)).
+		Do().
+		UnmarshalInto(&user)
+	if err != nil {
+		return fmt.Errorf("unable to fetch user: %v", err)
+	}
+	if user.Login == "" {
+		return errors.New("user has no login")
+	}
+
+	s.User = user.Login
+	s.Email = user.Email
+	return nil
+}
+
+// getGroups adds the user's orgs and teams to the SessionState Groups, as
+// `org` and `org/team`.
+func (p *GiteaProvider) getGroups(ctx context.Context, s *sessions.SessionState) error {
+	// https://gitea.com/api/swagger#/organization/orgListCurrentUserOrgs
+	type organization struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+	}
+	// https://gitea.com/api/swagger#/user/userListTeams
+	type team struct {
+		Name         string       `json:"name"`
+		Organization organization `json:"organization"`
+	}
+
+	err := p.getPages(ctx, "/user/orgs", s.AccessToken, func(result requests.Result) (int, error) {
+		var orgs []organization
+		if err := result.UnmarshalInto(&orgs); err != nil {
+			return 0, err
+		}
+		for _, org := range orgs {
+			name := giteaOrgName(org.Name, org.Username)
+			logger.Printf("Member of Gitea Organization:%q", name)
+			s.Groups = append(s.Groups, name)
+		}
+		return len(orgs), nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to fetch organizations: %v", err)
+	}
+
+	err = p.getPages(ctx, "/user/teams", s.AccessToken, func(result requests.Result) (int, error) {
+		var teams []team
+		if err := result.UnmarshalInto(&teams); err != nil {
+			return 0, err
+		}
+		for _, t := range teams {
+			group := giteaOrgName(t.Organization.Name, t.Organization.Username) + giteaOrgTeamSeparator + t.Name
+			logger.Printf("Member of Gitea Organization/Team:%q", group)
+			s.Groups = append(s.Groups, group)
+		}
+		return len(teams), nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to fetch teams: %v", err)
+	}
+
+	return nil
+}
+
+// giteaOrgName returns the name of an organization. Older Gitea versions
+// only return the name in the `username` field.
+func giteaOrgName(name, username string) string {
+	if name != "" {
+		return name
+	}
+	return username
+}
+
+// getPages requests every page of a paginated Gitea API endpoint, passing
+// each page to readPage, which returns the number of entries of the page.
+// Gitea returns fewer entries than the limit when its `MAX_RESPONSE_ITEMS`
+// is lower, so pages are requested until the `X-Total-Count` of entries has
+// been read, or until an empty page. An error is returned when there are more
+// than giteaMaxPages pages, as the entries would be incomplete.
+func (p *GiteaProvider) getPages(ctx context.Context, endpoint, accessToken string, readPage func(requests.Result) (int, error)) error {
+	read := 0
+	for page := 1; page <= giteaMaxPages; page++ {
+		params := url.Values{
+			"limit": {strconv.Itoa(giteaPageLimit)},
+			"page":  {strconv.Itoa(page)},
+		}
+
+		result := requests.New(p.makeGiteaAPIEndpoint(endpoint, params)).
+			WithContext(ctx).
+			WithHeaders(makeOIDCHeader(accessToken)).
+			Do()
+		entries, err := readPage(result)
+		if err != nil {
+			return err
+		}
+		read += entries
+		if entries == 0 {
+			return nil
+		}
+		if total, err := strconv.Atoi(result.Headers().Get("X-Total-Count")); err == nil && read >= total {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s has more than %d pages", endpoint, giteaMaxPages)
+}
+
+// checkRestrictions allows the user when no restrictions are configured, or
+// when the user is a member of one of the orgs or teams, or has the minimum
+// permission on one of the repositories.
+func (p *GiteaProvider) checkRestrictions(ctx context.Context, s *sessions.SessionState) error {
+	if len(p.Orgs) == 0 && len(p.Teams) == 0 && len(p.Repositories) == 0 {
+		return nil
+	}
+
+	for _, group := range s.Groups {
+		for _, org := range p.Orgs {
+			if strings.EqualFold(group, org) {
+				logger.Printf("Found Gitea Organization:%q", org)
+				return nil
+			}
+		}
+		for _, team := range p.Teams {
+			if strings.EqualFold(group, team) {
+				logger.Printf("Found Gitea Organization/Team:%q", team)
+				return nil
+			}
+		}
+	}
+
+	for _, repo := range p.Repositories {
+		ok, err := p.hasRepositoryPermission(ctx, repo, s.AccessToken)
+		if err != nil {
+			return err
+		}
+		if ok {
+			logger.Printf("Found Gitea Repository:%q with permission %q", repo, p.RepositoryPermission)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: not a member of the allowed organizations or teams and no access to the allowed repositories", ErrNotAuthorized)
+}
+
+// hasRepositoryPermission checks the user's permission on a repository.
+// Repositories the user cannot see are reported as not found by Gitea.
+func (p *GiteaProvider) hasRepositoryPermission(ctx context.Context, repo, accessToken string) (bool, error) {
+	// https://gitea.com/api/swagger#/repository/repoGet
+	var repository struct {
+		Permissions struct {
+			Admin bool `json:"admin"`
+			Push  bool `json:"push"`
+			Pull  bool `json:"pull"`
+		} `json:"permissions"`
+	}
+
+	result := requests.New(p.makeGiteaAPIEndpoint("/repos/"+repo, url.Values{})).
+		WithContext(ctx).
+		WithHeaders(makeOIDCHeader(accessToken)).
+		Do()
+	if result.Error() != nil {
+		return false, result.Error()
+	}
+	if result.StatusCode() == 404 {
+		return false, nil
+	}
+	if err := result.UnmarshalInto(&repository); err != nil {
+		return false, fmt.Errorf("unable to fetch repository %q: %v", repo, err)
+	}
+
+	switch p.RepositoryPermission {
+	case giteaPermissionAdmin:
+		return repository.Permissions.Admin, nil
+	case giteaPermissionWrite:
+		return repository.Permissions.Admin || repository.Permissions.Push, nil
+	default:
+		return repository.Permissions.Admin || repository.Permissions.Push || repository.Permissions.Pull, nil
+	}
+}
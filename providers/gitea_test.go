@@ -2,12 +2,16 @@ package providers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -96,3 +100,236 @@ func TestGiteaProvider_ValidateSessionWithUserEmails(t *testing.T) {
 	valid := p.ValidateSession(context.Background(), session)
 	assert.True(t, valid)
 }
+
+const giteaAccessToken = "gitea_access_token"
+
+// giteaTestMaxResponseItems is the `MAX_RESPONSE_ITEMS` of the fake Gitea
+// API, lower than the requested limit.
+const giteaTestMaxResponseItems = 20
+
+// testGiteaAPI fakes the Gitea endpoints used by the gitea provider. The user
+// is a member of enough orgs for them to span several pages.
+func testGiteaAPI(t *testing.T, repoPermissions map[string]string) *httptest.Server {
+	orgs := make([]string, 0, giteaPageLimit+1)
+	for i := 0; i < giteaPageLimit; i++ {
+		orgs = append(orgs, fmt.Sprintf(`{"name":"org-%d"}`, i))
+	}
+	orgs = append(orgs, `{"username":"oauth2-proxy"}`)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login/oauth/access_token" {
+			assert.NoError(t, r.ParseForm())
+			if r.PostForm.Get("grant_type") == "refresh_token" {
+				assert.Equal(t, "gitea_refresh_token", r.PostForm.Get("refresh_token"))
+			} else {
+				assert.Equal(t, "gitea_code", r.PostForm.Get("code"))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"` + giteaAccessToken + `","token_type":"bearer","expires_in":3600,"refresh_token":"gitea_refresh_token_2"}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer "+giteaAccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/api/v1/user/") {
+			assert.Equal(t, strconv.Itoa(giteaPageLimit), r.URL.Query().Get("limit"))
+		}
+
+		switch r.URL.Path {
+		case "/api/v1/user":
+			w.Write([]byte(`{"login":"mbland","email":"michael.bland@gsa.gov"}`))
+		case "/api/v1/user/orgs":
+			page, err := strconv.Atoi(r.URL.Query().Get("page"))
+			assert.NoError(t, err)
+			start := min((page-1)*giteaTestMaxResponseItems, len(orgs))
+			end := min(start+giteaTestMaxResponseItems, len(orgs))
+			w.Header().Set("X-Total-Count", strconv.Itoa(len(orgs)))
+			w.Write([]byte("[" + strings.Join(orgs[start:end], ",") + "]"))
+		case "/api/v1/user/teams":
+			// Without a total count, the pages are read until an empty one
+			if r.URL.Query().Get("page") != "1" {
+				w.Write([]byte("[]"))
+				return
+			}
+			w.Write([]byte(`[{"name":"maintainers","organization":{"name":"oauth2-proxy"}}]`))
+		default:
+			repo := strings.TrimPrefix(r.URL.Path, "/api/v1/repos/")
+			permission, ok := repoPermissions[repo]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprintf(w, `{"full_name":%q,"permissions":{"admin":%t,"push":%t,"pull":true}}`,
+				repo, permission == "admin", permission == "admin" || permission == "write")
+		}
+	}))
+}
+
+func newTestGiteaProvider(serverURL string, opts options.GiteaOptions) (*GiteaProvider, error) {
+	opts.BaseURL = serverURL
+	return NewGiteaProvider(&ProviderData{ClientID: "client", ClientSecret: "secret"}, opts)
+}
+
+func TestNewGiteaProvider(t *testing.T) {
+	p, err := NewGiteaProvider(&ProviderData{}, options.GiteaOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Gitea", p.Data().ProviderName)
+	assert.Equal(t, "https://gitea.com/login/oauth/authorize", p.Data().LoginURL.String())
+	assert.Equal(t, "https://gitea.com/login/oauth/access_token", p.Data().RedeemURL.String())
+	assert.Equal(t, "https://gitea.com/api/v1/user", p.Data().ProfileURL.String())
+	assert.Equal(t, "https://gitea.com/api/v1/user", p.Data().ValidateURL.String())
+	assert.Equal(t, "read:user read:organization read:repository", p.Data().Scope)
+	assert.Equal(t, "read", p.RepositoryPermission)
+
+	p, err = NewGiteaProvider(&ProviderData{}, options.GiteaOptions{BaseURL: "https://git.example.com/gitea/"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://git.example.com/gitea/login/oauth/authorize", p.Data().LoginURL.String())
+	assert.Equal(t, "https://git.example.com/gitea/api/v1/user", p.Data().ProfileURL.String())
+
+	_, err = NewGiteaProvider(&ProviderData{}, options.GiteaOptions{RepositoryPermission: "owner"})
+	assert.EqualError(t, err, `invalid repository permission "owner": must be one of read, write or admin`)
+
+	_, err = NewGiteaProvider(&ProviderData{}, options.GiteaOptions{Teams: []string{"maintainers"}})
+	assert.EqualError(t, err, `invalid team "maintainers": teams must be given as org/team`)
+
+	_, err = NewGiteaProvider(&ProviderData{}, options.GiteaOptions{Repositories: []string{"oauth2-proxy"}})
+	assert.EqualError(t, err, `invalid repository "oauth2-proxy": repositories must be given as owner/repo`)
+}
+
+func TestGiteaProviderEnrichSession(t *testing.T) {
+	testCases := map[string]struct {
+		opts          options.GiteaOptions
+		expectedError string
+	}{
+		"without restrictions": {},
+		"with an allowed org on the second page": {
+			opts: options.GiteaOptions{Orgs: []string{"OAuth2-Proxy"}},
+		},
+		"with an allowed team": {
+			opts: options.GiteaOptions{Teams: []string{"other/admins", "oauth2-proxy/maintainers"}},
+		},
+		"with a readable repository": {
+			opts: options.GiteaOptions{Repositories: []string{"oauth2-proxy/oauth2-proxy"}},
+		},
+		"with a writable repository and the write permission": {
+			opts: options.GiteaOptions{Repositories: []string{"oauth2-proxy/website"}, RepositoryPermission: "write"},
+		},
+		"with a readable repository and the write permission": {
+			opts:          options.GiteaOptions{Repositories: []string{"oauth2-proxy/oauth2-proxy"}, RepositoryPermission: "write"},
+			expectedError: "user is not authorized: not a member of the allowed organizations or teams and no access to the allowed repositories",
+		},
+		"with a writable repository and the admin permission": {
+			opts:          options.GiteaOptions{Repositories: []string{"oauth2-proxy/website"}, RepositoryPermission: "admin"},
+			expectedError: "user is not authorized: not a member of the allowed organizations or teams and no access to the allowed repositories",
+		},
+		"with an unknown repository": {
+			opts:          options.GiteaOptions{Repositories: []string{"oauth2-proxy/private"}},
+			expectedError: "user is not authorized: not a member of the allowed organizations or teams and no access to the allowed repositories",
+		},
+		"with a missing org and team": {
+			opts: options.GiteaOptions{
+				Orgs:  []string{"other"},
+				Teams: []string{"oauth2-proxy/owners"},
+			},
+			expectedError: "user is not authorized: not a member of the allowed organizations or teams and no access to the allowed repositories",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			b := testGiteaAPI(t, map[string]string{
+				"oauth2-proxy/oauth2-proxy": "read",
+				"oauth2-proxy/website":      "write",
+			})
+			defer b.Close()
+
+			p, err := newTestGiteaProvider(b.URL, tc.opts)
+			assert.NoError(t, err)
+
+			s := &sessions.SessionState{AccessToken: giteaAccessToken}
+			err = p.EnrichSession(context.Background(), s)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "mbland", s.User)
+			assert.Equal(t, "michael.bland@gsa.gov", s.Email)
+			assert.Len(t, s.Groups, giteaPageLimit+2)
+			assert.Equal(t, []string{"oauth2-proxy", "oauth2-proxy/maintainers"}, s.Groups[giteaPageLimit:])
+		})
+	}
+}
+
+func TestGiteaProviderEnrichSessionTooManyPages(t *testing.T) {
+	requested := 0
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/user":
+			w.Write([]byte(`{"login":"mbland","email":"michael.bland@gsa.gov"}`))
+		case "/api/v1/user/orgs":
+			// The server never runs out of pages
+			requested++
+			w.Header().Set("X-Total-Count", "1000000")
+			fmt.Fprintf(w, `[{"name":"org-%s"}]`, r.URL.Query().Get("page"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer b.Close()
+
+	p, err := newTestGiteaProvider(b.URL, options.GiteaOptions{})
+	assert.NoError(t, err)
+
+	err = p.EnrichSession(context.Background(), &sessions.SessionState{AccessToken: giteaAccessToken})
+	assert.EqualError(t, err, fmt.Sprintf("unable to fetch organizations: /user/orgs has more than %d pages", giteaMaxPages))
+	assert.Equal(t, giteaMaxPages, requested)
+}
+
+func TestGiteaProviderRedeem(t *testing.T) {
+	b := testGiteaAPI(t, nil)
+	defer b.Close()
+
+	p, err := newTestGiteaProvider(b.URL, options.GiteaOptions{})
+	assert.NoError(t, err)
+
+	s, err := p.Redeem(context.Background(), "https://proxy.example.com/oauth2/callback", "gitea_code", "")
+	assert.NoError(t, err)
+	assert.Equal(t, giteaAccessToken, s.AccessToken)
+	assert.Equal(t, "gitea_refresh_token_2", s.RefreshToken)
+	assert.NotNil(t, s.ExpiresOn)
+}
+
+func TestGiteaProviderRefreshSession(t *testing.T) {
+	b := testGiteaAPI(t, nil)
+	defer b.Close()
+
+	p, err := newTestGiteaProvider(b.URL, options.GiteaOptions{Teams: []string{"oauth2-proxy/maintainers"}})
+	assert.NoError(t, err)
+
+	refreshed, err := p.RefreshSession(context.Background(), &sessions.SessionState{})
+	assert.NoError(t, err)
+	assert.False(t, refreshed)
+
+	s := &sessions.SessionState{
+		User:         "mbland",
+		AccessToken:  "expired",
+		RefreshToken: "gitea_refresh_token",
+		Groups:       []string{"oauth2-proxy/maintainers"},
+	}
+	refreshed, err = p.RefreshSession(context.Background(), s)
+	assert.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.Equal(t, giteaAccessToken, s.AccessToken)
+	assert.Equal(t, "gitea_refresh_token_2", s.RefreshToken)
+	assert.Len(t, s.Groups, giteaPageLimit+2)
+
+	// The user has left the team since the session was created
+	p.Teams = []string{"oauth2-proxy/owners"}
+	s.RefreshToken = "gitea_refresh_token"
+	refreshed, err = p.RefreshSession(context.Background(), s)
+	assert.EqualError(t, err, "user is not authorized: not a member of the allowed organizations or teams and no access to the allowed repositories")
+	assert.False(t, refreshed)
+}
@@ -19,11 +19,25 @@ const (
 	gitlabProjectPrefix = "project:"
 )
 
+// gitlabRoles are the roles of the members of GitLab groups, by access level
+// see https://docs.gitlab.com/ee/api/members.html#roles
+var gitlabRoles = []struct {
+	Name        string
+	AccessLevel int
+}{
+	{Name: "guest", AccessLevel: 10},
+	{Name: "reporter", AccessLevel: 20},
+	{Name: "developer", AccessLevel: 30},
+	{Name: "maintainer", AccessLevel: 40},
+	{Name: "owner", AccessLevel: 50},
+}
+
 // GitLabProvider represents a GitLab based Identity Provider
 type GitLabProvider struct {
 	*OIDCProvider
 
-	allowedProjects []*gitlabProject
+	allowedProjects   []*gitlabProject
+	allowedGroupRoles []*gitlabGroupRole
 	// Expose this for unit testing
 	oidcRefreshFunc func(context.Context, *sessions.SessionState) (bool, error)
 }
@@ -46,7 +60,9 @@ func NewGitLabProvider(p *ProviderData, opts options.Provider) (*GitLabProvider,
 		OIDCProvider:    oidcProvider,
 		oidcRefreshFunc: oidcProvider.RefreshSession,
 	}
-	provider.setAllowedGroups(opts.GitLabConfig.Group)
+	if err := provider.setAllowedGroups(opts.GitLabConfig.Group); err != nil {
+		return nil, fmt.Errorf("could not configure allowed groups: %v", err)
+	}
 
 	if err := provider.setAllowedProjects(opts.GitLabConfig.Projects); err != nil {
 		return nil, fmt.Errorf("could not configure allowed projects: %v", err)
@@ -55,6 +71,35 @@ func NewGitLabProvider(p *ProviderData, opts options.Provider) (*GitLabProvider,
 	return provider, nil
 }
 
+// setAllowedGroups adds Gitlab groups to the AllowedGroups list. Groups with
+// a minimum role are added as `group:role`, and tracked to do a members API
+// lookup during `EnrichSession`.
+func (p *GitLabProvider) setAllowedGroups(groups []string) error {
+	var plainGroups []string
+	var groupRoles []*gitlabGroupRole
+	for _, group := range groups {
+		if !strings.Contains(group, "=") {
+			plainGroups = append(plainGroups, group)
+			continue
+		}
+		gr, err := newGitlabGroupRole(group)
+		if err != nil {
+			return err
+		}
+		groupRoles = append(groupRoles, gr)
+	}
+
+	p.ProviderData.setAllowedGroups(plainGroups)
+	for _, gr := range groupRoles {
+		p.allowedGroupRoles = append(p.allowedGroupRoles, gr)
+		p.AllowedGroups[formatGroupRole(gr.Name, gr.AccessLevel)] = struct{}{}
+	}
+	if len(p.allowedGroupRoles) > 0 {
+		p.setProjectScope()
+	}
+	return nil
+}
+
 // setAllowedProjects adds Gitlab projects to the AllowedGroups list
 // and tracks them to do a project API lookup during `EnrichSession`.
 func (p *GitLabProvider) setAllowedProjects(projects []string) error {
@@ -109,7 +154,43 @@ func newGitlabProject(project string) (*gitlabProject, error) {
 	}, nil
 }
 
-// setProjectScope ensures read_api is added to scope when filtering on projects
+// gitlabGroupRole represents a Gitlab group constraint entity with a minimum role
+type gitlabGroupRole struct {
+	Name        string
+	AccessLevel int
+}
+
+// newGitlabGroupRole Creates a new gitlabGroupRole struct from group string
+// formatted as `group=role`, where the role is the name of a role or its
+// access level
+func newGitlabGroupRole(group string) (*gitlabGroupRole, error) {
+	parts := strings.SplitN(group, "=", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid gitlab group role specified (%s)", group)
+	}
+	for _, role := range gitlabRoles {
+		if strings.EqualFold(parts[1], role.Name) || parts[1] == strconv.Itoa(role.AccessLevel) {
+			return &gitlabGroupRole{
+				Name:        parts[0],
+				AccessLevel: role.AccessLevel,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid gitlab group role specified (%s)", parts[1])
+}
+
+// formatGroupRole returns the `group:role` session group of a role
+func formatGroupRole(group string, accessLevel int) string {
+	for _, role := range gitlabRoles {
+		if role.AccessLevel == accessLevel {
+			return group + ":" + role.Name
+		}
+	}
+	return group + ":" + strconv.Itoa(accessLevel)
+}
+
+// setProjectScope ensures read_api is added to scope when filtering on
+// projects or group roles
 func (p *GitLabProvider) setProjectScope() {
 	for _, val := range strings.Split(p.Scope, " ") {
 		if val == "read_api" {
@@ -145,11 +226,14 @@ func (p *GitLabProvider) EnrichSession(ctx context.Context, s *sessions.SessionS
 
 	// Add projects as `project:blah` to s.Groups
 	p.addProjectsToSession(ctx, s)
+	// Add group roles as `group:role` to s.Groups
+	p.addGroupRolesToSession(ctx, s, userinfo.Subject)
 
 	return nil
 }
 
 type gitlabUserinfo struct {
+	Subject       string   `json:"sub"`
 	Nickname      string   `json:"nickname"`
 	Email         string   `json:"email"`
 	EmailVerified bool     `json:"email_verified"`
@@ -219,6 +303,72 @@ func (p *GitLabProvider) addProjectsToSession(ctx context.Context, s *sessions.S
 	}
 }
 
+// addGroupRolesToSession adds the roles of the user in the groups with a
+// minimum role into the session state groups list, formatted as `group:role`.
+// A role implies the lower roles, so that a maintainer of `group` is also
+// given `group:guest`, `group:reporter` and `group:developer`.
+func (p *GitLabProvider) addGroupRolesToSession(ctx context.Context, s *sessions.SessionState, userID string) {
+	checked := make(map[string]struct{})
+	for _, group := range p.allowedGroupRoles {
+		if _, ok := checked[group.Name]; ok {
+			continue
+		}
+		checked[group.Name] = struct{}{}
+
+		member, err := p.getGroupMember(ctx, s, group.Name, userID)
+		if err != nil {
+			logger.Errorf("Warning: group member request failed: %v", err)
+			continue
+		}
+
+		if member.AccessLevel < group.AccessLevel {
+			logger.Errorf(
+				"Warning: user %q does not have the minimum required role for group %q",
+				s.Email,
+				group.Name,
+			)
+		}
+
+		for _, role := range gitlabRoles {
+			if role.AccessLevel <= member.AccessLevel {
+				s.Groups = append(s.Groups, formatGroupRole(group.Name, role.AccessLevel))
+			}
+		}
+	}
+}
+
+type gitlabGroupMember struct {
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	AccessLevel int    `json:"access_level"`
+}
+
+// getGroupMember returns the membership of the user in the group, including
+// the memberships inherited from parent groups.
+func (p *GitLabProvider) getGroupMember(ctx context.Context, s *sessions.SessionState, group, userID string) (*gitlabGroupMember, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("failed to get member of group %s: unknown user ID", group)
+	}
+
+	endpointURL := &url.URL{
+		Scheme: p.LoginURL.Scheme,
+		Host:   p.LoginURL.Host,
+		Path:   "/api/v4/groups/",
+	}
+
+	var member gitlabGroupMember
+	err := requests.New(fmt.Sprintf("%s%s/members/all/%s", endpointURL.String(), url.PathEscape(group), url.PathEscape(userID))).
+		WithContext(ctx).
+		SetHeader("Authorization", tokenTypeBearer+" "+s.AccessToken).
+		Do().
+		UnmarshalInto(&member)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member of group %s: %v", group, err)
+	}
+
+	return &member, nil
+}
+
 type gitlabPermissionAccess struct {
 	AccessLevel int `json:"access_level"`
 }
@@ -262,6 +412,8 @@ func formatProject(project *gitlabProject) string {
 
 // RefreshSession refreshes the session with the OIDCProvider implementation
 // but preserves the custom GitLab projects added in the `EnrichSession` stage.
+// When projects or group roles are allowed, they are checked again instead,
+// so that users who lost them lose access without logging out.
 func (p *GitLabProvider) RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
 	nickname := s.User
 	projects := getSessionProjects(s)
@@ -270,12 +422,54 @@ func (p *GitLabProvider) RefreshSession(ctx context.Context, s *sessions.Session
 	refreshed, err := p.oidcRefreshFunc(ctx, s)
 	if refreshed && err == nil {
 		s.User = nickname
-		s.Groups = append(s.Groups, projects...)
+		if len(p.allowedProjects) == 0 && len(p.allowedGroupRoles) == 0 {
+			s.Groups = append(s.Groups, projects...)
+		} else if err := p.recheckMemberships(ctx, s); err != nil {
+			return refreshed, err
+		}
 		s.Groups = deduplicateGroups(s.Groups)
 	}
 	return refreshed, err
 }
 
+// recheckMemberships adds the projects and group roles of the user again
+// with the refreshed access token. The previous projects and group roles are
+// removed first, as a refresh without an ID token leaves the groups of the
+// session unchanged.
+func (p *GitLabProvider) recheckMemberships(ctx context.Context, s *sessions.SessionState) error {
+	s.Groups = p.withoutMemberships(s.Groups)
+	p.addProjectsToSession(ctx, s)
+
+	if len(p.allowedGroupRoles) > 0 {
+		userinfo, err := p.getUserinfo(ctx, s)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve user info: %v", err)
+		}
+		p.addGroupRolesToSession(ctx, s, userinfo.Subject)
+	}
+	return nil
+}
+
+// withoutMemberships returns the groups without the projects and the roles in
+// the groups with a minimum role, which are added by EnrichSession.
+func (p *GitLabProvider) withoutMemberships(groups []string) []string {
+	groupRoles := make(map[string]struct{})
+	for _, group := range p.allowedGroupRoles {
+		for _, role := range gitlabRoles {
+			groupRoles[formatGroupRole(group.Name, role.AccessLevel)] = struct{}{}
+		}
+	}
+
+	var kept []string
+	for _, group := range groups {
+		if _, ok := groupRoles[group]; ok || strings.HasPrefix(group, gitlabProjectPrefix) {
+			continue
+		}
+		kept = append(kept, group)
+	}
+	return kept
+}
+
 func getSessionProjects(s *sessions.SessionState) []string {
 	var projects []string
 	for _, group := range s.Groups {
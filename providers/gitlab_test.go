@@ -39,6 +39,7 @@ func testGitLabProvider(hostname, scope string, opts options.Provider) (*GitLabP
 func testGitLabBackend() *httptest.Server {
 	userInfo := `
 		{
+			"sub": "42",
 			"nickname": "FooBar",
 			"email": "foo@bar.com",
 			"email_verified": false,
@@ -103,6 +104,14 @@ func testGitLabBackend() *httptest.Server {
 		}
 	`
 
+	groupMember := `
+		{
+			"id": 42,
+			"username": "FooBar",
+			"access_level": 30
+		}
+	`
+
 	authHeader := "Bearer gitlab_access_token"
 
 	return httptest.NewServer(http.HandlerFunc(
@@ -143,6 +152,13 @@ func testGitLabBackend() *httptest.Server {
 				} else {
 					w.WriteHeader(401)
 				}
+			case "/api/v4/groups/my_group/members/all/42":
+				if r.Header["Authorization"][0] == authHeader {
+					w.WriteHeader(200)
+					w.Write([]byte(groupMember))
+				} else {
+					w.WriteHeader(401)
+				}
 			case "/api/v4/projects/my_group/my_bad_project":
 				w.WriteHeader(403)
 			default:
@@ -312,6 +328,41 @@ var _ = Describe("Gitlab Provider Tests", func() {
 				expectedGroups:  []string{"foo", "bar", "project:my_group/my_project", "project:my_profile/my_personal_project"},
 				expectedScope:   "openid email read_api",
 			}),
+			Entry("group role valid", entitiesTableInput{
+				allowedGroups:  []string{"my_group=developer"},
+				expectedAuthz:  true,
+				expectedGroups: []string{"foo", "bar", "my_group:guest", "my_group:reporter", "my_group:developer"},
+				expectedScope:  "openid email read_api",
+			}),
+			Entry("group role valid with an access level", entitiesTableInput{
+				allowedGroups:  []string{"my_group=30"},
+				expectedAuthz:  true,
+				expectedGroups: []string{"foo", "bar", "my_group:guest", "my_group:reporter", "my_group:developer"},
+				expectedScope:  "openid email read_api",
+			}),
+			Entry("group role invalid, insufficient role", entitiesTableInput{
+				allowedGroups:  []string{"my_group=Maintainer"},
+				expectedAuthz:  false,
+				expectedGroups: []string{"foo", "bar", "my_group:guest", "my_group:reporter", "my_group:developer"},
+				expectedScope:  "openid email read_api",
+			}),
+			Entry("group role invalid, not a member", entitiesTableInput{
+				allowedGroups:  []string{"other_group=guest"},
+				expectedAuthz:  false,
+				expectedGroups: []string{"foo", "bar"},
+				expectedScope:  "openid email read_api",
+			}),
+			Entry("groups and group roles", entitiesTableInput{
+				allowedGroups:  []string{"baz", "my_group=reporter"},
+				expectedAuthz:  true,
+				expectedGroups: []string{"foo", "bar", "my_group:guest", "my_group:reporter", "my_group:developer"},
+				expectedScope:  "openid email read_api",
+			}),
+			Entry("invalid group role format", entitiesTableInput{
+				allowedGroups: []string{"my_group=admin"},
+				expectedError: errors.New("could not configure allowed groups: invalid gitlab group role specified (admin)"),
+				expectedScope: "openid email read_api",
+			}),
 			Entry("archived projects", entitiesTableInput{
 				allowedProjects: []string{"my_group/my_archived_project"},
 				expectedAuthz:   false,
@@ -356,6 +407,63 @@ var _ = Describe("Gitlab Provider Tests", func() {
 			Expect(session.Groups).
 				To(ContainElements([]string{"baz", "project:thing", "project:sample"}))
 		})
+		It("checks projects and group roles again after refreshing", func() {
+			bURL, err := url.Parse(b.URL)
+			Expect(err).ToNot(HaveOccurred())
+
+			p, err := testGitLabProvider(bURL.Host, "", options.Provider{
+				GitLabConfig: options.GitLabOptions{
+					Group:    []string{"my_group=maintainer"},
+					Projects: []string{"my_group/my_project", "my_group/my_bad_project"},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			// The user was a maintainer of the group and a member of both projects
+			session := &sessions.SessionState{AccessToken: "gitlab_access_token"}
+			session.Groups = []string{"foo", "project:my_group/my_project", "project:my_group/my_bad_project",
+				"my_group:guest", "my_group:reporter", "my_group:developer", "my_group:maintainer"}
+
+			p.oidcRefreshFunc = func(_ context.Context, s *sessions.SessionState) (bool, error) {
+				s.Groups = []string{"foo"}
+				return true, nil
+			}
+
+			refreshed, err := p.RefreshSession(context.Background(), session)
+			Expect(refreshed).To(BeTrue())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session.Groups).To(ConsistOf("foo", "project:my_group/my_project",
+				"my_group:guest", "my_group:reporter", "my_group:developer"))
+			Expect(session.Groups).ToNot(ContainElement("my_group:maintainer"))
+		})
+		It("removes projects and group roles that are no longer found after refreshing without an ID token", func() {
+			bURL, err := url.Parse(b.URL)
+			Expect(err).ToNot(HaveOccurred())
+
+			p, err := testGitLabProvider(bURL.Host, "", options.Provider{
+				GitLabConfig: options.GitLabOptions{
+					Group:    []string{"my_group=maintainer"},
+					Projects: []string{"my_group/my_project", "my_group/my_bad_project"},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			// The user was a maintainer of the group and a member of both projects
+			session := &sessions.SessionState{AccessToken: "gitlab_access_token"}
+			session.Groups = []string{"foo", "project:my_group/my_project", "project:my_group/my_bad_project",
+				"my_group:guest", "my_group:reporter", "my_group:developer", "my_group:maintainer"}
+
+			// The refresh response has no ID token, so the groups are unchanged
+			p.oidcRefreshFunc = func(_ context.Context, s *sessions.SessionState) (bool, error) {
+				return true, nil
+			}
+
+			refreshed, err := p.RefreshSession(context.Background(), session)
+			Expect(refreshed).To(BeTrue())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session.Groups).To(ConsistOf("foo", "project:my_group/my_project",
+				"my_group:guest", "my_group:reporter", "my_group:developer"))
+		})
 		It("leaves existing groups when not refreshed", func() {
 			session := &sessions.SessionState{}
 			session.Groups = []string{"foo", "bar", "project:thing", "project:sample"}
@@ -1,7 +1,6 @@
 package providers
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -18,7 +17,6 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
-	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	admin "google.golang.org/api/admin/directory/v1"
@@ -144,15 +142,9 @@ func (p *GoogleProvider) Redeem(ctx context.Context, redirectURL, code, codeVeri
 	if code == "" {
 		return nil, ErrMissingCode
 	}
-	clientSecret, err := p.GetClientSecret()
-	if err != nil {
-		return nil, err
-	}
 
 	params := url.Values{}
 	params.Add("redirect_uri", redirectURL)
-	params.Add("client_id", p.ClientID)
-	params.Add("client_secret", clientSecret)
 	params.Add("code", code)
 	params.Add("grant_type", "authorization_code")
 	if codeVerifier != "" {
@@ -166,13 +158,11 @@ func (p *GoogleProvider) Redeem(ctx context.Context, redirectURL, code, codeVeri
 		IDToken      string `json:"id_token"`
 	}
 
-	err = requests.New(p.RedeemURL.String()).
-		WithContext(ctx).
-		WithMethod("POST").
-		WithBody(bytes.NewBufferString(params.Encode())).
-		SetHeader("Content-Type", "application/x-www-form-urlencoded").
-		Do().
-		UnmarshalInto(&jsonResponse)
+	req, err := p.newTokenRequest(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	err = req.Do().UnmarshalInto(&jsonResponse)
 	if err != nil {
 		return nil, err
 	}
@@ -357,14 +347,7 @@ func (p *GoogleProvider) RefreshSession(ctx context.Context, s *sessions.Session
 
 func (p *GoogleProvider) redeemRefreshToken(ctx context.Context, s *sessions.SessionState) error {
 	// https://developers.google.com/identity/protocols/OAuth2WebServer#refresh
-	clientSecret, err := p.GetClientSecret()
-	if err != nil {
-		return err
-	}
-
 	params := url.Values{}
-	params.Add("client_id", p.ClientID)
-	params.Add("client_secret", clientSecret)
 	params.Add("refresh_token", s.RefreshToken)
 	params.Add("grant_type", "refresh_token")
 
@@ -374,13 +357,11 @@ func (p *GoogleProvider) redeemRefreshToken(ctx context.Context, s *sessions.Ses
 		IDToken     string `json:"id_token"`
 	}
 
-	err = requests.New(p.RedeemURL.String()).
-		WithContext(ctx).
-		WithMethod("POST").
-		WithBody(bytes.NewBufferString(params.Encode())).
-		SetHeader("Content-Type", "application/x-www-form-urlencoded").
-		Do().
-		UnmarshalInto(&data)
+	req, err := p.newTokenRequest(ctx, params)
+	if err != nil {
+		return err
+	}
+	err = req.Do().UnmarshalInto(&data)
 	if err != nil {
 		return err
 	}
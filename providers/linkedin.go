@@ -6,25 +6,40 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 )
 
-// LinkedInProvider represents an LinkedIn based Identity Provider
+// LinkedInProvider represents a LinkedIn based Identity Provider, using
+// Sign In with LinkedIn using OpenID Connect
 type LinkedInProvider struct {
-	*ProviderData
+	*OIDCProvider
 }
 
 var _ Provider = (*LinkedInProvider)(nil)
 
+// LinkedInLegacyProvider represents a LinkedIn based Identity Provider, using
+// the retired profile API still granted to older LinkedIn apps
+type LinkedInLegacyProvider struct {
+	*ProviderData
+}
+
+var _ Provider = (*LinkedInLegacyProvider)(nil)
+
 const (
-	linkedinProviderName = "LinkedIn"
-	linkedinDefaultScope = "r_emailaddress r_liteprofile"
+	linkedinProviderName       = "LinkedIn"
+	linkedinDefaultScope       = "openid profile email"
+	linkedinLegacyDefaultScope = "r_emailaddress r_liteprofile"
+
+	// linkedinIssuerURL is the issuer of LinkedIn's ID tokens, used to
+	// discover LinkedIn's OpenID Connect endpoints and keys.
+	linkedinIssuerURL = "https://www.linkedin.com/oauth"
 )
 
 var (
 	// Default Login URL for LinkedIn.
-	// Pre-parsed URL of https://www.linkedin.com/uas/oauth2/authorization.
+	// Pre-parsed URL of https://www.linkedin.com/oauth/v2/authorization.
 	linkedinDefaultLoginURL = &url.URL{
 		Scheme: "https",
 		Host:   "www.linkedin.com",
@@ -32,23 +47,40 @@ var (
 	}
 
 	// Default Redeem URL for LinkedIn.
-	// Pre-parsed URL of https://www.linkedin.com/uas/oauth2/accessToken.
+	// Pre-parsed URL of https://www.linkedin.com/oauth/v2/accessToken.
 	linkedinDefaultRedeemURL = &url.URL{
 		Scheme: "https",
 		Host:   "www.linkedin.com",
-		Path:   "/uas/oauth2/accessToken",
+		Path:   "/oauth/v2/accessToken",
 	}
 
-	// Default Profile URL for LinkedIn.
-	// Pre-parsed URL of https://www.linkedin.com/v1/people/~/email-address.
+	// Default Profile and Validate URL for LinkedIn.
+	// Pre-parsed URL of https://api.linkedin.com/v2/userinfo.
 	linkedinDefaultProfileURL = &url.URL{
+		Scheme: "https",
+		Host:   "api.linkedin.com",
+		Path:   "/v2/userinfo",
+	}
+
+	// Default Redeem URL for legacy LinkedIn apps.
+	// Pre-parsed URL of https://www.linkedin.com/uas/oauth2/accessToken.
+	linkedinLegacyDefaultRedeemURL = &url.URL{
+		Scheme: "https",
+		Host:   "www.linkedin.com",
+		Path:   "/uas/oauth2/accessToken",
+	}
+
+	// Default Profile URL for legacy LinkedIn apps.
+	// Pre-parsed URL of https://api.linkedin.com/v2/emailAddress.
+	linkedinLegacyDefaultProfileURL = &url.URL{
 		Scheme: "https",
 		Host:   "api.linkedin.com",
 		Path:   "/v2/emailAddress",
 	}
 
-	// Default Validate URL for LinkedIn.
-	linkedinDefaultValidateURL = &url.URL{
+	// Default Validate URL for legacy LinkedIn apps.
+	// Pre-parsed URL of https://api.linkedin.com/v2/me.
+	linkedinLegacyDefaultValidateURL = &url.URL{
 		Scheme: "https",
 		Host:   "api.linkedin.com",
 		Path:   "/v2/me",
@@ -56,18 +88,55 @@ var (
 )
 
 // NewLinkedInProvider initiates a new LinkedInProvider
-func NewLinkedInProvider(p *ProviderData) *LinkedInProvider {
+func NewLinkedInProvider(p *ProviderData, opts options.OIDCOptions) *LinkedInProvider {
 	p.setProviderDefaults(providerDefaults{
 		name:        linkedinProviderName,
 		loginURL:    linkedinDefaultLoginURL,
 		redeemURL:   linkedinDefaultRedeemURL,
 		profileURL:  linkedinDefaultProfileURL,
-		validateURL: linkedinDefaultValidateURL,
+		validateURL: linkedinDefaultProfileURL,
 		scope:       linkedinDefaultScope,
 	})
+
+	return &LinkedInProvider{OIDCProvider: NewOIDCProvider(p, opts)}
+}
+
+// EnrichSession sets the user's name as the preferred username, as LinkedIn
+// members don't have a username
+func (p *LinkedInProvider) EnrichSession(ctx context.Context, s *sessions.SessionState) error {
+	if s.PreferredUsername == "" && s.IDToken != "" {
+		extractor, err := p.getClaimExtractor(s.IDToken, s.AccessToken)
+		if err != nil {
+			return err
+		}
+		if _, err := extractor.GetClaimInto("name", &s.PreferredUsername); err != nil {
+			return err
+		}
+	}
+
+	return p.OIDCProvider.EnrichSession(ctx, s)
+}
+
+// ValidateSession validates the AccessToken against the userinfo endpoint.
+// LinkedIn access tokens outlive the ID token, and are usually issued without
+// a refresh token.
+func (p *LinkedInProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
+	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
+}
+
+// NewLinkedInLegacyProvider initiates a new LinkedInLegacyProvider
+func NewLinkedInLegacyProvider(p *ProviderData) *LinkedInLegacyProvider {
+	p.setProviderDefaults(providerDefaults{
+		name:        linkedinProviderName,
+		loginURL:    linkedinDefaultLoginURL,
+		redeemURL:   linkedinLegacyDefaultRedeemURL,
+		profileURL:  linkedinLegacyDefaultProfileURL,
+		validateURL: linkedinLegacyDefaultValidateURL,
+		scope:       linkedinLegacyDefaultScope,
+	})
 	p.getAuthorizationHeaderFunc = makeLinkedInHeader
 
-	return &LinkedInProvider{ProviderData: p}
+	return &LinkedInLegacyProvider{ProviderData: p}
 }
 
 func makeLinkedInHeader(accessToken string) http.Header {
@@ -80,7 +149,7 @@ func makeLinkedInHeader(accessToken string) http.Header {
 }
 
 // GetEmailAddress returns the Account email address
-func (p *LinkedInProvider) GetEmailAddress(ctx context.Context, s *sessions.SessionState) (string, error) {
+func (p *LinkedInLegacyProvider) GetEmailAddress(ctx context.Context, s *sessions.SessionState) (string, error) {
 	if s.AccessToken == "" {
 		return "", errors.New("missing access token")
 	}
@@ -102,6 +171,6 @@ func (p *LinkedInProvider) GetEmailAddress(ctx context.Context, s *sessions.Sess
 }
 
 // ValidateSession validates the AccessToken
-func (p *LinkedInProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
+func (p *LinkedInLegacyProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
 	return validateToken(ctx, p, s.AccessToken, makeLinkedInHeader(s.AccessToken))
 }
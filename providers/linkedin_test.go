@@ -2,18 +2,22 @@ package providers
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
 
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/assert"
 )
 
-func testLinkedInProvider(hostname string) *LinkedInProvider {
-	p := NewLinkedInProvider(
+func testLinkedInProvider(hostname string) *LinkedInLegacyProvider {
+	p := NewLinkedInLegacyProvider(
 		&ProviderData{
 			ProviderName: "",
 			LoginURL:     &url.URL{},
@@ -49,7 +53,98 @@ func TestNewLinkedInProvider(t *testing.T) {
 	g := NewWithT(t)
 
 	// Test that defaults are set when calling for a new provider with nothing set
-	providerData := NewLinkedInProvider(&ProviderData{}).Data()
+	providerData := NewLinkedInProvider(&ProviderData{}, options.OIDCOptions{}).Data()
+	g.Expect(providerData.ProviderName).To(Equal("LinkedIn"))
+	g.Expect(providerData.LoginURL.String()).To(Equal("https://www.linkedin.com/oauth/v2/authorization"))
+	g.Expect(providerData.RedeemURL.String()).To(Equal("https://www.linkedin.com/oauth/v2/accessToken"))
+	g.Expect(providerData.ProfileURL.String()).To(Equal("https://api.linkedin.com/v2/userinfo"))
+	g.Expect(providerData.ValidateURL.String()).To(Equal("https://api.linkedin.com/v2/userinfo"))
+	g.Expect(providerData.Scope).To(Equal("openid profile email"))
+}
+
+func TestLinkedInProviderRequiresOIDCProviderVerifier(t *testing.T) {
+	g := NewWithT(t)
+
+	needsVerifier, err := providerRequiresOIDCProviderVerifier(options.Provider{Type: options.LinkedInProvider})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(needsVerifier).To(BeTrue())
+
+	needsVerifier, err = providerRequiresOIDCProviderVerifier(options.Provider{
+		Type:           options.LinkedInProvider,
+		LinkedInConfig: options.LinkedInOptions{Legacy: true},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(needsVerifier).To(BeFalse())
+}
+
+func TestLinkedInProviderRedeem(t *testing.T) {
+	g := NewWithT(t)
+
+	// LinkedIn's ID token has no `name` claim in this test, so the name is
+	// read from the userinfo endpoint.
+	rawIDToken, err := newSignedTestIDToken(idTokenClaims{
+		Email:            "janed@me.com",
+		Verified:         &verified,
+		RegisteredClaims: registeredClaims,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	body, err := json.Marshal(redeemTokenResponse{
+		AccessToken: accessToken,
+		ExpiresIn:   3600,
+		TokenType:   "Bearer",
+		IDToken:     rawIDToken,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	b := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/oauth/v2/accessToken":
+			rw.Write(body)
+		case "/v2/userinfo":
+			if r.Header.Get("Authorization") != "Bearer "+accessToken {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			rw.Write([]byte(`{"sub":"123456789","name":"Jane Dobbs","email":"janed@me.com","email_verified":true}`))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer b.Close()
+	bURL, _ := url.Parse(b.URL)
+
+	p := NewLinkedInProvider(&ProviderData{
+		ClientID:     oidcClientID,
+		ClientSecret: oidcSecret,
+		EmailClaim:   options.OIDCEmailClaim,
+		RedeemURL:    &url.URL{Scheme: bURL.Scheme, Host: bURL.Host, Path: "/oauth/v2/accessToken"},
+		ProfileURL:   &url.URL{Scheme: bURL.Scheme, Host: bURL.Host, Path: "/v2/userinfo"},
+		ValidateURL:  &url.URL{Scheme: bURL.Scheme, Host: bURL.Host, Path: "/v2/userinfo"},
+		Verifier: internaloidc.NewVerifier(oidc.NewVerifier(
+			oidcIssuer,
+			mockJWKS{},
+			&oidc.Config{ClientID: oidcClientID},
+		), internaloidc.IDTokenVerificationOptions{AudienceClaims: []string{"aud"}, ClientID: oidcClientID}),
+	}, options.OIDCOptions{InsecureSkipNonce: true})
+
+	s, err := p.Redeem(context.Background(), "https://proxy.example.com/oauth2/callback", "code1234", "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.EnrichSession(context.Background(), s)).To(Succeed())
+	g.Expect(s.User).To(Equal("123456789"))
+	g.Expect(s.Email).To(Equal("janed@me.com"))
+	g.Expect(s.PreferredUsername).To(Equal("Jane Dobbs"))
+	g.Expect(s.IDToken).To(Equal(rawIDToken))
+
+	g.Expect(p.ValidateSession(context.Background(), s)).To(BeTrue())
+	g.Expect(p.ValidateSession(context.Background(), &sessions.SessionState{AccessToken: "invalid"})).To(BeFalse())
+}
+
+func TestNewLinkedInLegacyProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	// Test that defaults are set when calling for a new provider with nothing set
+	providerData := NewLinkedInLegacyProvider(&ProviderData{}).Data()
 	g.Expect(providerData.ProviderName).To(Equal("LinkedIn"))
 	g.Expect(providerData.LoginURL.String()).To(Equal("https://www.linkedin.com/oauth/v2/authorization"))
 	g.Expect(providerData.RedeemURL.String()).To(Equal("https://www.linkedin.com/uas/oauth2/accessToken"))
@@ -59,7 +154,7 @@ func TestNewLinkedInProvider(t *testing.T) {
 }
 
 func TestLinkedInProviderOverrides(t *testing.T) {
-	p := NewLinkedInProvider(
+	p := NewLinkedInLegacyProvider(
 		&ProviderData{
 			LoginURL: &url.URL{
 				Scheme: "https",
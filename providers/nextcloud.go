@@ -2,7 +2,11 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
@@ -13,14 +17,26 @@ import (
 // NextcloudProvider represents an Nextcloud based Identity Provider
 type NextcloudProvider struct {
 	*ProviderData
+
+	AdminOnly                bool
+	ResolveGroupDisplayNames bool
 }
 
 var _ Provider = (*NextcloudProvider)(nil)
 
-const nextCloudProviderName = "Nextcloud"
+const (
+	nextCloudProviderName = "Nextcloud"
+
+	// nextcloudAdminGroup is the ID of the group of Nextcloud administrators
+	nextcloudAdminGroup = "admin"
+
+	// nextcloudUserPath is the path of the OCS endpoint of the current user,
+	// relative to the OCS API root.
+	nextcloudUserPath = "/cloud/user"
+)
 
 // NewNextcloudProvider initiates a new NextcloudProvider
-func NewNextcloudProvider(p *ProviderData) *NextcloudProvider {
+func NewNextcloudProvider(p *ProviderData, opts options.NextcloudOptions) *NextcloudProvider {
 	p.setProviderDefaults(providerDefaults{
 		name: nextCloudProviderName,
 	})
@@ -31,53 +47,187 @@ func NewNextcloudProvider(p *ProviderData) *NextcloudProvider {
 		// for this provider
 		p.EmailClaim = "ocs.data.email"
 	}
-	return &NextcloudProvider{ProviderData: p}
+
+	provider := &NextcloudProvider{
+		ProviderData:             p,
+		AdminOnly:                opts.AdminOnly,
+		ResolveGroupDisplayNames: opts.ResolveGroupDisplayNames,
+	}
+	provider.setAllowedGroups(opts.Groups)
+	return provider
+}
+
+// Redeem exchanges the OAuth2 authentication code for an access token,
+// keeping the refresh token and expiry of the response.
+func (p *NextcloudProvider) Redeem(ctx context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
+	return p.redeemOAuth2Code(ctx, redirectURL, code, codeVerifier)
 }
 
 // EnrichSession uses the Nextcloud userinfo endpoint to populate
-// the session's email, user, and groups.
+// the session's email, user, groups and quota.
 func (p *NextcloudProvider) EnrichSession(ctx context.Context, s *sessions.SessionState) error {
-	// Fallback to ValidateURL if ProfileURL not set for legacy compatibility
-	profileURL := p.ValidateURL.String()
-	if p.ProfileURL.String() != "" {
-		profileURL = p.ProfileURL.String()
+	// https://docs.nextcloud.com/server/latest/developer_manual/client_apis/OCS/ocs-api-overview.html#user-metadata
+	var userinfo struct {
+		OCS struct {
+			Data struct {
+				ID     string                 `json:"id"`
+				Email  string                 `json:"email"`
+				Groups []string               `json:"groups"`
+				Quota  map[string]interface{} `json:"quota"`
+			} `json:"data"`
+		} `json:"ocs"`
 	}
 
-	json, err := requests.New(profileURL).
+	err := requests.New(p.userinfoURL().String()).
 		WithContext(ctx).
 		SetHeader("Authorization", tokenTypeBearer+" "+s.AccessToken).
 		Do().
-		UnmarshalSimpleJSON()
+		UnmarshalInto(&userinfo)
 	if err != nil {
 		logger.Errorf("failed making request %v", err)
 		return err
 	}
 
-	groups, err := json.GetPath("ocs", "data", "groups").StringArray()
-	if err == nil {
-		for _, group := range groups {
-			if group != "" {
-				s.Groups = append(s.Groups, group)
-			}
+	data := userinfo.OCS.Data
+	if data.ID == "" {
+		return errors.New("unable to extract id from userinfo endpoint")
+	}
+	s.User = data.ID
+	if data.Email == "" {
+		return errors.New("unable to extract email from userinfo endpoint")
+	}
+	s.Email = data.Email
+
+	for _, group := range data.Groups {
+		if group != "" {
+			s.Groups = append(s.Groups, group)
+		}
+	}
+	if p.ResolveGroupDisplayNames {
+		if err := p.addGroupDisplayNames(ctx, s); err != nil {
+			return err
 		}
 	}
 
-	user, err := json.GetPath("ocs", "data", "id").String()
-	if err != nil {
-		return fmt.Errorf("unable to extract id from userinfo endpoint: %v", err)
+	setNextcloudQuotaClaims(s, data.Quota)
+	return nil
+}
+
+// userinfoURL returns the ProfileURL, falling back to the ValidateURL if the
+// ProfileURL is not set for legacy compatibility
+func (p *NextcloudProvider) userinfoURL() *url.URL {
+	if p.ProfileURL.String() != "" {
+		return p.ProfileURL
+	}
+	return p.ValidateURL
+}
+
+// addGroupDisplayNames adds the display names of the user's groups to the
+// SessionState Groups, next to their IDs, so that groups can be allowed by
+// either.
+func (p *NextcloudProvider) addGroupDisplayNames(ctx context.Context, s *sessions.SessionState) error {
+	// https://docs.nextcloud.com/server/latest/admin_manual/configuration_user/instruction_set_for_users.html
+	var details struct {
+		OCS struct {
+			Data struct {
+				Groups []struct {
+					ID          string `json:"id"`
+					DisplayName string `json:"displayname"`
+				} `json:"groups"`
+			} `json:"data"`
+		} `json:"ocs"`
 	}
-	s.User = user
 
-	email, err := json.GetPath("ocs", "data", "email").String()
+	userURL := p.userinfoURL()
+	if !strings.HasSuffix(userURL.Path, nextcloudUserPath) {
+		return fmt.Errorf("unable to resolve group display names: %q is not the OCS user endpoint", userURL.String())
+	}
+	detailsURL := *userURL
+	detailsURL.Path = strings.TrimSuffix(userURL.Path, nextcloudUserPath) + "/cloud/users/" + url.PathEscape(s.User) + "/groups/details"
+	detailsURL.RawPath = ""
+
+	err := requests.New(detailsURL.String()).
+		WithContext(ctx).
+		SetHeader("Authorization", tokenTypeBearer+" "+s.AccessToken).
+		Do().
+		UnmarshalInto(&details)
 	if err != nil {
-		return fmt.Errorf("unable to extract email from userinfo endpoint: %v", err)
+		return fmt.Errorf("unable to fetch group display names: %v", err)
 	}
-	s.Email = email
 
+	for _, group := range details.OCS.Data.Groups {
+		// A group named after the admin group must not grant AdminOnly access
+		if group.DisplayName == nextcloudAdminGroup {
+			continue
+		}
+		if group.DisplayName != "" && group.DisplayName != group.ID {
+			s.Groups = append(s.Groups, group.DisplayName)
+		}
+	}
 	return nil
 }
 
+// setNextcloudQuotaClaims sets the storage quota of the user as the
+// `quota_used`, `quota_total` and `quota_relative` additional claims.
+func setNextcloudQuotaClaims(s *sessions.SessionState, quota map[string]interface{}) {
+	for _, field := range []string{"used", "total", "relative"} {
+		var value string
+		switch v := quota[field].(type) {
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			value = v
+		default:
+			continue
+		}
+
+		if s.AdditionalClaims == nil {
+			s.AdditionalClaims = map[string]string{}
+		}
+		s.AdditionalClaims["quota_"+field] = value
+	}
+}
+
+// Authorize requires the user to be a Nextcloud administrator when AdminOnly
+// is set, in addition to the allowed groups.
+func (p *NextcloudProvider) Authorize(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	if p.AdminOnly {
+		isAdmin := false
+		for _, group := range s.Groups {
+			if group == nextcloudAdminGroup {
+				isAdmin = true
+				break
+			}
+		}
+		if !isAdmin {
+			return false, nil
+		}
+	}
+
+	return p.ProviderData.Authorize(ctx, s)
+}
+
 // ValidateSession validates the AccessToken
 func (p *NextcloudProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
 	return validateToken(ctx, p, s.AccessToken, makeOIDCHeader(s.AccessToken))
 }
+
+// RefreshSession uses the RefreshToken to fetch a new access token, then
+// reloads the user's groups so that the allowed groups are checked against
+// the current memberships.
+func (p *NextcloudProvider) RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	if s == nil || s.RefreshToken == "" {
+		return false, nil
+	}
+
+	if err := p.redeemOAuth2RefreshToken(ctx, s); err != nil {
+		return false, fmt.Errorf("unable to redeem refresh token: %w", err)
+	}
+
+	s.Groups = nil
+	if err := p.EnrichSession(ctx, s); err != nil {
+		return false, fmt.Errorf("unable to enrich refreshed session: %w", err)
+	}
+
+	return true, nil
+}
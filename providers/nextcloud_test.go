@@ -1,9 +1,14 @@
 package providers
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,7 +22,8 @@ func testNextcloudProvider(hostname string) *NextcloudProvider {
 			RedeemURL:    &url.URL{},
 			ProfileURL:   &url.URL{},
 			ValidateURL:  &url.URL{},
-			Scope:        ""})
+			Scope:        ""},
+		options.NextcloudOptions{})
 	if hostname != "" {
 		updateURL(p.Data().LoginURL, hostname)
 		updateURL(p.Data().RedeemURL, hostname)
@@ -55,7 +61,8 @@ func TestNextcloudProviderOverrides(t *testing.T) {
 				Host:     "example.com",
 				Path:     "/test/ocs/v2.php/cloud/user",
 				RawQuery: formatJSON},
-			Scope: "profile"})
+			Scope: "profile"},
+		options.NextcloudOptions{})
 	assert.NotEqual(t, nil, p)
 	assert.Equal(t, "Nextcloud", p.Data().ProviderName)
 	assert.Equal(t, "https://example.com/index.php/apps/oauth2/authorize",
@@ -65,3 +72,177 @@ func TestNextcloudProviderOverrides(t *testing.T) {
 	assert.Equal(t, "https://example.com/test/ocs/v2.php/cloud/user?"+formatJSON,
 		p.Data().ValidateURL.String())
 }
+
+func testNextcloudBackendProvider(hostname string, opts options.NextcloudOptions) *NextcloudProvider {
+	return NewNextcloudProvider(
+		&ProviderData{
+			LoginURL:    &url.URL{Scheme: "http", Host: hostname, Path: "/index.php/apps/oauth2/authorize"},
+			RedeemURL:   &url.URL{Scheme: "http", Host: hostname, Path: "/index.php/apps/oauth2/api/v1/token"},
+			ProfileURL:  &url.URL{Scheme: "http", Host: hostname, Path: "/ocs/v2.php/cloud/user", RawQuery: formatJSON},
+			ValidateURL: &url.URL{Scheme: "http", Host: hostname, Path: "/ocs/v2.php/cloud/user", RawQuery: formatJSON},
+		},
+		opts)
+}
+
+func testNextcloudBackend(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/index.php/apps/oauth2/api/v1/token" {
+				assert.NoError(t, r.ParseForm())
+				if r.PostForm.Get("grant_type") == "refresh_token" {
+					assert.Equal(t, "nextcloud_refresh_token", r.PostForm.Get("refresh_token"))
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token":"` + authorizedAccessToken + `","token_type":"Bearer","expires_in":3600,"refresh_token":"nextcloud_refresh_token_2","user_id":"mbland"}`))
+				return
+			}
+
+			if !IsAuthorizedInHeader(r.Header) {
+				w.WriteHeader(401)
+				return
+			}
+			assert.Equal(t, formatJSON, r.URL.RawQuery)
+
+			switch r.URL.Path {
+			case "/ocs/v2.php/cloud/user":
+				w.Write([]byte(`{"ocs": {"meta": {"status": "ok"}, "data": {"id": "mbland", "email": "michael.bland@gsa.gov", "groups": ["admin", "eng"], "quota": {"free": 1024, "used": 512, "total": 1536, "relative": 33.33, "quota": -3}}}}`))
+			case "/ocs/v2.php/cloud/users/mbland/groups/details":
+				w.Write([]byte(`{"ocs": {"meta": {"status": "ok"}, "data": {"groups": [{"id": "admin", "displayname": "admin"}, {"id": "eng", "displayname": "Engineering"}]}}}`))
+			default:
+				w.WriteHeader(404)
+			}
+		}))
+}
+
+func TestNextcloudProviderEnrichSession(t *testing.T) {
+	b := testNextcloudBackend(t)
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testNextcloudBackendProvider(bURL.Host, options.NextcloudOptions{})
+
+	session := CreateAuthorizedSession()
+	err := p.EnrichSession(context.Background(), session)
+	assert.NoError(t, err)
+	assert.Equal(t, "mbland", session.User)
+	assert.Equal(t, "michael.bland@gsa.gov", session.Email)
+	assert.Equal(t, []string{"admin", "eng"}, session.Groups)
+	assert.Equal(t, map[string]string{
+		"quota_used":     "512",
+		"quota_total":    "1536",
+		"quota_relative": "33.33",
+	}, session.AdditionalClaims)
+}
+
+func TestNextcloudProviderEnrichSessionGroupDisplayNames(t *testing.T) {
+	b := testNextcloudBackend(t)
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testNextcloudBackendProvider(bURL.Host, options.NextcloudOptions{ResolveGroupDisplayNames: true})
+
+	session := CreateAuthorizedSession()
+	err := p.EnrichSession(context.Background(), session)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin", "eng", "Engineering"}, session.Groups)
+}
+
+func TestNextcloudProviderEnrichSessionFailedRequest(t *testing.T) {
+	b := testNextcloudBackend(t)
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testNextcloudBackendProvider(bURL.Host, options.NextcloudOptions{})
+
+	session := &sessions.SessionState{AccessToken: "unexpected_access_token"}
+	err := p.EnrichSession(context.Background(), session)
+	assert.Error(t, err)
+	assert.Equal(t, "", session.Email)
+}
+
+func TestNextcloudProviderAuthorize(t *testing.T) {
+	testCases := map[string]struct {
+		opts       options.NextcloudOptions
+		groups     []string
+		authorized bool
+	}{
+		"without restrictions": {
+			groups:     []string{"eng"},
+			authorized: true,
+		},
+		"member of an allowed group": {
+			opts:       options.NextcloudOptions{Groups: []string{"Engineering"}},
+			groups:     []string{"eng", "Engineering"},
+			authorized: true,
+		},
+		"not a member of an allowed group": {
+			opts:       options.NextcloudOptions{Groups: []string{"Sales"}},
+			groups:     []string{"eng", "Engineering"},
+			authorized: false,
+		},
+		"administrator with admin only": {
+			opts:       options.NextcloudOptions{AdminOnly: true},
+			groups:     []string{"admin"},
+			authorized: true,
+		},
+		"not an administrator with admin only": {
+			opts:       options.NextcloudOptions{AdminOnly: true},
+			groups:     []string{"eng"},
+			authorized: false,
+		},
+		"administrator not in an allowed group with admin only": {
+			opts:       options.NextcloudOptions{AdminOnly: true, Groups: []string{"eng"}},
+			groups:     []string{"admin"},
+			authorized: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p := testNextcloudBackendProvider("", tc.opts)
+
+			authorized, err := p.Authorize(context.Background(), &sessions.SessionState{Groups: tc.groups})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.authorized, authorized)
+		})
+	}
+}
+
+func TestNextcloudProviderRedeem(t *testing.T) {
+	b := testNextcloudBackend(t)
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testNextcloudBackendProvider(bURL.Host, options.NextcloudOptions{})
+
+	s, err := p.Redeem(context.Background(), "https://proxy.example.com/oauth2/callback", "nextcloud_code", "")
+	assert.NoError(t, err)
+	assert.Equal(t, authorizedAccessToken, s.AccessToken)
+	assert.Equal(t, "nextcloud_refresh_token_2", s.RefreshToken)
+	assert.NotNil(t, s.ExpiresOn)
+}
+
+func TestNextcloudProviderRefreshSession(t *testing.T) {
+	b := testNextcloudBackend(t)
+	defer b.Close()
+
+	bURL, _ := url.Parse(b.URL)
+	p := testNextcloudBackendProvider(bURL.Host, options.NextcloudOptions{})
+
+	refreshed, err := p.RefreshSession(context.Background(), &sessions.SessionState{})
+	assert.NoError(t, err)
+	assert.False(t, refreshed)
+
+	s := &sessions.SessionState{
+		User:         "mbland",
+		AccessToken:  "expired",
+		RefreshToken: "nextcloud_refresh_token",
+		Groups:       []string{"eng", "sales"},
+	}
+	refreshed, err = p.RefreshSession(context.Background(), s)
+	assert.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.Equal(t, authorizedAccessToken, s.AccessToken)
+	assert.Equal(t, "nextcloud_refresh_token_2", s.RefreshToken)
+	assert.Equal(t, []string{"admin", "eng"}, s.Groups)
+}
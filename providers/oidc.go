@@ -67,31 +67,22 @@ func (p *OIDCProvider) GetLoginURL(redirectURI, state, nonce string, extraParams
 
 // Redeem exchanges the OAuth2 authentication token for an ID token
 func (p *OIDCProvider) Redeem(ctx context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
-	clientSecret, err := p.GetClientSecret()
-	if err != nil {
-		return nil, err
-	}
-
 	var opts []oauth2.AuthCodeOption
 	if codeVerifier != "" {
 		opts = append(opts, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
 	}
 
-	c := oauth2.Config{
-		ClientID:     p.ClientID,
-		ClientSecret: clientSecret,
-		Endpoint: oauth2.Endpoint{
-			TokenURL: p.RedeemURL.String(),
-		},
-		RedirectURL: redirectURL,
+	tokenCtx, c, err := p.newOAuth2Config(ctx, redirectURL)
+	if err != nil {
+		return nil, err
 	}
-
-	ctx = oidc.ClientContext(ctx, requests.DefaultHTTPClient)
-	token, err := c.Exchange(ctx, code, opts...)
+	token, err := c.Exchange(tokenCtx, code, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("token exchange failed: %v", err)
 	}
 
+	ctx = oidc.ClientContext(ctx, requests.DefaultHTTPClient)
+
 	return p.createSession(ctx, token, false)
 }
 
@@ -135,7 +126,7 @@ func (p *OIDCProvider) RefreshSession(ctx context.Context, s *sessions.SessionSt
 	ctx = oidc.ClientContext(ctx, requests.DefaultHTTPClient)
 	err := p.redeemRefreshToken(ctx, s)
 	if err != nil {
-		return false, fmt.Errorf("unable to redeem refresh token: %v", err)
+		return false, fmt.Errorf("unable to redeem refresh token: %w", err)
 	}
 
 	return true, nil
@@ -144,30 +135,22 @@ func (p *OIDCProvider) RefreshSession(ctx context.Context, s *sessions.SessionSt
 // redeemRefreshToken uses a RefreshToken with the RedeemURL to refresh the
 // Access Token and (probably) the ID Token.
 func (p *OIDCProvider) redeemRefreshToken(ctx context.Context, s *sessions.SessionState) error {
-	clientSecret, err := p.GetClientSecret()
+	tokenCtx, c, err := p.newOAuth2Config(ctx, "")
 	if err != nil {
 		return err
 	}
-
-	c := oauth2.Config{
-		ClientID:     p.ClientID,
-		ClientSecret: clientSecret,
-		Endpoint: oauth2.Endpoint{
-			TokenURL: p.RedeemURL.String(),
-		},
-	}
 	t := &oauth2.Token{
 		RefreshToken: s.RefreshToken,
 		Expiry:       time.Now().Add(-time.Hour),
 	}
-	token, err := c.TokenSource(ctx, t).Token()
+	token, err := c.TokenSource(tokenCtx, t).Token()
 	if err != nil {
 		return fmt.Errorf("failed to get token: %v", err)
 	}
 
 	newSession, err := p.createSession(ctx, token, true)
 	if err != nil {
-		return fmt.Errorf("unable create new session state from response: %v", err)
+		return fmt.Errorf("unable create new session state from response: %w", err)
 	}
 
 	// It's possible that if the refresh token isn't in the token response the
@@ -179,6 +162,10 @@ func (p *OIDCProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sessi
 		s.User = newSession.User
 		s.Groups = newSession.Groups
 		s.PreferredUsername = newSession.PreferredUsername
+	} else if err := p.recheckRequiredClaims(s.IDToken, newSession.AccessToken); err != nil {
+		// The required claims of the retained ID Token and of the profile
+		// URL are checked again, as no new ID Token was checked
+		return err
 	}
 
 	s.AccessToken = newSession.AccessToken
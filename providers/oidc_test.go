@@ -180,6 +180,60 @@ func TestOIDCProviderRefreshSessionIfNeededWithoutIdToken(t *testing.T) {
 	assert.Equal(t, "11223344", existingSession.User)
 }
 
+func TestOIDCProviderRefreshSessionWithoutIdTokenRequiredClaims(t *testing.T) {
+	idToken, _ := newSignedTestIDToken(defaultIDToken)
+	body, _ := json.Marshal(redeemTokenResponse{
+		AccessToken:  accessToken,
+		ExpiresIn:    10,
+		TokenType:    "Bearer",
+		RefreshToken: refreshToken,
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Add("content-type", "application/json")
+		if r.URL.Path == "/profile" {
+			_, _ = rw.Write([]byte(`{"department":"sales"}`))
+			return
+		}
+		_, _ = rw.Write(body)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	testCases := map[string]struct {
+		values      []string
+		expectedErr error
+	}{
+		"when the profile claims still satisfy the required claims": {
+			values: []string{"sales"},
+		},
+		"when the profile claims no longer satisfy the required claims": {
+			values:      []string{"engineering"},
+			expectedErr: ErrRequiredClaimNotSatisfied,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			provider := newOIDCProvider(serverURL, false)
+			assert.Empty(t, provider.compileRequiredClaims([]options.RequiredClaim{{Claim: "department", Values: tc.values}}))
+
+			existingSession := &sessions.SessionState{
+				AccessToken:  "changeit",
+				IDToken:      idToken,
+				RefreshToken: refreshToken,
+			}
+			refreshed, err := provider.RefreshSession(context.Background(), existingSession)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.False(t, refreshed)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, refreshed)
+		})
+	}
+}
+
 func TestOIDCProviderRefreshSessionIfNeededWithIdToken(t *testing.T) {
 
 	idToken, _ := newSignedTestIDToken(defaultIDToken)
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"crypto"
 	"errors"
 	"fmt"
 	"net/http"
@@ -9,8 +10,11 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
@@ -37,6 +41,14 @@ type ProviderData struct {
 	ClientSecret      string
 	ClientSecretFile  string
 	Scope             string
+	// How the client authenticates to the token endpoint, or empty for the
+	// default of the provider
+	TokenEndpointAuthMethod options.TokenEndpointAuthMethod
+	// The kid of the client assertion key, if any
+	ClientAssertionKeyID string
+	// The pushed authorization request endpoint, and whether it is used
+	PushedAuthorizationRequestURL *url.URL
+	PushedAuthorizationRequests   options.PushedAuthorizationRequestsMode
 	// The picked CodeChallenge Method or empty if none.
 	CodeChallengeMethod string
 	// Code challenge methods supported by the Provider
@@ -55,7 +67,22 @@ type ProviderData struct {
 	// any provider can set to consume
 	AllowedGroups map[string]struct{}
 
+	// Claims that must be satisfied by the ID Token (or profile URL) claims
+	// of OIDC based providers
+	requiredClaims []requiredClaim
+
+	// The provider verifier of a lazy OIDC discovery, until it succeeds
+	discovery     internaloidc.ProviderVerifier
+	discoveryLock sync.Mutex
+	discovered    bool
+	// afterDiscovery derives the provider specific settings again once a
+	// lazy OIDC discovery was applied
+	afterDiscovery func()
+
 	getAuthorizationHeaderFunc func(string) http.Header
+	issuerURL                  string
+	clientAssertionKey         crypto.Signer
+	clientAssertionMethod      jwt.SigningMethod
 	loginURLParameterDefaults  url.Values
 	loginURLParameterOverrides map[string]*regexp.Regexp
 
@@ -65,6 +92,31 @@ type ProviderData struct {
 // Data returns the ProviderData
 func (p *ProviderData) Data() *ProviderData { return p }
 
+// VerifyConnection returns an error while a lazy OIDC discovery of the
+// provider has not succeeded, to report the provider as not ready.
+func (p *ProviderData) VerifyConnection(ctx context.Context) error {
+	if p.discovery == nil {
+		return nil
+	}
+	return p.discovery.VerifyConnection(ctx)
+}
+
+// setAfterDiscovery sets the function deriving the provider specific
+// settings from the endpoints again, once a lazy OIDC discovery was applied.
+// It runs immediately when the discovery was already applied.
+func (p *ProviderData) setAfterDiscovery(f func()) {
+	if p.discovery == nil {
+		return
+	}
+
+	p.discoveryLock.Lock()
+	defer p.discoveryLock.Unlock()
+	p.afterDiscovery = f
+	if p.discovered {
+		f()
+	}
+}
+
 func (p *ProviderData) GetClientSecret() (clientSecret string, err error) {
 	if p.ClientSecret != "" || p.ClientSecretFile == "" {
 		return p.ClientSecret, nil
@@ -173,6 +225,80 @@ func regexpForRule(rule options.URLParameterRule) string {
 	return "(?:" + *rule.Pattern + ")"
 }
 
+// requiredClaim is the compiled form of an options.RequiredClaim
+type requiredClaim struct {
+	claim   string
+	values  map[string]struct{}
+	pattern *regexp.Regexp
+}
+
+// matches reports whether any of the given claim values is acceptable
+func (r requiredClaim) matches(values []string) bool {
+	if len(r.values) == 0 && r.pattern == nil {
+		return true
+	}
+	for _, value := range values {
+		if _, ok := r.values[value]; ok {
+			return true
+		}
+		if r.pattern != nil && r.pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compile the given set of RequiredClaim options into the internal
+// representation used to check claims at runtime.
+func (p *ProviderData) compileRequiredClaims(claimConfig []options.RequiredClaim) []error {
+	var errs []error
+	p.requiredClaims = make([]requiredClaim, 0, len(claimConfig))
+
+	for idx, rc := range claimConfig {
+		if rc.Claim == "" {
+			errs = append(errs, fmt.Errorf("required claim %d must have a claim name", idx))
+			continue
+		}
+
+		compiled := requiredClaim{
+			claim:  rc.Claim,
+			values: make(map[string]struct{}, len(rc.Values)),
+		}
+		for _, value := range rc.Values {
+			compiled.values[value] = struct{}{}
+		}
+		if rc.Pattern != "" {
+			re, err := regexp.Compile(rc.Pattern)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid pattern for required claim %s: %v", rc.Claim, err))
+				continue
+			}
+			compiled.pattern = re
+		}
+		p.requiredClaims = append(p.requiredClaims, compiled)
+	}
+	return errs
+}
+
+// checkRequiredClaims ensures every required claim is present in the
+// extracted claims with an acceptable value.
+func (p *ProviderData) checkRequiredClaims(extractor util.ClaimExtractor) error {
+	for _, rc := range p.requiredClaims {
+		var values []string
+		exists, err := extractor.GetClaimInto(rc.claim, &values)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: claim %q is missing", ErrRequiredClaimNotSatisfied, rc.claim)
+		}
+		if !rc.matches(values) {
+			return fmt.Errorf("%w: claim %q has value %v", ErrRequiredClaimNotSatisfied, rc.claim, values)
+		}
+	}
+	return nil
+}
+
 // setAllowedGroups organizes a group list into the AllowedGroups map
 // to be consumed by Authorize implementations
 func (p *ProviderData) setAllowedGroups(groups []string) {
@@ -237,6 +363,21 @@ func (p *ProviderData) verifyIDToken(ctx context.Context, token *oauth2.Token) (
 	return p.Verifier.Verify(ctx, rawIDToken)
 }
 
+// recheckRequiredClaims checks the required claims again against the claims
+// of a retained ID Token and of the profile URL, for a refresh that returned
+// no new ID Token.
+func (p *ProviderData) recheckRequiredClaims(rawIDToken, accessToken string) error {
+	if len(p.requiredClaims) == 0 {
+		return nil
+	}
+
+	extractor, err := p.getClaimExtractor(rawIDToken, accessToken)
+	if err != nil {
+		return err
+	}
+	return p.checkRequiredClaims(extractor)
+}
+
 // buildSessionFromClaims uses IDToken claims to populate a fresh SessionState
 // with non-Token related fields.
 func (p *ProviderData) buildSessionFromClaims(rawIDToken, accessToken string) (*sessions.SessionState, error) {
@@ -296,9 +437,45 @@ func (p *ProviderData) buildSessionFromClaims(rawIDToken, accessToken string) (*
 		}
 	}
 
+	if err := p.checkRequiredClaims(extractor); err != nil {
+		return nil, err
+	}
+
+	if err := setAuthenticationClaims(rawIDToken, ss); err != nil {
+		return nil, err
+	}
+
 	return ss, nil
 }
 
+// setAuthenticationClaims copies the claims describing how and when the user
+// authenticated into the session. They are only read from the ID token, as
+// the profile URL cannot attest to the authentication.
+func setAuthenticationClaims(rawIDToken string, ss *sessions.SessionState) error {
+	extractor, err := util.NewClaimExtractor(context.TODO(), rawIDToken, &url.URL{}, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialise claim extractor: %v", err)
+	}
+
+	if _, err := extractor.GetClaimInto("acr", &ss.ACR); err != nil {
+		return err
+	}
+	if _, err := extractor.GetClaimInto("amr", &ss.AMR); err != nil {
+		return err
+	}
+
+	var authTime int64
+	exists, err := extractor.GetClaimInto("auth_time", &authTime)
+	if err != nil {
+		return err
+	}
+	if exists && authTime > 0 {
+		t := time.Unix(authTime, 0)
+		ss.AuthTime = &t
+	}
+	return nil
+}
+
 func (p *ProviderData) getClaimExtractor(rawIDToken, accessToken string) (util.ClaimExtractor, error) {
 	profileURL := p.ProfileURL
 	if p.SkipClaimsFromProfileURL {
@@ -120,6 +120,9 @@ type idTokenClaims struct {
 	Roles    interface{} `json:"roles,omitempty"`
 	Verified *bool       `json:"email_verified,omitempty"`
 	Nonce    string      `json:"nonce,omitempty"`
+	ACR      string      `json:"acr,omitempty"`
+	AMR      []string    `json:"amr,omitempty"`
+	AuthTime int64       `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -226,6 +229,7 @@ func TestProviderData_verifyIDToken(t *testing.T) {
 }
 
 func TestProviderData_buildSessionFromClaims(t *testing.T) {
+	authTime := time.Unix(1700000000, 0)
 	testCases := map[string]struct {
 		IDToken                  idTokenClaims
 		AllowUnverified          bool
@@ -238,6 +242,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 		ExpectedSession          *sessions.SessionState
 		ExpectProfileURLCalled   bool
 		AllowAdditionalClaims    []string
+		RequiredClaims           []options.RequiredClaim
 	}{
 		"Standard": {
 			IDToken:         defaultIDToken,
@@ -252,6 +257,25 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				PreferredUsername: "Jane Dobbs",
 			},
 		},
+		"Authentication Claims": {
+			IDToken: idTokenClaims{
+				Email:            "janed@me.com",
+				ACR:              "urn:example:mfa",
+				AMR:              []string{"pwd", "otp"},
+				AuthTime:         1700000000,
+				RegisteredClaims: defaultIDToken.RegisteredClaims,
+			},
+			AllowUnverified: true,
+			EmailClaim:      "email",
+			UserClaim:       "sub",
+			ExpectedSession: &sessions.SessionState{
+				User:     "123456789",
+				Email:    "janed@me.com",
+				ACR:      "urn:example:mfa",
+				AMR:      []string{"pwd", "otp"},
+				AuthTime: &authTime,
+			},
+		},
 		"Unverified Denied": {
 			IDToken:         unverifiedIDToken,
 			AllowUnverified: false,
@@ -429,6 +453,44 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				},
 			},
 		},
+		"Required claims satisfied": {
+			IDToken:     defaultIDToken,
+			EmailClaim:  "email",
+			GroupsClaim: "groups",
+			UserClaim:   "sub",
+			RequiredClaims: []options.RequiredClaim{
+				{Claim: "email_verified", Values: []string{"true"}},
+				{Claim: "groups", Values: []string{"test:b", "test:x"}},
+				{Claim: "phone_number", Pattern: `^\+47`},
+				{Claim: "picture"},
+			},
+			ExpectedSession: &sessions.SessionState{
+				User:              "123456789",
+				Email:             "janed@me.com",
+				Groups:            []string{"test:a", "test:b"},
+				PreferredUsername: "Jane Dobbs",
+			},
+		},
+		"Required claim with unacceptable value": {
+			IDToken:     defaultIDToken,
+			EmailClaim:  "email",
+			GroupsClaim: "groups",
+			UserClaim:   "sub",
+			RequiredClaims: []options.RequiredClaim{
+				{Claim: "roles", Values: []string{"test:a"}, Pattern: "^admin"},
+			},
+			ExpectedError: fmt.Errorf("%w: claim %q has value %v", ErrRequiredClaimNotSatisfied, "roles", []string{"test:c", "test:d"}),
+		},
+		"Required claim missing": {
+			IDToken:     minimalIDToken,
+			EmailClaim:  "email",
+			GroupsClaim: "groups",
+			UserClaim:   "sub",
+			RequiredClaims: []options.RequiredClaim{
+				{Claim: "acr", Values: []string{"mfa"}},
+			},
+			ExpectedError: fmt.Errorf("%w: claim %q is missing", ErrRequiredClaimNotSatisfied, "acr"),
+		},
 	}
 	for testName, tc := range testCases {
 		t.Run(testName, func(t *testing.T) {
@@ -466,6 +528,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			provider.GroupsClaim = tc.GroupsClaim
 			provider.SkipClaimsFromProfileURL = tc.SkipClaimsFromProfileURL
 			provider.AllowAdditionalClaims = tc.AllowAdditionalClaims
+			g.Expect(provider.compileRequiredClaims(tc.RequiredClaims)).To(BeEmpty())
 
 			rawIDToken, err := newSignedTestIDToken(tc.IDToken)
 			g.Expect(err).ToNot(HaveOccurred())
@@ -473,6 +536,8 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			ss, err := provider.buildSessionFromClaims(rawIDToken, "testtoken")
 			if err != nil {
 				g.Expect(err).To(Equal(tc.ExpectedError))
+			} else {
+				g.Expect(tc.ExpectedError).To(BeNil())
 			}
 			if ss != nil {
 				g.Expect(ss).To(Equal(tc.ExpectedSession))
@@ -651,3 +716,41 @@ func TestProviderData_loginURLParameters(t *testing.T) {
 		})
 	}
 }
+
+func TestProviderData_compileRequiredClaims(t *testing.T) {
+	testCases := map[string]struct {
+		RequiredClaims []options.RequiredClaim
+		ExpectedErrors []error
+	}{
+		"Valid required claims": {
+			RequiredClaims: []options.RequiredClaim{
+				{Claim: "email_verified", Values: []string{"true"}},
+				{Claim: "acr", Pattern: "^mfa$"},
+			},
+		},
+		"Missing claim name": {
+			RequiredClaims: []options.RequiredClaim{
+				{Values: []string{"true"}},
+			},
+			ExpectedErrors: []error{errors.New("required claim 0 must have a claim name")},
+		},
+		"Invalid pattern": {
+			RequiredClaims: []options.RequiredClaim{
+				{Claim: "acr", Pattern: "(mfa"},
+			},
+			ExpectedErrors: []error{errors.New("invalid pattern for required claim acr: error parsing regexp: missing closing ): `(mfa`")},
+		},
+	}
+	for testName, tc := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			g := NewWithT(t)
+
+			p := &ProviderData{}
+			errs := p.compileRequiredClaims(tc.RequiredClaims)
+			g.Expect(errs).To(HaveLen(len(tc.ExpectedErrors)))
+			for i, err := range errs {
+				g.Expect(err).To(MatchError(tc.ExpectedErrors[i].Error()))
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package providers
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -9,7 +8,6 @@ import (
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
-	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 )
 
 var (
@@ -29,6 +27,15 @@ var (
 	// but an attempt to call `Verifier.Verify` was about to be made.
 	ErrMissingOIDCVerifier = errors.New("oidc verifier is not configured")
 
+	// ErrNotAuthorized is returned when the provider does not, or no longer,
+	// authorize a user, e.g. after the user was removed from an allowed
+	// organization. A session that fails to refresh with it is rejected.
+	ErrNotAuthorized = errors.New("user is not authorized")
+
+	// ErrRequiredClaimNotSatisfied is returned when the claims of a user do
+	// not satisfy one of the configured required claims.
+	ErrRequiredClaimNotSatisfied = fmt.Errorf("%w: required claim not satisfied", ErrNotAuthorized)
+
 	_ Provider = (*ProviderData)(nil)
 )
 
@@ -46,15 +53,9 @@ func (p *ProviderData) Redeem(ctx context.Context, redirectURL, code, codeVerifi
 	if code == "" {
 		return nil, ErrMissingCode
 	}
-	clientSecret, err := p.GetClientSecret()
-	if err != nil {
-		return nil, err
-	}
 
 	params := url.Values{}
 	params.Add("redirect_uri", redirectURL)
-	params.Add("client_id", p.ClientID)
-	params.Add("client_secret", clientSecret)
 	params.Add("code", code)
 	params.Add("grant_type", "authorization_code")
 	if codeVerifier != "" {
@@ -64,12 +65,11 @@ func (p *ProviderData) Redeem(ctx context.Context, redirectURL, code, codeVerifi
 		params.Add("resource", p.ProtectedResource.String())
 	}
 
-	result := requests.New(p.RedeemURL.String()).
-		WithContext(ctx).
-		WithMethod("POST").
-		WithBody(bytes.NewBufferString(params.Encode())).
-		SetHeader("Content-Type", "application/x-www-form-urlencoded").
-		Do()
+	req, err := p.newTokenRequest(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	result := req.Do()
 	if result.Error() != nil {
 		return nil, result.Error()
 	}
@@ -42,11 +42,15 @@ func NewProvider(providerConfig options.Provider) (Provider, error) {
 	case options.AzureProvider:
 		return NewAzureProvider(providerData, providerConfig.AzureConfig), nil
 	case options.BitbucketProvider:
-		return NewBitbucketProvider(providerData, providerConfig.BitbucketConfig), nil
+		return NewBitbucketProvider(providerData, providerConfig.BitbucketConfig)
 	case options.DigitalOceanProvider:
-		return NewDigitalOceanProvider(providerData), nil
+		return NewDigitalOceanProvider(providerData, providerConfig.DigitalOceanConfig), nil
 	case options.FacebookProvider:
 		return NewFacebookProvider(providerData), nil
+	case options.GenericOAuth2Provider:
+		return NewGenericOAuth2Provider(providerData, providerConfig.GenericOAuth2Config)
+	case options.GiteaProvider:
+		return NewGiteaProvider(providerData, providerConfig.GiteaConfig)
 	case options.GitHubProvider:
 		return NewGitHubProvider(providerData, providerConfig.GitHubConfig), nil
 	case options.GitLabProvider:
@@ -58,11 +62,14 @@ func NewProvider(providerConfig options.Provider) (Provider, error) {
 	case options.KeycloakOIDCProvider:
 		return NewKeycloakOIDCProvider(providerData, providerConfig), nil
 	case options.LinkedInProvider:
-		return NewLinkedInProvider(providerData), nil
+		if providerConfig.LinkedInConfig.Legacy {
+			return NewLinkedInLegacyProvider(providerData), nil
+		}
+		return NewLinkedInProvider(providerData, providerConfig.OIDCConfig), nil
 	case options.LoginGovProvider:
 		return NewLoginGovProvider(providerData, providerConfig.LoginGovConfig)
 	case options.NextCloudProvider:
-		return NewNextcloudProvider(providerData), nil
+		return NewNextcloudProvider(providerData, providerConfig.NextcloudConfig), nil
 	case options.OIDCProvider:
 		return NewOIDCProvider(providerData, providerConfig.OIDCConfig), nil
 	default:
@@ -80,27 +87,44 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 		AllowAdditionalClaims: providerConfig.AllowAdditionalClaims,
 	}
 
-	needsVerifier, err := providerRequiresOIDCProviderVerifier(providerConfig.Type)
+	needsVerifier, err := providerRequiresOIDCProviderVerifier(providerConfig)
 	if err != nil {
 		return nil, err
 	}
 
+	// Whether the discovered provider requires pushed authorization requests
+	var requiresPAR bool
+
+	// LinkedIn's issuer is fixed, so it doesn't need to be configured
+	if providerConfig.Type == options.LinkedInProvider && providerConfig.OIDCConfig.IssuerURL == "" {
+		providerConfig.OIDCConfig.IssuerURL = linkedinIssuerURL
+	}
+
 	if needsVerifier {
+		lazyConfig := providerConfig
 		pv, err := internaloidc.NewProviderVerifier(context.TODO(), internaloidc.ProviderVerifierOptions{
-			AudienceClaims:         providerConfig.OIDCConfig.AudienceClaims,
-			ClientID:               providerConfig.ClientID,
-			ExtraAudiences:         providerConfig.OIDCConfig.ExtraAudiences,
-			IssuerURL:              providerConfig.OIDCConfig.IssuerURL,
-			JWKsURL:                providerConfig.OIDCConfig.JwksURL,
-			SkipDiscovery:          providerConfig.OIDCConfig.SkipDiscovery,
-			SkipIssuerVerification: providerConfig.OIDCConfig.InsecureSkipIssuerVerification,
+			AudienceClaims:           providerConfig.OIDCConfig.AudienceClaims,
+			ClientID:                 providerConfig.ClientID,
+			ExtraAudiences:           providerConfig.OIDCConfig.ExtraAudiences,
+			IssuerURL:                providerConfig.OIDCConfig.IssuerURL,
+			JWKsURL:                  providerConfig.OIDCConfig.JwksURL,
+			SkipDiscovery:            providerConfig.OIDCConfig.SkipDiscovery,
+			SkipIssuerVerification:   providerConfig.OIDCConfig.InsecureSkipIssuerVerification,
+			LazyDiscovery:            providerConfig.OIDCConfig.LazyDiscovery,
+			DiscoveryRefreshInterval: providerConfig.OIDCConfig.DiscoveryRefreshInterval.Duration(),
+			OnDiscovery: func(provider internaloidc.DiscoveryProvider) error {
+				return p.applyDiscovery(provider, lazyConfig)
+			},
 		})
 		if err != nil {
 			return nil, fmt.Errorf("error building OIDC ProviderVerifier: %v", err)
 		}
 
 		p.Verifier = pv.Verifier()
-		if pv.DiscoveryEnabled() {
+		if err := pv.VerifyConnection(context.TODO()); err != nil {
+			// The discovered values are applied once the lazy discovery succeeds
+			p.discovery = pv
+		} else if pv.DiscoveryEnabled() {
 			// Use the discovered values rather than any specified values
 			endpoints := pv.Provider().Endpoints()
 			pkce := pv.Provider().PKCE()
@@ -109,6 +133,12 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 			providerConfig.ProfileURL = endpoints.UserInfoURL
 			providerConfig.OIDCConfig.JwksURL = endpoints.JWKsURL
 			p.SupportedCodeChallengeMethods = pkce.CodeChallengeAlgs
+
+			par := pv.Provider().PAR()
+			if par.RequestURL != "" {
+				providerConfig.PushedAuthorizationRequestURL = par.RequestURL
+			}
+			requiresPAR = par.Required
 		}
 	}
 
@@ -117,11 +147,12 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 		dst **url.URL
 		raw string
 	}{
-		"login":    {dst: &p.LoginURL, raw: providerConfig.LoginURL},
-		"redeem":   {dst: &p.RedeemURL, raw: providerConfig.RedeemURL},
-		"profile":  {dst: &p.ProfileURL, raw: providerConfig.ProfileURL},
-		"validate": {dst: &p.ValidateURL, raw: providerConfig.ValidateURL},
-		"resource": {dst: &p.ProtectedResource, raw: providerConfig.ProtectedResource},
+		"login":                        {dst: &p.LoginURL, raw: providerConfig.LoginURL},
+		"redeem":                       {dst: &p.RedeemURL, raw: providerConfig.RedeemURL},
+		"pushed authorization request": {dst: &p.PushedAuthorizationRequestURL, raw: providerConfig.PushedAuthorizationRequestURL},
+		"profile":                      {dst: &p.ProfileURL, raw: providerConfig.ProfileURL},
+		"validate":                     {dst: &p.ValidateURL, raw: providerConfig.ValidateURL},
+		"resource":                     {dst: &p.ProtectedResource, raw: providerConfig.ProtectedResource},
 	} {
 		var err error
 		*u.dst, err = url.Parse(u.raw)
@@ -131,6 +162,18 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 	}
 	// handle LoginURLParameters
 	errs = append(errs, p.compileLoginParams(providerConfig.LoginURLParameters)...)
+	// handle RequiredClaims
+	errs = append(errs, p.compileRequiredClaims(providerConfig.RequiredClaims)...)
+	// handle TokenEndpointAuthMethod
+	if err := p.setClientAuthentication(providerConfig); err != nil {
+		errs = append(errs, err)
+	}
+	// handle PushedAuthorizationRequests, unless they depend on a pending discovery
+	if p.discovery == nil {
+		if err := p.setPushedAuthorizationRequests(providerConfig.PushedAuthorizationRequests, requiresPAR); err != nil {
+			errs = append(errs, err)
+		}
+	}
 
 	if len(errs) > 0 {
 		return nil, k8serrors.NewAggregate(errs)
@@ -166,6 +209,56 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 	return p, nil
 }
 
+// applyDiscovery uses the endpoints, PKCE methods and pushed authorization
+// request endpoint of a lazily discovered provider, once the provider data
+// was created. When they can't be used, the provider stays not ready.
+// The provider specific settings are then derived again from the endpoints.
+func (p *ProviderData) applyDiscovery(provider internaloidc.DiscoveryProvider, providerConfig options.Provider) error {
+	p.discoveryLock.Lock()
+	defer p.discoveryLock.Unlock()
+
+	endpoints := provider.Endpoints()
+	par := provider.PAR()
+	if par.RequestURL != "" {
+		providerConfig.PushedAuthorizationRequestURL = par.RequestURL
+	}
+
+	errs := []error{}
+	for name, u := range map[string]struct {
+		dst **url.URL
+		raw string
+	}{
+		"login":                        {dst: &p.LoginURL, raw: endpoints.AuthURL},
+		"redeem":                       {dst: &p.RedeemURL, raw: endpoints.TokenURL},
+		"pushed authorization request": {dst: &p.PushedAuthorizationRequestURL, raw: providerConfig.PushedAuthorizationRequestURL},
+		"profile":                      {dst: &p.ProfileURL, raw: endpoints.UserInfoURL},
+	} {
+		var err error
+		*u.dst, err = url.Parse(u.raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not parse %s URL: %v", name, err))
+		}
+	}
+
+	p.SupportedCodeChallengeMethods = provider.PKCE().CodeChallengeAlgs
+	if len(p.SupportedCodeChallengeMethods) != 0 && p.CodeChallengeMethod == "" {
+		logger.Printf("Warning: Your provider supports PKCE methods %+q, but you have not enabled one with --code-challenge-method", p.SupportedCodeChallengeMethods)
+	}
+
+	if err := p.setPushedAuthorizationRequests(providerConfig.PushedAuthorizationRequests, par.Required); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return k8serrors.NewAggregate(errs)
+	}
+
+	p.discovered = true
+	if p.afterDiscovery != nil {
+		p.afterDiscovery()
+	}
+	return nil
+}
+
 // Pick the most appropriate code challenge method for PKCE
 // At this time we do not consider what the server supports to be safe and
 // only enable PKCE if the user opts-in
@@ -178,11 +271,14 @@ func parseCodeChallengeMethod(providerConfig options.Provider) string {
 	}
 }
 
-func providerRequiresOIDCProviderVerifier(providerType options.ProviderType) (bool, error) {
-	switch providerType {
-	case options.BitbucketProvider, options.DigitalOceanProvider, options.FacebookProvider, options.GitHubProvider,
-		options.GoogleProvider, options.KeycloakProvider, options.LinkedInProvider, options.LoginGovProvider, options.NextCloudProvider:
+func providerRequiresOIDCProviderVerifier(providerConfig options.Provider) (bool, error) {
+	switch providerType := providerConfig.Type; providerType {
+	case options.BitbucketProvider, options.DigitalOceanProvider, options.FacebookProvider, options.GenericOAuth2Provider,
+		options.GiteaProvider, options.GitHubProvider, options.GoogleProvider, options.KeycloakProvider,
+		options.LoginGovProvider, options.NextCloudProvider:
 		return false, nil
+	case options.LinkedInProvider:
+		return !providerConfig.LinkedInConfig.Legacy, nil
 	case options.ADFSProvider, options.AzureProvider, options.GitLabProvider, options.KeycloakOIDCProvider, options.OIDCProvider:
 		return true, nil
 	default:
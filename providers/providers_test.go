@@ -1,8 +1,15 @@
 package providers
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	. "github.com/onsi/gomega"
@@ -96,6 +103,139 @@ func TestSkipOIDCDiscovery(t *testing.T) {
 	g.Expect(err).ToNot(HaveOccurred())
 }
 
+func TestLazyOIDCDiscovery(t *testing.T) {
+	g := NewWithT(t)
+
+	var available atomic.Bool
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                server.URL,
+			"authorization_endpoint":                server.URL + "/authorize",
+			"token_endpoint":                        server.URL + "/token",
+			"userinfo_endpoint":                     server.URL + "/userinfo",
+			"jwks_uri":                              server.URL + "/keys",
+			"pushed_authorization_request_endpoint": server.URL + "/par",
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	}))
+	defer server.Close()
+
+	p, err := newProviderDataFromConfig(options.Provider{
+		ID:                          providerID,
+		Type:                        options.OIDCProvider,
+		ClientID:                    clientID,
+		ClientSecret:                clientSecret,
+		PushedAuthorizationRequests: options.PushedAuthorizationRequestsRequired,
+		OIDCConfig: options.OIDCOptions{
+			IssuerURL:     server.URL,
+			LazyDiscovery: true,
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.VerifyConnection(context.Background())).To(MatchError(HavePrefix("OIDC discovery has not completed")))
+
+	available.Store(true)
+	g.Eventually(func() error { return p.VerifyConnection(context.Background()) }, 5*time.Second, 100*time.Millisecond).Should(Succeed())
+
+	g.Expect(p.LoginURL.String()).To(Equal(server.URL + "/authorize"))
+	g.Expect(p.RedeemURL.String()).To(Equal(server.URL + "/token"))
+	g.Expect(p.ProfileURL.String()).To(Equal(server.URL + "/userinfo"))
+	g.Expect(p.PushedAuthorizationRequestURL.String()).To(Equal(server.URL + "/par"))
+	g.Expect(p.PushedAuthorizationRequests).To(Equal(options.PushedAuthorizationRequestsRequired))
+	g.Expect(p.SupportedCodeChallengeMethods).To(Equal([]string{"S256"}))
+}
+
+func TestLazyOIDCDiscoveryWithoutPushedAuthorizationRequestEndpoint(t *testing.T) {
+	g := NewWithT(t)
+
+	var available atomic.Bool
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/keys",
+		})
+	}))
+	defer server.Close()
+
+	p, err := newProviderDataFromConfig(options.Provider{
+		ID:                          providerID,
+		Type:                        options.OIDCProvider,
+		ClientID:                    clientID,
+		ClientSecret:                clientSecret,
+		PushedAuthorizationRequests: options.PushedAuthorizationRequestsRequired,
+		OIDCConfig: options.OIDCOptions{
+			IssuerURL:     server.URL,
+			LazyDiscovery: true,
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	available.Store(true)
+	g.Eventually(func() error { return p.VerifyConnection(context.Background()) }, 5*time.Second, 100*time.Millisecond).Should(
+		MatchError(ContainSubstring("unable to use the discovered provider: pushed authorization requests are required")))
+	g.Consistently(func() error { return p.VerifyConnection(context.Background()) }, 500*time.Millisecond, 100*time.Millisecond).ShouldNot(Succeed())
+}
+
+func TestLazyOIDCDiscoveryWithAzure(t *testing.T) {
+	g := NewWithT(t)
+
+	var available atomic.Bool
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/tenant/oauth2/v2.0/authorize",
+			"token_endpoint":         server.URL + "/tenant/oauth2/v2.0/token",
+			"userinfo_endpoint":      server.URL + "/oidc/userinfo",
+			"jwks_uri":               server.URL + "/keys",
+		})
+	}))
+	defer server.Close()
+
+	providerConfig := options.Provider{
+		ID:           providerID,
+		Type:         options.AzureProvider,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		OIDCConfig: options.OIDCOptions{
+			IssuerURL:     server.URL,
+			LazyDiscovery: true,
+		},
+	}
+	p, err := NewProvider(providerConfig)
+	g.Expect(err).ToNot(HaveOccurred())
+	azure := p.(*AzureProvider)
+	g.Expect(azure.isV2Endpoint).To(BeFalse())
+
+	available.Store(true)
+	g.Eventually(func() error { return p.Data().VerifyConnection(context.Background()) }, 5*time.Second, 100*time.Millisecond).Should(Succeed())
+
+	serverURL, err := url.Parse(server.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(azure.isV2Endpoint).To(BeTrue())
+	g.Expect(azure.Scope).To(HaveSuffix(" https://" + serverURL.Host + "/.default"))
+	g.Expect(azure.ValidateURL.String()).To(Equal(server.URL + "/oidc/userinfo"))
+}
+
 func TestURLsCorrectlyParsed(t *testing.T) {
 	g := NewWithT(t)
 
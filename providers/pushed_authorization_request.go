@@ -0,0 +1,101 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// setPushedAuthorizationRequests configures the pushed authorization requests
+// of the provider, which must have a pushed authorization request endpoint
+// when they are required.
+func (p *ProviderData) setPushedAuthorizationRequests(mode options.PushedAuthorizationRequestsMode, requiredByProvider bool) error {
+	hasEndpoint := p.PushedAuthorizationRequestURL != nil && p.PushedAuthorizationRequestURL.String() != ""
+
+	switch mode {
+	case "":
+		mode = options.PushedAuthorizationRequestsOff
+		if requiredByProvider {
+			logger.Printf("The provider requires pushed authorization requests, enabling them")
+			mode = options.PushedAuthorizationRequestsRequired
+		}
+	case options.PushedAuthorizationRequestsOff:
+		if requiredByProvider {
+			logger.Printf("Warning: The provider requires pushed authorization requests, but they are turned off")
+		}
+	}
+
+	if mode == options.PushedAuthorizationRequestsRequired && !hasEndpoint {
+		return errors.New("pushed authorization requests are required but the provider has no pushed authorization request endpoint")
+	}
+
+	p.PushedAuthorizationRequests = mode
+	return nil
+}
+
+// PushAuthorizationRequest pushes the parameters of the login URL to the
+// pushed authorization request endpoint (RFC 9126), and returns the login URL
+// that references them with only the client_id and request_uri parameters.
+// The login URL is returned unchanged when pushed authorization requests are
+// off, or when they are preferred but the request cannot be pushed.
+func (p *ProviderData) PushAuthorizationRequest(ctx context.Context, loginURL string) (string, error) {
+	switch p.PushedAuthorizationRequests {
+	case options.PushedAuthorizationRequestsRequired, options.PushedAuthorizationRequestsPreferred:
+	default:
+		return loginURL, nil
+	}
+	if p.PushedAuthorizationRequestURL == nil || p.PushedAuthorizationRequestURL.String() == "" {
+		return loginURL, nil
+	}
+
+	pushedURL, err := p.pushAuthorizationRequest(ctx, loginURL)
+	if err != nil {
+		if p.PushedAuthorizationRequests == options.PushedAuthorizationRequestsRequired {
+			return "", fmt.Errorf("could not push authorization request: %v", err)
+		}
+		logger.Errorf("Unable to push authorization request, sending it through the browser: %v", err)
+		return loginURL, nil
+	}
+	return pushedURL, nil
+}
+
+func (p *ProviderData) pushAuthorizationRequest(ctx context.Context, loginURL string) (string, error) {
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		return "", fmt.Errorf("could not parse login URL: %v", err)
+	}
+
+	req, err := p.newClientAuthenticatedRequest(ctx, p.PushedAuthorizationRequestURL.String(), u.Query())
+	if err != nil {
+		return "", err
+	}
+	result := req.SetHeader("Accept", "application/json").Do()
+	if result.Error() != nil {
+		return "", result.Error()
+	}
+	if result.StatusCode() != http.StatusCreated && result.StatusCode() != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d: %s", result.StatusCode(), result.Body())
+	}
+
+	var response struct {
+		RequestURI string `json:"request_uri"`
+	}
+	if err := json.Unmarshal(result.Body(), &response); err != nil {
+		return "", fmt.Errorf("error unmarshalling body: %v", err)
+	}
+	if response.RequestURI == "" {
+		return "", errors.New("no request_uri in the response")
+	}
+
+	params := url.Values{}
+	params.Set("client_id", p.ClientID)
+	params.Set("request_uri", response.RequestURI)
+	u.RawQuery = params.Encode()
+	return u.String(), nil
+}
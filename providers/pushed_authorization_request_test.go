@@ -0,0 +1,194 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/gomega"
+)
+
+func TestProviderDataPushAuthorizationRequest(t *testing.T) {
+	testCases := []struct {
+		name          string
+		mode          options.PushedAuthorizationRequestsMode
+		status        int
+		response      string
+		expectPushed  bool
+		expectedError string
+	}{
+		{
+			name:         "Required",
+			mode:         options.PushedAuthorizationRequestsRequired,
+			status:       http.StatusCreated,
+			response:     `{"request_uri":"urn:ietf:params:oauth:request_uri:6esc_11ACC5bwc014ltc14eY22c","expires_in":60}`,
+			expectPushed: true,
+		},
+		{
+			name:         "Preferred",
+			mode:         options.PushedAuthorizationRequestsPreferred,
+			status:       http.StatusCreated,
+			response:     `{"request_uri":"urn:ietf:params:oauth:request_uri:6esc_11ACC5bwc014ltc14eY22c","expires_in":60}`,
+			expectPushed: true,
+		},
+		{
+			name:     "Off",
+			mode:     options.PushedAuthorizationRequestsOff,
+			status:   http.StatusCreated,
+			response: `{"request_uri":"urn:ietf:params:oauth:request_uri:6esc_11ACC5bwc014ltc14eY22c","expires_in":60}`,
+		},
+		{
+			name:     "PreferredWithAnError",
+			mode:     options.PushedAuthorizationRequestsPreferred,
+			status:   http.StatusBadRequest,
+			response: `{"error":"invalid_request"}`,
+		},
+		{
+			name:          "RequiredWithAnError",
+			mode:          options.PushedAuthorizationRequestsRequired,
+			status:        http.StatusBadRequest,
+			response:      `{"error":"invalid_request"}`,
+			expectedError: "could not push authorization request: unexpected status 400",
+		},
+		{
+			name:          "RequiredWithoutRequestURI",
+			mode:          options.PushedAuthorizationRequestsRequired,
+			status:        http.StatusCreated,
+			response:      `{"expires_in":60}`,
+			expectedError: "could not push authorization request: no request_uri in the response",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var pushed url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				g.Expect(r.Method).To(Equal(http.MethodPost))
+				g.Expect(r.URL.Path).To(Equal("/par"))
+				g.Expect(r.ParseForm()).To(Succeed())
+				pushed = r.PostForm
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.response))
+			}))
+			defer server.Close()
+
+			p, err := newProviderDataFromConfig(options.Provider{
+				Type:                          options.KeycloakProvider,
+				ClientID:                      "client",
+				ClientSecret:                  "secret",
+				LoginURL:                      "https://idp.example.com/authorize",
+				PushedAuthorizationRequestURL: server.URL + "/par",
+				PushedAuthorizationRequests:   tc.mode,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+
+			loginURL := p.GetLoginURL("https://proxy.example.com/oauth2/callback", "state", "", url.Values{
+				"code_challenge":        {"challenge"},
+				"code_challenge_method": {"S256"},
+			})
+
+			result, err := p.PushAuthorizationRequest(context.Background(), loginURL)
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.expectedError)))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+
+			if !tc.expectPushed {
+				g.Expect(result).To(Equal(loginURL))
+				return
+			}
+
+			g.Expect(pushed.Get("client_id")).To(Equal("client"))
+			g.Expect(pushed.Get("client_secret")).To(Equal("secret"))
+			g.Expect(pushed.Get("redirect_uri")).To(Equal("https://proxy.example.com/oauth2/callback"))
+			g.Expect(pushed.Get("response_type")).To(Equal("code"))
+			g.Expect(pushed.Get("state")).To(Equal("state"))
+			g.Expect(pushed.Get("code_challenge")).To(Equal("challenge"))
+
+			u, err := url.Parse(result)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(u.Host).To(Equal("idp.example.com"))
+			g.Expect(u.Path).To(Equal("/authorize"))
+			g.Expect(u.Query()).To(Equal(url.Values{
+				"client_id":   {"client"},
+				"request_uri": {"urn:ietf:params:oauth:request_uri:6esc_11ACC5bwc014ltc14eY22c"},
+			}))
+		})
+	}
+}
+
+func TestProviderDataPushAuthorizationRequestClientAssertion(t *testing.T) {
+	testCases := []struct {
+		name             string
+		issuerURL        string
+		expectedAudience string
+	}{
+		{
+			name:             "WithAnIssuer",
+			issuerURL:        "https://idp.example.com/realms/test",
+			expectedAudience: "https://idp.example.com/realms/test",
+		},
+		{
+			name:             "WithoutAnIssuer",
+			expectedAudience: "https://idp.example.com/token",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var clientAssertion string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				g.Expect(r.ParseForm()).To(Succeed())
+				clientAssertion = r.PostForm.Get("client_assertion")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"request_uri":"urn:ietf:params:oauth:request_uri:6esc_11ACC5bwc014ltc14eY22c","expires_in":60}`))
+			}))
+			defer server.Close()
+
+			p, err := newProviderDataFromConfig(options.Provider{
+				Type:                          options.KeycloakProvider,
+				ClientID:                      "client",
+				ClientSecret:                  "secret",
+				LoginURL:                      "https://idp.example.com/authorize",
+				RedeemURL:                     "https://idp.example.com/token",
+				PushedAuthorizationRequestURL: server.URL + "/par",
+				PushedAuthorizationRequests:   options.PushedAuthorizationRequestsRequired,
+				TokenEndpointAuthMethod:       options.ClientSecretJWT,
+				OIDCConfig:                    options.OIDCOptions{IssuerURL: tc.issuerURL},
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+
+			loginURL := p.GetLoginURL("https://proxy.example.com/oauth2/callback", "state", "", url.Values{})
+			_, err = p.PushAuthorizationRequest(context.Background(), loginURL)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			_, err = jwt.Parse(clientAssertion, func(token *jwt.Token) (interface{}, error) {
+				return []byte("secret"), nil
+			}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithAudience(tc.expectedAudience))
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+}
+
+func TestProviderDataPushedAuthorizationRequestsRequiredWithoutEndpoint(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := newProviderDataFromConfig(options.Provider{
+		Type:                        options.KeycloakProvider,
+		ClientID:                    "client",
+		ClientSecret:                "secret",
+		PushedAuthorizationRequests: options.PushedAuthorizationRequestsRequired,
+	})
+	g.Expect(err).To(MatchError(ContainSubstring("pushed authorization requests are required but the provider has no pushed authorization request endpoint")))
+}
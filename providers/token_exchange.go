@@ -0,0 +1,79 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+const (
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	accessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// ExchangedToken is an access token the provider issued in exchange for the
+// access token of a session
+type ExchangedToken struct {
+	AccessToken string
+	// ExpiresOn is nil when the provider did not return the lifetime
+	ExpiresOn *time.Time
+}
+
+// ExchangeToken exchanges the subject access token for an access token for
+// the target audience, scopes and resource using OAuth 2.0 Token Exchange
+// (RFC 8693)
+func (p *ProviderData) ExchangeToken(ctx context.Context, subjectToken string, target options.TokenExchange) (*ExchangedToken, error) {
+	params := url.Values{}
+	params.Add("grant_type", tokenExchangeGrantType)
+	params.Add("subject_token", subjectToken)
+	params.Add("subject_token_type", accessTokenType)
+	params.Add("requested_token_type", accessTokenType)
+	if target.Audience != "" {
+		params.Add("audience", target.Audience)
+	}
+	if len(target.Scopes) > 0 {
+		params.Add("scope", strings.Join(target.Scopes, " "))
+	}
+	if target.Resource != "" {
+		params.Add("resource", target.Resource)
+	}
+
+	var response struct {
+		AccessToken     string `json:"access_token"`
+		IssuedTokenType string `json:"issued_token_type"`
+		TokenType       string `json:"token_type"`
+		ExpiresIn       int64  `json:"expires_in"`
+	}
+	req, err := p.newTokenRequest(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	err = req.
+		SetHeader("Accept", "application/json").
+		Do().
+		UnmarshalInto(&response)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange request failed: %v", err)
+	}
+
+	if response.AccessToken == "" {
+		return nil, fmt.Errorf("token exchange response did not contain an access token")
+	}
+	if response.IssuedTokenType != "" && response.IssuedTokenType != accessTokenType {
+		return nil, fmt.Errorf("token exchange issued an unexpected token type %q", response.IssuedTokenType)
+	}
+	if !strings.EqualFold(response.TokenType, "bearer") {
+		return nil, fmt.Errorf("token exchange issued a token of type %q, only bearer tokens are supported", response.TokenType)
+	}
+
+	token := &ExchangedToken{AccessToken: response.AccessToken}
+	if response.ExpiresIn > 0 {
+		expiresOn := time.Now().Add(time.Duration(response.ExpiresIn) * time.Second).Truncate(time.Second)
+		token.ExpiresOn = &expiresOn
+	}
+	return token, nil
+}
@@ -0,0 +1,121 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/gomega"
+)
+
+func TestProviderDataExchangeToken(t *testing.T) {
+	testCases := []struct {
+		name          string
+		target        options.TokenExchange
+		status        int
+		response      map[string]interface{}
+		expectedForm  url.Values
+		expectedToken string
+		expectExpiry  bool
+		expectedError string
+	}{
+		{
+			name:   "WithAudienceScopesAndResource",
+			target: options.TokenExchange{Audience: "orders-api", Scopes: []string{"orders:read", "orders:write"}, Resource: "https://orders.example.com"},
+			status: http.StatusOK,
+			response: map[string]interface{}{
+				"access_token":      "exchanged",
+				"issued_token_type": accessTokenType,
+				"token_type":        "Bearer",
+				"expires_in":        300,
+			},
+			expectedForm: url.Values{
+				"grant_type":           {tokenExchangeGrantType},
+				"client_id":            {"client"},
+				"client_secret":        {"secret"},
+				"subject_token":        {"subject"},
+				"subject_token_type":   {accessTokenType},
+				"requested_token_type": {accessTokenType},
+				"audience":             {"orders-api"},
+				"scope":                {"orders:read orders:write"},
+				"resource":             {"https://orders.example.com"},
+			},
+			expectedToken: "exchanged",
+			expectExpiry:  true,
+		},
+		{
+			name:   "WithoutExpiry",
+			target: options.TokenExchange{Audience: "orders-api"},
+			status: http.StatusOK,
+			response: map[string]interface{}{
+				"access_token": "exchanged",
+				"token_type":   "bearer",
+			},
+			expectedToken: "exchanged",
+		},
+		{
+			name:   "WithAnError",
+			target: options.TokenExchange{Audience: "unknown-api"},
+			status: http.StatusBadRequest,
+			response: map[string]interface{}{
+				"error": "invalid_target",
+			},
+			expectedError: "token exchange request failed",
+		},
+		{
+			name:   "WithANonBearerToken",
+			target: options.TokenExchange{Audience: "orders-api"},
+			status: http.StatusOK,
+			response: map[string]interface{}{
+				"access_token": "exchanged",
+				"token_type":   "N_A",
+			},
+			expectedError: "only bearer tokens are supported",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var form url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				g.Expect(req.ParseForm()).To(Succeed())
+				form = req.PostForm
+				rw.Header().Set("Content-Type", "application/json")
+				rw.WriteHeader(tc.status)
+				g.Expect(json.NewEncoder(rw).Encode(tc.response)).To(Succeed())
+			}))
+			defer server.Close()
+
+			redeemURL, err := url.Parse(server.URL + "/token")
+			g.Expect(err).ToNot(HaveOccurred())
+			p := &ProviderData{
+				ClientID:     "client",
+				ClientSecret: "secret",
+				RedeemURL:    redeemURL,
+			}
+
+			token, err := p.ExchangeToken(context.Background(), "subject", tc.target)
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.expectedError)))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			if tc.expectedForm != nil {
+				g.Expect(form).To(Equal(tc.expectedForm))
+			}
+			g.Expect(token.AccessToken).To(Equal(tc.expectedToken))
+			if tc.expectExpiry {
+				g.Expect(*token.ExpiresOn).To(BeTemporally("~", time.Now().Add(5*time.Minute), 5*time.Second))
+			} else {
+				g.Expect(token.ExpiresOn).To(BeNil())
+			}
+		})
+	}
+}